@@ -23,6 +23,14 @@ const (
 	commandResetNetwork
 	commandClearLog
 	commandSubscribeLogs
+	commandQueryDNSTransportMetrics
+	commandQueryDNSUpstreamStats
+	commandClearDNSCache
+	commandQueryDNSFilterHits
+	commandDialOutbound
+	commandSetMeteredPolicy
+	commandQueryMeteredStatus
+	commandNewTemporaryInstance
 )
 
 const (
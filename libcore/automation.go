@@ -0,0 +1,120 @@
+package libcore
+
+import (
+	"time"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/protocol/group"
+
+	"libcore/automation"
+)
+
+// SetAutomationRules sets JSON list of automation rules, see automation.Rule.
+// Empty content disables automation.
+func (s *Service) SetAutomationRules(content string) error {
+	var engine *automation.Engine
+	if content != "" {
+		var err error
+		engine, err = automation.Parse([]byte(content))
+		if err != nil {
+			return err
+		}
+	}
+	s.access.Lock()
+	s.automation = engine
+	instance := s.instance
+	s.access.Unlock()
+	if instance != nil {
+		instance.automation.Store(engine)
+		instance.runAutomation()
+	}
+	return nil
+}
+
+// runAutomation evaluates automation rules, which is called on every default interface update.
+func (b *boxInstance) runAutomation() {
+	engine := b.automation.Load()
+	if engine == nil {
+		return
+	}
+	rule, changed := engine.Evaluate(b.automationState())
+	if !changed {
+		return
+	}
+	if rule.Name != "" {
+		log.Info("automation: apply rule ", rule.Name)
+	}
+	action := rule.Action
+	if action.ClashMode != "" && b.api != nil {
+		b.api.SetMode(action.ClashMode)
+	}
+	for groupName, tag := range action.Select {
+		if !b.selectOutbound(groupName, tag) {
+			log.Warn("automation: select ", tag, " in group ", groupName, " failed")
+		}
+	}
+	switch action.Tunnel {
+	case automation.TunnelPause:
+		if !b.pauseManager.IsNetworkPaused() {
+			b.pauseManager.NetworkPause()
+		}
+	case automation.TunnelResume:
+		if b.pauseManager.IsNetworkPaused() {
+			b.pauseManager.NetworkWake()
+		}
+	}
+}
+
+func (b *boxInstance) automationState() automation.State {
+	state := automation.State{
+		InterfaceType: automation.InterfaceTypeOther,
+		Time:          time.Now(),
+	}
+	defaultInterface := b.Network().DefaultNetworkInterface()
+	if defaultInterface == nil {
+		return state
+	}
+	state.InterfaceType = defaultInterface.Type.String()
+	state.Metered = defaultInterface.Expensive
+	if state.InterfaceType == automation.InterfaceTypeWIFI {
+		if wifiState := b.platformInterface.ReadWIFIState(); wifiState != nil {
+			state.SSID = wifiState.GetSSID()
+			state.BSSID = wifiState.GetBSSID()
+		}
+	}
+	return state
+}
+
+// loopAutomation re-evaluates rules with time conditions every minute.
+func (b *boxInstance) loopAutomation() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			if engine := b.automation.Load(); engine != nil && engine.HasTimeCondition() {
+				b.runAutomation()
+			}
+		}
+	}
+}
+
+// selectOutbound selects tag in selector groupName, and notifies the platform.
+func (b *boxInstance) selectOutbound(groupName, tag string) bool {
+	outbound, loaded := b.Outbound().Outbound(groupName)
+	if !loaded {
+		return false
+	}
+	selector, isSelector := outbound.(*group.Selector)
+	if !isSelector {
+		return false
+	}
+	old := selector.Now()
+	if !selector.SelectOutbound(tag) {
+		return false
+	}
+	b.platformInterface.OnGroupSelectedChange(groupName, old, tag)
+	return true
+}
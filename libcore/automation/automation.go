@@ -0,0 +1,257 @@
+// Package automation switches behaviors of instance by network state and time.
+package automation
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	InterfaceTypeWIFI     = "wifi"
+	InterfaceTypeCellular = "cellular"
+	InterfaceTypeEthernet = "ethernet"
+	InterfaceTypeOther    = "other"
+)
+
+const (
+	TunnelPause  = "pause"
+	TunnelResume = "resume"
+)
+
+// Rule matches if all of its non-empty conditions match.
+// Items of each condition are in "or" relation.
+type Rule struct {
+	Name string `json:"name,omitempty"`
+
+	// SSID and BSSID are regular expressions, which only match on Wi-Fi.
+	SSID          []string `json:"ssid,omitempty"`
+	BSSID         []string `json:"bssid,omitempty"`
+	InterfaceType []string `json:"interface_type,omitempty"`
+	Metered       *bool    `json:"metered,omitempty"`
+	// Time is like "22:00-07:00" in local time, which may cross midnight.
+	Time []string `json:"time,omitempty"`
+	// Weekday is like "mon", "tue".
+	Weekday []string `json:"weekday,omitempty"`
+
+	Action Action `json:"action"`
+}
+
+type Action struct {
+	ClashMode string `json:"clash_mode,omitempty"`
+	// Select maps selector group to the member to select.
+	Select map[string]string `json:"select,omitempty"`
+	// Tunnel is TunnelPause or TunnelResume.
+	Tunnel string `json:"tunnel,omitempty"`
+}
+
+// State is the environment when evaluating.
+type State struct {
+	SSID          string
+	BSSID         string
+	InterfaceType string
+	Metered       bool
+	Time          time.Time
+}
+
+type timeWindow struct {
+	start, end int // Minutes of day
+}
+
+func (w timeWindow) contains(minute int) bool {
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+type compiledRule struct {
+	Rule
+	ssid    []*regexp.Regexp
+	bssid   []*regexp.Regexp
+	time    []timeWindow
+	weekday []time.Weekday
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func compileRule(rule Rule) (*compiledRule, error) {
+	compiled := &compiledRule{Rule: rule}
+	for _, expression := range rule.SSID {
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, E.Cause(err, "parse ssid")
+		}
+		compiled.ssid = append(compiled.ssid, regex)
+	}
+	for _, expression := range rule.BSSID {
+		regex, err := regexp.Compile("(?i)" + expression)
+		if err != nil {
+			return nil, E.Cause(err, "parse bssid")
+		}
+		compiled.bssid = append(compiled.bssid, regex)
+	}
+	for _, interfaceType := range rule.InterfaceType {
+		switch interfaceType {
+		case InterfaceTypeWIFI, InterfaceTypeCellular, InterfaceTypeEthernet, InterfaceTypeOther:
+		default:
+			return nil, E.New("unknown interface type: ", interfaceType)
+		}
+	}
+	for _, window := range rule.Time {
+		parsed, err := parseTimeWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		compiled.time = append(compiled.time, parsed)
+	}
+	for _, weekday := range rule.Weekday {
+		index := slices.Index(weekdays, strings.ToLower(weekday))
+		if index < 0 {
+			return nil, E.New("unknown weekday: ", weekday)
+		}
+		compiled.weekday = append(compiled.weekday, time.Weekday(index))
+	}
+	switch rule.Action.Tunnel {
+	case "", TunnelPause, TunnelResume:
+	default:
+		return nil, E.New("unknown tunnel action: ", rule.Action.Tunnel)
+	}
+	return compiled, nil
+}
+
+func parseTimeWindow(window string) (timeWindow, error) {
+	start, end, found := strings.Cut(window, "-")
+	if !found {
+		return timeWindow{}, E.New("invalid time window: ", window)
+	}
+	startMinute, err := parseClock(start)
+	if err != nil {
+		return timeWindow{}, E.Cause(err, "parse time window ", window)
+	}
+	endMinute, err := parseClock(end)
+	if err != nil {
+		return timeWindow{}, E.Cause(err, "parse time window ", window)
+	}
+	return timeWindow{start: startMinute, end: endMinute}, nil
+}
+
+func parseClock(clock string) (int, error) {
+	hourString, minuteString, found := strings.Cut(strings.TrimSpace(clock), ":")
+	if !found {
+		return 0, E.New("invalid clock: ", clock)
+	}
+	hour, err := strconv.Atoi(hourString)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, E.New("invalid hour: ", hourString)
+	}
+	minute, err := strconv.Atoi(minuteString)
+	if err != nil || minute < 0 || minute > 59 || hour == 24 && minute != 0 {
+		return 0, E.New("invalid minute: ", minuteString)
+	}
+	return hour*60 + minute, nil
+}
+
+func matchAny(rules []*regexp.Regexp, content string) bool {
+	return slices.ContainsFunc(rules, func(it *regexp.Regexp) bool {
+		return it.MatchString(content)
+	})
+}
+
+func (r *compiledRule) match(state State) bool {
+	if (len(r.ssid) > 0 || len(r.bssid) > 0) && state.InterfaceType != InterfaceTypeWIFI {
+		return false
+	}
+	if len(r.ssid) > 0 && !matchAny(r.ssid, state.SSID) {
+		return false
+	}
+	if len(r.bssid) > 0 && !matchAny(r.bssid, state.BSSID) {
+		return false
+	}
+	if len(r.InterfaceType) > 0 && !slices.Contains(r.InterfaceType, state.InterfaceType) {
+		return false
+	}
+	if r.Metered != nil && *r.Metered != state.Metered {
+		return false
+	}
+	if len(r.weekday) > 0 && !slices.Contains(r.weekday, state.Time.Weekday()) {
+		return false
+	}
+	if len(r.time) > 0 {
+		minute := state.Time.Hour()*60 + state.Time.Minute()
+		if !slices.ContainsFunc(r.time, func(it timeWindow) bool {
+			return it.contains(minute)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Engine evaluates rules in order, and the first matched rule wins.
+type Engine struct {
+	access  sync.Mutex
+	rules   []*compiledRule
+	matched int
+}
+
+// Parse parses JSON list of Rule.
+func Parse(content []byte) (*Engine, error) {
+	var rules []Rule
+	err := json.Unmarshal(content, &rules)
+	if err != nil {
+		return nil, E.Cause(err, "decode automation rules")
+	}
+	return New(rules)
+}
+
+func New(rules []Rule) (*Engine, error) {
+	engine := &Engine{matched: -1}
+	for i, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, E.Cause(err, "automation rule[", i, "]")
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	return engine, nil
+}
+
+// HasTimeCondition reports whether the engine should be evaluated periodically.
+func (e *Engine) HasTimeCondition() bool {
+	return slices.ContainsFunc(e.rules, func(it *compiledRule) bool {
+		return len(it.time) > 0 || len(it.weekday) > 0
+	})
+}
+
+// Evaluate returns the action of the first matched rule,
+// only if it is different from the last evaluation,
+// so that manual changes are kept until the environment changes.
+func (e *Engine) Evaluate(state State) (rule *Rule, changed bool) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	matched := slices.IndexFunc(e.rules, func(it *compiledRule) bool {
+		return it.match(state)
+	})
+	if matched == e.matched {
+		return nil, false
+	}
+	e.matched = matched
+	if matched < 0 {
+		return nil, false
+	}
+	return &e.rules[matched].Rule, true
+}
+
+// Reset makes next Evaluate apply the matched rule again.
+func (e *Engine) Reset() {
+	e.access.Lock()
+	defer e.access.Unlock()
+	e.matched = -1
+}
@@ -0,0 +1,58 @@
+package automation
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Engine(t *testing.T) {
+	engine, err := Parse([]byte(`[
+  {"name": "home", "ssid": ["^Home"], "action": {"clash_mode": "direct"}},
+  {"name": "night", "time": ["23:00-06:00"], "action": {"tunnel": "pause"}},
+  {"name": "metered", "interface_type": ["cellular"], "metered": true, "action": {"select": {"proxy": "cheap"}}}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !engine.HasTimeCondition() {
+		t.Fatal("expected time condition")
+	}
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	midnight := time.Date(2026, 1, 1, 0, 30, 0, 0, time.Local)
+	tt := []struct {
+		name    string
+		state   State
+		want    string
+		changed bool
+	}{
+		{"Home Wi-Fi", State{SSID: "Home-5G", InterfaceType: InterfaceTypeWIFI, Time: noon}, "home", true},
+		{"Same rule", State{SSID: "Home-2.4G", InterfaceType: InterfaceTypeWIFI, Time: noon}, "", false},
+		{"SSID only on Wi-Fi", State{SSID: "Home", InterfaceType: InterfaceTypeEthernet, Time: noon}, "", false},
+		{"Metered cellular", State{InterfaceType: InterfaceTypeCellular, Metered: true, Time: noon}, "metered", true},
+		{"Night crosses midnight", State{InterfaceType: InterfaceTypeCellular, Metered: true, Time: midnight}, "night", true},
+		{"Unmetered cellular", State{InterfaceType: InterfaceTypeCellular, Time: noon}, "", false},
+		{"Metered again", State{InterfaceType: InterfaceTypeCellular, Metered: true, Time: noon}, "metered", true},
+	}
+	for _, tc := range tt {
+		rule, changed := engine.Evaluate(tc.state)
+		if changed != tc.changed {
+			t.Fatalf("%s: changed = %v, want %v", tc.name, changed, tc.changed)
+		}
+		if changed && rule.Name != tc.want {
+			t.Fatalf("%s: got rule %s, want %s", tc.name, rule.Name, tc.want)
+		}
+	}
+
+	for _, content := range []string{
+		`[{"ssid": ["("]}]`,
+		`[{"interface_type": ["bluetooth"]}]`,
+		`[{"time": ["25:00-01:00"]}]`,
+		`[{"weekday": ["someday"]}]`,
+		`[{"action": {"tunnel": "stop"}}]`,
+	} {
+		_, err = Parse([]byte(content))
+		if err == nil {
+			t.Errorf("expected error for %s", content)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package libcore
 import (
 	"context"
 	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/sing-box"
@@ -18,8 +20,13 @@ import (
 
 	"github.com/xchacha20-poly1305/anchor/anchorservice"
 
+	"libcore/automation"
 	"libcore/combinedapi"
+	"libcore/dnscache"
+	"libcore/metered"
+	"libcore/plugin/pluginoption"
 	"libcore/protect"
+	"libcore/redact"
 )
 
 type boxInstance struct {
@@ -32,19 +39,45 @@ type boxInstance struct {
 	protect           *protect.Service
 	api               *combinedapi.CombinedAPI
 	anchor            *anchorservice.Anchor
+	dnsCache          *dnscache.Cache
 
 	pauseManager pause.Manager
+	automation   atomic.Pointer[automation.Engine]
+
+	metered           *metered.Controller
+	meteredAccess     sync.Mutex
+	meteredSelections map[string]string // Selections before applying low data outbound
 }
 
-// newBoxInstance creates a new boxInstance.
-func newBoxInstance(config string, platformInterface PlatformInterface, forTest bool) (b *boxInstance, err error) {
+// newBoxInstance creates a new boxInstance. dnsCache and meteredController are optional.
+func newBoxInstance(config string, platformInterface PlatformInterface, dnsCache *dnscache.Cache, meteredController *metered.Controller, forTest bool) (b *boxInstance, err error) {
 	defer catchPanic("NewSingBoxInstance", func(panicErr error) { err = panicErr })
 
 	ctx := baseContext(platformInterface)
+	config, err = openConfig(config)
+	if err != nil {
+		return nil, err
+	}
 	options, err := parseConfig(ctx, config)
 	if err != nil {
 		return nil, err
 	}
+	if !forTest {
+		secretRedactor.Add(redact.Secrets(&options)...)
+	}
+	err = defaultTrustStore().ResolveOptions(&options)
+	if err != nil {
+		return nil, E.Cause(err, "resolve trust store")
+	}
+	ctx = pluginoption.ContextWithOptions(ctx, &options)
+	if dnsCache != nil {
+		dnsRegistry := dnscache.NewRegistry(service.FromContext[adapter.DNSTransportRegistry](ctx), dnsCache)
+		ctx = service.ContextWith[adapter.DNSTransportRegistry](ctx, dnsRegistry)
+	}
+
+	if meteredController != nil {
+		ctx = service.ContextWithPtr(ctx, meteredController)
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	ctx = pause.WithDefaultManager(ctx)
@@ -84,7 +117,10 @@ func newBoxInstance(config string, platformInterface PlatformInterface, forTest
 		cancel:            cancel,
 		platformInterface: platformInterface,
 		pauseManager:      service.FromContext[pause.Manager](ctx),
+		dnsCache:          dnsCache,
+		metered:           meteredController,
 	}
+	interfaceWrapper.onDefaultInterfaceUpdate = b.defaultInterfaceUpdated
 
 	if !forTest {
 		// Protect
@@ -132,6 +168,13 @@ func (b *boxInstance) Start() (err error) {
 			return E.Cause(err, "start anchor service")
 		}
 	}
+	if b.dnsCache != nil {
+		b.dnsCache.Start(b.ctx)
+	}
+	if !b.forTest {
+		b.defaultInterfaceUpdated()
+		go b.loopAutomation()
+	}
 
 	if !b.forTest {
 		debug.FreeOSMemory()
@@ -140,6 +183,12 @@ func (b *boxInstance) Start() (err error) {
 	return nil
 }
 
+// defaultInterfaceUpdated applies policies depending on the default network.
+func (b *boxInstance) defaultInterfaceUpdated() {
+	b.updateMetered()
+	b.runAutomation()
+}
+
 func (b *boxInstance) Close() (err error) {
 	return b.CloseTimeout(C.FatalStopTimeout)
 }
@@ -151,6 +200,12 @@ func (b *boxInstance) CloseTimeout(timeout time.Duration) (err error) {
 		common.PtrOrNil(b.protect),
 		common.PtrOrNil(b.anchor),
 	)
+	if b.dnsCache != nil {
+		err := b.dnsCache.Save()
+		if err != nil {
+			log.Warn("save dns cache: ", err)
+		}
+	}
 
 	done := make(chan error, 1)
 	start := time.Now()
@@ -165,6 +220,8 @@ func (b *boxInstance) CloseTimeout(timeout time.Duration) (err error) {
 	case err = <-done:
 		if !b.forTest {
 			log.Info("sing-box closed in ", F.Seconds(time.Since(start).Seconds()), " s.")
+			// Kept if close timeout, as the box may still log.
+			secretRedactor.Reset()
 		}
 		return
 	}
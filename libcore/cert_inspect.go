@@ -0,0 +1,219 @@
+package libcore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+
+	"libcore/plugin/raybridge"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// CertChain is the parsed certificate chain of server.
+type CertChain struct {
+	certs []*CertInfo
+
+	// PEM is the whole chain in PEM.
+	PEM string
+	// V2RayPemHash is the pin of V2Ray pinnedPeerCertificateChainSha256.
+	V2RayPemHash string
+	// Verified reports whether the chain is trusted by current root store. See UpdateRootCACerts.
+	Verified    bool
+	VerifyError string
+}
+
+// Certs returns certificates from leaf to root.
+func (c *CertChain) Certs() CertInfoIterator {
+	return newIterator(c.certs)
+}
+
+// CertInfo is the details of a certificate.
+type CertInfo struct {
+	Subject            string
+	Issuer             string
+	SerialNumber       string
+	NotBefore          int64 // Unix seconds
+	NotAfter           int64 // Unix seconds
+	KeyType            string
+	SignatureAlgorithm string
+	IsCA               bool
+	// SCTCount is count of embedded signed certificate timestamps of Certificate Transparency.
+	SCTCount int32
+
+	// SHA256 is the pin of Hysteria and certificate_sha256.
+	SHA256 string
+	// PublicKeySHA256 is the pin of sing-box certificate_public_key_sha256.
+	PublicKeySHA256 string
+
+	sans        []string
+	ocspServers []string
+}
+
+// SANs returns DNS names, IP addresses, email addresses and URIs.
+func (c *CertInfo) SANs() StringIterator {
+	return newIterator(c.sans)
+}
+
+func (c *CertInfo) OCSPServers() StringIterator {
+	return newIterator(c.ocspServers)
+}
+
+type CertInfoIterator interface {
+	Next() *CertInfo
+	HasNext() bool
+	Length() int32
+}
+
+// InspectCert likes GetCert, but returns parsed chain.
+// mode can be "https", "quic" or "reality", which shows the certificate of REALITY target.
+func InspectCert(address, serverName, mode, proxy string) (*CertChain, error) {
+	dialer, err := proxyDialer(proxy)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := fetchCerts(address, serverName, mode, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return newCertChain(certs, verifyName(address, serverName))
+}
+
+// InspectCertByOutbound likes InspectCert, but connects by outbound of the running instance.
+// QUIC is not supported.
+func InspectCertByOutbound(address, serverName, mode, tag string) (*CertChain, error) {
+	if mode == "quic" {
+		return nil, E.New("QUIC is not supported by outbound")
+	}
+	dialer := httpDialer{func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialOutbound(ctx, 0, tag, addr)
+	}}
+	certs, err := fetchCerts(address, serverName, mode, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return newCertChain(certs, verifyName(address, serverName))
+}
+
+func verifyName(address, serverName string) string {
+	if serverName != "" {
+		return serverName
+	}
+	return M.ParseSocksaddr(address).AddrString()
+}
+
+func newCertChain(certs []*x509.Certificate, serverName string) (*CertChain, error) {
+	if len(certs) == 0 {
+		return nil, E.New("no certificate")
+	}
+	chain := &CertChain{
+		certs: make([]*CertInfo, 0, len(certs)),
+		PEM:   encodeCerts(certs),
+	}
+	chain.V2RayPemHash = string(raybridge.CalculatePEMCertHash([]byte(chain.PEM)))
+	for _, cert := range certs {
+		info, err := newCertInfo(cert)
+		if err != nil {
+			return nil, err
+		}
+		chain.certs = append(chain.certs, info)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	// Nil roots means system roots, which is replaced by UpdateRootCACerts.
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		chain.VerifyError = err.Error()
+	} else {
+		chain.Verified = true
+	}
+	return chain, nil
+}
+
+func newCertInfo(cert *x509.Certificate) (*CertInfo, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, E.Cause(err, "marshal public key")
+	}
+	certSum := sha256.Sum256(cert.Raw)
+	publicKeySum := sha256.Sum256(publicKey)
+	info := &CertInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotBefore:          cert.NotBefore.Unix(),
+		NotAfter:           cert.NotAfter.Unix(),
+		KeyType:            keyType(cert.PublicKey),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		IsCA:               cert.IsCA,
+		SCTCount:           sctCount(cert),
+		SHA256:             hex.EncodeToString(certSum[:]),
+		PublicKeySHA256:    base64.StdEncoding.EncodeToString(publicKeySum[:]),
+		ocspServers:        cert.OCSPServer,
+	}
+	info.sans = append(info.sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.sans = append(info.sans, ip.String())
+	}
+	info.sans = append(info.sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		info.sans = append(info.sans, uri.String())
+	}
+	return info, nil
+}
+
+func keyType(publicKey any) string {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return F.ToString("RSA ", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+// https://www.rfc-editor.org/rfc/rfc6962#section-3.3
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+func sctCount(cert *x509.Certificate) int32 {
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(oidSCTList) {
+			continue
+		}
+		var list []byte
+		_, err := asn1.Unmarshal(extension.Value, &list)
+		if err != nil || len(list) < 2 {
+			return 0
+		}
+		list = list[2:]
+		var count int32
+		for len(list) >= 2 {
+			length := int(binary.BigEndian.Uint16(list))
+			if len(list) < 2+length {
+				break
+			}
+			list = list[2+length:]
+			count++
+		}
+		return count
+	}
+	return 0
+}
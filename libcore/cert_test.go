@@ -2,6 +2,8 @@ package libcore
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"net"
 	"os"
 	"testing"
@@ -97,3 +99,80 @@ func Test_UpdateRootCACerts(t *testing.T) {
 	testConnect(chinaRailway, trustAsiaAddress, false, "normal 12306 2")
 	testConnect(husi, listen, !C.IsAndroid, "loaded custom 2")
 }
+
+func Test_NewCertChain(t *testing.T) {
+	const serverName = "husi.fr"
+	_, publicKey := common.Must2(aTLS.GenerateCertificate(nil, nil, time.Now, serverName, time.Now().Add(5*time.Minute)))
+	block, _ := pem.Decode(publicKey)
+	cert := common.Must1(x509.ParseCertificate(block.Bytes))
+
+	chain, err := newCertChain([]*x509.Certificate{cert}, serverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Verified {
+		t.Error("self-signed certificate verified")
+	}
+	if chain.V2RayPemHash != ToV2RayPemHash(string(publicKey)) {
+		t.Error("V2Ray pin not matched")
+	}
+	info := chain.Certs().Next()
+	if info.SHA256 != ToHysteriaHexSha256(string(publicKey)) {
+		t.Error("Hysteria pin not matched")
+	}
+	if info.PublicKeySHA256 != common.Must1(ToSingPublicKeySha256(string(publicKey))) {
+		t.Error("sing-box pin not matched")
+	}
+	if sans := iteratorToArray[string](info.SANs()); len(sans) != 1 || sans[0] != serverName {
+		t.Errorf("SANs: %v", sans)
+	}
+	if info.KeyType == "unknown" {
+		t.Error("unknown key type")
+	}
+}
+
+func Test_RootBlocklist(t *testing.T) {
+	const serverName = "husi.fr"
+	oldAssetsPath := externalAssetsPath
+	externalAssetsPath = t.TempDir()
+	defer func() {
+		externalAssetsPath = oldAssetsPath
+		UpdateRootCACerts(CertGoOrigin, nil)
+	}()
+	_, publicKey := common.Must2(aTLS.GenerateCertificate(nil, nil, time.Now, serverName, time.Now().Add(5*time.Minute)))
+	block, _ := pem.Decode(publicKey)
+	cert := common.Must1(x509.ParseCertificate(block.Bytes))
+	verify := func() error {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: serverName})
+		return err
+	}
+
+	UpdateRootCACerts(CertWithUserTrust, newIterator([]string{string(publicKey)}))
+	if err := verify(); err != nil {
+		t.Fatal("user trusted: ", err)
+	}
+	trustStore := NewTrustStore()
+	spkiHash := common.Must1(ToSingPublicKeySha256(string(publicKey)))
+	if err := trustStore.Block(spkiHash); err != nil {
+		t.Fatal(err)
+	}
+	if verify() == nil {
+		t.Error("blocked certificate verified")
+	}
+	// Also applied to later updates, even if all roots are blocked.
+	UpdateRootCACerts(CertWithUserTrust, newIterator([]string{string(publicKey)}))
+	if verify() == nil {
+		t.Error("blocked certificate verified after update")
+	}
+	if err := trustStore.Unblock(spkiHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(); err != nil {
+		t.Error("unblocked: ", err)
+	}
+
+	UpdateRootCACerts(CertMozilla, nil)
+	if trustStore.Block(spkiHash) == nil {
+		t.Error("block succeeded on Mozilla store")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	_ "unsafe" // for go:linkname
 
 	_ "github.com/sagernet/sing-box/common/certificate"
@@ -35,6 +36,19 @@ var (
 	boxChromeCert  *x509.CertPool
 )
 
+// rootAccess guards rootCertificates and the updates of systemRoots.
+var rootAccess sync.Mutex
+
+// rootCertificates is the PEM or DER list of current root store, before filtered by blocklist of trust store.
+// It is nil if the store can not be listed, such as Mozilla and Chrome, which do not support blocklist.
+var rootCertificates []string
+
+// androidCertDirectories are where crypto/x509 reads system roots on Android.
+var androidCertDirectories = []string{
+	"/system/etc/security/cacerts",
+	"/data/misc/keychain/certs-added",
+}
+
 const (
 	CertGoOrigin int32 = iota
 	CertWithUserTrust
@@ -49,6 +63,8 @@ const customCaFile = "ca.pem"
 //
 // In each time, this appends externalAssetsPath/ca.pem to root CA.
 func UpdateRootCACerts(certOption int32, certFromJava StringIterator) {
+	rootAccess.Lock()
+	defer rootAccess.Unlock()
 	// https://github.com/golang/go/blob/30b6fd60a63c738c2736e83b6a6886a032e6f269/src/crypto/x509/root.go#L31
 	// Make sure initialize system cert pool.
 	// If system cert has not been initialized,
@@ -57,9 +73,13 @@ func UpdateRootCACerts(certOption int32, certFromJava StringIterator) {
 	sysRoots, _ := x509.SystemCertPool()
 
 	var roots *x509.CertPool
+	rootCertificates = nil
 	switch certOption {
 	case CertGoOrigin:
 		roots = sysRoots
+		if C.IsAndroid {
+			rootCertificates = readCertDirectories(androidCertDirectories)
+		}
 	case CertWithUserTrust:
 		roots = x509.NewCertPool()
 		for certFromJava.HasNext() {
@@ -67,7 +87,9 @@ func UpdateRootCACerts(certOption int32, certFromJava StringIterator) {
 			// Unsupported: CatCert(SHA1WithRSA) since Go 1.24
 			if !tryAddCert(roots, []byte(cert)) {
 				log.Warn("failed to load java cert: ", cert)
+				continue
 			}
+			rootCertificates = append(rootCertificates, cert)
 		}
 	case CertMozilla:
 		roots = boxMozillaCert.Clone()
@@ -82,6 +104,9 @@ func UpdateRootCACerts(certOption int32, certFromJava StringIterator) {
 		if len(externalPem) > 0 {
 			if tryAddCert(roots, externalPem) {
 				log.Info("loaded external cert")
+				if rootCertificates != nil {
+					rootCertificates = append(rootCertificates, string(externalPem))
+				}
 			} else {
 				log.Warn("failed to loaded external cert")
 			}
@@ -89,6 +114,61 @@ func UpdateRootCACerts(certOption int32, certFromJava StringIterator) {
 	}
 
 	systemRoots = roots
+	if rootCertificates != nil {
+		filterRoots()
+	} else if blocklist, _ := defaultTrustStore().Blocklist(); len(blocklist) > 0 {
+		log.Warn("blocklist of trust store is not supported by this root store")
+	}
+}
+
+// readCertDirectories reads every certificate file in dirs.
+func readCertDirectories(dirs []string) []string {
+	var certificates []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err == nil && len(content) > 0 {
+				certificates = append(certificates, string(content))
+			}
+		}
+	}
+	return certificates
+}
+
+// blocklistSupported reports whether current root store can exclude blocked CAs.
+func blocklistSupported() bool {
+	rootAccess.Lock()
+	defer rootAccess.Unlock()
+	return rootCertificates != nil
+}
+
+// applyBlocklist applies changed blocklist to system roots if supported by current root store.
+func applyBlocklist() {
+	rootAccess.Lock()
+	defer rootAccess.Unlock()
+	if rootCertificates != nil {
+		filterRoots()
+	}
+}
+
+// filterRoots rebuilds system roots from rootCertificates, excluding blocked ones. rootAccess must be held.
+// The pool may become empty, which is intended,
+// so that x509.SystemCertPool() as fallback of sing-box does not trust the blocked.
+func filterRoots() {
+	certificates, err := defaultTrustStore().FilterPEM(rootCertificates)
+	if err != nil {
+		log.Warn("filter root certificates: ", err)
+		return
+	}
+	roots := x509.NewCertPool()
+	for _, cert := range certificates {
+		tryAddCert(roots, []byte(cert))
+	}
+	systemRoots = roots
 }
 
 // tryAddCert tries to add raw as pem or DER to pool.
@@ -115,21 +195,38 @@ const typeCert = "CERTIFICATE"
 // mode can choose TLS or QUIC.
 // proxy is a socks5 URL for dialer.
 func GetCert(address, serverName, mode, proxy string) (string, error) {
-	target := M.ParseSocksaddr(address)
-	if target.Port == 0 {
-		target.Port = 443
+	dialer, err := proxyDialer(proxy)
+	if err != nil {
+		return "", err
 	}
-	if !target.IsValid() {
-		return "", E.New("invalid server address: ", address)
+	certs, err := fetchCerts(address, serverName, mode, dialer)
+	if err != nil {
+		return "", err
 	}
+	return encodeCerts(certs), nil
+}
+
+// proxyDialer returns dialer by socks5 URL, or default dialer if proxy is empty.
+func proxyDialer(proxy string) (N.Dialer, error) {
 	var dialer N.Dialer = new(N.DefaultDialer)
 	if proxy != "" {
 		var err error
 		dialer, err = socks.NewClientFromURL(dialer, proxy)
 		if err != nil {
-			return "", E.Cause(err, "create proxy dialer")
+			return nil, E.Cause(err, "create proxy dialer")
 		}
 	}
+	return dialer, nil
+}
+
+func fetchCerts(address, serverName, mode string, dialer N.Dialer) ([]*x509.Certificate, error) {
+	target := M.ParseSocksaddr(address)
+	if target.Port == 0 {
+		target.Port = 443
+	}
+	if !target.IsValid() {
+		return nil, E.New("invalid server address: ", address)
+	}
 
 	options := scribe.Option{
 		Target: target,
@@ -140,34 +237,36 @@ func GetCert(address, serverName, mode, proxy string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), C.ProtocolTimeouts[C.ProtocolQUIC])
 	defer cancel()
 
-	var (
-		certs []*x509.Certificate
-		err   error
-	)
 	switch mode {
 	case "https":
-		certs, err = scribe.GetCert(ctx, options)
+		return scribe.GetCert(ctx, options)
+	case "reality":
+		// REALITY server forwards handshake without authentication to its target,
+		// so the certificate is the target's.
+		if serverName == "" {
+			return nil, E.New("missing server name for REALITY")
+		}
+		return scribe.GetCert(ctx, options)
 	case "quic":
 		if target.IsFqdn() {
 			ips, err := net.LookupIP(target.Fqdn)
 			if err != nil {
-				return "", E.Cause(err, "look up ip for ", target.Fqdn)
+				return nil, E.Cause(err, "look up ip for ", target.Fqdn)
 			}
 			if len(ips) == 0 {
-				return "", E.New("not found ip for ", target.Fqdn)
+				return nil, E.New("not found ip for ", target.Fqdn)
 			}
 			options.Target.Addr = M.AddrFromIP(ips[0])
 			options.SNI = target.Fqdn
 			options.Target.Fqdn = ""
 		}
-		certs, err = scribe.GetCertQuic(ctx, options)
+		return scribe.GetCertQuic(ctx, options)
 	default:
-		err = E.New("unknown mode: ", mode)
-	}
-	if err != nil {
-		return "", err
+		return nil, E.New("unknown mode: ", mode)
 	}
+}
 
+func encodeCerts(certs []*x509.Certificate) string {
 	buffer := bytes.NewBuffer(nil)
 	for _, cert := range certs {
 		_ = pem.Encode(buffer, &pem.Block{
@@ -175,7 +274,7 @@ func GetCert(address, serverName, mode, proxy string) (string, error) {
 			Bytes: cert.Raw,
 		})
 	}
-	return buffer.String(), nil
+	return buffer.String()
 }
 
 func ToV2RayPemHash(rawPem string) string {
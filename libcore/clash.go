@@ -0,0 +1,37 @@
+package libcore
+
+import (
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/clash"
+)
+
+// ClashConversion is the converted Clash profile.
+type ClashConversion struct {
+	// Config is JSON of sing-box configuration.
+	Config string
+
+	warnings []string
+}
+
+func (c *ClashConversion) GetWarnings() StringIterator {
+	return newIterator(c.warnings)
+}
+
+// ConvertClashProfile converts Clash or mihomo profile to sing-box configuration,
+// including proxy groups and rules.
+func ConvertClashProfile(content string) (*ClashConversion, error) {
+	result, err := clash.Convert([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	config, err := json.MarshalContext(baseContext(nil), &result.Options)
+	if err != nil {
+		return nil, E.Cause(err, "encode options")
+	}
+	return &ClashConversion{
+		Config:   string(config),
+		warnings: result.Warnings,
+	}, nil
+}
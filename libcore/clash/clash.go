@@ -0,0 +1,205 @@
+// Package clash converts Clash and mihomo profiles to sing-box options.
+package clash
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in policies of Clash.
+const (
+	PolicyDirect     = "DIRECT"
+	PolicyReject     = "REJECT"
+	PolicyRejectDrop = "REJECT-DROP"
+	PolicyPass       = "PASS"
+)
+
+// IsProfile reports whether content looks like a Clash profile.
+func IsProfile(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("proxies:")) || bytes.Contains(content, []byte("\nproxies:"))
+}
+
+// Result is the converted profile.
+type Result struct {
+	Options option.Options
+
+	// Warnings records items that were skipped or only partially converted.
+	Warnings []string
+}
+
+type profile struct {
+	Proxies        []yaml.Node          `yaml:"proxies"`
+	ProxyGroups    []yaml.Node          `yaml:"proxy-groups"`
+	Rules          []string             `yaml:"rules"`
+	RuleProviders  map[string]yaml.Node `yaml:"rule-providers"`
+	ProxyProviders map[string]yaml.Node `yaml:"proxy-providers"`
+	DNS            *yaml.Node           `yaml:"dns"`
+	Hosts          *yaml.Node           `yaml:"hosts"`
+	Listeners      []yaml.Node          `yaml:"listeners"`
+	SubRules       map[string]yaml.Node `yaml:"sub-rules"`
+}
+
+type converter struct {
+	options  option.Options
+	warnings []string
+
+	// tags records all outbound and endpoint tags that could be referenced.
+	tags map[string]bool
+	// builtin records the referenced built-in policies.
+	builtin map[string]bool
+	// providers records the behavior of converted rule providers.
+	providers map[string]string
+	// resolved is whether a resolve action has been inserted.
+	resolved bool
+}
+
+// Convert converts Clash profile to sing-box options.
+// Items that can't be converted are skipped and reported in Result.Warnings.
+func Convert(content []byte) (*Result, error) {
+	var profile profile
+	err := yaml.Unmarshal(content, &profile)
+	if err != nil {
+		return nil, E.Cause(err, "decode clash profile")
+	}
+	c := newConverter()
+	if len(profile.ProxyProviders) > 0 {
+		c.warn("proxy-providers are not supported")
+	}
+	if profile.DNS != nil {
+		c.warn("dns section is ignored")
+	}
+	if profile.Hosts != nil {
+		c.warn("hosts section is ignored")
+	}
+	if len(profile.Listeners) > 0 {
+		c.warn("listeners are ignored")
+	}
+	if len(profile.SubRules) > 0 {
+		c.warn("sub-rules are not supported")
+	}
+	for i := range profile.Proxies {
+		c.convertProxy(&profile.Proxies[i])
+	}
+	c.convertGroups(profile.ProxyGroups)
+	c.convertRuleProviders(profile.RuleProviders)
+	c.convertRules(profile.Rules)
+	c.appendBuiltin()
+	if len(c.options.Outbounds) == 0 && len(c.options.Endpoints) == 0 {
+		return nil, E.New("no proxy found in clash profile")
+	}
+	return &Result{
+		Options:  c.options,
+		Warnings: c.warnings,
+	}, nil
+}
+
+// ConvertProxies converts only proxies of Clash profile,
+// which is used by subscriptions.
+func ConvertProxies(content []byte) (*Result, error) {
+	var profile profile
+	err := yaml.Unmarshal(content, &profile)
+	if err != nil {
+		return nil, E.Cause(err, "decode clash profile")
+	}
+	c := newConverter()
+	for i := range profile.Proxies {
+		c.convertProxy(&profile.Proxies[i])
+	}
+	return &Result{
+		Options:  c.options,
+		Warnings: c.warnings,
+	}, nil
+}
+
+func newConverter() *converter {
+	return &converter{
+		tags:      make(map[string]bool),
+		builtin:   make(map[string]bool),
+		providers: make(map[string]string),
+	}
+}
+
+func (c *converter) warn(message ...any) {
+	c.warnings = append(c.warnings, E.New(message...).Error())
+}
+
+// reference resolves a policy name used by groups and rules.
+// It returns false if name is unknown.
+func (c *converter) reference(name string) (string, bool) {
+	switch name {
+	case PolicyDirect, PolicyReject:
+		c.builtin[name] = true
+		return name, true
+	case PolicyRejectDrop:
+		c.builtin[PolicyReject] = true
+		return PolicyReject, true
+	}
+	return name, c.tags[name]
+}
+
+func (c *converter) addTag(name string) bool {
+	switch name {
+	case "", PolicyDirect, PolicyReject, PolicyRejectDrop, PolicyPass:
+		return false
+	}
+	if c.tags[name] {
+		return false
+	}
+	c.tags[name] = true
+	return true
+}
+
+func (c *converter) appendBuiltin() {
+	if c.builtin[PolicyDirect] {
+		c.options.Outbounds = append(c.options.Outbounds, option.Outbound{
+			Type:    C.TypeDirect,
+			Tag:     PolicyDirect,
+			Options: &option.DirectOutboundOptions{},
+		})
+	}
+	if c.builtin[PolicyReject] {
+		c.options.Outbounds = append(c.options.Outbounds, option.Outbound{
+			Type:    C.TypeBlock,
+			Tag:     PolicyReject,
+			Options: &option.StubOptions{},
+		})
+	}
+}
+
+// unknownFields returns keys in node which are not declared by yaml tags of v.
+func unknownFields(node *yaml.Node, v any, ignored ...string) []string {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	known := yamlFields(reflect.TypeOf(v))
+	var unknown []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if !known[key] && !slices.Contains(ignored, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	return unknown
+}
+
+func yamlFields(t reflect.Type) map[string]bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
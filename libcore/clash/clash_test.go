@@ -0,0 +1,242 @@
+package clash
+
+import (
+	"slices"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"libcore/plugin/pluginoption"
+)
+
+const testProfile = `
+mixed-port: 7890
+dns:
+  enable: true
+proxies:
+  - name: ss
+    type: ss
+    server: example.com
+    port: 8388
+    cipher: aes-128-gcm
+    password: password
+  - name: vless
+    type: vless
+    server: example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    network: ws
+    tls: true
+    servername: example.com
+    ws-opts:
+      path: /ws
+    icon: unknown
+  - name: wg
+    type: wireguard
+    server: example.com
+    port: 51820
+    ip: 172.16.0.2
+    private-key: eCtXsJZ27+4PbhDkHnB923tkUn2Gj59wZw5wFA75MnU=
+    public-key: Cr8hWlKvtDt7nrvf+f0brNQQzabAqrjfBvas9pmowjo=
+  - name: snell
+    type: snell
+    server: example.com
+    port: 443
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: [Auto, ss, DIRECT, snell]
+  - name: Auto
+    type: url-test
+    include-all-proxies: true
+    exclude-filter: wg
+    url: https://www.gstatic.com/generate_204
+    interval: 300
+  - name: Balance
+    type: load-balance
+    proxies: [ss, vless]
+  - name: Relay
+    type: relay
+    proxies: [ss, vless]
+rule-providers:
+  ads:
+    type: inline
+    behavior: domain
+    payload:
+      - +.ads.example.com
+      - tracker.example.com
+  remote:
+    type: http
+    behavior: ipcidr
+    url: https://example.com/ip.srs
+    interval: 86400
+  yaml:
+    type: http
+    behavior: domain
+    url: https://example.com/domain.yaml
+rules:
+  - RULE-SET,ads,REJECT
+  - DOMAIN-SUFFIX,example.com,Proxy
+  - AND,((NETWORK,UDP),(DST-PORT,443)),REJECT-DROP
+  - NOT,((GEOSITE,cn)),Balance
+  - RULE-SET,yaml,Proxy
+  - IP-CIDR,10.0.0.0/8,DIRECT,no-resolve
+  - GEOIP,CN,DIRECT
+  - SRC-PORT,1000-2000,Relay
+  - IN-TYPE,SOCKS,DIRECT
+  - MATCH,Proxy
+  - DOMAIN,unreachable.com,DIRECT
+`
+
+func Test_Convert(t *testing.T) {
+	result, err := Convert([]byte(testProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := result.Options
+	for _, warning := range result.Warnings {
+		t.Log(warning)
+	}
+
+	var outbounds []string
+	for _, outbound := range options.Outbounds {
+		outbounds = append(outbounds, outbound.Type+"/"+outbound.Tag)
+	}
+	expectedOutbounds := []string{
+		C.TypeShadowsocks + "/ss",
+		C.TypeVLESS + "/vless",
+		C.TypeSelector + "/Proxy",
+		C.TypeURLTest + "/Auto",
+		pluginoption.TypeLoadBalance + "/Balance",
+		pluginoption.TypeChain + "/Relay",
+		C.TypeDirect + "/" + PolicyDirect,
+	}
+	if !slices.Equal(outbounds, expectedOutbounds) {
+		t.Errorf("outbounds: expected %v, got %v", expectedOutbounds, outbounds)
+	}
+	if len(options.Endpoints) != 1 || options.Endpoints[0].Type != C.TypeWireGuard {
+		t.Errorf("expected one wireguard endpoint, got %v", options.Endpoints)
+	}
+
+	selector := options.Outbounds[2].Options.(*option.SelectorOutboundOptions)
+	if expected := []string{"Auto", "ss", PolicyDirect}; !slices.Equal(selector.Outbounds, expected) {
+		t.Errorf("selector: expected %v, got %v", expected, selector.Outbounds)
+	}
+	urlTest := options.Outbounds[3].Options.(*option.URLTestOutboundOptions)
+	if expected := []string{"ss", "vless"}; !slices.Equal(urlTest.Outbounds, expected) {
+		t.Errorf("url-test: expected %v, got %v", expected, urlTest.Outbounds)
+	}
+
+	route := options.Route
+	if route.Final != "Proxy" {
+		t.Errorf("final: expected Proxy, got %s", route.Final)
+	}
+	var ruleSets []string
+	for _, ruleSet := range route.RuleSet {
+		ruleSets = append(ruleSets, ruleSet.Type+"/"+ruleSet.Tag)
+	}
+	expectedRuleSets := []string{
+		C.RuleSetTypeInline + "/ads",
+		C.RuleSetTypeRemote + "/remote",
+		C.RuleSetTypeRemote + "/geosite-cn",
+		C.RuleSetTypeRemote + "/geoip-cn",
+	}
+	if !slices.Equal(ruleSets, expectedRuleSets) {
+		t.Errorf("rule sets: expected %v, got %v", expectedRuleSets, ruleSets)
+	}
+
+	var actions []string
+	for _, rule := range route.Rules {
+		action := rule.DefaultOptions.RuleAction
+		if rule.Type == C.RuleTypeLogical {
+			action = rule.LogicalOptions.RuleAction
+		}
+		actions = append(actions, action.Action+"/"+action.RouteOptions.Outbound+action.RejectOptions.Method)
+	}
+	expectedActions := []string{
+		C.RuleActionTypeReject + "/",
+		C.RuleActionTypeRoute + "/Proxy",
+		C.RuleActionTypeReject + "/" + C.RuleActionRejectMethodDrop,
+		C.RuleActionTypeRoute + "/Balance",
+		C.RuleActionTypeRoute + "/" + PolicyDirect,
+		C.RuleActionTypeResolve + "/",
+		C.RuleActionTypeRoute + "/" + PolicyDirect,
+		C.RuleActionTypeRoute + "/Relay",
+	}
+	if !slices.Equal(actions, expectedActions) {
+		t.Errorf("rules: expected %v, got %v", expectedActions, actions)
+	}
+	not := route.Rules[3].LogicalOptions
+	if !not.Invert || len(not.Rules) != 1 || !slices.Equal(not.Rules[0].DefaultOptions.RuleSet, []string{"geosite-cn"}) {
+		t.Errorf("unexpected NOT rule: %+v", not)
+	}
+
+	balance := options.Outbounds[4].Options.(*pluginoption.LoadBalanceOutboundOptions)
+	if balance.Strategy != pluginoption.LoadBalanceStrategyConsistentHashing {
+		t.Errorf("expected default strategy, got %s", balance.Strategy)
+	}
+
+	relay := options.Outbounds[5].Options.(*pluginoption.ChainOutboundOptions)
+	if expected := []string{"ss", "vless"}; !slices.Equal(relay.Outbounds, expected) {
+		t.Errorf("relay: expected %v, got %v", expected, relay.Outbounds)
+	}
+
+	// dns, icon, snell (proxy and member), yaml provider,
+	// RULE-SET yaml, IN-TYPE, unreachable rule.
+	if len(result.Warnings) != 8 {
+		t.Errorf("expected 8 warnings, got %d", len(result.Warnings))
+	}
+}
+
+func Test_ConvertRuleProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior string
+		payload  []string
+		rules    int
+		warnings int
+	}{
+		{
+			name:     "domain",
+			behavior: "domain",
+			payload:  []string{"+.example.com", "*.example.org", "example.net"},
+			rules:    1,
+		},
+		{
+			name:     "ipcidr",
+			behavior: "ipcidr",
+			payload:  []string{"10.0.0.0/8", "fc00::/7"},
+			rules:    1,
+		},
+		{
+			name:     "classical",
+			behavior: "classical",
+			payload:  []string{"DOMAIN,example.com", "DST-PORT,80/443", "GEOIP,CN", "UID,1000"},
+			rules:    2,
+			warnings: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, warnings, err := ConvertRuleProvider(tt.behavior, tt.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plain.Rules) != tt.rules {
+				t.Errorf("expected %d rules, got %d", tt.rules, len(plain.Rules))
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("expected %d warnings, got %v", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func Test_splitRule(t *testing.T) {
+	fields := splitRule("AND,((DOMAIN,a.com),(OR,((NETWORK,UDP),(DST-PORT,443)))),Proxy")
+	expected := []string{"AND", "((DOMAIN,a.com),(OR,((NETWORK,UDP),(DST-PORT,443))))", "Proxy"}
+	if !slices.Equal(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
@@ -0,0 +1,199 @@
+package clash
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"libcore/plugin/pluginoption"
+
+	"gopkg.in/yaml.v3"
+)
+
+// https://wiki.metacubex.one/config/proxy-groups/
+type clashGroup struct {
+	Name              string   `yaml:"name"`
+	Type              string   `yaml:"type"`
+	Proxies           []string `yaml:"proxies"`
+	Use               []string `yaml:"use"`
+	URL               string   `yaml:"url"`
+	Interval          int      `yaml:"interval"`
+	Tolerance         uint16   `yaml:"tolerance"`
+	Strategy          string   `yaml:"strategy"`
+	IncludeAll        bool     `yaml:"include-all"`
+	IncludeAllProxies bool     `yaml:"include-all-proxies"`
+	Filter            string   `yaml:"filter"`
+	ExcludeFilter     string   `yaml:"exclude-filter"`
+}
+
+// Fields only used by the UI of Clash.
+var ignoredGroupFields = []string{"icon", "hidden", "lazy"}
+
+func (c *converter) convertGroups(nodes []yaml.Node) {
+	// Proxies which can be selected by include-all.
+	proxies := make([]string, 0, len(c.tags))
+	for _, outbound := range c.options.Outbounds {
+		proxies = append(proxies, outbound.Tag)
+	}
+	for _, endpoint := range c.options.Endpoints {
+		proxies = append(proxies, endpoint.Tag)
+	}
+
+	// Groups can reference each other, so register all names first.
+	groups := make([]clashGroup, 0, len(nodes))
+	for i := range nodes {
+		var group clashGroup
+		err := nodes[i].Decode(&group)
+		if err != nil {
+			c.warn("decode proxy-group at line ", nodes[i].Line, ": ", err)
+			continue
+		}
+		switch group.Type {
+		case "select", "url-test", "fallback", "load-balance", "relay":
+		default:
+			c.warn("proxy-group ", group.Name, ": unsupported type: ", group.Type)
+			continue
+		}
+		if !c.addTag(group.Name) {
+			c.warn("proxy-group at line ", nodes[i].Line, ": empty or duplicate name: ", group.Name)
+			continue
+		}
+		for _, field := range unknownFields(&nodes[i], group, ignoredGroupFields...) {
+			c.warn("proxy-group ", group.Name, ": ignored field: ", field)
+		}
+		groups = append(groups, group)
+	}
+
+	for _, group := range groups {
+		members := c.groupMembers(&group, proxies)
+		c.options.Outbounds = append(c.options.Outbounds, c.buildGroup(&group, members))
+	}
+}
+
+func (c *converter) groupMembers(group *clashGroup, proxies []string) []string {
+	if len(group.Use) > 0 {
+		c.warn("proxy-group ", group.Name, ": proxy-providers are not supported: ", strings.Join(group.Use, ", "))
+	}
+	var members []string
+	added := make(map[string]bool)
+	for _, name := range group.Proxies {
+		if name == PolicyPass {
+			c.warn("proxy-group ", group.Name, ": ", PolicyPass, " is not supported")
+			continue
+		}
+		tag, ok := c.reference(name)
+		if !ok {
+			c.warn("proxy-group ", group.Name, ": unknown member: ", name)
+			continue
+		}
+		if tag == group.Name || added[tag] {
+			continue
+		}
+		added[tag] = true
+		members = append(members, tag)
+	}
+	if group.IncludeAll || group.IncludeAllProxies {
+		filter := c.compileFilter(group.Name, group.Filter)
+		exclude := c.compileFilter(group.Name, group.ExcludeFilter)
+		for _, proxy := range proxies {
+			if added[proxy] {
+				continue
+			}
+			if filter != nil && !matchAny(filter, proxy) {
+				continue
+			}
+			if exclude != nil && matchAny(exclude, proxy) {
+				continue
+			}
+			added[proxy] = true
+			members = append(members, proxy)
+		}
+	}
+	if len(members) == 0 {
+		c.warn("proxy-group ", group.Name, ": no member available, fallback to ", PolicyDirect)
+		tag, _ := c.reference(PolicyDirect)
+		members = append(members, tag)
+	}
+	return members
+}
+
+// compileFilter compiles filter of mihomo, which uses backquote to separate multiple patterns.
+func (c *converter) compileFilter(group, filter string) []*regexp.Regexp {
+	if filter == "" {
+		return nil
+	}
+	var patterns []*regexp.Regexp
+	for _, pattern := range strings.Split(filter, "`") {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			c.warn("proxy-group ", group, ": ignored filter ", pattern, ": ", err)
+			continue
+		}
+		patterns = append(patterns, compiled)
+	}
+	return patterns
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *converter) buildGroup(group *clashGroup, members []string) option.Outbound {
+	outbound := option.Outbound{Tag: group.Name}
+	interval := badoption.Duration(time.Duration(group.Interval) * time.Second)
+	switch group.Type {
+	case "select":
+		outbound.Type = C.TypeSelector
+		outbound.Options = &option.SelectorOutboundOptions{
+			Outbounds: members,
+		}
+	case "fallback":
+		outbound.Type = pluginoption.TypeFallback
+		outbound.Options = &pluginoption.FallbackOutboundOptions{
+			Outbounds: members,
+			URL:       group.URL,
+			Interval:  interval,
+		}
+	case "load-balance":
+		strategy := group.Strategy
+		switch strategy {
+		case "":
+			// Default of mihomo, which is also the default of load balance outbound.
+			strategy = pluginoption.LoadBalanceStrategyConsistentHashing
+		case pluginoption.LoadBalanceStrategyRoundRobin, pluginoption.LoadBalanceStrategyConsistentHashing, pluginoption.LoadBalanceStrategyStickySessions:
+		default:
+			c.warn("proxy-group ", group.Name, ": unsupported strategy ", strategy, ", fallback to ", pluginoption.LoadBalanceStrategyConsistentHashing)
+			strategy = pluginoption.LoadBalanceStrategyConsistentHashing
+		}
+		outbound.Type = pluginoption.TypeLoadBalance
+		outbound.Options = &pluginoption.LoadBalanceOutboundOptions{
+			Outbounds: members,
+			URL:       group.URL,
+			Interval:  interval,
+			Strategy:  strategy,
+		}
+	case "relay":
+		outbound.Type = pluginoption.TypeChain
+		outbound.Options = &pluginoption.ChainOutboundOptions{
+			Outbounds: members,
+		}
+	default:
+		outbound.Type = C.TypeURLTest
+		outbound.Options = &option.URLTestOutboundOptions{
+			Outbounds: members,
+			URL:       group.URL,
+			Interval:  interval,
+			Tolerance: group.Tolerance,
+		}
+	}
+	return outbound
+}
@@ -0,0 +1,654 @@
+package clash
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+	N "github.com/sagernet/sing/common/network"
+
+	"libcore/plugin/pluginoption"
+	"libcore/sharelink"
+
+	"gopkg.in/yaml.v3"
+)
+
+// https://wiki.metacubex.one/config/proxies/
+type clashProxy struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Server string `yaml:"server"`
+	Port   string `yaml:"port"`
+	Ports  string `yaml:"ports"`
+	UDP    *bool  `yaml:"udp"`
+
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"`
+	UUID       string `yaml:"uuid"`
+	AlterID    int    `yaml:"alterId"`
+	Cipher     string `yaml:"cipher"`
+	Flow       string `yaml:"flow"`
+	Encryption string `yaml:"encryption"`
+
+	GlobalPadding       bool   `yaml:"global-padding"`
+	AuthenticatedLength bool   `yaml:"authenticated-length"`
+	PacketEncoding      string `yaml:"packet-encoding"`
+	UDPOverTCP          bool   `yaml:"udp-over-tcp"`
+
+	TLS            bool              `yaml:"tls"`
+	SNI            string            `yaml:"sni"`
+	ServerName     string            `yaml:"servername"`
+	SkipCertVerify bool              `yaml:"skip-cert-verify"`
+	ALPN           []string          `yaml:"alpn"`
+	Fingerprint    string            `yaml:"client-fingerprint"`
+	RealityOpts    *clashRealityOpts `yaml:"reality-opts"`
+
+	Network  string         `yaml:"network"`
+	WSOpts   *clashWSOpts   `yaml:"ws-opts"`
+	GRPCOpts *clashGRPCOpts `yaml:"grpc-opts"`
+	H2Opts   *clashH2Opts   `yaml:"h2-opts"`
+	HTTPOpts *clashHTTPOpts `yaml:"http-opts"`
+	Smux     *clashSmux     `yaml:"smux"`
+
+	Plugin     string         `yaml:"plugin"`
+	PluginOpts map[string]any `yaml:"plugin-opts"`
+
+	Obfs         string `yaml:"obfs"`
+	ObfsPassword string `yaml:"obfs-password"`
+	Up           string `yaml:"up"`
+	Down         string `yaml:"down"`
+	HopInterval  int    `yaml:"hop-interval"`
+
+	Congestion   string `yaml:"congestion-controller"`
+	UDPRelayMode string `yaml:"udp-relay-mode"`
+	ReduceRTT    bool   `yaml:"reduce-rtt"`
+	DisableSNI   bool   `yaml:"disable-sni"`
+
+	IdleSessionCheckInterval int `yaml:"idle-session-check-interval"`
+	IdleSessionTimeout       int `yaml:"idle-session-timeout"`
+	MinIdleSession           int `yaml:"min-idle-session"`
+
+	PrivateKey           string   `yaml:"private-key"`
+	PrivateKeyPassphrase string   `yaml:"private-key-passphrase"`
+	HostKey              []string `yaml:"host-key"`
+	HostKeyAlgorithms    []string `yaml:"host-key-algorithms"`
+
+	IP                  string           `yaml:"ip"`
+	IPv6                string           `yaml:"ipv6"`
+	PublicKey           string           `yaml:"public-key"`
+	PreSharedKey        string           `yaml:"pre-shared-key"`
+	Reserved            yaml.Node        `yaml:"reserved"`
+	MTU                 uint32           `yaml:"mtu"`
+	AllowedIPs          []string         `yaml:"allowed-ips"`
+	PersistentKeepalive uint16           `yaml:"persistent-keepalive"`
+	Peers               []clashWireGuard `yaml:"peers"`
+}
+
+type clashRealityOpts struct {
+	PublicKey string `yaml:"public-key"`
+	ShortID   string `yaml:"short-id"`
+}
+
+type clashWSOpts struct {
+	Path                string            `yaml:"path"`
+	Headers             map[string]string `yaml:"headers"`
+	MaxEarlyData        uint32            `yaml:"max-early-data"`
+	EarlyDataHeaderName string            `yaml:"early-data-header-name"`
+	V2RayHTTPUpgrade    bool              `yaml:"v2ray-http-upgrade"`
+}
+
+type clashGRPCOpts struct {
+	ServiceName string `yaml:"grpc-service-name"`
+}
+
+type clashH2Opts struct {
+	Host []string `yaml:"host"`
+	Path string   `yaml:"path"`
+}
+
+type clashHTTPOpts struct {
+	Method  string              `yaml:"method"`
+	Path    []string            `yaml:"path"`
+	Headers map[string][]string `yaml:"headers"`
+}
+
+type clashSmux struct {
+	Enabled        bool   `yaml:"enabled"`
+	Protocol       string `yaml:"protocol"`
+	MaxConnections int    `yaml:"max-connections"`
+	MinStreams     int    `yaml:"min-streams"`
+	MaxStreams     int    `yaml:"max-streams"`
+	Padding        bool   `yaml:"padding"`
+}
+
+type clashWireGuard struct {
+	Server       string    `yaml:"server"`
+	Port         uint16    `yaml:"port"`
+	PublicKey    string    `yaml:"public-key"`
+	PreSharedKey string    `yaml:"pre-shared-key"`
+	Reserved     yaml.Node `yaml:"reserved"`
+	AllowedIPs   []string  `yaml:"allowed-ips"`
+}
+
+func (c *converter) convertProxy(node *yaml.Node) {
+	var proxy clashProxy
+	err := node.Decode(&proxy)
+	if err != nil {
+		c.warn("decode proxy at line ", node.Line, ": ", err)
+		return
+	}
+	if !c.addTag(proxy.Name) {
+		c.warn("proxy at line ", node.Line, ": empty or duplicate name: ", proxy.Name)
+		return
+	}
+	for _, field := range unknownFields(node, proxy) {
+		c.warn("proxy ", proxy.Name, ": ignored field: ", field)
+	}
+	if proxy.Type == C.TypeWireGuard {
+		endpoint, err := proxy.buildWireGuard()
+		if err != nil {
+			delete(c.tags, proxy.Name)
+			c.warn("proxy ", proxy.Name, ": ", err)
+			return
+		}
+		c.options.Endpoints = append(c.options.Endpoints, endpoint)
+		return
+	}
+	outbound, err := proxy.build()
+	if err != nil {
+		delete(c.tags, proxy.Name)
+		c.warn("proxy ", proxy.Name, ": ", err)
+		return
+	}
+	c.options.Outbounds = append(c.options.Outbounds, outbound)
+}
+
+// ConvertProxy converts a single Clash proxy mapping to outbound.
+// WireGuard is not supported here as it is an endpoint in sing-box.
+func ConvertProxy(content []byte) (option.Outbound, error) {
+	var proxy clashProxy
+	err := yaml.Unmarshal(content, &proxy)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "decode proxy")
+	}
+	return proxy.build()
+}
+
+func (p *clashProxy) serverOptions() (option.ServerOptions, error) {
+	port, err := sharelink.ParsePort(p.Port)
+	if err != nil {
+		return option.ServerOptions{}, err
+	}
+	return option.ServerOptions{
+		Server:     p.Server,
+		ServerPort: port,
+	}, nil
+}
+
+func (p *clashProxy) network() option.NetworkList {
+	if p.UDP != nil && !*p.UDP {
+		return option.NetworkList(N.NetworkTCP)
+	}
+	return ""
+}
+
+func (p *clashProxy) build() (option.Outbound, error) {
+	outbound := option.Outbound{Type: p.Type, Tag: p.Name}
+	server, err := p.serverOptions()
+	if err != nil && p.Type != "hysteria2" {
+		return outbound, err
+	}
+	switch p.Type {
+	case "ss":
+		options := &option.ShadowsocksOutboundOptions{
+			ServerOptions: server,
+			Method:        p.Cipher,
+			Password:      p.Password,
+			Network:       p.network(),
+			Multiplex:     p.multiplex(),
+		}
+		if p.UDPOverTCP {
+			options.UDPOverTCP = &option.UDPOverTCPOptions{Enabled: true}
+		}
+		if p.Plugin != "" {
+			options.Plugin, options.PluginOptions, err = p.sip003Plugin()
+			if err != nil {
+				return outbound, err
+			}
+		}
+		outbound.Type = C.TypeShadowsocks
+		outbound.Options = options
+	case "vmess":
+		options := &option.VMessOutboundOptions{
+			ServerOptions:       server,
+			UUID:                p.UUID,
+			Security:            p.Cipher,
+			AlterId:             p.AlterID,
+			GlobalPadding:       p.GlobalPadding,
+			AuthenticatedLength: p.AuthenticatedLength,
+			Network:             p.network(),
+			PacketEncoding:      p.PacketEncoding,
+			Multiplex:           p.multiplex(),
+		}
+		if options.Security == "" {
+			options.Security = "auto"
+		}
+		options.Transport, err = p.transport()
+		if err != nil {
+			return outbound, err
+		}
+		options.TLS = p.tls(p.TLS)
+		outbound.Type = C.TypeVMess
+		outbound.Options = options
+	case "vless":
+		options := &pluginoption.VLESSOutboundOptions{
+			VLESSOutboundOptions: option.VLESSOutboundOptions{
+				ServerOptions: server,
+				UUID:          p.UUID,
+				Flow:          p.Flow,
+				Network:       p.network(),
+				Multiplex:     p.multiplex(),
+			},
+		}
+		if p.Encryption != "none" {
+			options.Encryption = p.Encryption
+		}
+		if p.PacketEncoding != "" {
+			options.PacketEncoding = &p.PacketEncoding
+		}
+		options.Transport, err = p.transport()
+		if err != nil {
+			return outbound, err
+		}
+		options.TLS = p.tls(p.TLS)
+		outbound.Type = C.TypeVLESS
+		outbound.Options = options
+	case "trojan":
+		options := &option.TrojanOutboundOptions{
+			ServerOptions: server,
+			Password:      p.Password,
+			Network:       p.network(),
+			Multiplex:     p.multiplex(),
+		}
+		options.Transport, err = p.transport()
+		if err != nil {
+			return outbound, err
+		}
+		options.TLS = p.tls(true)
+		outbound.Type = C.TypeTrojan
+		outbound.Options = options
+	case "hysteria2":
+		options := &option.Hysteria2OutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server: p.Server,
+			},
+			HopInterval: badoption.Duration(time.Duration(p.HopInterval) * time.Second),
+			Password:    p.Password,
+			Network:     p.network(),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: p.tls(true),
+			},
+		}
+		if p.Ports != "" {
+			options.ServerPorts, err = sharelink.ParsePortRanges(p.Ports)
+		} else {
+			options.ServerPort, err = sharelink.ParsePort(p.Port)
+		}
+		if err != nil {
+			return outbound, err
+		}
+		options.UpMbps, err = parseBandwidth(p.Up)
+		if err != nil {
+			return outbound, E.Cause(err, "parse up")
+		}
+		options.DownMbps, err = parseBandwidth(p.Down)
+		if err != nil {
+			return outbound, E.Cause(err, "parse down")
+		}
+		if p.Obfs != "" {
+			options.Obfs = &option.Hysteria2Obfs{
+				Type:     p.Obfs,
+				Password: p.ObfsPassword,
+			}
+		}
+		outbound.Type = C.TypeHysteria2
+		outbound.Options = options
+	case "tuic":
+		options := &option.TUICOutboundOptions{
+			ServerOptions:     server,
+			UUID:              p.UUID,
+			Password:          p.Password,
+			CongestionControl: p.Congestion,
+			UDPRelayMode:      p.UDPRelayMode,
+			ZeroRTTHandshake:  p.ReduceRTT,
+			Network:           p.network(),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: p.tls(true),
+			},
+		}
+		options.TLS.DisableSNI = p.DisableSNI
+		outbound.Type = C.TypeTUIC
+		outbound.Options = options
+	case "anytls":
+		outbound.Type = C.TypeAnyTLS
+		outbound.Options = &option.AnyTLSOutboundOptions{
+			ServerOptions: server,
+			Password:      p.Password,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: p.tls(true),
+			},
+			IdleSessionCheckInterval: badoption.Duration(time.Duration(p.IdleSessionCheckInterval) * time.Second),
+			IdleSessionTimeout:       badoption.Duration(time.Duration(p.IdleSessionTimeout) * time.Second),
+			MinIdleSession:           p.MinIdleSession,
+		}
+	case "ssh":
+		options := &option.SSHOutboundOptions{
+			ServerOptions:        server,
+			User:                 p.Username,
+			Password:             p.Password,
+			PrivateKeyPassphrase: p.PrivateKeyPassphrase,
+			HostKey:              p.HostKey,
+			HostKeyAlgorithms:    p.HostKeyAlgorithms,
+		}
+		if strings.Contains(p.PrivateKey, "PRIVATE KEY") {
+			options.PrivateKey = strings.Split(strings.TrimSpace(p.PrivateKey), "\n")
+		} else {
+			options.PrivateKeyPath = p.PrivateKey
+		}
+		outbound.Type = C.TypeSSH
+		outbound.Options = options
+	case "socks5":
+		if p.TLS {
+			return outbound, E.New("socks5 over TLS is not supported")
+		}
+		outbound.Type = C.TypeSOCKS
+		outbound.Options = &option.SOCKSOutboundOptions{
+			ServerOptions: server,
+			Username:      p.Username,
+			Password:      p.Password,
+			Network:       p.network(),
+		}
+	case "http":
+		outbound.Type = C.TypeHTTP
+		outbound.Options = &pluginoption.HTTPOutboundOptions{
+			HTTPOutboundOptions: option.HTTPOutboundOptions{
+				ServerOptions: server,
+				Username:      p.Username,
+				Password:      p.Password,
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: p.tls(p.TLS),
+				},
+			},
+		}
+	default:
+		return outbound, E.New("unsupported proxy type: ", p.Type)
+	}
+	return outbound, nil
+}
+
+func (p *clashProxy) buildWireGuard() (option.Endpoint, error) {
+	options := &option.WireGuardEndpointOptions{
+		PrivateKey: p.PrivateKey,
+		MTU:        p.MTU,
+	}
+	for _, address := range []string{p.IP, p.IPv6} {
+		if address == "" {
+			continue
+		}
+		prefix, err := parsePrefix(address)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse ip")
+		}
+		options.Address = append(options.Address, prefix)
+	}
+	peers := p.Peers
+	if len(peers) == 0 {
+		port, err := sharelink.ParsePort(p.Port)
+		if err != nil {
+			return option.Endpoint{}, err
+		}
+		peers = []clashWireGuard{{
+			Server:       p.Server,
+			Port:         port,
+			PublicKey:    p.PublicKey,
+			PreSharedKey: p.PreSharedKey,
+			Reserved:     p.Reserved,
+			AllowedIPs:   p.AllowedIPs,
+		}}
+	}
+	for _, clashPeer := range peers {
+		peer := option.WireGuardPeer{
+			Address:                     clashPeer.Server,
+			Port:                        clashPeer.Port,
+			PublicKey:                   clashPeer.PublicKey,
+			PreSharedKey:                clashPeer.PreSharedKey,
+			PersistentKeepaliveInterval: p.PersistentKeepalive,
+		}
+		allowedIPs := clashPeer.AllowedIPs
+		if len(allowedIPs) == 0 {
+			allowedIPs = []string{"0.0.0.0/0", "::/0"}
+		}
+		for _, allowedIP := range allowedIPs {
+			prefix, err := parsePrefix(allowedIP)
+			if err != nil {
+				return option.Endpoint{}, E.Cause(err, "parse allowed-ips")
+			}
+			peer.AllowedIPs = append(peer.AllowedIPs, prefix)
+		}
+		var err error
+		peer.Reserved, err = parseReserved(&clashPeer.Reserved)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse reserved")
+		}
+		options.Peers = append(options.Peers, peer)
+	}
+	return option.Endpoint{
+		Type:    C.TypeWireGuard,
+		Tag:     p.Name,
+		Options: options,
+	}, nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		return netip.ParsePrefix(value)
+	}
+	address, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(address, address.BitLen()), nil
+}
+
+// parseReserved accepts both `[1, 2, 3]` and base64 string.
+func parseReserved(node *yaml.Node) ([]uint8, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.SequenceNode:
+		var reserved []uint8
+		err := node.Decode(&reserved)
+		return reserved, err
+	case yaml.ScalarNode:
+		if node.Value == "" {
+			return nil, nil
+		}
+		return sharelink.DecodeBase64(node.Value)
+	default:
+		return nil, E.New("bad reserved")
+	}
+}
+
+// parseBandwidth parses `100` or `100 Mbps` to Mbps.
+func parseBandwidth(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	number := strings.TrimRight(value, " ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+	unit := strings.ToLower(strings.TrimSpace(value[len(number):]))
+	mbps, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "", "m", "mbps":
+		return mbps, nil
+	case "g", "gbps":
+		return mbps * 1000, nil
+	default:
+		return 0, E.New("unsupported unit: ", unit)
+	}
+}
+
+func (p *clashProxy) tls(enabled bool) *option.OutboundTLSOptions {
+	if !enabled {
+		return nil
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: p.SNI,
+		Insecure:   p.SkipCertVerify,
+		ALPN:       p.ALPN,
+	}
+	if options.ServerName == "" {
+		options.ServerName = p.ServerName
+	}
+	if p.Fingerprint != "" {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: p.Fingerprint,
+		}
+	}
+	if p.RealityOpts != nil {
+		options.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: p.RealityOpts.PublicKey,
+			ShortID:   p.RealityOpts.ShortID,
+		}
+	}
+	return options
+}
+
+func (p *clashProxy) multiplex() *option.OutboundMultiplexOptions {
+	if p.Smux == nil || !p.Smux.Enabled {
+		return nil
+	}
+	return &option.OutboundMultiplexOptions{
+		Enabled:        true,
+		Protocol:       p.Smux.Protocol,
+		MaxConnections: p.Smux.MaxConnections,
+		MinStreams:     p.Smux.MinStreams,
+		MaxStreams:     p.Smux.MaxStreams,
+		Padding:        p.Smux.Padding,
+	}
+}
+
+func (p *clashProxy) transport() (*option.V2RayTransportOptions, error) {
+	switch p.Network {
+	case "", "tcp":
+		return nil, nil
+	case "ws":
+		transport := &option.V2RayTransportOptions{Type: C.V2RayTransportTypeWebsocket}
+		if p.WSOpts == nil {
+			return transport, nil
+		}
+		var headers badoption.HTTPHeader
+		if len(p.WSOpts.Headers) > 0 {
+			headers = make(badoption.HTTPHeader, len(p.WSOpts.Headers))
+			for key, value := range p.WSOpts.Headers {
+				headers[key] = []string{value}
+			}
+		}
+		if p.WSOpts.V2RayHTTPUpgrade {
+			transport.Type = C.V2RayTransportTypeHTTPUpgrade
+			transport.HTTPUpgradeOptions.Path = p.WSOpts.Path
+			transport.HTTPUpgradeOptions.Host = headers.Build().Get("Host")
+			delete(headers, "Host")
+			if len(headers) > 0 {
+				transport.HTTPUpgradeOptions.Headers = headers
+			}
+			return transport, nil
+		}
+		transport.WebsocketOptions.Path = p.WSOpts.Path
+		transport.WebsocketOptions.Headers = headers
+		transport.WebsocketOptions.MaxEarlyData = p.WSOpts.MaxEarlyData
+		transport.WebsocketOptions.EarlyDataHeaderName = p.WSOpts.EarlyDataHeaderName
+		return transport, nil
+	case "grpc":
+		transport := &option.V2RayTransportOptions{Type: C.V2RayTransportTypeGRPC}
+		if p.GRPCOpts != nil {
+			transport.GRPCOptions.ServiceName = p.GRPCOpts.ServiceName
+		}
+		return transport, nil
+	case "h2":
+		transport := &option.V2RayTransportOptions{Type: C.V2RayTransportTypeHTTP}
+		if p.H2Opts != nil {
+			transport.HTTPOptions.Host = p.H2Opts.Host
+			transport.HTTPOptions.Path = p.H2Opts.Path
+		}
+		return transport, nil
+	case "http":
+		transport := &option.V2RayTransportOptions{Type: C.V2RayTransportTypeHTTP}
+		if p.HTTPOpts == nil {
+			return transport, nil
+		}
+		transport.HTTPOptions.Method = p.HTTPOpts.Method
+		if len(p.HTTPOpts.Path) > 0 {
+			transport.HTTPOptions.Path = p.HTTPOpts.Path[0]
+		}
+		if len(p.HTTPOpts.Headers) > 0 {
+			transport.HTTPOptions.Headers = make(badoption.HTTPHeader, len(p.HTTPOpts.Headers))
+			for key, values := range p.HTTPOpts.Headers {
+				if strings.EqualFold(key, "Host") {
+					transport.HTTPOptions.Host = values
+					continue
+				}
+				transport.HTTPOptions.Headers[key] = values
+			}
+		}
+		return transport, nil
+	default:
+		return nil, E.New("unsupported network: ", p.Network)
+	}
+}
+
+// sip003Plugin converts Clash plugin-opts to SIP003 plugin options.
+func (p *clashProxy) sip003Plugin() (name, options string, err error) {
+	var values []string
+	switch p.Plugin {
+	case "obfs":
+		name = "obfs-local"
+		if mode, _ := p.PluginOpts["mode"].(string); mode != "" {
+			values = append(values, "obfs="+mode)
+		}
+		if host, _ := p.PluginOpts["host"].(string); host != "" {
+			values = append(values, "obfs-host="+host)
+		}
+	case "v2ray-plugin":
+		name = "v2ray-plugin"
+		if mode, _ := p.PluginOpts["mode"].(string); mode != "" {
+			values = append(values, "mode="+mode)
+		}
+		if tls, _ := p.PluginOpts["tls"].(bool); tls {
+			values = append(values, "tls")
+		}
+		if host, _ := p.PluginOpts["host"].(string); host != "" {
+			values = append(values, "host="+host)
+		}
+		if path, _ := p.PluginOpts["path"].(string); path != "" {
+			values = append(values, "path="+path)
+		}
+		if mux, isBool := p.PluginOpts["mux"].(bool); isBool {
+			values = append(values, "mux="+strconv.FormatBool(mux))
+		}
+	default:
+		return "", "", E.New("unsupported plugin: ", p.Plugin)
+	}
+	return name, strings.Join(values, ";"), nil
+}
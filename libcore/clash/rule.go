@@ -0,0 +1,519 @@
+package clash
+
+import (
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+	"github.com/sagernet/sing/common/x/linkedhashmap"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule sets of GEOIP and GEOSITE, with the same names used by husi.
+const (
+	GeoIPRuleSetURL   = "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/"
+	GeositeRuleSetURL = "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/"
+)
+
+// https://wiki.metacubex.one/config/rule-providers/
+type clashRuleProvider struct {
+	Type     string   `yaml:"type"`
+	Behavior string   `yaml:"behavior"`
+	Format   string   `yaml:"format"`
+	URL      string   `yaml:"url"`
+	Path     string   `yaml:"path"`
+	Interval int      `yaml:"interval"`
+	Proxy    string   `yaml:"proxy"`
+	Payload  []string `yaml:"payload"`
+}
+
+func (c *converter) convertRuleProviders(nodes map[string]yaml.Node) {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		node := nodes[name]
+		var provider clashRuleProvider
+		err := node.Decode(&provider)
+		if err != nil {
+			c.warn("decode rule-provider ", name, ": ", err)
+			continue
+		}
+		for _, field := range unknownFields(&node, provider) {
+			c.warn("rule-provider ", name, ": ignored field: ", field)
+		}
+		ruleSet, err := c.buildRuleProvider(name, &provider)
+		if err != nil {
+			c.warn("rule-provider ", name, ": ", err)
+			continue
+		}
+		c.providers[name] = provider.Behavior
+		c.route().RuleSet = append(c.route().RuleSet, ruleSet)
+	}
+}
+
+func (c *converter) buildRuleProvider(name string, provider *clashRuleProvider) (option.RuleSet, error) {
+	ruleSet := option.RuleSet{Tag: name}
+	if provider.Type == "inline" {
+		plain, warnings, err := ConvertRuleProvider(provider.Behavior, provider.Payload)
+		if err != nil {
+			return ruleSet, err
+		}
+		for _, warning := range warnings {
+			c.warn("rule-provider ", name, ": ", warning)
+		}
+		ruleSet.Type = C.RuleSetTypeInline
+		ruleSet.InlineOptions = plain
+		return ruleSet, nil
+	}
+	// sing-box can only read its own rule set formats.
+	source := provider.Path
+	if provider.Type == "http" {
+		u, err := url.Parse(provider.URL)
+		if err != nil {
+			return ruleSet, E.Cause(err, "parse url")
+		}
+		source = u.Path
+	}
+	switch {
+	case strings.HasSuffix(source, ".srs"):
+		ruleSet.Format = C.RuleSetFormatBinary
+	case strings.HasSuffix(source, ".json"):
+		ruleSet.Format = C.RuleSetFormatSource
+	default:
+		format := provider.Format
+		if format == "" {
+			format = "yaml"
+		}
+		return ruleSet, E.New("format ", format, " is not supported, use sing-box rule set instead")
+	}
+	switch provider.Type {
+	case "http":
+		ruleSet.Type = C.RuleSetTypeRemote
+		ruleSet.RemoteOptions = option.RemoteRuleSet{
+			URL:            provider.URL,
+			UpdateInterval: badoption.Duration(time.Duration(provider.Interval) * time.Second),
+		}
+		if provider.Proxy != "" {
+			detour, ok := c.reference(provider.Proxy)
+			if !ok {
+				c.warn("rule-provider ", name, ": unknown proxy: ", provider.Proxy)
+			} else {
+				ruleSet.RemoteOptions.DownloadDetour = detour
+			}
+		}
+	case "file":
+		ruleSet.Type = C.RuleSetTypeLocal
+		ruleSet.LocalOptions.Path = provider.Path
+	default:
+		return ruleSet, E.New("unsupported type: ", provider.Type)
+	}
+	return ruleSet, nil
+}
+
+// ConvertRuleProvider converts payload of Clash rule provider to sing-box rule set.
+// Unsupported entries are skipped and returned as warnings.
+func ConvertRuleProvider(behavior string, payload []string) (option.PlainRuleSet, []string, error) {
+	var (
+		plain    option.PlainRuleSet
+		warnings []string
+	)
+	switch behavior {
+	case "domain":
+		var rule option.DefaultHeadlessRule
+		for _, entry := range payload {
+			entry = strings.TrimSpace(entry)
+			switch {
+			case entry == "":
+			case strings.HasPrefix(entry, "+."):
+				rule.DomainSuffix = append(rule.DomainSuffix, entry[2:])
+			case strings.HasPrefix(entry, "*."):
+				// `*` matches exactly one level.
+				rule.DomainRegex = append(rule.DomainRegex, `^[^.]+\.`+regexpQuote(entry[2:])+`$`)
+			case strings.HasPrefix(entry, "."):
+				rule.DomainSuffix = append(rule.DomainSuffix, entry)
+			default:
+				rule.Domain = append(rule.Domain, entry)
+			}
+		}
+		plain.Rules = append(plain.Rules, headlessRule(rule))
+	case "ipcidr":
+		var rule option.DefaultHeadlessRule
+		for _, entry := range payload {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				rule.IPCIDR = append(rule.IPCIDR, entry)
+			}
+		}
+		plain.Rules = append(plain.Rules, headlessRule(rule))
+	case "classical":
+		for _, entry := range payload {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			fields := splitRule(entry)
+			var rule option.RawDefaultRule
+			_, err := applyCondition(&rule, fields[0], ruleValue(fields), nil)
+			if err != nil {
+				warnings = append(warnings, E.Cause(err, entry).Error())
+				continue
+			}
+			headless, err := toHeadless(rule)
+			if err != nil {
+				warnings = append(warnings, E.Cause(err, entry).Error())
+				continue
+			}
+			plain.Rules = append(plain.Rules, headlessRule(headless))
+		}
+	default:
+		return plain, nil, E.New("unsupported behavior: ", behavior)
+	}
+	return plain, warnings, nil
+}
+
+func headlessRule(rule option.DefaultHeadlessRule) option.HeadlessRule {
+	return option.HeadlessRule{
+		Type:           C.RuleTypeDefault,
+		DefaultOptions: rule,
+	}
+}
+
+func regexpQuote(domain string) string {
+	return strings.ReplaceAll(domain, ".", `\.`)
+}
+
+// toHeadless moves conditions supported by rule set to headless rule.
+func toHeadless(rule option.RawDefaultRule) (option.DefaultHeadlessRule, error) {
+	headless := option.DefaultHeadlessRule{
+		Network:          rule.Network,
+		Domain:           rule.Domain,
+		DomainSuffix:     rule.DomainSuffix,
+		DomainKeyword:    rule.DomainKeyword,
+		DomainRegex:      rule.DomainRegex,
+		SourceIPCIDR:     rule.SourceIPCIDR,
+		IPCIDR:           rule.IPCIDR,
+		SourcePort:       rule.SourcePort,
+		SourcePortRange:  rule.SourcePortRange,
+		Port:             rule.Port,
+		PortRange:        rule.PortRange,
+		ProcessName:      rule.ProcessName,
+		ProcessPath:      rule.ProcessPath,
+		ProcessPathRegex: rule.ProcessPathRegex,
+		PackageName:      rule.PackageName,
+	}
+	rule.Network = nil
+	rule.Domain = nil
+	rule.DomainSuffix = nil
+	rule.DomainKeyword = nil
+	rule.DomainRegex = nil
+	rule.SourceIPCIDR = nil
+	rule.IPCIDR = nil
+	rule.SourcePort = nil
+	rule.SourcePortRange = nil
+	rule.Port = nil
+	rule.PortRange = nil
+	rule.ProcessName = nil
+	rule.ProcessPath = nil
+	rule.ProcessPathRegex = nil
+	rule.PackageName = nil
+	if !reflect.ValueOf(rule).IsZero() {
+		return headless, E.New("not supported in rule set")
+	}
+	return headless, nil
+}
+
+func (c *converter) route() *option.RouteOptions {
+	if c.options.Route == nil {
+		c.options.Route = &option.RouteOptions{}
+	}
+	return c.options.Route
+}
+
+func (c *converter) convertRules(rules []string) {
+	geoRuleSets := new(linkedhashmap.Map[string, string])
+	for i, line := range rules {
+		fields := splitRule(line)
+		kind := strings.ToUpper(fields[0])
+		if kind == "MATCH" || kind == "FINAL" {
+			if len(fields) < 2 {
+				c.warn("rule ", line, ": missing target")
+				continue
+			}
+			final, ok := c.reference(fields[1])
+			if !ok {
+				c.warn("rule ", line, ": unknown target: ", fields[1])
+				continue
+			}
+			c.route().Final = final
+			if i+1 < len(rules) {
+				c.warn("ignored unreachable rules after ", line, ": ", len(rules)-i-1)
+			}
+			break
+		}
+		rule, err := c.convertRule(fields, geoRuleSets)
+		if err != nil {
+			c.warn("rule ", line, ": ", err)
+			continue
+		}
+		c.route().Rules = append(c.route().Rules, rule)
+	}
+	for _, entry := range geoRuleSets.Entries() {
+		c.route().RuleSet = append(c.route().RuleSet, option.RuleSet{
+			Type:   C.RuleSetTypeRemote,
+			Tag:    entry.Key,
+			Format: C.RuleSetFormatBinary,
+			RemoteOptions: option.RemoteRuleSet{
+				URL: entry.Value,
+			},
+		})
+	}
+}
+
+func (c *converter) convertRule(fields []string, geoRuleSets *linkedhashmap.Map[string, string]) (option.Rule, error) {
+	if len(fields) < 3 {
+		return option.Rule{}, E.New("missing target")
+	}
+	action, err := c.ruleAction(fields[2])
+	if err != nil {
+		return option.Rule{}, err
+	}
+	rule, needResolve, err := c.parseCondition(fields, geoRuleSets)
+	if err != nil {
+		return option.Rule{}, err
+	}
+	if needResolve && !c.resolved {
+		// Clash resolves domain for IP rules without no-resolve.
+		c.resolved = true
+		c.route().Rules = append(c.route().Rules, option.Rule{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultRule{
+				RuleAction: option.RuleAction{Action: C.RuleActionTypeResolve},
+			},
+		})
+	}
+	if rule.Type == C.RuleTypeLogical {
+		rule.LogicalOptions.RuleAction = action
+	} else {
+		rule.DefaultOptions.RuleAction = action
+	}
+	return rule, nil
+}
+
+func (c *converter) ruleAction(target string) (option.RuleAction, error) {
+	switch target {
+	case PolicyReject:
+		return option.RuleAction{Action: C.RuleActionTypeReject}, nil
+	case PolicyRejectDrop:
+		return option.RuleAction{
+			Action:        C.RuleActionTypeReject,
+			RejectOptions: option.RejectActionOptions{Method: C.RuleActionRejectMethodDrop},
+		}, nil
+	case PolicyPass:
+		return option.RuleAction{}, E.New(PolicyPass, " is not supported")
+	}
+	outbound, ok := c.reference(target)
+	if !ok {
+		return option.RuleAction{}, E.New("unknown target: ", target)
+	}
+	return option.RuleAction{
+		Action:       C.RuleActionTypeRoute,
+		RouteOptions: option.RouteActionOptions{Outbound: outbound},
+	}, nil
+}
+
+// parseCondition parses rule without target.
+// fields is like `DOMAIN,example.com` or `AND,((DOMAIN,example.com),(NETWORK,UDP))`.
+func (c *converter) parseCondition(fields []string, geoRuleSets *linkedhashmap.Map[string, string]) (rule option.Rule, needResolve bool, err error) {
+	kind := strings.ToUpper(fields[0])
+	switch kind {
+	case "AND", "OR", "NOT":
+		if len(fields) < 2 {
+			return rule, false, E.New("missing sub rules")
+		}
+		payload, ok := trimParentheses(fields[1])
+		if !ok {
+			return rule, false, E.New("bad sub rules: ", fields[1])
+		}
+		logical := option.LogicalRule{}
+		logical.Mode = C.LogicalTypeAnd
+		if kind == "OR" {
+			logical.Mode = C.LogicalTypeOr
+		}
+		for _, sub := range splitRule(payload) {
+			sub, ok = trimParentheses(sub)
+			if !ok {
+				return rule, false, E.New("bad sub rule: ", sub)
+			}
+			subRule, subResolve, err := c.parseCondition(splitRule(sub), geoRuleSets)
+			if err != nil {
+				return rule, false, err
+			}
+			needResolve = needResolve || subResolve
+			logical.Rules = append(logical.Rules, subRule)
+		}
+		if kind == "NOT" {
+			if len(logical.Rules) != 1 {
+				return rule, false, E.New("NOT requires exactly one sub rule")
+			}
+			logical.Invert = true
+		}
+		return option.Rule{Type: C.RuleTypeLogical, LogicalOptions: logical}, needResolve, nil
+	}
+	var params []string
+	if len(fields) > 3 {
+		params = fields[3:]
+	}
+	var defaultRule option.DefaultRule
+	isIP, err := applyCondition(&defaultRule.RawDefaultRule, kind, ruleValue(fields), geoRuleSets)
+	if err != nil {
+		return rule, false, err
+	}
+	if kind == "RULE-SET" {
+		behavior, loaded := c.providers[ruleValue(fields)]
+		if !loaded {
+			return rule, false, E.New("unknown rule-provider: ", ruleValue(fields))
+		}
+		isIP = behavior == "ipcidr"
+	}
+	needResolve = isIP && !slices.Contains(params, "no-resolve")
+	return option.Rule{Type: C.RuleTypeDefault, DefaultOptions: defaultRule}, needResolve, nil
+}
+
+// applyCondition converts Clash rule condition to fields of rule.
+// It returns whether the condition matches destination IP.
+func applyCondition(rule *option.RawDefaultRule, kind, value string, geoRuleSets *linkedhashmap.Map[string, string]) (isIP bool, err error) {
+	if value == "" {
+		return false, E.New("missing value")
+	}
+	switch strings.ToUpper(kind) {
+	case "DOMAIN":
+		rule.Domain = append(rule.Domain, value)
+	case "DOMAIN-SUFFIX":
+		rule.DomainSuffix = append(rule.DomainSuffix, value)
+	case "DOMAIN-KEYWORD":
+		rule.DomainKeyword = append(rule.DomainKeyword, value)
+	case "DOMAIN-REGEX":
+		rule.DomainRegex = append(rule.DomainRegex, value)
+	case "GEOSITE":
+		if geoRuleSets == nil {
+			return false, E.New("GEOSITE is not supported in rule-provider")
+		}
+		tag := "geosite-" + strings.ToLower(value)
+		geoRuleSets.Put(tag, GeositeRuleSetURL+tag+".srs")
+		rule.RuleSet = append(rule.RuleSet, tag)
+	case "GEOIP", "SRC-GEOIP":
+		isSource := strings.ToUpper(kind) == "SRC-GEOIP"
+		if code := strings.ToLower(value); code == "lan" || code == "private" {
+			if isSource {
+				rule.SourceIPIsPrivate = true
+			} else {
+				rule.IPIsPrivate = true
+			}
+			return !isSource, nil
+		}
+		if geoRuleSets == nil {
+			return false, E.New(kind, " is not supported in rule-provider")
+		}
+		tag := "geoip-" + strings.ToLower(value)
+		geoRuleSets.Put(tag, GeoIPRuleSetURL+tag+".srs")
+		rule.RuleSet = append(rule.RuleSet, tag)
+		rule.RuleSetIPCIDRMatchSource = isSource
+		return !isSource, nil
+	case "IP-CIDR", "IP-CIDR6":
+		rule.IPCIDR = append(rule.IPCIDR, value)
+		return true, nil
+	case "SRC-IP-CIDR":
+		rule.SourceIPCIDR = append(rule.SourceIPCIDR, value)
+	case "DST-PORT":
+		rule.Port, rule.PortRange, err = parsePorts(value)
+	case "SRC-PORT":
+		rule.SourcePort, rule.SourcePortRange, err = parsePorts(value)
+	case "NETWORK":
+		rule.Network = append(rule.Network, strings.ToLower(value))
+	case "PROCESS-NAME":
+		rule.ProcessName = append(rule.ProcessName, value)
+	case "PROCESS-PATH":
+		rule.ProcessPath = append(rule.ProcessPath, value)
+	case "PROCESS-PATH-REGEX":
+		rule.ProcessPathRegex = append(rule.ProcessPathRegex, value)
+	case "UID":
+		var uid int64
+		uid, err = strconv.ParseInt(value, 10, 32)
+		rule.UserID = append(rule.UserID, int32(uid))
+	case "RULE-SET":
+		if geoRuleSets == nil {
+			return false, E.New("RULE-SET is not supported in rule-provider")
+		}
+		rule.RuleSet = append(rule.RuleSet, value)
+	default:
+		return false, E.New("unsupported rule type: ", kind)
+	}
+	return false, err
+}
+
+// parsePorts parses `80`, `8000-9000` or `80/443` of Clash.
+func parsePorts(value string) (badoption.Listable[uint16], badoption.Listable[string], error) {
+	var (
+		ports  badoption.Listable[uint16]
+		ranges badoption.Listable[string]
+	)
+	for _, field := range strings.Split(value, "/") {
+		start, end, isRange := strings.Cut(strings.TrimSpace(field), "-")
+		if isRange {
+			ranges = append(ranges, start+":"+end)
+			continue
+		}
+		port, err := strconv.ParseUint(start, 10, 16)
+		if err != nil {
+			return nil, nil, E.Cause(err, "parse port")
+		}
+		ports = append(ports, uint16(port))
+	}
+	return ports, ranges, nil
+}
+
+func ruleValue(fields []string) string {
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// splitRule splits rule by top level commas.
+func splitRule(rule string) []string {
+	var (
+		fields []string
+		depth  int
+		start  int
+	)
+	for i, r := range rule {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				fields = append(fields, strings.TrimSpace(rule[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, strings.TrimSpace(rule[start:]))
+}
+
+func trimParentheses(s string) (string, bool) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return s, false
+	}
+	return s[1 : len(s)-1], true
+}
@@ -95,6 +95,7 @@ var boxList = []any{
 	option.WireGuardPeer{},
 	// option.V2RayTransportOptions{},
 	option.DomainResolveOptions{},
+	pluginoption.FilterListOptions{},
 
 	// MITM
 	// option.MITMOptions{},
@@ -143,6 +144,9 @@ var outboundList = []any{
 	option.ShadowTLSOutboundOptions{},
 	option.SelectorOutboundOptions{},
 	option.URLTestOutboundOptions{},
+	pluginoption.FallbackOutboundOptions{},
+	pluginoption.LoadBalanceOutboundOptions{},
+	pluginoption.ChainOutboundOptions{},
 	option.SOCKSOutboundOptions{},
 	// option.HTTPOutboundOptions{},
 	pluginoption.HTTPOutboundOptions{},
@@ -171,6 +175,11 @@ var newDNSServerList = []any{
 	pluginoption.RemoteTCPDNSServerOptions{},
 	// option.RemoteTLSDNSServerOptions{},
 	pluginoption.RemoteTLSDNSServerOptions{},
-	option.RemoteHTTPSDNSServerOptions{},
+	// option.RemoteHTTPSDNSServerOptions{},
+	pluginoption.RemoteHTTPSDNSServerOptions{},
+	pluginoption.RemoteQUICDNSServerOptions{},
+	pluginoption.DNSCryptDNSServerOptions{},
+	pluginoption.ParallelDNSServerOptions{},
+	pluginoption.FilterDNSServerOptions{},
 	option.FakeIPDNSServerOptions{},
 }
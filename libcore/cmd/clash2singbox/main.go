@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/clash"
+	"libcore/distro"
+)
+
+var (
+	input  = flag.String("i", "", "Input Clash profile, stdin if empty.")
+	output = flag.String("o", "", "Output sing-box configuration, stdout if empty.")
+)
+
+func main() {
+	flag.Parse()
+
+	var (
+		content []byte
+		err     error
+	)
+	if *input == "" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := clash.Convert(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range result.Warnings {
+		log.Warn(warning)
+	}
+
+	writer := os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	ctx := box.Context(
+		context.Background(),
+		distro.InboundRegistry(),
+		distro.OutboundRegistry(),
+		distro.EndpointRegistry(),
+		distro.DNSTransportRegistry(),
+		distro.ServiceRegistry(),
+	)
+	encoder := json.NewEncoderContext(ctx, writer)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(&result.Options)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+
+	"libcore/dnsfilter"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+)
+
+func loadFilter(path string) ([]dnsfilter.Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return dnsfilter.Parse(file)
+}
+
+// compileFilter converts block list rules to rule set matching blocked domains.
+//
+// Matches (block && !exception && !important exception) || (important block && !important exception).
+func compileFilter(rules []dnsfilter.Rule) option.PlainRuleSet {
+	var block, exception, importantBlock, importantException option.DefaultHeadlessRule
+	for _, rule := range rules {
+		var target *option.DefaultHeadlessRule
+		switch {
+		case rule.Important && rule.Exception:
+			target = &importantException
+		case rule.Important:
+			target = &importantBlock
+		case rule.Exception:
+			target = &exception
+		default:
+			target = &block
+		}
+		if rule.Suffix {
+			// Domain suffix without leading dot also matches itself.
+			target.DomainSuffix = append(target.DomainSuffix, rule.Domain)
+		} else {
+			target.Domain = append(target.Domain, rule.Domain)
+		}
+	}
+	var plainRuleSet option.PlainRuleSet
+	if rule, loaded := compileBlock(block, exception, importantException); loaded {
+		plainRuleSet.Rules = append(plainRuleSet.Rules, rule)
+	}
+	if rule, loaded := compileBlock(importantBlock, importantException); loaded {
+		plainRuleSet.Rules = append(plainRuleSet.Rules, rule)
+	}
+	return plainRuleSet
+}
+
+// compileBlock matches block && !exception for each of exceptions.
+func compileBlock(block option.DefaultHeadlessRule, exceptions ...option.DefaultHeadlessRule) (option.HeadlessRule, bool) {
+	if !block.IsValid() {
+		return option.HeadlessRule{}, false
+	}
+	block.Domain = common.Uniq(block.Domain)
+	block.DomainSuffix = common.Uniq(block.DomainSuffix)
+	compiled := []option.HeadlessRule{{Type: C.RuleTypeDefault, DefaultOptions: block}}
+	for _, exceptionRule := range exceptions {
+		if !exceptionRule.IsValid() {
+			continue
+		}
+		exceptionRule.Invert = true
+		compiled = append(compiled, option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: exceptionRule})
+	}
+	if len(compiled) == 1 {
+		return compiled[0], true
+	}
+	return option.HeadlessRule{
+		Type: C.RuleTypeLogical,
+		LogicalOptions: option.LogicalHeadlessRule{
+			Mode:  C.LogicalTypeAnd,
+			Rules: compiled,
+		},
+	}, true
+}
@@ -24,6 +24,9 @@ var (
 
 	geositeOutput = flag.String("so", "geosite.tar.zst", "geosite tar.zst output")
 	geoipOutput   = flag.String("io", "geoip.tar.zst", "geoip tar.zst output")
+
+	filterInput  = flag.String("filter", "", "hosts or adblock list to compile")
+	filterOutput = flag.String("fo", "filter.srs", "filter srs output")
 )
 
 const (
@@ -176,6 +179,27 @@ func main() {
 	}
 
 	log.Trace("Buf length: ", buf.Len(), " cap: ", buf.Cap())
+
+	if *filterInput != "" {
+		rules, err := loadFilter(*filterInput)
+		if err != nil {
+			log.Fatal(err)
+		}
+		plainRuleSet := compileFilter(rules)
+		if len(plainRuleSet.Rules) == 0 {
+			log.Fatal("no blocking rule in ", *filterInput)
+		}
+		filterFile, err := os.Create(*filterOutput)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer filterFile.Close()
+		err = srs.Write(filterFile, plainRuleSet, C.RuleSetVersionCurrent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Info("compiled ", len(rules), " rules to ", *filterOutput)
+	}
 }
 
 func fetch(repo, tag, name string) ([]byte, error) {
@@ -8,12 +8,14 @@ import (
 	"strings"
 
 	"libcore/combinedapi/trafficcontrol"
+	"libcore/metered"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/urltest"
 	"github.com/sagernet/sing-box/experimental"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/protocol/group"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	N "github.com/sagernet/sing/common/network"
@@ -36,6 +38,9 @@ type CombinedAPI struct {
 	modeList       []string
 	modeUpdateHook *observable.Subscriber[struct{}]
 	urlTestHistory adapter.URLTestHistoryStorage
+	// groupHistory is urlTestHistory seen by URL test groups, which is changed by metered policy.
+	groupHistory adapter.URLTestHistoryStorage
+	metered      *metered.Controller
 }
 
 func New(ctx context.Context, logFactory log.ObservableFactory, options option.ClashAPIOptions) (adapter.ClashServer, error) {
@@ -55,6 +60,12 @@ func New(ctx context.Context, logFactory log.ObservableFactory, options option.C
 	if c.urlTestHistory == nil {
 		c.urlTestHistory = urltest.NewHistoryStorage()
 	}
+	c.groupHistory = c.urlTestHistory
+	c.metered = service.PtrFromContext[metered.Controller](ctx)
+	if c.metered != nil {
+		c.groupHistory = c.metered.WrapHistory(c.urlTestHistory)
+	}
+	c.trafficManager.SetUnhealthyHandler(c.outboundUnhealthy)
 	var defaultMode string
 	if options.DefaultMode == "" {
 		defaultMode = ModeRule
@@ -99,11 +110,37 @@ func (c *CombinedAPI) Close() error {
 	return common.Close(c.trafficManager, c.urlTestHistory)
 }
 
+// outboundUnhealthy removes the URL test result of the outbound failed repeatedly,
+// and makes groups containing it select again without waiting for the next interval.
+func (c *CombinedAPI) outboundUnhealthy(tag string) {
+	c.logger.Warn("outbound ", tag, " failed repeatedly, marked as unhealthy")
+	c.urlTestHistory.DeleteURLTestHistory(tag)
+	for _, outbound := range c.outbound.Outbounds() {
+		urlTestGroup, isURLTestGroup := outbound.(adapter.URLTestGroup)
+		if !isURLTestGroup {
+			continue
+		}
+		if !common.Any(urlTestGroup.All(), func(it string) bool {
+			detour, loaded := c.outbound.Outbound(it)
+			return loaded && group.RealTag(detour) == tag
+		}) {
+			continue
+		}
+		_, _ = urlTestGroup.URLTest(c.ctx)
+	}
+}
+
 func (c *CombinedAPI) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	if c.metered != nil {
+		conn = c.metered.NewConn(ctx, conn)
+	}
 	return trafficcontrol.NewTCPTracker(conn, c.trafficManager, metadata, c.outbound, matchedRule, matchOutbound)
 }
 
 func (c *CombinedAPI) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	if c.metered != nil {
+		conn = c.metered.NewPacketConn(ctx, conn)
+	}
 	return trafficcontrol.NewUDPTracker(conn, c.trafficManager, metadata, c.outbound, matchedRule, matchOutbound)
 }
 
@@ -149,7 +186,14 @@ func (c *CombinedAPI) TrafficManager() *trafficcontrol.Manager {
 	return c.trafficManager
 }
 
+// HistoryStorage returns the history for URL test groups, where tests may look fresh by metered policy.
+// Use URLTestHistory for the real results.
 func (c *CombinedAPI) HistoryStorage() adapter.URLTestHistoryStorage {
+	return c.groupHistory
+}
+
+// URLTestHistory returns the history with real test time.
+func (c *CombinedAPI) URLTestHistory() adapter.URLTestHistoryStorage {
 	return c.urlTestHistory
 }
 
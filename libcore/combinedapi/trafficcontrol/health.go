@@ -0,0 +1,75 @@
+package trafficcontrol
+
+import (
+	"sync"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+)
+
+const (
+	// unhealthyFailures is the count of consecutive failures to consider an outbound unhealthy.
+	unhealthyFailures = 3
+	// healthScoreWeight is the weight of the latest result in health score.
+	healthScoreWeight = 0.2
+)
+
+// OutboundHealth is the health of an outbound learned from real connections.
+type OutboundHealth struct {
+	// Score is the moving average of success rate from 0 to 1.
+	Score float64
+	// Failures is the count of consecutive failures.
+	Failures    int
+	LastFailure time.Time
+}
+
+type outboundHealth struct {
+	access sync.Mutex
+	OutboundHealth
+}
+
+// UnhealthyHandler is called when an outbound failed repeatedly.
+type UnhealthyHandler func(tag string)
+
+func (m *Manager) SetUnhealthyHandler(handler UnhealthyHandler) {
+	m.unhealthyHandler = handler
+}
+
+// OutboundHealth returns the health of the real outbound tag.
+func (m *Manager) OutboundHealth(tag string) (OutboundHealth, bool) {
+	health, loaded := m.outboundHealth.Load(tag)
+	if !loaded {
+		return OutboundHealth{}, false
+	}
+	health.access.Lock()
+	defer health.access.Unlock()
+	return health.OutboundHealth, true
+}
+
+// reportHealth records result of a connection through the real outbound in metadata.
+func (m *Manager) reportHealth(metadata *TrackerMetadata, success bool) {
+	// Failures of these are expected or not about the proxy.
+	if metadata.Outbound == "" || common.Contains([]string{C.TypeDirect, C.TypeBlock, C.TypeDNS}, metadata.OutboundType) {
+		return
+	}
+	health, _ := m.outboundHealth.LoadOrStore(metadata.Outbound, &outboundHealth{
+		OutboundHealth: OutboundHealth{Score: 1},
+	})
+	health.access.Lock()
+	var result float64
+	if success {
+		result = 1
+		health.Failures = 0
+	} else {
+		health.Failures++
+		health.LastFailure = time.Now()
+	}
+	health.Score = health.Score*(1-healthScoreWeight) + result*healthScoreWeight
+	// Only notify once for each failure streak.
+	becameUnhealthy := health.Failures == unhealthyFailures
+	health.access.Unlock()
+	if becameUnhealthy && m.unhealthyHandler != nil {
+		go m.unhealthyHandler(metadata.Outbound)
+	}
+}
@@ -0,0 +1,85 @@
+package trafficcontrol
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common/bufio"
+)
+
+func Test_reportHealth(t *testing.T) {
+	manager := NewManager()
+	unhealthy := make(chan string, 2)
+	manager.SetUnhealthyHandler(func(tag string) {
+		unhealthy <- tag
+	})
+	proxy := &TrackerMetadata{Outbound: "proxy", OutboundType: C.TypeVMess}
+	block := &TrackerMetadata{Outbound: "block", OutboundType: C.TypeBlock}
+	direct := &TrackerMetadata{Outbound: "direct", OutboundType: C.TypeDirect}
+
+	for _, success := range []bool{false, false, true, false, false, false, false} {
+		manager.reportHealth(proxy, success)
+		manager.reportHealth(block, false)
+		manager.reportHealth(direct, false)
+	}
+	select {
+	case tag := <-unhealthy:
+		if tag != "proxy" {
+			t.Errorf("expected proxy, got %s", tag)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+	select {
+	case tag := <-unhealthy:
+		t.Errorf("handler called again for %s", tag)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	health, loaded := manager.OutboundHealth("proxy")
+	if !loaded || health.Failures != 4 || health.Score >= 0.5 {
+		t.Errorf("unexpected health: %+v", health)
+	}
+	for _, tag := range []string{"block", "direct"} {
+		if _, loaded = manager.OutboundHealth(tag); loaded {
+			t.Errorf("%s should not be scored", tag)
+		}
+	}
+}
+
+func Test_TCPConnCloseHealth(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		upload   int64
+		download int64
+		lifetime time.Duration
+		failures int
+		loaded   bool
+	}{
+		{name: "response", upload: 1, download: 1, loaded: true},
+		{name: "no response", upload: 1, lifetime: zeroByteCloseLifetime, failures: 1, loaded: true},
+		{name: "cancelled", upload: 1},
+		{name: "unused", lifetime: zeroByteCloseLifetime},
+	} {
+		manager := NewManager()
+		conn, _ := net.Pipe()
+		metadata := TrackerMetadata{
+			Outbound:     "proxy",
+			OutboundType: C.TypeVMess,
+			CreatedAt:    time.Now().Add(-testCase.lifetime),
+			Upload:       new(atomic.Int64),
+			Download:     new(atomic.Int64),
+		}
+		metadata.Upload.Store(testCase.upload)
+		metadata.Download.Store(testCase.download)
+		tracker := &TCPConn{ExtendedConn: bufio.NewExtendedConn(conn), metadata: metadata, manager: manager}
+		tracker.Close()
+		health, loaded := manager.OutboundHealth("proxy")
+		if loaded != testCase.loaded || health.Failures != testCase.failures {
+			t.Errorf("%s: unexpected health: %v %+v", testCase.name, loaded, health)
+		}
+	}
+}
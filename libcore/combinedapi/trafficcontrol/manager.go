@@ -37,6 +37,8 @@ type Manager struct {
 
 	connections             compatible.Map[uuid.UUID, Tracker]
 	outboundCounters        compatible.Map[string, *outboundCounter]
+	outboundHealth          compatible.Map[string, *outboundHealth]
+	unhealthyHandler        UnhealthyHandler
 	closedConnectionsAccess sync.RWMutex
 	closedConnections       list.List[TrackerMetadata]
 
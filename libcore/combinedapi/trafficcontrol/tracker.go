@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/bufio"
 	N "github.com/sagernet/sing/common/network"
 
@@ -41,10 +42,15 @@ type Tracker interface {
 	Close() error
 }
 
+// zeroByteCloseLifetime is the minimum lifetime for a TCP connection closed without any response to be a failure,
+// as clients may cancel requests and servers may close idle connections shortly.
+const zeroByteCloseLifetime = 5 * time.Second
+
 type TCPConn struct {
 	N.ExtendedConn
 	metadata TrackerMetadata
 	manager  *Manager
+	reported atomic.Bool
 }
 
 func (t *TCPConn) Metadata() TrackerMetadata {
@@ -52,10 +58,26 @@ func (t *TCPConn) Metadata() TrackerMetadata {
 }
 
 func (t *TCPConn) Close() error {
+	if !t.reported.Swap(true) {
+		if t.metadata.Download.Load() > 0 {
+			t.manager.reportHealth(&t.metadata, true)
+		} else if t.metadata.Upload.Load() > 0 && time.Since(t.metadata.CreatedAt) >= zeroByteCloseLifetime {
+			// No response for a long time means the proxy failed to relay.
+			t.manager.reportHealth(&t.metadata, false)
+		}
+	}
 	t.manager.Leave(t)
 	return t.ExtendedConn.Close()
 }
 
+// HandshakeFailure is called when failed to dial through the outbound.
+func (t *TCPConn) HandshakeFailure(err error) error {
+	if !t.reported.Swap(true) {
+		t.manager.reportHealth(&t.metadata, false)
+	}
+	return reportHandshakeFailure(t.ExtendedConn, err)
+}
+
 func (t *TCPConn) Upstream() any {
 	return t.ExtendedConn
 }
@@ -68,35 +90,69 @@ func (t *TCPConn) WriterReplaceable() bool {
 	return true
 }
 
-func NewTCPTracker(conn net.Conn, manager *Manager, metadata adapter.InboundContext, outboundManager adapter.OutboundManager, matchRule adapter.Rule, matchOutbound adapter.Outbound) *TCPConn {
-	id, _ := uuid.NewV4()
-	var (
-		chain        []string
-		next         string
-		outbound     string
-		outboundType string
-		counter      *outboundCounter
-	)
-	if matchOutbound != nil {
-		next = matchOutbound.Tag()
-		counter = manager.loadOrCreateCounter(next)
-	} else {
-		next = outboundManager.Default().Tag()
+// reportHandshakeFailure passes the failure to the inbound connection,
+// as trackers hide it from N.CloseOnHandshakeFailure.
+func reportHandshakeFailure(upstream any, err error) error {
+	if _, isHandshakeConn := common.Cast[N.HandshakeFailure](upstream); isHandshakeConn {
+		return N.ReportHandshakeFailure(upstream, err)
+	}
+	if tcpConn, isTCPConn := common.Cast[interface {
+		SetLinger(sec int) error
+	}](upstream); isTCPConn {
+		_ = tcpConn.SetLinger(0)
 	}
+	return nil
+}
+
+// chainOutbound is implemented by outbounds that relay through other outbounds.
+type chainOutbound interface {
+	// Hops returns tags of the real outbounds in order.
+	Hops() []string
+}
+
+// resolveChain follows the selected outbounds of groups and the hops of chains from next.
+func resolveChain(outboundManager adapter.OutboundManager, next string) (chain []string, outbound string, outboundType string) {
 	for {
 		detour, loaded := outboundManager.Outbound(next)
 		if !loaded {
-			break
+			return
 		}
 		chain = append(chain, next)
 		outbound = detour.Tag()
 		outboundType = detour.Type()
+		if hops, isChain := detour.(chainOutbound); isChain {
+			for _, hop := range hops.Hops() {
+				detour, loaded = outboundManager.Outbound(hop)
+				if !loaded {
+					return
+				}
+				chain = append(chain, hop)
+				outbound = detour.Tag()
+				outboundType = detour.Type()
+			}
+			return
+		}
 		group, isGroup := detour.(adapter.OutboundGroup)
 		if !isGroup {
-			break
+			return
 		}
 		next = group.Now()
 	}
+}
+
+func NewTCPTracker(conn net.Conn, manager *Manager, metadata adapter.InboundContext, outboundManager adapter.OutboundManager, matchRule adapter.Rule, matchOutbound adapter.Outbound) *TCPConn {
+	id, _ := uuid.NewV4()
+	var (
+		next    string
+		counter *outboundCounter
+	)
+	if matchOutbound != nil {
+		next = matchOutbound.Tag()
+		counter = manager.loadOrCreateCounter(next)
+	} else {
+		next = outboundManager.Default().Tag()
+	}
+	chain, outbound, outboundType := resolveChain(outboundManager, next)
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
 	tracker := &TCPConn{
@@ -134,6 +190,7 @@ type UDPConn struct {
 	N.PacketConn `json:"-"`
 	metadata     TrackerMetadata
 	manager      *Manager
+	reported     atomic.Bool
 }
 
 func (u *UDPConn) Metadata() TrackerMetadata {
@@ -141,10 +198,22 @@ func (u *UDPConn) Metadata() TrackerMetadata {
 }
 
 func (u *UDPConn) Close() error {
+	// No response of UDP is common, so only reports success.
+	if !u.reported.Swap(true) && u.metadata.Download.Load() > 0 {
+		u.manager.reportHealth(&u.metadata, true)
+	}
 	u.manager.Leave(u)
 	return u.PacketConn.Close()
 }
 
+// HandshakeFailure is called when failed to dial through the outbound.
+func (u *UDPConn) HandshakeFailure(err error) error {
+	if !u.reported.Swap(true) {
+		u.manager.reportHealth(&u.metadata, false)
+	}
+	return reportHandshakeFailure(u.PacketConn, err)
+}
+
 func (u *UDPConn) Upstream() any {
 	return u.PacketConn
 }
@@ -160,11 +229,8 @@ func (u *UDPConn) WriterReplaceable() bool {
 func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata adapter.InboundContext, outboundManager adapter.OutboundManager, matchRule adapter.Rule, matchOutbound adapter.Outbound) *UDPConn {
 	id, _ := uuid.NewV4()
 	var (
-		chain        []string
-		next         string
-		outbound     string
-		outboundType string
-		counter      *outboundCounter
+		next    string
+		counter *outboundCounter
 	)
 	if matchOutbound != nil {
 		next = matchOutbound.Tag()
@@ -172,20 +238,7 @@ func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata adapter.Inbound
 	} else {
 		next = outboundManager.Default().Tag()
 	}
-	for {
-		detour, loaded := outboundManager.Outbound(next)
-		if !loaded {
-			break
-		}
-		chain = append(chain, next)
-		outbound = detour.Tag()
-		outboundType = detour.Type()
-		group, isGroup := detour.(adapter.OutboundGroup)
-		if !isGroup {
-			break
-		}
-		next = group.Now()
-	}
+	chain, outbound, outboundType := resolveChain(outboundManager, next)
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
 	trackerConn := &UDPConn{
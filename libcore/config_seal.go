@@ -0,0 +1,90 @@
+package libcore
+
+import (
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/redact"
+	"libcore/sealedconfig"
+)
+
+// secretRedactor hides secrets of the running instance in logs, and is reset when it is closed.
+var secretRedactor redact.Redactor
+
+// ConfigSealKeyProvider provides the key of sealed configs.
+type ConfigSealKeyProvider interface {
+	// ConfigSealKey returns 32 bytes key from platform keystore.
+	ConfigSealKey() ([]byte, error)
+}
+
+var configSealKeyProvider common.TypedValue[ConfigSealKeyProvider]
+
+// SetConfigSealKeyProvider sets provider for SealConfig and opening sealed configs.
+// It is optional for platforms not using sealed configs.
+func SetConfigSealKeyProvider(provider ConfigSealKeyProvider) {
+	configSealKeyProvider.Store(provider)
+}
+
+func configSealKey() ([]byte, error) {
+	provider := configSealKeyProvider.Load()
+	if provider == nil {
+		return nil, E.New("config seal key provider is not set")
+	}
+	key, err := provider.ConfigSealKey()
+	if err != nil {
+		return nil, E.Cause(err, "get seal key")
+	}
+	return key, nil
+}
+
+// SealConfig encrypts config by key of ConfigSealKeyProvider,
+// and the result can be passed to NewInstance directly.
+func SealConfig(config string) (string, error) {
+	// Validate before sealing, as sealed config can not be checked.
+	_, err := parseConfig(baseContext(nil), config)
+	if err != nil {
+		return "", err
+	}
+	key, err := configSealKey()
+	if err != nil {
+		return "", err
+	}
+	return sealedconfig.Seal(key, []byte(config))
+}
+
+// IsSealedConfig reports whether config is the result of SealConfig.
+func IsSealedConfig(config string) bool {
+	return sealedconfig.IsSealed(config)
+}
+
+// openConfig decrypts config if it is sealed.
+func openConfig(config string) (string, error) {
+	if !sealedconfig.IsSealed(config) {
+		return config, nil
+	}
+	key, err := configSealKey()
+	if err != nil {
+		return "", err
+	}
+	content, err := sealedconfig.Open(key, config)
+	if err != nil {
+		return "", E.Cause(err, "open sealed config")
+	}
+	return string(content), nil
+}
+
+// RedactConfig returns config with secrets replaced, which is safe for diagnostic exports.
+func RedactConfig(config string) (string, error) {
+	ctx := baseContext(nil)
+	options, err := parseConfig(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	redact.Options(&options)
+	content, err := json.MarshalContext(ctx, &options)
+	if err != nil {
+		return "", E.Cause(err, "encode options")
+	}
+	return string(content), nil
+}
@@ -0,0 +1,98 @@
+package libcore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type testSealKeyProvider []byte
+
+func (p testSealKeyProvider) ConfigSealKey() ([]byte, error) {
+	return p, nil
+}
+
+func Test_SealConfig(t *testing.T) {
+	defer SetConfigSealKeyProvider(nil)
+	config := `{"outbounds": [{"type": "direct", "tag": "direct"}]}`
+	_, err := SealConfig(config)
+	if err == nil {
+		t.Error("expected error without provider")
+	}
+	SetConfigSealKeyProvider(testSealKeyProvider(bytes.Repeat([]byte{1}, 32)))
+	sealed, err := SealConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealedConfig(sealed) {
+		t.Error("config is not sealed")
+	}
+	opened, err := openConfig(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != config {
+		t.Errorf("unexpected opened config: %s", opened)
+	}
+}
+
+func Test_RedactConfig(t *testing.T) {
+	config := `{
+  "outbounds": [
+    {"type": "vless", "tag": "proxy", "server": "example.com", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811",
+      "tls": {"enabled": true, "reality": {"enabled": true, "public_key": "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", "short_id": "0123abcd"}}},
+    {"type": "shadowsocks", "tag": "ss", "server": "example.org", "server_port": 8388, "method": "aes-128-gcm", "password": "hunter2hunter2"}
+  ]
+}`
+	redacted, err := RedactConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"b831381d-6324-4d53-ad4f-8cda48b30811", "0123abcd", "hunter2hunter2"} {
+		if strings.Contains(redacted, secret) {
+			t.Errorf("secret %s is not redacted", secret)
+		}
+	}
+	for _, kept := range []string{"example.com", "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", "aes-128-gcm"} {
+		if !strings.Contains(redacted, kept) {
+			t.Errorf("%s should be kept", kept)
+		}
+	}
+}
+
+type testRedactPlatform struct {
+	PlatformInterface
+}
+
+func (testRedactPlatform) HasCoreFunction() bool {
+	return false
+}
+
+func (testRedactPlatform) UseProcFS() bool {
+	return false
+}
+
+func Test_NewBoxInstanceRedactSealed(t *testing.T) {
+	defer SetConfigSealKeyProvider(nil)
+	defer secretRedactor.Reset()
+	SetConfigSealKeyProvider(testSealKeyProvider(bytes.Repeat([]byte{1}, 32)))
+	sealed, err := SealConfig(`{
+  "outbounds": [
+    {"type": "shadowsocks", "tag": "ss", "server": "127.0.0.1", "server_port": 8388, "method": "aes-128-gcm", "password": "hunter2hunter2"},
+    {"type": "vmess", "tag": "vmess", "server": "127.0.0.1", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811",
+      "transport": {"type": "ws", "headers": {"Authorization": "Bearer token-value"}}}
+  ]
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newBoxInstance(sealed, testRedactPlatform{}, nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2hunter2", "b831381d-6324-4d53-ad4f-8cda48b30811", "Bearer token-value"} {
+		if message := secretRedactor.Redact("dial with " + secret); strings.Contains(message, secret) {
+			t.Errorf("secret %s is not redacted", secret)
+		}
+	}
+}
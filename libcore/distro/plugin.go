@@ -4,21 +4,36 @@ import (
 	"libcore/plugin/http"
 	"libcore/plugin/juicity"
 	"libcore/plugin/plugindns"
+	"libcore/plugin/plugingroup"
 	"libcore/plugin/trusttunnel"
+	"libcore/plugin/tun"
 	"libcore/plugin/vless"
 
+	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/adapter/outbound"
 	"github.com/sagernet/sing-box/dns"
 )
 
+func registerPluginsInbound(registry *inbound.Registry) {
+	tun.RegisterInbound(registry)
+}
+
 func registerPluginsOutbound(registry *outbound.Registry) {
 	http.RegisterOutbound(registry)
 	juicity.RegisterOutbound(registry)
 	vless.RegisterOutbound(registry)
 	trusttunnel.RegisterOutbound(registry)
+	plugingroup.RegisterFallback(registry)
+	plugingroup.RegisterLoadBalance(registry)
+	plugingroup.RegisterChain(registry)
 }
 
 func registerPluginsDNSTransport(registry *dns.TransportRegistry) {
 	plugindns.RegisterTCP(registry)
 	plugindns.RegisterTLS(registry)
+	plugindns.RegisterHTTPS(registry)
+	plugindns.RegisterQUIC(registry)
+	plugindns.RegisterDNSCrypt(registry)
+	plugindns.RegisterParallel(registry)
+	plugindns.RegisterFilter(registry)
 }
@@ -25,7 +25,6 @@ import (
 	"github.com/sagernet/sing-box/protocol/ssh"
 	"github.com/sagernet/sing-box/protocol/trojan"
 	"github.com/sagernet/sing-box/protocol/tuic"
-	"github.com/sagernet/sing-box/protocol/tun"
 	"github.com/sagernet/sing-box/protocol/vmess"
 	"github.com/sagernet/sing-box/protocol/wireguard"
 	_ "github.com/sagernet/sing-box/transport/v2rayquic"
@@ -34,7 +33,7 @@ import (
 func InboundRegistry() *inbound.Registry {
 	registry := inbound.NewRegistry()
 
-	tun.RegisterInbound(registry)
+	// tun.RegisterInbound(registry) // Move to plugin
 	direct.RegisterInbound(registry)
 
 	socks.RegisterInbound(registry)
@@ -50,6 +49,8 @@ func InboundRegistry() *inbound.Registry {
 
 	registerQUICInbounds(registry)
 
+	registerPluginsInbound(registry)
+
 	return registry
 }
 
@@ -115,7 +116,7 @@ func DNSTransportRegistry() *dns.TransportRegistry {
 	// transport.RegisterTCP(registry) // Move to plugin
 	transport.RegisterUDP(registry)
 	// transport.RegisterTLS(registry) // Move to plugin
-	transport.RegisterHTTPS(registry)
+	// transport.RegisterHTTPS(registry) // Move to plugin
 	hosts.RegisterTransport(registry)
 	local.RegisterTransport(registry)
 	fakeip.RegisterTransport(registry)
@@ -128,7 +129,7 @@ func DNSTransportRegistry() *dns.TransportRegistry {
 }
 
 func registerQUICTransports(registry *dns.TransportRegistry) {
-	quic.RegisterTransport(registry)
+	// quic.RegisterTransport(registry) // Move to plugin
 	quic.RegisterHTTP3Transport(registry)
 }
 
@@ -0,0 +1,64 @@
+package libcore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"libcore/dnscache"
+	"libcore/vario"
+
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const dnsCacheFile = "dns_cache.bin"
+
+func dnsCachePath() string {
+	return filepath.Join(internalAssetsPath, dnsCacheFile)
+}
+
+// SetPersistentDNSCache enables DNS cache kept across instances and restarts.
+// It takes effect on the next instance.
+func (s *Service) SetPersistentDNSCache(enabled bool) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if !enabled {
+		if s.dnsCache != nil {
+			_ = s.dnsCache.Save()
+		}
+		s.dnsCache = nil
+		return
+	}
+	if s.dnsCache != nil {
+		return
+	}
+	s.dnsCache = dnscache.New(dnsCachePath())
+	err := s.dnsCache.Load()
+	if err != nil {
+		log.Warn("load dns cache: ", err)
+	}
+}
+
+func (c *Client) ClearDNSCache() error {
+	err := vario.WriteUint8(c.conn, commandClearDNSCache)
+	if err != nil {
+		return E.Cause(err, "write command")
+	}
+	return nil
+}
+
+// clearDNSCache drops persistent DNS cache, used when network changed.
+func (s *Service) clearDNSCache() error {
+	s.access.RLock()
+	dnsCache := s.dnsCache
+	s.access.RUnlock()
+	if dnsCache != nil {
+		return dnsCache.Clear()
+	}
+	err := os.Remove(dnsCachePath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
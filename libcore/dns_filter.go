@@ -0,0 +1,157 @@
+package libcore
+
+import (
+	"io"
+	"os"
+
+	"libcore/dnscache"
+	"libcore/dnsfilter"
+	"libcore/plugin/plugindns"
+	"libcore/vario"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+// FetchDNSFilterList downloads hosts or AdGuard/ABP style list from link to path,
+// and returns count of the rules. The old file is kept if the list is invalid.
+func FetchDNSFilterList(client HTTPClient, link, path string) (int32, error) {
+	request := client.NewRequest()
+	err := request.SetURL(link)
+	if err != nil {
+		return 0, E.Cause(err, "set URL")
+	}
+	response, err := request.Execute()
+	if err != nil {
+		return 0, E.Cause(err, "fetch list")
+	}
+	tempPath := path + ".tmp"
+	err = response.WriteTo(tempPath, nil)
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return 0, E.Cause(err, "download list")
+	}
+	rules, err := parseDNSFilterList(tempPath)
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return 0, err
+	}
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		return 0, err
+	}
+	return rules, nil
+}
+
+func parseDNSFilterList(path string) (int32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	rules, err := dnsfilter.Parse(file)
+	if err != nil {
+		return 0, E.Cause(err, "parse list")
+	}
+	if len(rules) == 0 {
+		return 0, E.New("no rule found in list")
+	}
+	return int32(len(rules)), nil
+}
+
+func (c *Client) QueryDNSFilterHits() (DNSFilterHitsIterator, error) {
+	err := vario.WriteUint8(c.conn, commandQueryDNSFilterHits)
+	if err != nil {
+		return nil, E.Cause(err, "write command")
+	}
+	hits, err := vario.ReadSlices(c.conn, readDNSFilterHits)
+	if err != nil {
+		return nil, E.Cause(err, "read dns filter hits")
+	}
+	return newIterator(hits), nil
+}
+
+func (s *Service) handleQueryDNSFilterHits(conn io.ReadWriter, instance *boxInstance) error {
+	var hits []*DNSFilterHits
+	transportManager := service.FromContext[adapter.DNSTransportManager](instance.ctx)
+	if transportManager != nil {
+		for _, transport := range transportManager.Transports() {
+			filterTransport, isFilterTransport := dnscache.Unwrap(transport).(plugindns.FilterHitsTransport)
+			if !isFilterTransport {
+				continue
+			}
+			for _, list := range filterTransport.ListHits() {
+				hits = append(hits, &DNSFilterHits{
+					Server: transport.Tag(),
+					List:   list.Tag,
+					Rules:  int32(list.Rules),
+					Hits:   list.Hits,
+				})
+			}
+		}
+	}
+	err := vario.WriteSlices(conn, hits)
+	if err != nil {
+		return E.Cause(err, "write dns filter hits")
+	}
+	return nil
+}
+
+type DNSFilterHitsIterator interface {
+	Next() *DNSFilterHits
+	HasNext() bool
+	Length() int32
+}
+
+// DNSFilterHits is the count of queries blocked by a list of filter DNS server.
+type DNSFilterHits struct {
+	Server string
+	List   string
+	Rules  int32
+	Hits   int64
+}
+
+func (h *DNSFilterHits) WriteToBinary(writer io.Writer) error {
+	err := vario.WriteString(writer, h.Server)
+	if err != nil {
+		return E.Cause(err, "write server")
+	}
+	err = vario.WriteString(writer, h.List)
+	if err != nil {
+		return E.Cause(err, "write list")
+	}
+	err = vario.WriteInt32(writer, h.Rules)
+	if err != nil {
+		return E.Cause(err, "write rules")
+	}
+	err = vario.WriteInt64(writer, h.Hits)
+	if err != nil {
+		return E.Cause(err, "write hits")
+	}
+	return nil
+}
+
+func readDNSFilterHits(reader io.Reader) (*DNSFilterHits, error) {
+	var (
+		hits DNSFilterHits
+		err  error
+	)
+	hits.Server, err = vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read server")
+	}
+	hits.List, err = vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read list")
+	}
+	hits.Rules, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read rules")
+	}
+	hits.Hits, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read hits")
+	}
+	return &hits, nil
+}
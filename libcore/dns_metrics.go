@@ -0,0 +1,220 @@
+package libcore
+
+import (
+	"io"
+
+	"libcore/dnscache"
+	"libcore/plugin/plugindns"
+	"libcore/vario"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+func (c *Client) QueryDNSTransportMetrics() (DNSTransportMetricsIterator, error) {
+	err := vario.WriteUint8(c.conn, commandQueryDNSTransportMetrics)
+	if err != nil {
+		return nil, E.Cause(err, "write command")
+	}
+	metrics, err := vario.ReadSlices(c.conn, readDNSTransportMetrics)
+	if err != nil {
+		return nil, E.Cause(err, "read dns transport metrics")
+	}
+	return newIterator(metrics), nil
+}
+
+func (s *Service) handleQueryDNSTransportMetrics(conn io.ReadWriter, instance *boxInstance) error {
+	var metrics []*DNSTransportMetrics
+	transportManager := service.FromContext[adapter.DNSTransportManager](instance.ctx)
+	if transportManager != nil {
+		for _, transport := range transportManager.Transports() {
+			metricsTransport, isMetricsTransport := dnscache.Unwrap(transport).(plugindns.MetricsTransport)
+			if !isMetricsTransport {
+				continue
+			}
+			metrics = append(metrics, buildDNSTransportMetrics(transport, metricsTransport.Metrics()))
+		}
+	}
+	err := vario.WriteSlices(conn, metrics)
+	if err != nil {
+		return E.Cause(err, "write dns transport metrics")
+	}
+	return nil
+}
+
+type DNSTransportMetricsIterator interface {
+	Next() *DNSTransportMetrics
+	HasNext() bool
+	Length() int32
+}
+
+// DNSTransportMetrics is the statistics of TCP, TLS, HTTPS and QUIC DNS transport.
+type DNSTransportMetrics struct {
+	Tag                 string
+	Type                string
+	ActiveConnections   int32
+	PooledConnections   int32
+	InflightQueries     int32
+	Pipeline            bool
+	PipelineDetected    bool
+	OutOfOrderResponses int64
+	PipelinedResponses  int64
+	Queries             int64
+	Errors              int64
+	// Latency percentiles in milliseconds.
+	LatencyP50 int32
+	LatencyP90 int32
+	LatencyP99 int32
+}
+
+func buildDNSTransportMetrics(transport adapter.DNSTransport, metrics plugindns.Metrics) *DNSTransportMetrics {
+	return &DNSTransportMetrics{
+		Tag:                 transport.Tag(),
+		Type:                transport.Type(),
+		ActiveConnections:   metrics.ActiveConnections,
+		PooledConnections:   metrics.PooledConnections,
+		InflightQueries:     metrics.InflightQueries,
+		Pipeline:            metrics.Pipeline,
+		PipelineDetected:    metrics.PipelineDetected,
+		OutOfOrderResponses: metrics.OutOfOrderResponses,
+		PipelinedResponses:  metrics.PipelinedResponses,
+		Queries:             metrics.Queries,
+		Errors:              metrics.Errors,
+		LatencyP50:          int32(metrics.LatencyP50.Milliseconds()),
+		LatencyP90:          int32(metrics.LatencyP90.Milliseconds()),
+		LatencyP99:          int32(metrics.LatencyP99.Milliseconds()),
+	}
+}
+
+// GetOutOfOrderRatio returns the ratio of out-of-order responses in pipelined responses.
+func (m *DNSTransportMetrics) GetOutOfOrderRatio() float64 {
+	if m.PipelinedResponses == 0 {
+		return 0
+	}
+	return float64(m.OutOfOrderResponses) / float64(m.PipelinedResponses)
+}
+
+func (m *DNSTransportMetrics) WriteToBinary(writer io.Writer) error {
+	err := vario.WriteString(writer, m.Tag)
+	if err != nil {
+		return E.Cause(err, "write tag")
+	}
+	err = vario.WriteString(writer, m.Type)
+	if err != nil {
+		return E.Cause(err, "write type")
+	}
+	err = vario.WriteInt32(writer, m.ActiveConnections)
+	if err != nil {
+		return E.Cause(err, "write active connections")
+	}
+	err = vario.WriteInt32(writer, m.PooledConnections)
+	if err != nil {
+		return E.Cause(err, "write pooled connections")
+	}
+	err = vario.WriteInt32(writer, m.InflightQueries)
+	if err != nil {
+		return E.Cause(err, "write inflight queries")
+	}
+	err = vario.WriteBool(writer, m.Pipeline)
+	if err != nil {
+		return E.Cause(err, "write pipeline")
+	}
+	err = vario.WriteBool(writer, m.PipelineDetected)
+	if err != nil {
+		return E.Cause(err, "write pipeline detected")
+	}
+	err = vario.WriteInt64(writer, m.OutOfOrderResponses)
+	if err != nil {
+		return E.Cause(err, "write out of order responses")
+	}
+	err = vario.WriteInt64(writer, m.PipelinedResponses)
+	if err != nil {
+		return E.Cause(err, "write pipelined responses")
+	}
+	err = vario.WriteInt64(writer, m.Queries)
+	if err != nil {
+		return E.Cause(err, "write queries")
+	}
+	err = vario.WriteInt64(writer, m.Errors)
+	if err != nil {
+		return E.Cause(err, "write errors")
+	}
+	err = vario.WriteInt32(writer, m.LatencyP50)
+	if err != nil {
+		return E.Cause(err, "write latency p50")
+	}
+	err = vario.WriteInt32(writer, m.LatencyP90)
+	if err != nil {
+		return E.Cause(err, "write latency p90")
+	}
+	err = vario.WriteInt32(writer, m.LatencyP99)
+	if err != nil {
+		return E.Cause(err, "write latency p99")
+	}
+	return nil
+}
+
+func readDNSTransportMetrics(reader io.Reader) (*DNSTransportMetrics, error) {
+	var (
+		metrics DNSTransportMetrics
+		err     error
+	)
+	metrics.Tag, err = vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read tag")
+	}
+	metrics.Type, err = vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read type")
+	}
+	metrics.ActiveConnections, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read active connections")
+	}
+	metrics.PooledConnections, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read pooled connections")
+	}
+	metrics.InflightQueries, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read inflight queries")
+	}
+	metrics.Pipeline, err = vario.ReadBool(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read pipeline")
+	}
+	metrics.PipelineDetected, err = vario.ReadBool(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read pipeline detected")
+	}
+	metrics.OutOfOrderResponses, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read out of order responses")
+	}
+	metrics.PipelinedResponses, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read pipelined responses")
+	}
+	metrics.Queries, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read queries")
+	}
+	metrics.Errors, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read errors")
+	}
+	metrics.LatencyP50, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read latency p50")
+	}
+	metrics.LatencyP90, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read latency p90")
+	}
+	metrics.LatencyP99, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read latency p99")
+	}
+	return &metrics, nil
+}
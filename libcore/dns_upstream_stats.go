@@ -0,0 +1,120 @@
+package libcore
+
+import (
+	"io"
+
+	"libcore/dnscache"
+	"libcore/plugin/plugindns"
+	"libcore/vario"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+func (c *Client) QueryDNSUpstreamStats() (DNSUpstreamStatsIterator, error) {
+	err := vario.WriteUint8(c.conn, commandQueryDNSUpstreamStats)
+	if err != nil {
+		return nil, E.Cause(err, "write command")
+	}
+	stats, err := vario.ReadSlices(c.conn, readDNSUpstreamStats)
+	if err != nil {
+		return nil, E.Cause(err, "read dns upstream stats")
+	}
+	return newIterator(stats), nil
+}
+
+func (s *Service) handleQueryDNSUpstreamStats(conn io.ReadWriter, instance *boxInstance) error {
+	var stats []*DNSUpstreamStats
+	transportManager := service.FromContext[adapter.DNSTransportManager](instance.ctx)
+	if transportManager != nil {
+		for _, transport := range transportManager.Transports() {
+			statsTransport, isStatsTransport := dnscache.Unwrap(transport).(plugindns.UpstreamStatsTransport)
+			if !isStatsTransport {
+				continue
+			}
+			for _, upstream := range statsTransport.UpstreamStats() {
+				stats = append(stats, &DNSUpstreamStats{
+					Group:          transport.Tag(),
+					Tag:            upstream.Tag,
+					Queries:        upstream.Queries,
+					Errors:         upstream.Errors,
+					Wins:           upstream.Wins,
+					Disagreements:  upstream.Disagreements,
+					AverageLatency: int32(upstream.AverageLatency.Milliseconds()),
+				})
+			}
+		}
+	}
+	err := vario.WriteSlices(conn, stats)
+	if err != nil {
+		return E.Cause(err, "write dns upstream stats")
+	}
+	return nil
+}
+
+type DNSUpstreamStatsIterator interface {
+	Next() *DNSUpstreamStats
+	HasNext() bool
+	Length() int32
+}
+
+// DNSUpstreamStats is the statistics of a server in parallel DNS transport.
+type DNSUpstreamStats struct {
+	Group         string
+	Tag           string
+	Queries       int64
+	Errors        int64
+	Wins          int64
+	Disagreements int64
+	// AverageLatency in milliseconds.
+	AverageLatency int32
+}
+
+func (s *DNSUpstreamStats) WriteToBinary(writer io.Writer) error {
+	err := vario.WriteString(writer, s.Group)
+	if err != nil {
+		return E.Cause(err, "write group")
+	}
+	err = vario.WriteString(writer, s.Tag)
+	if err != nil {
+		return E.Cause(err, "write tag")
+	}
+	for _, value := range []int64{s.Queries, s.Errors, s.Wins, s.Disagreements} {
+		err = vario.WriteInt64(writer, value)
+		if err != nil {
+			return E.Cause(err, "write counters")
+		}
+	}
+	err = vario.WriteInt32(writer, s.AverageLatency)
+	if err != nil {
+		return E.Cause(err, "write average latency")
+	}
+	return nil
+}
+
+func readDNSUpstreamStats(reader io.Reader) (*DNSUpstreamStats, error) {
+	var (
+		stats DNSUpstreamStats
+		err   error
+	)
+	stats.Group, err = vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read group")
+	}
+	stats.Tag, err = vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read tag")
+	}
+	for _, value := range []*int64{&stats.Queries, &stats.Errors, &stats.Wins, &stats.Disagreements} {
+		*value, err = vario.ReadInt64(reader)
+		if err != nil {
+			return nil, E.Cause(err, "read counters")
+		}
+	}
+	stats.AverageLatency, err = vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read average latency")
+	}
+	return &stats, nil
+}
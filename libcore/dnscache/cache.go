@@ -0,0 +1,314 @@
+// Package dnscache provides DNS cache persisted to file, which serves stale answers as RFC 8767.
+package dnscache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"libcore/vario"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	fileVersion uint8 = 1
+
+	defaultCapacity = 4096
+	// maxStale is how long expired answers can be served.
+	// https://www.rfc-editor.org/rfc/rfc8767#section-5
+	maxStale = 24 * time.Hour
+	// staleTTL is the TTL of stale answers.
+	staleTTL     = 30
+	saveInterval = time.Minute
+)
+
+type entry struct {
+	message []byte
+	stored  time.Time
+	expire  time.Time
+}
+
+// Cache stores packed answers by key, and saves them to path.
+type Cache struct {
+	path     string
+	capacity int
+
+	access  sync.Mutex
+	entries map[string]*entry
+	dirty   bool
+}
+
+func New(path string) *Cache {
+	return &Cache{
+		path:     path,
+		capacity: defaultCapacity,
+		entries:  make(map[string]*entry),
+	}
+}
+
+// Start saves the cache periodically until ctx done.
+func (c *Cache) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = c.Save()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Load reads entries from file. Missing file is not an error.
+func (c *Cache) Load() error {
+	file, err := os.Open(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	version, err := vario.ReadUint8(reader)
+	if err != nil {
+		return E.Cause(err, "read version")
+	}
+	if version != fileVersion {
+		return E.New("unknown version: ", version)
+	}
+	now := time.Now()
+	entries := make(map[string]*entry)
+	for {
+		key, err := vario.ReadString(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return E.Cause(err, "read key")
+		}
+		stored, err := vario.ReadInt64(reader)
+		if err != nil {
+			return E.Cause(err, "read stored time")
+		}
+		expire, err := vario.ReadInt64(reader)
+		if err != nil {
+			return E.Cause(err, "read expire time")
+		}
+		message, err := vario.ReadBytes(reader)
+		if err != nil {
+			return E.Cause(err, "read message")
+		}
+		it := &entry{
+			message: message,
+			stored:  time.Unix(stored, 0),
+			expire:  time.Unix(expire, 0),
+		}
+		if now.Sub(it.expire) > maxStale {
+			continue
+		}
+		entries[key] = it
+	}
+	c.access.Lock()
+	defer c.access.Unlock()
+	for key, it := range entries {
+		if _, loaded := c.entries[key]; !loaded {
+			c.entries[key] = it
+		}
+	}
+	c.evict(now)
+	return nil
+}
+
+// Save writes entries to file if changed.
+func (c *Cache) Save() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	tempPath := c.path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	err = c.writeTo(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Close()
+	} else {
+		_ = file.Close()
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	err = os.Rename(tempPath, c.path)
+	if err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func (c *Cache) writeTo(writer io.Writer) error {
+	err := vario.WriteUint8(writer, fileVersion)
+	if err != nil {
+		return E.Cause(err, "write version")
+	}
+	for key, it := range c.entries {
+		err = vario.WriteString(writer, key)
+		if err != nil {
+			return E.Cause(err, "write key")
+		}
+		err = vario.WriteInt64(writer, it.stored.Unix())
+		if err != nil {
+			return E.Cause(err, "write stored time")
+		}
+		err = vario.WriteInt64(writer, it.expire.Unix())
+		if err != nil {
+			return E.Cause(err, "write expire time")
+		}
+		err = vario.WriteBytes(writer, it.message)
+		if err != nil {
+			return E.Cause(err, "write message")
+		}
+	}
+	return nil
+}
+
+// Clear drops all entries and the file.
+func (c *Cache) Clear() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	clear(c.entries)
+	c.dirty = false
+	err := os.Remove(c.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Len returns count of entries.
+func (c *Cache) Len() int {
+	c.access.Lock()
+	defer c.access.Unlock()
+	return len(c.entries)
+}
+
+// lookup returns the answer with TTL counted down, or with staleTTL if expired.
+func (c *Cache) lookup(key string, now time.Time) (response *mDNS.Msg, stale bool, loaded bool) {
+	c.access.Lock()
+	it, loaded := c.entries[key]
+	c.access.Unlock()
+	if !loaded {
+		return nil, false, false
+	}
+	if now.Sub(it.expire) > maxStale {
+		return nil, false, false
+	}
+	var message mDNS.Msg
+	err := message.Unpack(it.message)
+	if err != nil {
+		return nil, false, false
+	}
+	stale = !now.Before(it.expire)
+	elapsed := uint32(max(now.Sub(it.stored)/time.Second, 0))
+	for _, records := range [][]mDNS.RR{message.Answer, message.Ns, message.Extra} {
+		for _, record := range records {
+			header := record.Header()
+			if header.Rrtype == mDNS.TypeOPT {
+				continue
+			}
+			if stale || header.Ttl <= elapsed {
+				header.Ttl = staleTTL
+			} else {
+				header.Ttl -= elapsed
+			}
+		}
+	}
+	return &message, stale, true
+}
+
+func (c *Cache) store(key string, response *mDNS.Msg, now time.Time) {
+	ttl, cacheable := cacheTTL(response)
+	if !cacheable {
+		return
+	}
+	message, err := response.Pack()
+	if err != nil {
+		return
+	}
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.entries[key] = &entry{
+		message: message,
+		stored:  now,
+		expire:  now.Add(time.Duration(ttl) * time.Second),
+	}
+	c.dirty = true
+	if len(c.entries) > c.capacity {
+		c.evict(now)
+	}
+}
+
+// evict drops entries too stale, then the earliest expiring ones over capacity.
+func (c *Cache) evict(now time.Time) {
+	for key, it := range c.entries {
+		if now.Sub(it.expire) > maxStale {
+			delete(c.entries, key)
+			c.dirty = true
+		}
+	}
+	for len(c.entries) > c.capacity {
+		var (
+			earliestKey string
+			earliest    *entry
+		)
+		for key, it := range c.entries {
+			if earliest == nil || it.expire.Before(earliest.expire) {
+				earliestKey, earliest = key, it
+			}
+		}
+		delete(c.entries, earliestKey)
+		c.dirty = true
+	}
+}
+
+// cacheTTL returns the minimum TTL of records, or the negative TTL from SOA.
+func cacheTTL(response *mDNS.Msg) (ttl uint32, cacheable bool) {
+	if response.Truncated || response.Rcode != mDNS.RcodeSuccess && response.Rcode != mDNS.RcodeNameError {
+		return 0, false
+	}
+	for _, records := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range records {
+			header := record.Header()
+			if header.Rrtype == mDNS.TypeOPT {
+				continue
+			}
+			recordTTL := header.Ttl
+			if soa, isSOA := record.(*mDNS.SOA); isSOA && len(response.Answer) == 0 {
+				recordTTL = min(recordTTL, soa.Minttl)
+			}
+			if !cacheable || recordTTL < ttl {
+				ttl = recordTTL
+				cacheable = true
+			}
+		}
+	}
+	return ttl, cacheable && ttl > 0
+}
@@ -0,0 +1,179 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func newResponse(name string, ttl uint32) *mDNS.Msg {
+	request := new(mDNS.Msg)
+	request.SetQuestion(name, mDNS.TypeA)
+	response := new(mDNS.Msg)
+	response.SetReply(request)
+	response.Answer = append(response.Answer, &mDNS.A{
+		Hdr: mDNS.RR_Header{Name: name, Rrtype: mDNS.TypeA, Class: mDNS.ClassINET, Ttl: ttl},
+		A:   net.IPv4(1, 1, 1, 1),
+	})
+	return response
+}
+
+func Test_Cache(t *testing.T) {
+	cache := New(filepath.Join(t.TempDir(), "dns_cache.bin"))
+	now := time.Now()
+	cache.store("a", newResponse("example.com.", 60), now)
+
+	for _, tt := range []struct {
+		name   string
+		after  time.Duration
+		loaded bool
+		stale  bool
+		ttl    uint32
+	}{
+		{name: "fresh", after: 10 * time.Second, loaded: true, ttl: 50},
+		{name: "stale", after: time.Minute + time.Second, loaded: true, stale: true, ttl: staleTTL},
+		{name: "too stale", after: maxStale + 2*time.Minute},
+	} {
+		response, stale, loaded := cache.lookup("a", now.Add(tt.after))
+		if loaded != tt.loaded || stale != tt.stale {
+			t.Errorf("%s: expected loaded %v stale %v, got %v %v", tt.name, tt.loaded, tt.stale, loaded, stale)
+			continue
+		}
+		if loaded && response.Answer[0].Header().Ttl != tt.ttl {
+			t.Errorf("%s: expected TTL %d, got %d", tt.name, tt.ttl, response.Answer[0].Header().Ttl)
+		}
+	}
+
+	// Negative answers use TTL from SOA.
+	negative := newResponse("nx.example.com.", 0)
+	negative.Rcode = mDNS.RcodeNameError
+	negative.Answer = nil
+	negative.Ns = append(negative.Ns, &mDNS.SOA{
+		Hdr:    mDNS.RR_Header{Name: "example.com.", Rrtype: mDNS.TypeSOA, Class: mDNS.ClassINET, Ttl: 3600},
+		Ns:     "ns.example.com.",
+		Mbox:   "admin.example.com.",
+		Minttl: 300,
+	})
+	if ttl, cacheable := cacheTTL(negative); !cacheable || ttl != 300 {
+		t.Errorf("expected negative TTL 300, got %d %v", ttl, cacheable)
+	}
+	servfail := newResponse("example.com.", 60)
+	servfail.Rcode = mDNS.RcodeServerFailure
+	if _, cacheable := cacheTTL(servfail); cacheable {
+		t.Error("SERVFAIL should not be cached")
+	}
+}
+
+func Test_CachePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns_cache.bin")
+	cache := New(path)
+	now := time.Now()
+	cache.store("a", newResponse("example.com.", 60), now)
+	cache.store("expired", newResponse("example.org.", 1), now.Add(-maxStale-time.Minute))
+	err := cache.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(path)
+	err = loaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", loaded.Len())
+	}
+	if _, _, ok := loaded.lookup("a", now); !ok {
+		t.Error("entry should be loaded")
+	}
+
+	err = loaded.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = New(path).Load()
+	if err != nil || loaded.Len() != 0 {
+		t.Errorf("cache should be cleared: %v", err)
+	}
+}
+
+type countingTransport struct {
+	dns.TransportAdapter
+	queries atomic.Int32
+	ttl     uint32
+}
+
+func (c *countingTransport) Start(adapter.StartStage) error { return nil }
+func (c *countingTransport) Close() error                   { return nil }
+func (c *countingTransport) Reset()                         {}
+
+func (c *countingTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	c.queries.Add(1)
+	response := newResponse(message.Question[0].Name, c.ttl)
+	response.Id = message.Id
+	return response, nil
+}
+
+func Test_Transport(t *testing.T) {
+	cache := New(filepath.Join(t.TempDir(), "dns_cache.bin"))
+	upstream := &countingTransport{
+		TransportAdapter: dns.NewTransportAdapter(C.DNSTypeUDP, "remote", nil),
+		ttl:              60,
+	}
+	transport := &Transport{
+		DNSTransport: upstream,
+		ctx:          context.Background(),
+		logger:       log.StdLogger(),
+		cache:        cache,
+		refreshing:   make(map[string]struct{}),
+	}
+	exchange := func(subnet netip.Prefix) {
+		request := new(mDNS.Msg)
+		request.SetQuestion("example.com.", mDNS.TypeA)
+		if subnet.IsValid() {
+			request = dns.SetClientSubnet(request, subnet)
+		}
+		response, err := transport.Exchange(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Id != request.Id {
+			t.Errorf("unexpected response id %d, expected %d", response.Id, request.Id)
+		}
+	}
+	exchange(netip.Prefix{})
+	exchange(netip.Prefix{})
+	if queries := upstream.queries.Load(); queries != 1 {
+		t.Errorf("second query should be cached, got %d queries", queries)
+	}
+	// Client subnet is a part of key.
+	exchange(netip.MustParsePrefix("1.2.3.0/24"))
+	if queries := upstream.queries.Load(); queries != 2 {
+		t.Errorf("query with client subnet should not be cached, got %d queries", queries)
+	}
+
+	cache.access.Lock()
+	for _, it := range cache.entries {
+		it.expire = time.Now().Add(-time.Second)
+	}
+	cache.access.Unlock()
+	exchange(netip.Prefix{})
+	deadline := time.Now().Add(time.Second)
+	for upstream.queries.Load() != 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if queries := upstream.queries.Load(); queries != 3 {
+		t.Errorf("stale answer should be refreshed in background, got %d queries", queries)
+	}
+}
@@ -0,0 +1,144 @@
+package dnscache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var _ adapter.DNSTransportRegistry = (*Registry)(nil)
+
+// Registry wraps remote transports created by the upstream registry with cache.
+type Registry struct {
+	adapter.DNSTransportRegistry
+	cache *Cache
+}
+
+func NewRegistry(registry adapter.DNSTransportRegistry, cache *Cache) *Registry {
+	return &Registry{
+		DNSTransportRegistry: registry,
+		cache:                cache,
+	}
+}
+
+func (r *Registry) CreateDNSTransport(ctx context.Context, logger log.ContextLogger, tag string, transportType string, options any) (adapter.DNSTransport, error) {
+	transport, err := r.DNSTransportRegistry.CreateDNSTransport(ctx, logger, tag, transportType, options)
+	if err != nil {
+		return nil, err
+	}
+	switch transportType {
+	case C.DNSTypeUDP, C.DNSTypeTCP, C.DNSTypeTLS, C.DNSTypeHTTPS, C.DNSTypeQUIC, C.DNSTypeHTTP3, pluginoption.DNSTypeDNSCrypt:
+		return &Transport{
+			DNSTransport: transport,
+			ctx:          ctx,
+			logger:       logger,
+			cache:        r.cache,
+			refreshing:   make(map[string]struct{}),
+		}, nil
+	default:
+		return transport, nil
+	}
+}
+
+// Transport answers from cache, and refreshes stale answers in background.
+type Transport struct {
+	adapter.DNSTransport
+	ctx    context.Context
+	logger logger.ContextLogger
+	cache  *Cache
+
+	access     sync.Mutex
+	refreshing map[string]struct{}
+}
+
+// Unwrap returns the transport wrapped by cache, or itself.
+func Unwrap(transport adapter.DNSTransport) adapter.DNSTransport {
+	if cached, isCached := transport.(*Transport); isCached {
+		return cached.DNSTransport
+	}
+	return transport
+}
+
+func (t *Transport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	key, cacheable := cacheKey(t.Tag(), message)
+	if !cacheable {
+		return t.DNSTransport.Exchange(ctx, message)
+	}
+	response, stale, loaded := t.cache.lookup(key, time.Now())
+	if loaded {
+		if stale {
+			t.refresh(key, message.Copy())
+		}
+		response.Id = message.Id
+		return response, nil
+	}
+	response, err := t.DNSTransport.Exchange(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	t.cache.store(key, response, time.Now())
+	return response, nil
+}
+
+func (t *Transport) refresh(key string, message *mDNS.Msg) {
+	t.access.Lock()
+	if _, loaded := t.refreshing[key]; loaded {
+		t.access.Unlock()
+		return
+	}
+	t.refreshing[key] = struct{}{}
+	t.access.Unlock()
+	go func() {
+		defer func() {
+			t.access.Lock()
+			delete(t.refreshing, key)
+			t.access.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(t.ctx, C.DNSTimeout)
+		defer cancel()
+		response, err := t.DNSTransport.Exchange(ctx, message)
+		if err != nil {
+			t.logger.DebugContext(ctx, "refresh stale answer of ", message.Question[0].Name, ": ", err)
+			return
+		}
+		t.cache.store(key, response, time.Now())
+	}()
+}
+
+// cacheKey identifies the question by transport tag and client subnet.
+func cacheKey(tag string, message *mDNS.Msg) (key string, cacheable bool) {
+	if message.Opcode != mDNS.OpcodeQuery || len(message.Question) != 1 {
+		return "", false
+	}
+	question := message.Question[0]
+	var builder strings.Builder
+	builder.WriteString(tag)
+	builder.WriteByte('|')
+	if opt := message.IsEdns0(); opt != nil {
+		for _, option := range opt.Option {
+			if subnet, isSubnet := option.(*mDNS.EDNS0_SUBNET); isSubnet {
+				builder.WriteString(subnet.Address.String())
+				builder.WriteByte('/')
+				builder.WriteString(strconv.Itoa(int(subnet.SourceNetmask)))
+			}
+		}
+	}
+	builder.WriteByte('|')
+	builder.WriteString(strings.ToLower(question.Name))
+	builder.WriteByte('|')
+	builder.WriteString(strconv.Itoa(int(question.Qtype)))
+	builder.WriteByte('|')
+	builder.WriteString(strconv.Itoa(int(question.Qclass)))
+	return builder.String(), true
+}
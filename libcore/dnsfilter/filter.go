@@ -0,0 +1,134 @@
+package dnsfilter
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Rule levels, the highest matched wins.
+const (
+	levelNone uint8 = iota
+	levelBlock
+	levelException
+	levelImportantBlock
+	levelImportantException
+)
+
+type ruleMatch struct {
+	level uint8
+	list  int
+}
+
+type domainEntry struct {
+	exact  ruleMatch
+	suffix ruleMatch
+}
+
+// List is a loaded block list.
+type List struct {
+	Tag   string
+	Rules int
+	hits  atomic.Int64
+}
+
+// Hits returns count of queries blocked by the list.
+func (l *List) Hits() int64 {
+	return l.hits.Load()
+}
+
+// Filter matches domains against all loaded lists.
+type Filter struct {
+	lists   []*List
+	entries map[string]*domainEntry
+}
+
+func New() *Filter {
+	return &Filter{
+		entries: make(map[string]*domainEntry),
+	}
+}
+
+// LoadFile parses the list from path and adds it as tag.
+func (f *Filter) LoadFile(tag, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rules, err := Parse(file)
+	if err != nil {
+		return E.Cause(err, "parse ", path)
+	}
+	f.Add(tag, rules)
+	return nil
+}
+
+// Add adds rules as a list. For the same domain and level, rules from former lists are kept.
+func (f *Filter) Add(tag string, rules []Rule) {
+	index := len(f.lists)
+	f.lists = append(f.lists, &List{Tag: tag, Rules: len(rules)})
+	for _, rule := range rules {
+		entry := f.entries[rule.Domain]
+		if entry == nil {
+			entry = new(domainEntry)
+			f.entries[rule.Domain] = entry
+		}
+		match := &entry.exact
+		if rule.Suffix {
+			match = &entry.suffix
+		}
+		level := ruleLevel(rule)
+		if level > match.level {
+			*match = ruleMatch{level: level, list: index}
+		}
+	}
+}
+
+func ruleLevel(rule Rule) uint8 {
+	switch {
+	case rule.Important && rule.Exception:
+		return levelImportantException
+	case rule.Important:
+		return levelImportantBlock
+	case rule.Exception:
+		return levelException
+	default:
+		return levelBlock
+	}
+}
+
+// Lists returns loaded lists.
+func (f *Filter) Lists() []*List {
+	return f.lists
+}
+
+// Match reports whether domain is blocked, and counts the hit to the list.
+func (f *Filter) Match(domain string) (list *List, blocked bool) {
+	match := f.match(strings.ToLower(strings.TrimSuffix(domain, ".")))
+	if match.level != levelBlock && match.level != levelImportantBlock {
+		return nil, false
+	}
+	list = f.lists[match.list]
+	list.hits.Add(1)
+	return list, true
+}
+
+func (f *Filter) match(domain string) ruleMatch {
+	var best ruleMatch
+	if entry := f.entries[domain]; entry != nil && entry.exact.level > best.level {
+		best = entry.exact
+	}
+	for {
+		if entry := f.entries[domain]; entry != nil && entry.suffix.level > best.level {
+			best = entry.suffix
+		}
+		index := strings.IndexByte(domain, '.')
+		if index < 0 {
+			return best
+		}
+		domain = domain[index+1:]
+	}
+}
@@ -0,0 +1,92 @@
+package dnsfilter
+
+import (
+	"strings"
+	"testing"
+)
+
+const testList = `[Adblock Plus 2.0]
+! comment
+# comment
+0.0.0.0 hosts.example.com hosts2.example.com # trailing comment
+127.0.0.1 localhost
+1.2.3.4 not-blocking.example.com
+plain.example.com
+||ads.example.org^
+||tracker.example.org^$important
+@@||safe.ads.example.org^
+@@||tracker.example.org^
+|exact.example.net^|
+||partial.example
+||third.example.net^$third-party
+example.com##.banner
+`
+
+func Test_Parse(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Rule{
+		{Domain: "hosts.example.com"},
+		{Domain: "hosts2.example.com"},
+		{Domain: "plain.example.com"},
+		{Domain: "ads.example.org", Suffix: true},
+		{Domain: "tracker.example.org", Suffix: true, Important: true},
+		{Domain: "safe.ads.example.org", Suffix: true, Exception: true},
+		{Domain: "tracker.example.org", Suffix: true, Exception: true},
+		{Domain: "exact.example.net"},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, got %d: %+v", len(expected), len(rules), rules)
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Errorf("rule %d: expected %+v, got %+v", i, expected[i], rules[i])
+		}
+	}
+}
+
+func Test_Filter(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := New()
+	filter.Add("main", rules)
+	filter.Add("extra", []Rule{
+		{Domain: "ads.example.org", Suffix: true},
+		{Domain: "extra.example.com", Suffix: true},
+	})
+	for _, tt := range []struct {
+		domain  string
+		blocked bool
+		list    string
+	}{
+		{domain: "hosts.example.com.", blocked: true, list: "main"},
+		{domain: "sub.hosts.example.com"},
+		{domain: "localhost"},
+		{domain: "ADS.example.org", blocked: true, list: "main"},
+		{domain: "a.b.ads.example.org", blocked: true, list: "main"},
+		{domain: "safe.ads.example.org"},
+		{domain: "x.safe.ads.example.org"},
+		{domain: "cdn.tracker.example.org", blocked: true, list: "main"},
+		{domain: "exact.example.net", blocked: true, list: "main"},
+		{domain: "sub.exact.example.net"},
+		{domain: "a.extra.example.com", blocked: true, list: "extra"},
+		{domain: "example.org"},
+	} {
+		list, blocked := filter.Match(tt.domain)
+		if blocked != tt.blocked {
+			t.Errorf("%s: expected blocked %v", tt.domain, tt.blocked)
+			continue
+		}
+		if blocked && list.Tag != tt.list {
+			t.Errorf("%s: expected list %s, got %s", tt.domain, tt.list, list.Tag)
+		}
+	}
+	lists := filter.Lists()
+	if lists[0].Hits() != 5 || lists[1].Hits() != 1 {
+		t.Errorf("unexpected hits: %d %d", lists[0].Hits(), lists[1].Hits())
+	}
+}
@@ -0,0 +1,148 @@
+// Package dnsfilter parses hosts and AdGuard/ABP style block lists, and matches domains against them.
+package dnsfilter
+
+import (
+	"bufio"
+	"io"
+	"net/netip"
+	"strings"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// Rule is a domain rule of block list.
+type Rule struct {
+	Domain string
+	// Suffix matches the domain and its subdomains, like `||example.org^`.
+	Suffix bool
+	// Exception allows the domain, like `@@||example.org^`.
+	Exception bool
+	// Important overrides rules without it, like `||example.org^$important`.
+	Important bool
+}
+
+// Parse reads rules from hosts or AdGuard/ABP format list. Unsupported rules are skipped.
+func Parse(reader io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 64*1024)
+	for scanner.Scan() {
+		rules = parseLine(scanner.Text(), rules)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseLine(line string, rules []Rule) []Rule {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return rules
+	}
+	switch line[0] {
+	case '!', '#', '[':
+		return rules
+	}
+	if strings.Contains(line, "##") || strings.Contains(line, "#@#") {
+		// Cosmetic rule.
+		return rules
+	}
+	if index := strings.IndexByte(line, '#'); index > 0 {
+		// Hosts comment.
+		line = strings.TrimSpace(line[:index])
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 1 {
+		return parseHosts(fields, rules)
+	}
+	if strings.HasPrefix(line, "|") || strings.HasPrefix(line, "@@") {
+		rule, ok := parseAdblock(line)
+		if ok {
+			rules = append(rules, rule)
+		}
+		return rules
+	}
+	// Plain domain list.
+	if domain, ok := normalizeDomain(line); ok {
+		rules = append(rules, Rule{Domain: domain})
+	}
+	return rules
+}
+
+func parseHosts(fields []string, rules []Rule) []Rule {
+	address, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return rules
+	}
+	if !address.IsUnspecified() && !address.IsLoopback() {
+		// Only blocking entries are used.
+		return rules
+	}
+	for _, field := range fields[1:] {
+		domain, ok := normalizeDomain(field)
+		if !ok || isLocalHostname(domain) {
+			continue
+		}
+		rules = append(rules, Rule{Domain: domain})
+	}
+	return rules
+}
+
+func parseAdblock(line string) (rule Rule, ok bool) {
+	line, rule.Exception = strings.CutPrefix(line, "@@")
+	line, rule.Suffix = strings.CutPrefix(line, "||")
+	if !rule.Suffix {
+		if !strings.HasPrefix(line, "|") || !strings.HasSuffix(line, "|") {
+			return Rule{}, false
+		}
+		// `|example.org|` matches exact domain.
+		line = line[1:]
+	}
+	pattern, modifiers, hasModifiers := strings.Cut(line, "$")
+	if hasModifiers {
+		for _, modifier := range strings.Split(modifiers, ",") {
+			if modifier != "important" {
+				// Other modifiers restrict clients or types, which can't be applied.
+				return Rule{}, false
+			}
+			rule.Important = true
+		}
+	}
+	pattern = strings.TrimSuffix(pattern, "|")
+	if rule.Suffix {
+		var terminated bool
+		pattern, terminated = strings.CutSuffix(pattern, "^")
+		if !terminated {
+			// Without separator, it matches a part of label.
+			return Rule{}, false
+		}
+	} else {
+		pattern = strings.TrimSuffix(pattern, "^")
+	}
+	rule.Domain, ok = normalizeDomain(pattern)
+	return rule, ok
+}
+
+func normalizeDomain(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if domain == "" || strings.ContainsAny(domain, "*/:|^$") {
+		return "", false
+	}
+	if _, ok := mDNS.IsDomainName(domain); !ok {
+		return "", false
+	}
+	if _, err := netip.ParseAddr(domain); err == nil {
+		return "", false
+	}
+	return domain, true
+}
+
+func isLocalHostname(domain string) bool {
+	switch domain {
+	case "localhost", "localhost.localdomain", "local", "broadcasthost", "ip6-localhost", "ip6-loopback", "0.0.0.0":
+		return true
+	}
+	return false
+}
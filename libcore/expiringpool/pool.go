@@ -134,6 +134,12 @@ func (e *ExpiringPool[T]) Put(value T) {
 	e.items[value] = it
 }
 
+func (e *ExpiringPool[T]) Len() int {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return len(e.heap)
+}
+
 func (e *ExpiringPool[T]) Close() {
 	e.access.Lock()
 	defer e.access.Unlock()
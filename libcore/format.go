@@ -18,6 +18,7 @@ import (
 	"github.com/sagernet/sing/service"
 
 	"libcore/distro"
+	"libcore/sealedconfig"
 )
 
 func baseContext(platformInterface PlatformInterface) context.Context {
@@ -41,6 +42,9 @@ func baseContext(platformInterface PlatformInterface) context.Context {
 
 // parseConfig parses configContent to option.Options.
 func parseConfig(ctx context.Context, configContent string) (option.Options, error) {
+	if sealedconfig.IsSealed(configContent) {
+		return option.Options{}, E.New("sealed config can only be used by instance")
+	}
 	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(configContent))
 	if err != nil {
 		return option.Options{}, E.Cause(err, "decode config")
@@ -11,11 +11,13 @@ import (
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"sync"
 	"time"
 
+	"libcore/minisign"
+
+	"github.com/sagernet/quic-go/http3"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/bufio"
@@ -32,8 +34,8 @@ type HTTPClient interface {
 	// RestrictedTLS forces to use TLS 1.3.
 	RestrictedTLS()
 
-	// PinnedSHA256 verifies server TLS certificate's sha256 when using self-signed certificates.
-	// This is designed for OOCv1
+	// PinnedSHA256 also accepts server TLS certificate by its sha256 when using self-signed certificates,
+	// besides certificates trusted by root CA. This is designed for OOCv1
 	//
 	// https://github.com/Shadowsocks-NET/OpenOnlineConfig/blob/0db1f2452f8ad579967ca4c5092f5e11053c813c/docs/0001-open-online-config-v1.md?plain=1#L69
 	PinnedSHA256(sumHex string)
@@ -41,9 +43,31 @@ type HTTPClient interface {
 	// UseSocks5 connects to server by socks5.
 	UseSocks5(port int32, username, password string)
 
+	// UseOutbound connects to server by outbound of the running instance through command server.
+	// Empty tag means the default outbound.
+	UseOutbound(tag string)
+
+	// UseProfile is like UseOutbound, but by a temporary instance of config,
+	// which is created by the first connection and closed by Close. So no need to open any inbound.
+	UseProfile(config, tag string)
+
 	// KeepAlive force use HTTP/2 and enable keep alive.
 	KeepAlive()
 
+	// UseHTTP3 sends requests by HTTP/3, which is not available with proxy.
+	UseHTTP3()
+
+	// SetUTLSFingerprint mimics TLS fingerprint of browsers by uTLS, such as "chrome".
+	// HTTP/1.1 is used because of ALPN, and HTTP/3 is not affected.
+	SetUTLSFingerprint(fingerprint string)
+
+	// SetECHConfigList enables Encrypted Client Hello by ECHConfigList in base64.
+	SetECHConfigList(configBase64 string) error
+
+	// UseECH enables Encrypted Client Hello by ECHConfigList in HTTPS record,
+	// which is queried from dnsServer (such as "1.1.1.1:53") over TCP and follows the proxy.
+	UseECH(dnsServer string)
+
 	// NewRequest creates a new HTTPRequest base settings.
 	NewRequest() HTTPRequest
 
@@ -74,6 +98,23 @@ type HTTPRequest interface {
 	// SetTimeout sets timeout millisecond.
 	SetTimeout(timeout int32)
 
+	// EnableResume continues the interrupted download to path by Range,
+	// if the partial file is still valid. The path must be the same as HTTPResponse.WriteTo if resumed.
+	EnableResume(path string)
+
+	// SetIfNoneMatch skips downloading if server content is still the etag.
+	SetIfNoneMatch(etag string)
+
+	// SetPinnedSHA256 is like HTTPClient.PinnedSHA256, but only for this request.
+	// It fails if the client dials TLS by itself, such as HTTP/3, uTLS and ECH.
+	SetPinnedSHA256(sumHex string) error
+
+	// SetSHA256 verifies sha256 of the downloaded file.
+	SetSHA256(sumHex string) error
+
+	// SetMinisign verifies the downloaded file by minisign public key and signature file content.
+	SetMinisign(publicKey string, signature string) error
+
 	// Execute do HTTP query.
 	Execute() (HTTPResponse, error)
 }
@@ -86,7 +127,14 @@ type HTTPResponse interface {
 	// GetContentString returns server content string in response.
 	GetContentString() (string, error)
 
+	// NotModified returns whether server content not modified since SetIfNoneMatch.
+	NotModified() bool
+
+	// Resumed returns whether the response continues the partial file.
+	Resumed() bool
+
 	// WriteTo writes content to the file of `path`.
+	// The file is replaced only after the whole content is written and verified.
 	// callback could be nil
 	WriteTo(path string, callback CopyCallback) error
 
@@ -101,9 +149,13 @@ var (
 )
 
 type httpClient struct {
-	tls       tls.Config
-	client    http.Client
-	transport http.Transport
+	tls            tls.Config
+	tlsOptions     tlsOptions
+	client         http.Client
+	transport      http.Transport
+	http3Transport *http3.Transport
+
+	temporaryInstance *temporaryInstance
 }
 
 // NewHttpClient returns the basic HTTPClient.
@@ -121,15 +173,19 @@ func (c *httpClient) RestrictedTLS() {
 }
 
 func (c *httpClient) PinnedSHA256(sumHex string) {
-	c.tls.InsecureSkipVerify = true
-	c.tls.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	pinSHA256(&c.tls, sumHex)
+}
+
+func pinSHA256(config *tls.Config, sumHex string) {
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
 		opts := x509.VerifyOptions{
-			DNSName:       c.tls.ServerName,
-			Roots:         c.tls.RootCAs,
+			DNSName:       config.ServerName,
+			Roots:         config.RootCAs,
 			Intermediates: x509.NewCertPool(),
 		}
-		if c.tls.Time != nil {
-			opts.CurrentTime = c.tls.Time()
+		if config.Time != nil {
+			opts.CurrentTime = config.Time()
 		}
 		for _, rawCert := range rawCerts[1:] {
 			cert, _ := x509.ParseCertificate(rawCert)
@@ -193,11 +249,19 @@ func (c *httpClient) NewRequest() HTTPRequest {
 
 func (c *httpClient) Close() {
 	c.transport.CloseIdleConnections()
+	if c.http3Transport != nil {
+		_ = c.http3Transport.Close()
+	}
+	if c.temporaryInstance != nil {
+		_ = c.temporaryInstance.Close()
+	}
 }
 
 type httpRequest struct {
 	*httpClient
-	request http.Request
+	request      http.Request
+	download     downloadOptions
+	pinnedSHA256 string
 }
 
 func (r *httpRequest) SetURL(link string) (err error) {
@@ -250,13 +314,118 @@ func (r *httpRequest) SetTimeout(timeout int32) {
 	r.client.Timeout = time.Duration(timeout) * time.Millisecond
 }
 
+func (r *httpRequest) EnableResume(path string) {
+	r.download.resumePath = path
+	r.download.resumeOffset = 0
+	offset, validator := loadPartial(path)
+	if offset <= 0 || validator == "" {
+		r.request.Header.Del("Range")
+		r.request.Header.Del("If-Range")
+		return
+	}
+	r.download.resumeOffset = offset
+	r.request.Header.Set("Range", F.ToString("bytes=", offset, "-"))
+	r.request.Header.Set("If-Range", validator)
+}
+
+// canResume reports whether response continues the partial file.
+// 304 is also accepted, as server compares If-None-Match before Range.
+func (r *httpRequest) canResume(response *http.Response) bool {
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return true
+	case http.StatusNotModified:
+		return r.request.Header.Get("If-None-Match") != ""
+	default:
+		return false
+	}
+}
+
+func (r *httpRequest) SetIfNoneMatch(etag string) {
+	if etag == "" {
+		r.request.Header.Del("If-None-Match")
+		return
+	}
+	r.request.Header.Set("If-None-Match", etag)
+}
+
+func (r *httpRequest) SetPinnedSHA256(sumHex string) error {
+	// Only the TLS config of net/http can be copied for a request.
+	if r.client.Transport != &r.transport || r.transport.DialTLSContext != nil {
+		return E.New("certificate pin of request is not available with HTTP/3, uTLS or ECH, use HTTPClient.PinnedSHA256 instead")
+	}
+	r.pinnedSHA256 = sumHex
+	return nil
+}
+
+// pinnedClient returns a copy of client with its own transport, which pins certificate of the request.
+// Keep alive is disabled, so no pinned connection is left for other requests.
+func (r *httpRequest) pinnedClient() *http.Client {
+	config := r.tls.Clone()
+	pinSHA256(config, r.pinnedSHA256)
+	transport := r.transport.Clone()
+	transport.TLSClientConfig = config
+	transport.DisableKeepAlives = true
+	client := r.client
+	client.Transport = transport
+	return &client
+}
+
+func (r *httpRequest) SetSHA256(sumHex string) error {
+	sum, err := hex.DecodeString(sumHex)
+	if err != nil {
+		return E.Cause(err, "decode sha256")
+	}
+	if len(sum) != sha256.Size {
+		return E.New("invalid sha256 length: ", len(sum))
+	}
+	r.download.sha256 = sum
+	return nil
+}
+
+func (r *httpRequest) SetMinisign(publicKey string, signature string) error {
+	key, err := minisign.ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := minisign.ParseSignature(signature)
+	if err != nil {
+		return err
+	}
+	r.download.minisignKey = key
+	r.download.minisignSignature = sig
+	return nil
+}
+
 func (r *httpRequest) Execute() (HTTPResponse, error) {
-	response, err := r.client.Do(&r.request)
+	client := &r.client
+	if r.pinnedSHA256 != "" {
+		client = r.pinnedClient()
+	}
+	response, err := client.Do(&r.request)
 	if err != nil {
 		return nil, err
 	}
-	httpResp := &httpResponse{Response: response}
-	if response.StatusCode != http.StatusOK {
+	if r.download.resumeOffset > 0 && r.request.Body == nil && !r.canResume(response) {
+		// Such as 416 for a partial file which is already complete.
+		// The partial file is useless, so download again from start.
+		_ = response.Body.Close()
+		removePartial(r.download.resumePath)
+		r.download.resumeOffset = 0
+		r.request.Header.Del("Range")
+		r.request.Header.Del("If-Range")
+		response, err = client.Do(&r.request)
+		if err != nil {
+			return nil, err
+		}
+	}
+	httpResp := &httpResponse{Response: response, download: r.download}
+	switch {
+	case response.StatusCode == http.StatusOK:
+		httpResp.download.resumeOffset = 0
+	case response.StatusCode == http.StatusPartialContent && r.download.resumeOffset > 0:
+	case response.StatusCode == http.StatusNotModified && r.request.Header.Get("If-None-Match") != "":
+	default:
 		return nil, E.New(httpResp.errorString())
 	}
 	return httpResp, nil
@@ -264,6 +433,7 @@ func (r *httpRequest) Execute() (HTTPResponse, error) {
 
 type httpResponse struct {
 	*http.Response
+	download downloadOptions
 
 	getContentOnce sync.Once
 	content        []byte
@@ -296,26 +466,29 @@ func (h *httpResponse) GetContentString() (string, error) {
 	return string(h.content), nil
 }
 
+func (h *httpResponse) NotModified() bool {
+	return h.Response.StatusCode == http.StatusNotModified
+}
+
+func (h *httpResponse) Resumed() bool {
+	return h.download.resumeOffset > 0
+}
+
 func (h *httpResponse) WriteTo(path string, callback CopyCallback) error {
 	defer h.Response.Body.Close()
-	var writer io.Writer
+	if h.NotModified() {
+		return nil
+	}
+	reader := h.Response.Body
 	if path == DevNull {
 		// Android not support /dev/null
-		writer = io.Discard
-	} else {
-		file, err := os.Create(path)
-		if err != nil {
-			return err
+		if callback != nil {
+			callback.SetLength(h.Response.ContentLength)
+			reader = &callbackReader{reader, callback.Update}
 		}
-		defer file.Close()
-		writer = file
-	}
-	reader := h.Response.Body
-	if callback != nil {
-		callback.SetLength(h.Response.ContentLength)
-		reader = &callbackReader{reader, callback.Update}
+		return common.Error(bufio.Copy(io.Discard, reader))
 	}
-	return common.Error(bufio.Copy(writer, reader))
+	return h.download.writeTo(h.Response, path, callback)
 }
 
 func (h *httpResponse) Close() error {
@@ -0,0 +1,158 @@
+package libcore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"libcore/minisign"
+
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Partial file is written next to the target, with its validator (ETag or Last-Modified)
+// to check whether server content changed before resuming.
+const (
+	partialSuffix   = ".part"
+	validatorSuffix = ".part.validator"
+)
+
+type downloadOptions struct {
+	resumePath        string
+	resumeOffset      int64
+	sha256            []byte
+	minisignKey       *minisign.PublicKey
+	minisignSignature *minisign.Signature
+}
+
+// loadPartial returns size and validator of the partial file of path.
+func loadPartial(path string) (int64, string) {
+	info, err := os.Stat(path + partialSuffix)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, ""
+	}
+	validator, err := os.ReadFile(path + validatorSuffix)
+	if err != nil {
+		return 0, ""
+	}
+	return info.Size(), string(validator)
+}
+
+func removePartial(path string) {
+	_ = os.Remove(path + partialSuffix)
+	_ = os.Remove(path + validatorSuffix)
+}
+
+func (d *downloadOptions) writeTo(response *http.Response, path string, callback CopyCallback) error {
+	partialPath := path + partialSuffix
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if d.resumeOffset > 0 {
+		// The response only has the rest of the partial file of resume path.
+		if path != d.resumePath {
+			return E.New("resumed response must be written to ", d.resumePath, ", not ", path)
+		}
+		start, err := contentRangeStart(response.Header.Get("Content-Range"))
+		if err != nil || start != d.resumeOffset {
+			removePartial(path)
+			return E.New("unexpected content range: ", response.Header.Get("Content-Range"))
+		}
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(partialPath, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	// Save validator before content, so that interrupted download is able to resume.
+	validator := response.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		// Weak ETag is not allowed in If-Range.
+		validator = response.Header.Get("Last-Modified")
+	}
+	if validator != "" {
+		err = os.WriteFile(path+validatorSuffix, []byte(validator), 0o644)
+	} else {
+		err = os.Remove(path + validatorSuffix)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	var reader io.Reader = response.Body
+	if callback != nil {
+		if response.ContentLength >= 0 {
+			callback.SetLength(d.resumeOffset + response.ContentLength)
+		} else {
+			callback.SetLength(response.ContentLength)
+		}
+		if d.resumeOffset > 0 {
+			callback.Update(d.resumeOffset)
+		}
+		reader = &callbackReader{reader, callback.Update}
+	}
+	_, err = bufio.Copy(file, reader)
+	err = E.Errors(err, file.Close())
+	if err != nil {
+		// Keep partial file to resume.
+		return err
+	}
+	err = d.verify(partialPath)
+	if err != nil {
+		removePartial(path)
+		return err
+	}
+	err = os.Rename(partialPath, path)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(path + validatorSuffix)
+	return nil
+}
+
+func (d *downloadOptions) verify(path string) error {
+	if d.sha256 != nil {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		if sum := hash.Sum(nil); !bytes.Equal(sum, d.sha256) {
+			return E.New("sha256 not matched: ", hex.EncodeToString(sum))
+		}
+	}
+	if d.minisignKey != nil {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return d.minisignKey.Verify(d.minisignSignature, file)
+	}
+	return nil
+}
+
+// contentRangeStart parses start of "bytes start-end/size".
+func contentRangeStart(contentRange string) (int64, error) {
+	rangeString, found := strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return 0, E.New("invalid content range unit")
+	}
+	startString, _, found := strings.Cut(rangeString, "-")
+	if !found {
+		return 0, E.New("invalid content range")
+	}
+	return strconv.ParseInt(startString, 10, 64)
+}
@@ -0,0 +1,166 @@
+package libcore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_HTTPResponseWriteToResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1024)
+	const etag = `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "asset")
+	// Interrupted download.
+	err := os.WriteFile(path+partialSuffix, content[:4000], 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path+validatorSuffix, []byte(etag), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(content)
+	execute := func() HTTPResponse {
+		request := NewHttpClient().NewRequest()
+		err := request.SetURL(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.EnableResume(path)
+		err = request.SetSHA256(hex.EncodeToString(sum[:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := request.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !response.Resumed() {
+			t.Error("response not resumed")
+		}
+		return response
+	}
+	// Writing the rest to another path would lose the head.
+	err = execute().WriteTo(path+".other", nil)
+	if err == nil {
+		t.Error("expected error for different path")
+	}
+	err = execute().WriteTo(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, content) {
+		t.Error("content not matched")
+	}
+	if _, err = os.Stat(path + partialSuffix); !os.IsNotExist(err) {
+		t.Error("partial file not removed")
+	}
+
+	request := NewHttpClient().NewRequest()
+	_ = request.SetURL(server.URL)
+	request.SetIfNoneMatch(etag)
+	response, err := request.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.NotModified() {
+		t.Error("expected not modified")
+	}
+}
+
+func Test_HTTPResponseWriteToResumeComplete(t *testing.T) {
+	content := []byte("0123456789")
+	const etag = `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "asset")
+	// Interrupted after the whole content is received, so server responds 416 for Range.
+	err := os.WriteFile(path+partialSuffix, content, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path+validatorSuffix, []byte(etag), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := NewHttpClient().NewRequest()
+	err = request.SetURL(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.EnableResume(path)
+	response, err := request.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Resumed() {
+		t.Error("response resumed")
+	}
+	err = response.WriteTo(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, content) {
+		t.Error("content not matched")
+	}
+}
+
+func Test_HTTPResponseWriteToSHA256Mismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "asset")
+	err := os.WriteFile(path, []byte("old"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := NewHttpClient().NewRequest()
+	_ = request.SetURL(server.URL)
+	err = request.SetSHA256(hex.EncodeToString(make([]byte, sha256.Size)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := request.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = response.WriteTo(path, nil)
+	if err == nil {
+		t.Fatal("expected sha256 error")
+	}
+	old, _ := os.ReadFile(path)
+	if string(old) != "old" {
+		t.Error("old file replaced")
+	}
+	if _, err = os.Stat(path + partialSuffix); !os.IsNotExist(err) {
+		t.Error("partial file not removed")
+	}
+}
@@ -0,0 +1,286 @@
+package libcore
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"libcore/vario"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func (c *httpClient) UseOutbound(tag string) {
+	c.transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialOutbound(ctx, 0, tag, addr)
+	}
+}
+
+func (c *httpClient) UseProfile(config, tag string) {
+	if c.temporaryInstance != nil {
+		_ = c.temporaryInstance.Close()
+	}
+	instance := &temporaryInstance{config: config}
+	c.temporaryInstance = instance
+	c.transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		id, err := instance.ID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return dialOutbound(ctx, id, tag, addr)
+	}
+}
+
+// temporaryInstance is an instance of config in command server,
+// which lives as long as the command connection holding it.
+type temporaryInstance struct {
+	access sync.Mutex
+	config string
+	conn   net.Conn
+	id     uint64
+}
+
+// ID creates the instance at the first call, and returns its ID.
+func (t *temporaryInstance) ID(ctx context.Context) (uint64, error) {
+	t.access.Lock()
+	defer t.access.Unlock()
+	if t.conn != nil {
+		return t.id, nil
+	}
+	client, err := NewClient()
+	if err != nil {
+		return 0, err
+	}
+	conn := client.conn
+	if deadline, loaded := ctx.Deadline(); loaded {
+		_ = conn.SetDeadline(deadline)
+	}
+	err = vario.WriteUint8(conn, commandNewTemporaryInstance)
+	if err != nil {
+		conn.Close()
+		return 0, E.Cause(err, "write command")
+	}
+	err = vario.WriteString(conn, t.config)
+	if err != nil {
+		conn.Close()
+		return 0, E.Cause(err, "write config")
+	}
+	resultCode, err := vario.ReadUint8(conn)
+	if err != nil {
+		conn.Close()
+		return 0, E.Cause(err, "read result code")
+	}
+	if resultCode != resultNoError {
+		message, err := vario.ReadString(conn)
+		conn.Close()
+		if err != nil {
+			return 0, E.Cause(err, "read error message")
+		}
+		return 0, E.New(message)
+	}
+	id, err := vario.ReadUvarint(conn)
+	if err != nil {
+		conn.Close()
+		return 0, E.Cause(err, "read instance id")
+	}
+	_ = conn.SetDeadline(time.Time{})
+	t.conn = conn
+	t.id = id
+	return id, nil
+}
+
+// Close closes the command connection, then command server closes the instance.
+func (t *temporaryInstance) Close() error {
+	t.access.Lock()
+	defer t.access.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// dialOutbound asks command server to dial address by outbound,
+// and the command connection becomes the connection to address after that.
+// Zero instanceID means the running instance, otherwise a temporary instance.
+func dialOutbound(ctx context.Context, instanceID uint64, tag, address string) (net.Conn, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	conn := client.conn
+	if deadline, loaded := ctx.Deadline(); loaded {
+		_ = conn.SetDeadline(deadline)
+	}
+	err = vario.WriteUint8(conn, commandDialOutbound)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "write command")
+	}
+	err = vario.WriteUvarint(conn, instanceID)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "write instance id")
+	}
+	err = vario.WriteString(conn, tag)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "write tag")
+	}
+	err = vario.WriteString(conn, address)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "write address")
+	}
+	resultCode, err := vario.ReadUint8(conn)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "read result code")
+	}
+	if resultCode != resultNoError {
+		message, err := vario.ReadString(conn)
+		conn.Close()
+		if err != nil {
+			return nil, E.Cause(err, "read error message")
+		}
+		return nil, E.New(message)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (s *Service) handleDialOutbound(conn net.Conn) error {
+	instanceID, err := vario.ReadUvarint(conn)
+	if err != nil {
+		return E.Cause(err, "read instance id")
+	}
+	tag, err := vario.ReadString(conn)
+	if err != nil {
+		return E.Cause(err, "read tag")
+	}
+	address, err := vario.ReadString(conn)
+	if err != nil {
+		return E.Cause(err, "read address")
+	}
+
+	var instance *boxInstance
+	if instanceID == 0 {
+		s.access.RLock()
+		instance, err = s.requireInstance()
+		s.access.RUnlock()
+	} else {
+		s.temporaryAccess.Lock()
+		held, loaded := s.temporaryInstances[instanceID]
+		s.temporaryAccess.Unlock()
+		if loaded {
+			instance = held.boxInstance
+		} else {
+			err = E.New("temporary instance ", instanceID, " not found")
+		}
+	}
+	if err != nil {
+		_ = vario.WriteUint8(conn, resultCommonError)
+		_ = vario.WriteString(conn, err.Error())
+		return nil
+	}
+
+	remote, err := instance.dialOutbound(tag, M.ParseSocksaddr(address))
+	if err != nil {
+		_ = vario.WriteUint8(conn, resultCommonError)
+		_ = vario.WriteString(conn, err.Error())
+		return nil
+	}
+	err = vario.WriteUint8(conn, resultNoError)
+	if err != nil {
+		remote.Close()
+		return E.Cause(err, "write result")
+	}
+	err = bufio.CopyConn(instance.ctx, conn, remote)
+	if err != nil && !E.IsClosedOrCanceled(err) {
+		log.Debug("copy outbound connection: ", err)
+	}
+	return nil
+}
+
+// heldInstance is a temporary instance and the command connection holding it.
+type heldInstance struct {
+	*boxInstance
+	conn net.Conn
+}
+
+// handleNewTemporaryInstance creates instance of config for dialOutbound,
+// and closes it after the client closes the connection.
+func (s *Service) handleNewTemporaryInstance(conn net.Conn) error {
+	config, err := vario.ReadString(conn)
+	if err != nil {
+		return E.Cause(err, "read config")
+	}
+	instance, err := s.newTemporaryInstance(config)
+	if err != nil {
+		_ = vario.WriteUint8(conn, resultCommonError)
+		_ = vario.WriteString(conn, err.Error())
+		return nil
+	}
+	defer instance.Close()
+	s.temporaryAccess.Lock()
+	s.temporaryID++
+	id := s.temporaryID
+	if s.temporaryInstances == nil {
+		s.temporaryInstances = make(map[uint64]*heldInstance)
+	}
+	s.temporaryInstances[id] = &heldInstance{boxInstance: instance, conn: conn}
+	s.temporaryAccess.Unlock()
+	defer func() {
+		s.temporaryAccess.Lock()
+		delete(s.temporaryInstances, id)
+		s.temporaryAccess.Unlock()
+	}()
+	err = vario.WriteUint8(conn, resultNoError)
+	if err != nil {
+		return E.Cause(err, "write result")
+	}
+	err = vario.WriteUvarint(conn, id)
+	if err != nil {
+		return E.Cause(err, "write instance id")
+	}
+	// Nothing more is sent by client.
+	_, _ = io.Copy(io.Discard, conn)
+	return nil
+}
+
+func (s *Service) newTemporaryInstance(config string) (*boxInstance, error) {
+	instance, err := newBoxInstance(config, s.platformInterface, nil, nil, true)
+	if err != nil {
+		return nil, E.Cause(err, "create instance")
+	}
+	err = instance.Start()
+	if err != nil {
+		instance.Close()
+		return nil, E.Cause(err, "start instance")
+	}
+	return instance, nil
+}
+
+func (b *boxInstance) dialOutbound(tag string, destination M.Socksaddr) (net.Conn, error) {
+	var detour N.Dialer
+	if tag == "" {
+		detour = b.Outbound().Default()
+	} else {
+		var loaded bool
+		detour, loaded = b.Outbound().Outbound(tag)
+		if !loaded {
+			return nil, E.New(tag, " is not found")
+		}
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, C.TCPConnectTimeout)
+	defer cancel()
+	return detour.DialContext(ctx, N.NetworkTCP, destination)
+}
@@ -0,0 +1,236 @@
+package libcore
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	sTLS "github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// tlsOptions is the TLS settings that crypto/tls in net/http can't provide,
+// and handled by TLS of sing-box instead.
+type tlsOptions struct {
+	utlsFingerprint string
+	echConfigList   []byte
+	echDNSServer    string
+
+	echAccess sync.Mutex
+	echCache  map[string]echCacheEntry
+}
+
+type echCacheEntry struct {
+	configList []byte
+	expire     time.Time
+}
+
+func (c *httpClient) SetUTLSFingerprint(fingerprint string) {
+	c.tlsOptions.utlsFingerprint = fingerprint
+	c.transport.DialTLSContext = c.dialTLS
+}
+
+func (c *httpClient) SetECHConfigList(configBase64 string) error {
+	configList, err := base64.StdEncoding.DecodeString(configBase64)
+	if err != nil {
+		return E.Cause(err, "decode ECH config list")
+	}
+	c.tlsOptions.echConfigList = configList
+	c.transport.DialTLSContext = c.dialTLS
+	return nil
+}
+
+func (c *httpClient) UseECH(dnsServer string) {
+	c.tlsOptions.echDNSServer = dnsServer
+	c.transport.DialTLSContext = c.dialTLS
+}
+
+func (c *httpClient) UseHTTP3() {
+	c.http3Transport = &http3.Transport{
+		TLSClientConfig: &c.tls,
+		Dial: func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (*quic.Conn, error) {
+			if c.transport.DialContext != nil {
+				return nil, E.New("HTTP/3 is not supported by proxy")
+			}
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = host
+			}
+			echConfigList, err := c.loadECHConfigList(ctx, tlsConfig.ServerName)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.EncryptedClientHelloConfigList = echConfigList
+			return quic.DialAddrEarly(ctx, addr, tlsConfig, quicConfig)
+		},
+	}
+	c.client.Transport = c.http3Transport
+}
+
+// dialTLS dials TLS by the same TLS implementation of sing-box outbounds.
+func (c *httpClient) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	serverName := c.tls.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	options := option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: serverName,
+		// net/http only speaks HTTP/2 over *tls.Conn.
+		ALPN: []string{"http/1.1"},
+		// Verified after handshake by PinnedSHA256.
+		Insecure: c.tls.VerifyPeerCertificate != nil,
+	}
+	if c.tls.MinVersion == tls.VersionTLS13 {
+		options.MinVersion = "1.3"
+	}
+	if c.tlsOptions.utlsFingerprint != "" {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: c.tlsOptions.utlsFingerprint,
+		}
+	}
+	echConfigList, err := c.loadECHConfigList(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	if len(echConfigList) > 0 {
+		options.ECH = &option.OutboundECHOptions{
+			Enabled: true,
+			Config: []string{string(pem.EncodeToMemory(&pem.Block{
+				Type:  "ECH CONFIGS",
+				Bytes: echConfigList,
+			}))},
+		}
+	}
+	config, err := sTLS.NewClient(ctx, log.StdLogger(), serverName, options)
+	if err != nil {
+		return nil, err
+	}
+	dialer := sTLS.NewDialer(httpDialer{c.dialContext}, config)
+	conn, err := dialer.DialTLSContext(ctx, M.ParseSocksaddr(addr))
+	if err != nil {
+		return nil, err
+	}
+	if c.tls.VerifyPeerCertificate != nil {
+		peerCertificates := conn.ConnectionState().PeerCertificates
+		rawCerts := make([][]byte, 0, len(peerCertificates))
+		for _, cert := range peerCertificates {
+			rawCerts = append(rawCerts, cert.Raw)
+		}
+		err = c.tls.VerifyPeerCertificate(rawCerts, nil)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *httpClient) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.transport.DialContext != nil {
+		return c.transport.DialContext(ctx, network, addr)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// loadECHConfigList returns the given ECH config list, or the one from HTTPS record of serverName.
+func (c *httpClient) loadECHConfigList(ctx context.Context, serverName string) ([]byte, error) {
+	if len(c.tlsOptions.echConfigList) > 0 || c.tlsOptions.echDNSServer == "" {
+		return c.tlsOptions.echConfigList, nil
+	}
+	c.tlsOptions.echAccess.Lock()
+	defer c.tlsOptions.echAccess.Unlock()
+	if cached, loaded := c.tlsOptions.echCache[serverName]; loaded && time.Now().Before(cached.expire) {
+		return cached.configList, nil
+	}
+	configList, ttl, err := c.queryECHConfigList(ctx, serverName)
+	if err != nil {
+		return nil, E.Cause(err, "fetch ECH config list")
+	}
+	if c.tlsOptions.echCache == nil {
+		c.tlsOptions.echCache = make(map[string]echCacheEntry)
+	}
+	c.tlsOptions.echCache[serverName] = echCacheEntry{
+		configList: configList,
+		expire:     time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+	return configList, nil
+}
+
+// queryECHConfigList queries HTTPS record over TCP, so that it follows the proxy settings.
+func (c *httpClient) queryECHConfigList(ctx context.Context, serverName string) ([]byte, uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
+	defer cancel()
+	conn, err := c.dialContext(ctx, N.NetworkTCP, c.tlsOptions.echDNSServer)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, loaded := ctx.Deadline(); loaded {
+		_ = conn.SetDeadline(deadline)
+	}
+	message := new(mDNS.Msg)
+	message.SetQuestion(mDNS.Fqdn(serverName), mDNS.TypeHTTPS)
+	dnsConn := &mDNS.Conn{Conn: conn}
+	err = dnsConn.WriteMsg(message)
+	if err != nil {
+		return nil, 0, err
+	}
+	response, err := dnsConn.ReadMsg()
+	if err != nil {
+		return nil, 0, err
+	}
+	if response.Rcode != mDNS.RcodeSuccess {
+		return nil, 0, E.New("rcode: ", mDNS.RcodeToString[response.Rcode])
+	}
+	for _, rr := range response.Answer {
+		https, isHTTPS := rr.(*mDNS.HTTPS)
+		if !isHTTPS {
+			continue
+		}
+		for _, value := range https.Value {
+			if echConfig, isECH := value.(*mDNS.SVCBECHConfig); isECH {
+				return echConfig.ECH, rr.Header().Ttl, nil
+			}
+		}
+	}
+	return nil, 0, E.New("no ECH config found in DNS records")
+}
+
+var _ N.Dialer = httpDialer{}
+
+// httpDialer adapts the dial function of http.Transport to N.Dialer.
+type httpDialer struct {
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func (d httpDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return d.dial(ctx, network, destination.String())
+}
+
+func (d httpDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
@@ -0,0 +1,95 @@
+package libcore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func Test_HTTPClientLoadECHConfigList(t *testing.T) {
+	echConfigList := []byte{0x00, 0x04, 0xfe, 0x0d, 0x00, 0x00}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries int
+	server := &mDNS.Server{
+		Listener: listener,
+		Handler: mDNS.HandlerFunc(func(w mDNS.ResponseWriter, request *mDNS.Msg) {
+			queries++
+			response := new(mDNS.Msg)
+			response.SetReply(request)
+			response.Answer = []mDNS.RR{&mDNS.HTTPS{SVCB: mDNS.SVCB{
+				Hdr:      mDNS.RR_Header{Name: request.Question[0].Name, Rrtype: mDNS.TypeHTTPS, Class: mDNS.ClassINET, Ttl: 300},
+				Priority: 1,
+				Target:   ".",
+				Value:    []mDNS.SVCBKeyValue{&mDNS.SVCBECHConfig{ECH: echConfigList}},
+			}}}
+			_ = w.WriteMsg(response)
+		}),
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	defer server.Shutdown()
+
+	client := NewHttpClient().(*httpClient)
+	client.UseECH(listener.Addr().String())
+	for range 2 {
+		configList, err := client.loadECHConfigList(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(configList, echConfigList) {
+			t.Errorf("config list: %x, want %x", configList, echConfigList)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("queried %d times, expected cached", queries)
+	}
+}
+
+func Test_HTTPRequestPinnedSHA256(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	sum := sha256.Sum256(server.Certificate().Raw)
+
+	client := NewHttpClient()
+	request := client.NewRequest()
+	err := request.SetURL(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = request.SetPinnedSHA256(hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := request.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Close()
+
+	// The pin is not left in client.
+	request = client.NewRequest()
+	_ = request.SetURL(server.URL)
+	_, err = request.Execute()
+	if err == nil {
+		t.Error("self-signed certificate accepted without pin")
+	}
+
+	http3Client := NewHttpClient()
+	http3Client.UseHTTP3()
+	if http3Client.NewRequest().SetPinnedSHA256(hex.EncodeToString(sum[:])) == nil {
+		t.Error("pin of request accepted with HTTP/3")
+	}
+}
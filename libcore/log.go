@@ -170,6 +170,7 @@ func (w *logWriter) DisableColors() bool {
 }
 
 func (w *logWriter) WriteMessage(level log.Level, message string) {
+	message = secretRedactor.Redact(message)
 	entry := log.Entry{
 		Level:   level,
 		Message: message,
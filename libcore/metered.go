@@ -0,0 +1,196 @@
+package libcore
+
+import (
+	"io"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/protocol/group"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/metered"
+	"libcore/vario"
+)
+
+// MeteredStatus is the state of metered policy.
+type MeteredStatus struct {
+	Enabled bool
+	// Metered reports whether the default network is metered.
+	Metered bool
+	// Active reports whether the policy is being applied.
+	Active bool
+	// AutoUpdateAllowed reports whether scheduled asset updates of the platform should run now.
+	// Remote rule-sets of the instance are not affected.
+	AutoUpdateAllowed bool
+	// Policy is JSON of metered.Policy.
+	Policy string
+}
+
+// SetMeteredPolicy switches the policy applied on metered networks.
+// policy is JSON of metered.Policy, such as {"url_test_interval":"30m","low_data_outbound":"low","bandwidth_limit":131072}.
+func (c *Client) SetMeteredPolicy(enabled bool, policy string) error {
+	err := vario.WriteUint8(c.conn, commandSetMeteredPolicy)
+	if err != nil {
+		return E.Cause(err, "write command")
+	}
+	err = vario.WriteBool(c.conn, enabled)
+	if err != nil {
+		return E.Cause(err, "write enabled")
+	}
+	err = vario.WriteString(c.conn, policy)
+	if err != nil {
+		return E.Cause(err, "write policy")
+	}
+	resultCode, err := vario.ReadUint8(c.conn)
+	if err != nil {
+		return E.Cause(err, "read result code")
+	}
+	if resultCode != resultNoError {
+		message, err := vario.ReadString(c.conn)
+		if err != nil {
+			return E.Cause(err, "read error message")
+		}
+		return E.New(message)
+	}
+	return nil
+}
+
+func (s *Service) handleSetMeteredPolicy(conn io.ReadWriter) error {
+	enabled, err := vario.ReadBool(conn)
+	if err != nil {
+		return E.Cause(err, "read enabled")
+	}
+	content, err := vario.ReadString(conn)
+	if err != nil {
+		return E.Cause(err, "read policy")
+	}
+	var policy metered.Policy
+	if content != "" {
+		err = json.Unmarshal([]byte(content), &policy)
+		if err != nil {
+			_ = vario.WriteUint8(conn, resultCommonError)
+			_ = vario.WriteString(conn, E.Cause(err, "decode metered policy").Error())
+			return nil
+		}
+	}
+	s.metered.SetPolicy(enabled, policy)
+	s.access.RLock()
+	instance := s.instance
+	s.access.RUnlock()
+	if instance != nil {
+		// Low data outbound may be changed even if Active is not changed.
+		instance.applyMeteredPolicy()
+	}
+	return vario.WriteUint8(conn, resultNoError)
+}
+
+func (c *Client) QueryMeteredStatus() (*MeteredStatus, error) {
+	err := vario.WriteUint8(c.conn, commandQueryMeteredStatus)
+	if err != nil {
+		return nil, E.Cause(err, "write command")
+	}
+	status := &MeteredStatus{}
+	status.Enabled, err = vario.ReadBool(c.conn)
+	if err != nil {
+		return nil, E.Cause(err, "read enabled")
+	}
+	status.Metered, err = vario.ReadBool(c.conn)
+	if err != nil {
+		return nil, E.Cause(err, "read metered")
+	}
+	status.Active, err = vario.ReadBool(c.conn)
+	if err != nil {
+		return nil, E.Cause(err, "read active")
+	}
+	status.AutoUpdateAllowed, err = vario.ReadBool(c.conn)
+	if err != nil {
+		return nil, E.Cause(err, "read auto update allowed")
+	}
+	status.Policy, err = vario.ReadString(c.conn)
+	if err != nil {
+		return nil, E.Cause(err, "read policy")
+	}
+	return status, nil
+}
+
+func (s *Service) handleQueryMeteredStatus(conn io.ReadWriter) error {
+	policy, err := json.Marshal(s.metered.Policy())
+	if err != nil {
+		return E.Cause(err, "encode policy")
+	}
+	err = vario.WriteBool(conn, s.metered.Enabled())
+	if err != nil {
+		return E.Cause(err, "write enabled")
+	}
+	err = vario.WriteBool(conn, s.metered.Metered())
+	if err != nil {
+		return E.Cause(err, "write metered")
+	}
+	err = vario.WriteBool(conn, s.metered.Active())
+	if err != nil {
+		return E.Cause(err, "write active")
+	}
+	err = vario.WriteBool(conn, s.metered.AutoUpdateAllowed())
+	if err != nil {
+		return E.Cause(err, "write auto update allowed")
+	}
+	err = vario.WriteString(conn, string(policy))
+	if err != nil {
+		return E.Cause(err, "write policy")
+	}
+	return nil
+}
+
+// updateMetered reads whether the default network is metered, and applies the policy if changed.
+func (b *boxInstance) updateMetered() {
+	if b.metered == nil {
+		return
+	}
+	defaultInterface := b.Network().DefaultNetworkInterface()
+	isMetered := defaultInterface != nil && defaultInterface.Expensive
+	if b.metered.SetMetered(isMetered) {
+		b.applyMeteredPolicy()
+	}
+}
+
+// applyMeteredPolicy selects low data outbound while the policy is active,
+// and restores previous selections after that.
+func (b *boxInstance) applyMeteredPolicy() {
+	if b.metered == nil {
+		return
+	}
+	b.meteredAccess.Lock()
+	defer b.meteredAccess.Unlock()
+	if !b.metered.Active() {
+		if len(b.meteredSelections) > 0 {
+			log.Info("metered: restore selections")
+		}
+		for groupName, tag := range b.meteredSelections {
+			b.selectOutbound(groupName, tag)
+		}
+		b.meteredSelections = nil
+		return
+	}
+	log.Info("metered: apply policy")
+	lowDataOutbound := b.metered.Policy().LowDataOutbound
+	if lowDataOutbound == "" {
+		return
+	}
+	for _, outbound := range b.Outbound().Outbounds() {
+		selector, isSelector := outbound.(*group.Selector)
+		if !isSelector || selector.Now() == lowDataOutbound {
+			continue
+		}
+		if !common.Contains(selector.All(), lowDataOutbound) {
+			continue
+		}
+		if b.meteredSelections == nil {
+			b.meteredSelections = make(map[string]string)
+		}
+		if _, saved := b.meteredSelections[selector.Tag()]; !saved {
+			b.meteredSelections[selector.Tag()] = selector.Now()
+		}
+		b.selectOutbound(selector.Tag(), lowDataOutbound)
+	}
+}
@@ -0,0 +1,92 @@
+package metered
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// NewConn limits bandwidth of conn while the policy is active.
+// Connections are wrapped only if the policy has bandwidth limit,
+// as the wrapper disables zero-copy of upstream.
+// This is decided at dial time, so connections opened before
+// the policy got bandwidth limit are never limited.
+func (c *Controller) NewConn(ctx context.Context, conn net.Conn) net.Conn {
+	if !c.hasBandwidthLimit() {
+		return conn
+	}
+	return &limitedConn{Conn: conn, ctx: ctx, controller: c}
+}
+
+func (c *Controller) NewPacketConn(ctx context.Context, conn N.PacketConn) N.PacketConn {
+	if !c.hasBandwidthLimit() {
+		return conn
+	}
+	return &limitedPacketConn{PacketConn: conn, ctx: ctx, controller: c}
+}
+
+func (c *Controller) hasBandwidthLimit() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.enabled && c.policy.BandwidthLimit > 0
+}
+
+type limitedConn struct {
+	net.Conn
+	ctx        context.Context
+	controller *Controller
+}
+
+func (c *limitedConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		waitErr := c.controller.wait(c.ctx, n)
+		if err == nil {
+			err = waitErr
+		}
+	}
+	return
+}
+
+func (c *limitedConn) Write(p []byte) (n int, err error) {
+	err = c.controller.wait(c.ctx, len(p))
+	if err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *limitedConn) Upstream() any {
+	return c.Conn
+}
+
+type limitedPacketConn struct {
+	N.PacketConn
+	ctx        context.Context
+	controller *Controller
+}
+
+func (c *limitedPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err != nil {
+		return
+	}
+	err = c.controller.wait(c.ctx, buffer.Len())
+	return
+}
+
+func (c *limitedPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	err := c.controller.wait(c.ctx, buffer.Len())
+	if err != nil {
+		buffer.Release()
+		return err
+	}
+	return c.PacketConn.WritePacket(buffer, destination)
+}
+
+func (c *limitedPacketConn) Upstream() any {
+	return c.PacketConn
+}
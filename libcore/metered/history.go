@@ -0,0 +1,36 @@
+package metered
+
+import (
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+)
+
+// WrapHistory returns the view of storage for URL test groups,
+// which makes URL tests less frequent while the policy is active.
+// URL test groups skip outbounds tested within their intervals,
+// so reporting the history as fresh until URLTestInterval skips the test.
+// The stored history is not changed, and other readers should use storage directly.
+func (c *Controller) WrapHistory(storage adapter.URLTestHistoryStorage) adapter.URLTestHistoryStorage {
+	return &historyStorage{URLTestHistoryStorage: storage, controller: c}
+}
+
+type historyStorage struct {
+	adapter.URLTestHistoryStorage
+	controller *Controller
+}
+
+func (h *historyStorage) LoadURLTestHistory(tag string) *adapter.URLTestHistory {
+	history := h.URLTestHistoryStorage.LoadURLTestHistory(tag)
+	if history == nil {
+		return nil
+	}
+	interval := h.controller.urlTestInterval()
+	if interval > 0 && time.Since(history.Time) < interval {
+		return &adapter.URLTestHistory{
+			Time:  time.Now(),
+			Delay: history.Delay,
+		}
+	}
+	return history
+}
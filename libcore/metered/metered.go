@@ -0,0 +1,141 @@
+// Package metered reduces data usage of instance on metered networks.
+package metered
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"golang.org/x/time/rate"
+)
+
+// Policy is applied when the default network is metered.
+type Policy struct {
+	// URLTestInterval is the minimum interval between URL tests of an outbound.
+	URLTestInterval badoption.Duration `json:"url_test_interval,omitempty"`
+	// DisableAutoUpdate asks the platform to skip its scheduled asset updates, see Controller.AutoUpdateAllowed.
+	// Remote rule-sets of the instance still update by their own update_interval.
+	DisableAutoUpdate bool `json:"disable_auto_update,omitempty"`
+	// LowDataOutbound is selected in every selector which contains it,
+	// and previous selections are restored after leaving metered network.
+	LowDataOutbound string `json:"low_data_outbound,omitempty"`
+	// BandwidthLimit is the total bytes per second of all connections, in both directions.
+	BandwidthLimit uint64 `json:"bandwidth_limit,omitempty"`
+}
+
+// Controller tracks the policy and whether the default network is metered.
+type Controller struct {
+	access  sync.RWMutex
+	enabled bool
+	policy  Policy
+	metered bool
+	limiter *rate.Limiter
+}
+
+func NewController() *Controller {
+	return &Controller{
+		limiter: rate.NewLimiter(rate.Inf, 0),
+	}
+}
+
+// SetPolicy updates the policy, and reports whether Active changed.
+func (c *Controller) SetPolicy(enabled bool, policy Policy) bool {
+	c.access.Lock()
+	defer c.access.Unlock()
+	wasActive := c.active()
+	c.enabled = enabled
+	c.policy = policy
+	c.updateLimiter()
+	return wasActive != c.active()
+}
+
+// SetMetered updates state of the default network, and reports whether Active changed.
+func (c *Controller) SetMetered(metered bool) bool {
+	c.access.Lock()
+	defer c.access.Unlock()
+	wasActive := c.active()
+	c.metered = metered
+	c.updateLimiter()
+	return wasActive != c.active()
+}
+
+func (c *Controller) updateLimiter() {
+	if !c.active() || c.policy.BandwidthLimit == 0 {
+		c.limiter.SetLimit(rate.Inf)
+		return
+	}
+	limit := min(c.policy.BandwidthLimit, math.MaxInt32)
+	c.limiter.SetLimit(rate.Limit(limit))
+	c.limiter.SetBurst(int(limit))
+}
+
+func (c *Controller) active() bool {
+	return c.enabled && c.metered
+}
+
+// Active reports whether the policy is being applied.
+func (c *Controller) Active() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.active()
+}
+
+func (c *Controller) Enabled() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.enabled
+}
+
+func (c *Controller) Metered() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.metered
+}
+
+func (c *Controller) Policy() Policy {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.policy
+}
+
+// AutoUpdateAllowed reports whether scheduled updates of the platform should run now.
+// It is only advisory, the instance does not check it.
+func (c *Controller) AutoUpdateAllowed() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return !c.active() || !c.policy.DisableAutoUpdate
+}
+
+func (c *Controller) urlTestInterval() time.Duration {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	if !c.active() {
+		return 0
+	}
+	return time.Duration(c.policy.URLTestInterval)
+}
+
+func (c *Controller) limited() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.active() && c.policy.BandwidthLimit > 0
+}
+
+// wait blocks until n bytes are allowed by bandwidth limit.
+func (c *Controller) wait(ctx context.Context, n int) error {
+	for n > 0 && c.limited() {
+		chunk := min(n, c.limiter.Burst())
+		if chunk <= 0 {
+			return nil
+		}
+		err := c.limiter.WaitN(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package metered
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+func Test_Controller(t *testing.T) {
+	controller := NewController()
+	policy := Policy{
+		URLTestInterval:   badoption.Duration(time.Hour),
+		DisableAutoUpdate: true,
+		BandwidthLimit:    1024,
+	}
+	tt := []struct {
+		name              string
+		update            func() bool
+		changed           bool
+		active            bool
+		autoUpdateAllowed bool
+	}{
+		{"Metered without policy", func() bool { return controller.SetMetered(true) }, false, false, true},
+		{"Enable policy", func() bool { return controller.SetPolicy(true, policy) }, true, true, false},
+		{"Unmetered", func() bool { return controller.SetMetered(false) }, true, false, true},
+		{"Disable policy", func() bool { return controller.SetPolicy(false, policy) }, false, false, true},
+	}
+	for _, tc := range tt {
+		if changed := tc.update(); changed != tc.changed {
+			t.Errorf("%s: changed = %v, want %v", tc.name, changed, tc.changed)
+		}
+		if active := controller.Active(); active != tc.active {
+			t.Errorf("%s: active = %v, want %v", tc.name, active, tc.active)
+		}
+		if allowed := controller.AutoUpdateAllowed(); allowed != tc.autoUpdateAllowed {
+			t.Errorf("%s: auto update allowed = %v, want %v", tc.name, allowed, tc.autoUpdateAllowed)
+		}
+	}
+}
+
+func Test_History(t *testing.T) {
+	controller := NewController()
+	rawStorage := urltest.NewHistoryStorage()
+	storage := controller.WrapHistory(rawStorage)
+	tested := time.Now().Add(-10 * time.Minute)
+	storage.StoreURLTestHistory("proxy", &adapter.URLTestHistory{Time: tested, Delay: 100})
+
+	if history := storage.LoadURLTestHistory("proxy"); !history.Time.Equal(tested) {
+		t.Fatal("history should not be changed while policy is inactive")
+	}
+	controller.SetMetered(true)
+	controller.SetPolicy(true, Policy{URLTestInterval: badoption.Duration(time.Hour)})
+	history := storage.LoadURLTestHistory("proxy")
+	if time.Since(history.Time) > time.Minute || history.Delay != 100 {
+		t.Fatalf("history should look fresh within URL test interval: %+v", history)
+	}
+	if history := rawStorage.LoadURLTestHistory("proxy"); !history.Time.Equal(tested) {
+		t.Fatal("stored history should keep the real test time")
+	}
+	controller.SetPolicy(true, Policy{URLTestInterval: badoption.Duration(5 * time.Minute)})
+	if history := storage.LoadURLTestHistory("proxy"); !history.Time.Equal(tested) {
+		t.Fatal("history older than URL test interval should not be changed")
+	}
+}
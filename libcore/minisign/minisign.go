@@ -0,0 +1,134 @@
+// Package minisign verifies minisign signatures.
+//
+// https://jedisct1.github.io/minisign/#signature-format
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	keyIDSize = 8
+
+	algorithmLegacy    = "Ed"
+	algorithmPrehashed = "ED"
+
+	untrustedCommentPrefix = "untrusted comment:"
+	trustedCommentPrefix   = "trusted comment: "
+)
+
+type PublicKey struct {
+	KeyID [keyIDSize]byte
+	Key   ed25519.PublicKey
+}
+
+// ParsePublicKey parses public key, which can be either the whole public key file or its base64 line.
+func ParsePublicKey(text string) (*PublicKey, error) {
+	var encoded string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, untrustedCommentPrefix) {
+			continue
+		}
+		encoded = line
+		break
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, E.Cause(err, "decode public key")
+	}
+	if len(data) != 2+keyIDSize+ed25519.PublicKeySize {
+		return nil, E.New("invalid public key length: ", len(data))
+	}
+	if string(data[:2]) != algorithmLegacy {
+		return nil, E.New("unsupported public key algorithm: ", string(data[:2]))
+	}
+	publicKey := &PublicKey{Key: ed25519.PublicKey(data[2+keyIDSize:])}
+	copy(publicKey.KeyID[:], data[2:])
+	return publicKey, nil
+}
+
+type Signature struct {
+	Algorithm       string
+	KeyID           [keyIDSize]byte
+	Signature       []byte
+	TrustedComment  string
+	GlobalSignature []byte
+}
+
+// ParseSignature parses content of signature file.
+func ParseSignature(text string) (*Signature, error) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) < 4 {
+		return nil, E.New("incomplete signature")
+	}
+	if !strings.HasPrefix(lines[0], untrustedCommentPrefix) {
+		return nil, E.New("missing untrusted comment")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return nil, E.Cause(err, "decode signature")
+	}
+	if len(data) != 2+keyIDSize+ed25519.SignatureSize {
+		return nil, E.New("invalid signature length: ", len(data))
+	}
+	signature := &Signature{
+		Algorithm: string(data[:2]),
+		Signature: data[2+keyIDSize:],
+	}
+	copy(signature.KeyID[:], data[2:])
+	if signature.Algorithm != algorithmLegacy && signature.Algorithm != algorithmPrehashed {
+		return nil, E.New("unsupported signature algorithm: ", signature.Algorithm)
+	}
+	trustedComment := strings.TrimRight(lines[2], "\r")
+	if !strings.HasPrefix(trustedComment, trustedCommentPrefix) {
+		return nil, E.New("missing trusted comment")
+	}
+	signature.TrustedComment = strings.TrimPrefix(trustedComment, trustedCommentPrefix)
+	signature.GlobalSignature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return nil, E.Cause(err, "decode global signature")
+	}
+	if len(signature.GlobalSignature) != ed25519.SignatureSize {
+		return nil, E.New("invalid global signature length: ", len(signature.GlobalSignature))
+	}
+	return signature, nil
+}
+
+// Verify verifies signature and trusted comment of content read from reader.
+func (k *PublicKey) Verify(signature *Signature, reader io.Reader) error {
+	if k.KeyID != signature.KeyID {
+		return E.New("key ID not matched")
+	}
+	var message []byte
+	switch signature.Algorithm {
+	case algorithmPrehashed:
+		hash, _ := blake2b.New512(nil)
+		_, err := io.Copy(hash, reader)
+		if err != nil {
+			return err
+		}
+		message = hash.Sum(nil)
+	default:
+		var err error
+		message, err = io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+	}
+	if !ed25519.Verify(k.Key, message, signature.Signature) {
+		return E.New("signature verification failed")
+	}
+	globalMessage := bytes.Join([][]byte{signature.Signature, []byte(signature.TrustedComment)}, nil)
+	if !ed25519.Verify(k.Key, globalMessage, signature.GlobalSignature) {
+		return E.New("trusted comment verification failed")
+	}
+	return nil
+}
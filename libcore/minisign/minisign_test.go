@@ -0,0 +1,61 @@
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func Test_Verify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte("12345678")
+	publicKeyText := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(bytes.Join([][]byte{[]byte(algorithmLegacy), keyID, publicKey}, nil)) + "\n"
+	key, err := ParsePublicKey(publicKeyText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("rule set content")
+	for _, algorithm := range []string{algorithmLegacy, algorithmPrehashed} {
+		t.Run(algorithm, func(t *testing.T) {
+			message := content
+			if algorithm == algorithmPrehashed {
+				sum := blake2b.Sum512(content)
+				message = sum[:]
+			}
+			signature := ed25519.Sign(privateKey, message)
+			const trustedComment = "timestamp:1700000000"
+			globalSignature := ed25519.Sign(privateKey, append(bytes.Clone(signature), trustedComment...))
+			signatureText := strings.Join([]string{
+				"untrusted comment: signature",
+				base64.StdEncoding.EncodeToString(bytes.Join([][]byte{[]byte(algorithm), keyID, signature}, nil)),
+				trustedCommentPrefix + trustedComment,
+				base64.StdEncoding.EncodeToString(globalSignature),
+			}, "\n")
+			sig, err := ParseSignature(signatureText)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = key.Verify(sig, bytes.NewReader(content))
+			if err != nil {
+				t.Errorf("verify: %v", err)
+			}
+			err = key.Verify(sig, strings.NewReader("tampered"))
+			if err == nil {
+				t.Error("expected error for tampered content")
+			}
+			sig.TrustedComment = "timestamp:0"
+			err = key.Verify(sig, bytes.NewReader(content))
+			if err == nil {
+				t.Error("expected error for tampered trusted comment")
+			}
+		})
+	}
+}
@@ -175,7 +175,7 @@ func (s *Service) handleGroupTest(conn io.ReadWriter, instance *boxInstance) err
 			return err
 		}
 	} else {
-		historyStorage := instance.api.HistoryStorage()
+		historyStorage := instance.api.URLTestHistory()
 		if historyStorage == nil {
 			return nil
 		}
@@ -297,15 +297,11 @@ func (s *Service) handleNewInstanceURLTest(conn io.ReadWriter) error {
 }
 
 func (s *Service) doURLTest(config, tag, link string, timeout int32) (int32, error) {
-	instance, err := newBoxInstance(config, s.platformInterface, true)
+	instance, err := s.newTemporaryInstance(config)
 	if err != nil {
-		return -1, E.Cause(err, "create instance")
+		return -1, err
 	}
 	defer instance.Close()
-	err = instance.Start()
-	if err != nil {
-		return -1, E.Cause(err, "start instance")
-	}
 	return instance.urlTest(tag, link, timeout)
 }
 
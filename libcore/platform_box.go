@@ -28,6 +28,8 @@ type boxPlatformInterfaceWrapper struct {
 	defaultInterfaceAccess sync.Mutex
 	defaultInterface       *control.Interface
 	forTest                bool
+	// onDefaultInterfaceUpdate is called after every UpdateDefaultInterface.
+	onDefaultInterfaceUpdate func()
 
 	// Set by interface monitor, which can't provide these information on Android.
 	/*isExpensive            bool
@@ -64,12 +66,6 @@ func (w *boxPlatformInterfaceWrapper) UsePlatformInterface() bool {
 }
 
 func (w *boxPlatformInterfaceWrapper) OpenInterface(options *tun.Options, platformOptions option.TunPlatformOptions) (tun.Tun, error) {
-	if len(options.IncludeUID) > 0 || len(options.ExcludeUID) > 0 {
-		return nil, E.New("android: unsupported uid options")
-	}
-	if len(options.IncludeAndroidUser) > 0 {
-		return nil, E.New("android: unsupported android_user option")
-	}
 	tunFd, err := w.iif.OpenTun()
 	if err != nil {
 		return nil, E.Cause(err, "iif.OpenTun")
@@ -237,6 +233,6 @@ func (w *boxPlatformInterfaceWrapper) SendNotification(_ *adapter.Notification)
 }
 
 func (w *boxPlatformInterfaceWrapper) SystemCertificates() []string {
-	// Already set in certs.go
+	// Already set in certs.go, with blocklist applied.
 	return nil
 }
@@ -0,0 +1,95 @@
+package juicity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// quicECHConfig fetches ECH config list from DNS before QUIC dials.
+// tls.ECHClientConfig only fetches in TLS handshake over stream, which QUIC does not use.
+type quicECHConfig struct {
+	tls.ECHCapableConfig
+	ctx             context.Context
+	dnsRouter       adapter.DNSRouter
+	queryServerName string
+
+	access sync.Mutex
+	expire time.Time
+}
+
+func newQUICECHConfig(ctx context.Context, config tls.ECHCapableConfig, queryServerName string) *quicECHConfig {
+	return &quicECHConfig{
+		ECHCapableConfig: config,
+		ctx:              ctx,
+		dnsRouter:        service.FromContext[adapter.DNSRouter](ctx),
+		queryServerName:  queryServerName,
+	}
+}
+
+func (c *quicECHConfig) STDConfig() (*tls.STDConfig, error) {
+	err := c.update()
+	if err != nil {
+		return nil, err
+	}
+	return c.ECHCapableConfig.STDConfig()
+}
+
+func (c *quicECHConfig) update() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if len(c.ECHConfigList()) > 0 && time.Now().Before(c.expire) {
+		return nil
+	}
+	if c.dnsRouter == nil {
+		return E.New("fetch ECH config list: missing DNS router")
+	}
+	queryServerName := c.queryServerName
+	if queryServerName == "" {
+		queryServerName = c.ServerName()
+	}
+	message := &mDNS.Msg{
+		MsgHdr: mDNS.MsgHdr{
+			RecursionDesired: true,
+		},
+		Question: []mDNS.Question{
+			{
+				Name:   mDNS.Fqdn(queryServerName),
+				Qtype:  mDNS.TypeHTTPS,
+				Qclass: mDNS.ClassINET,
+			},
+		},
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, C.DNSTimeout)
+	defer cancel()
+	response, err := c.dnsRouter.Exchange(ctx, message, adapter.DNSQueryOptions{})
+	if err != nil {
+		return E.Cause(err, "fetch ECH config list")
+	}
+	if response.Rcode != mDNS.RcodeSuccess {
+		return E.Cause(dns.RcodeError(response.Rcode), "fetch ECH config list")
+	}
+	for _, rr := range response.Answer {
+		https, isHTTPS := rr.(*mDNS.HTTPS)
+		if !isHTTPS {
+			continue
+		}
+		for _, value := range https.Value {
+			if echConfig, isECH := value.(*mDNS.SVCBECHConfig); isECH {
+				c.SetECHConfigList(echConfig.ECH)
+				c.expire = time.Now().Add(time.Duration(rr.Header().Ttl) * time.Second)
+				return nil
+			}
+		}
+	}
+	return E.New("no ECH config found in DNS records")
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"net"
 	"os"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/outbound"
@@ -32,12 +33,17 @@ func RegisterOutbound(registry *outbound.Registry) {
 	outbound.Register[pluginoption.JuicityOutboundOptions](registry, pluginoption.TypeJuicity, NewOutbound)
 }
 
-var _ adapter.Outbound = (*Outbound)(nil)
+var (
+	_ adapter.Outbound                = (*Outbound)(nil)
+	_ adapter.InterfaceUpdateListener = (*Outbound)(nil)
+)
 
 type Outbound struct {
 	outbound.Adapter
-	logger logger.ContextLogger
-	client *juicity.Client
+	logger     logger.ContextLogger
+	client     *juicity.Client
+	udpStats   udpStats
+	reconnects atomic.Int64
 }
 
 func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options pluginoption.JuicityOutboundOptions) (adapter.Outbound, error) {
@@ -52,10 +58,14 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if err != nil {
 		return nil, err
 	}
+	if options.TLS.UTLS != nil && options.TLS.UTLS.Enabled || options.TLS.Reality != nil && options.TLS.Reality.Enabled {
+		// QUIC handshakes by crypto/tls only.
+		return nil, E.New("uTLS and reality are not supported by QUIC")
+	}
 	if options.TLS.ALPN == nil { // not len(options.TLS.ALPN) > 0
 		options.TLS.ALPN = []string{"h3"}
 	}
-	tlsConfig, err := tls.NewSTDClient(ctx, logger, options.Server, *options.TLS)
+	tlsConfig, err := tls.NewClient(ctx, logger, options.Server, *options.TLS)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +74,10 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		if err != nil {
 			return nil, E.Cause(err, "decode pin cert sha256")
 		}
-		stdTLSConfig, _ := tlsConfig.STDConfig()
+		stdTLSConfig, err := tlsConfig.STDConfig()
+		if err != nil {
+			return nil, err
+		}
 		stdTLSConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
 			peerHash := raybridge.CertChainHash(rawCerts)
 			if !bytes.Equal(pinCertSha256, peerHash) {
@@ -74,17 +87,21 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		}
 		stdTLSConfig.InsecureSkipVerify = true
 	}
+	if echConfig, isECH := tlsConfig.(*tls.ECHClientConfig); isECH {
+		tlsConfig = newQUICECHConfig(ctx, echConfig.ECHCapableConfig, options.TLS.ECH.QueryServerName)
+	}
 	uuidInstance, err := uuid.FromString(options.UUID)
 	if err != nil {
 		return nil, err
 	}
 	client, err := juicity.NewClient(juicity.ClientOptions{
-		Context:       ctx,
-		Dialer:        outboundDialer,
-		ServerAddress: options.Build(),
-		TLSConfig:     tlsConfig,
-		UUID:          [uuid.Size]byte(uuidInstance.Bytes()),
-		Password:      options.Password,
+		Context:           ctx,
+		Dialer:            outboundDialer,
+		ServerAddress:     options.Build(),
+		TLSConfig:         tlsConfig,
+		UUID:              [uuid.Size]byte(uuidInstance.Bytes()),
+		Password:          options.Password,
+		CongestionControl: options.CongestionControl,
 	})
 	if err != nil {
 		return nil, err
@@ -100,6 +117,17 @@ func (o *Outbound) Close() error {
 	return o.client.CloseWithError(os.ErrClosed)
 }
 
+// InterfaceUpdated closes the QUIC connection, which is bound to the old network.
+func (o *Outbound) InterfaceUpdated() {
+	o.reconnects.Add(1)
+	_ = o.client.CloseWithError(E.New("network changed"))
+}
+
+// Reconnects returns count of connections reset by network changes.
+func (o *Outbound) Reconnects() int64 {
+	return o.reconnects.Load()
+}
+
 func (o *Outbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	switch network {
 	case N.NetworkTCP:
@@ -124,7 +152,12 @@ func (o *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (n
 	metadata.Outbound = o.Tag()
 	metadata.Destination = destination
 	o.logger.InfoContext(ctx, "outbound packet connection to ", destination)
-	return o.client.ListenPacket(ctx, destination)
+	packetConn, err := o.client.ListenPacket(ctx, destination)
+	if err != nil {
+		o.udpStats.failed.Add(1)
+		return nil, err
+	}
+	return o.udpStats.track(packetConn), nil
 }
 
 // https://github.com/juicity/juicity/blob/412dbe43e091788c5464eb2d6e9c169bdf39f19c/cmd/client/run.go#L86-L96
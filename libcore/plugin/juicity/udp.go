@@ -0,0 +1,56 @@
+package juicity
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"libcore/plugin/pluginoption"
+)
+
+// UDPStats returns the statistics of UDP sessions, each of them is carried by a stream of the QUIC connection.
+func (o *Outbound) UDPStats() pluginoption.UDPStats {
+	return pluginoption.UDPStats{
+		Active: o.udpStats.active.Load(),
+		Total:  o.udpStats.total.Load(),
+		Failed: o.udpStats.failed.Load(),
+	}
+}
+
+type udpStats struct {
+	active atomic.Int64
+	total  atomic.Int64
+	failed atomic.Int64
+}
+
+func (s *udpStats) track(conn net.PacketConn) net.PacketConn {
+	s.active.Add(1)
+	s.total.Add(1)
+	return &trackedPacketConn{PacketConn: conn, stats: s}
+}
+
+// trackedPacketConn counts active sessions, and is replaceable to keep copying with the stream directly.
+type trackedPacketConn struct {
+	net.PacketConn
+	stats     *udpStats
+	closeOnce sync.Once
+}
+
+func (c *trackedPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stats.active.Add(-1)
+	})
+	return c.PacketConn.Close()
+}
+
+func (c *trackedPacketConn) Upstream() any {
+	return c.PacketConn
+}
+
+func (c *trackedPacketConn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *trackedPacketConn) WriterReplaceable() bool {
+	return true
+}
@@ -0,0 +1,383 @@
+package plugindns
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	mRand "math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+	"golang.org/x/crypto/curve25519"
+)
+
+var _ adapter.DNSTransport = (*DNSCryptTransport)(nil)
+
+const defaultDNSCryptCertRefreshInterval = time.Hour
+
+// anonymizedDNSHeader prefixes queries sent to relays.
+// https://github.com/DNSCrypt/dnscrypt-protocol/blob/master/ANONYMIZED-DNSCRYPT.txt
+var anonymizedDNSHeader = [...]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00}
+
+func RegisterDNSCrypt(registry *dns.TransportRegistry) {
+	dns.RegisterTransport[pluginoption.DNSCryptDNSServerOptions](registry, pluginoption.DNSTypeDNSCrypt, NewDNSCrypt)
+}
+
+// DNSCryptTransport is a DNSCrypt v2 client. Queries are sent over UDP,
+// and resent over TCP if the response is truncated.
+type DNSCryptTransport struct {
+	dns.TransportAdapter
+	logger              logger.ContextLogger
+	dialer              N.Dialer
+	serverAddr          M.Socksaddr
+	providerKey         ed25519.PublicKey
+	providerName        string
+	relays              []M.Socksaddr
+	certRefreshInterval time.Duration
+
+	access  sync.Mutex
+	session *dnscryptSession
+	// fetching is closed when the certificate being fetched is stored.
+	fetching chan struct{}
+}
+
+// dnscryptSession is the state derived from a resolver certificate.
+type dnscryptSession struct {
+	*dnscryptCertificate
+	publicKey [32]byte
+	sharedKey [32]byte
+	fetched   time.Time
+}
+
+func NewDNSCrypt(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.DNSCryptDNSServerOptions) (adapter.DNSTransport, error) {
+	stamp, err := parseDNSCryptStamp(options.Stamp)
+	if err != nil {
+		return nil, E.Cause(err, "parse stamp")
+	}
+	relays := make([]M.Socksaddr, 0, len(options.Relays))
+	for _, rawRelay := range options.Relays {
+		relay, err := parseDNSCryptRelay(rawRelay)
+		if err != nil {
+			return nil, E.Cause(err, "parse relay ", rawRelay)
+		}
+		relays = append(relays, relay)
+	}
+	remoteOptions := option.RemoteDNSServerOptions{
+		RawLocalDNSServerOptions: options.RawLocalDNSServerOptions,
+		DNSServerAddressOptions: option.DNSServerAddressOptions{
+			Server:     stamp.serverAddr.AddrString(),
+			ServerPort: stamp.serverAddr.Port,
+		},
+	}
+	transportDialer, err := dns.NewRemoteDialer(ctx, remoteOptions)
+	if err != nil {
+		return nil, err
+	}
+	certRefreshInterval := time.Duration(options.CertRefreshInterval)
+	if certRefreshInterval <= 0 {
+		certRefreshInterval = defaultDNSCryptCertRefreshInterval
+	}
+	return &DNSCryptTransport{
+		TransportAdapter:    dns.NewTransportAdapterWithRemoteOptions(pluginoption.DNSTypeDNSCrypt, tag, remoteOptions),
+		logger:              logger,
+		dialer:              transportDialer,
+		serverAddr:          stamp.serverAddr,
+		providerKey:         stamp.providerKey,
+		providerName:        mDNS.Fqdn(stamp.providerName),
+		relays:              relays,
+		certRefreshInterval: certRefreshInterval,
+	}, nil
+}
+
+func (t *DNSCryptTransport) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	return dialer.InitializeDetour(t.dialer)
+}
+
+func (t *DNSCryptTransport) Close() error {
+	t.Reset()
+	return nil
+}
+
+// Reset drops the certificate, so that it will be fetched again.
+func (t *DNSCryptTransport) Reset() {
+	t.access.Lock()
+	t.session = nil
+	t.access.Unlock()
+}
+
+func (t *DNSCryptTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	session, err := t.getSession(ctx)
+	if err != nil {
+		return nil, E.Cause(err, "fetch certificate")
+	}
+	rawMessage, err := message.Pack()
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.exchangeEncrypted(ctx, session, N.NetworkUDP, rawMessage)
+	if err == nil && response.Truncated {
+		response, err = t.exchangeEncrypted(ctx, session, N.NetworkTCP, rawMessage)
+	}
+	if errors.Is(err, errDNSCryptDecrypt) {
+		// The resolver may have rotated its certificate.
+		t.access.Lock()
+		if t.session == session {
+			t.session = nil
+		}
+		t.access.Unlock()
+	}
+	return response, err
+}
+
+func (t *DNSCryptTransport) exchangeEncrypted(ctx context.Context, session *dnscryptSession, network string, rawMessage []byte) (*mDNS.Msg, error) {
+	minLength := 0
+	if network == N.NetworkUDP {
+		minLength = dnscryptMinUDPQueryLength - dnscryptQueryOverhead
+	}
+	var nonce [dnscryptNonceLength]byte
+	_, err := rand.Read(nonce[:dnscryptHalfNonceLength])
+	if err != nil {
+		return nil, err
+	}
+	padded := dnscryptPad(rawMessage, minLength)
+	query := make([]byte, 0, dnscryptQueryOverhead+len(padded))
+	query = append(query, session.clientMagic[:]...)
+	query = append(query, session.publicKey[:]...)
+	query = append(query, nonce[:dnscryptHalfNonceLength]...)
+	query = dnscryptSeal(query, session.esVersion, &session.sharedKey, &nonce, padded)
+
+	rawResponse, err := t.roundTrip(ctx, network, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(rawResponse) < dnscryptResponseOverhead || string(rawResponse[:len(dnscryptResolverMagic)]) != dnscryptResolverMagic {
+		return nil, E.New("unexpected response")
+	}
+	rawResponse = rawResponse[len(dnscryptResolverMagic):]
+	var responseNonce [dnscryptNonceLength]byte
+	copy(responseNonce[:], rawResponse)
+	if !bytes.Equal(responseNonce[:dnscryptHalfNonceLength], nonce[:dnscryptHalfNonceLength]) {
+		return nil, E.New("unexpected response nonce")
+	}
+	plaintext, err := dnscryptOpen(nil, session.esVersion, &session.sharedKey, &responseNonce, rawResponse[dnscryptNonceLength:])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err = dnscryptUnpad(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	var response mDNS.Msg
+	err = response.Unpack(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (t *DNSCryptTransport) getSession(ctx context.Context) (*dnscryptSession, error) {
+	for {
+		t.access.Lock()
+		now := time.Now()
+		session := t.session
+		if session != nil && session.validAt(now) && now.Sub(session.fetched) < t.certRefreshInterval {
+			t.access.Unlock()
+			return session, nil
+		}
+		fetching := t.fetching
+		if fetching == nil {
+			t.fetching = make(chan struct{})
+			t.access.Unlock()
+			return t.refreshSession(ctx)
+		}
+		t.access.Unlock()
+		// Keep using the valid certificate while another query is refreshing it.
+		if session != nil && session.validAt(now) {
+			return session, nil
+		}
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// refreshSession fetches the certificate without holding access, then stores it.
+func (t *DNSCryptTransport) refreshSession(ctx context.Context) (*dnscryptSession, error) {
+	session, err := t.fetchSession(ctx)
+	t.access.Lock()
+	defer t.access.Unlock()
+	close(t.fetching)
+	t.fetching = nil
+	now := time.Now()
+	if err != nil {
+		if t.session != nil && t.session.validAt(now) {
+			t.logger.WarnContext(ctx, "refresh certificate: ", err)
+			return t.session, nil
+		}
+		return nil, err
+	}
+	if t.session == nil || t.session.serial != session.serial {
+		t.logger.DebugContext(ctx, "use certificate serial ", session.serial, " for ", t.providerName)
+	}
+	t.session = session
+	return session, nil
+}
+
+func (t *DNSCryptTransport) fetchSession(ctx context.Context) (*dnscryptSession, error) {
+	query := new(mDNS.Msg)
+	query.SetQuestion(t.providerName, mDNS.TypeTXT)
+	rawQuery, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.exchangePlain(ctx, N.NetworkUDP, rawQuery)
+	if err == nil && response.Truncated {
+		response, err = t.exchangePlain(ctx, N.NetworkTCP, rawQuery)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var cert *dnscryptCertificate
+	for _, answer := range response.Answer {
+		txt, isTXT := answer.(*mDNS.TXT)
+		if !isTXT {
+			continue
+		}
+		var content []byte
+		for _, s := range txt.Txt {
+			part, err := unpackTXTString(s)
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, part...)
+		}
+		newCert, err := parseDNSCryptCertificate(content, t.providerKey)
+		if err != nil {
+			t.logger.DebugContext(ctx, "skip certificate: ", err)
+			continue
+		}
+		if !newCert.validAt(now) {
+			continue
+		}
+		if newCert.better(cert) {
+			cert = newCert
+		}
+	}
+	if cert == nil {
+		return nil, E.New("no valid certificate for ", t.providerName)
+	}
+	// Every certificate uses a new key pair.
+	var secretKey [32]byte
+	_, err = rand.Read(secretKey[:])
+	if err != nil {
+		return nil, err
+	}
+	session := &dnscryptSession{
+		dnscryptCertificate: cert,
+		fetched:             now,
+	}
+	publicKey, err := curve25519.X25519(secretKey[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	copy(session.publicKey[:], publicKey)
+	session.sharedKey, err = dnscryptSharedKey(cert.esVersion, &secretKey, &cert.resolverPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (t *DNSCryptTransport) exchangePlain(ctx context.Context, network string, rawQuery []byte) (*mDNS.Msg, error) {
+	rawResponse, err := t.roundTrip(ctx, network, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	var response mDNS.Msg
+	err = response.Unpack(rawResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// roundTrip sends packet to the resolver, through a random relay if configured.
+func (t *DNSCryptTransport) roundTrip(ctx context.Context, network string, packet []byte) ([]byte, error) {
+	destination := t.serverAddr
+	if len(t.relays) > 0 {
+		destination = t.relays[mRand.IntN(len(t.relays))]
+		relayed := make([]byte, 0, len(anonymizedDNSHeader)+net.IPv6len+2+len(packet))
+		relayed = append(relayed, anonymizedDNSHeader[:]...)
+		serverIP := t.serverAddr.Addr.As16()
+		relayed = append(relayed, serverIP[:]...)
+		relayed = binary.BigEndian.AppendUint16(relayed, t.serverAddr.Port)
+		packet = append(relayed, packet...)
+	}
+	conn, err := t.dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, loaded := ctx.Deadline(); loaded {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(C.DNSTimeout))
+	}
+	if network == N.NetworkUDP {
+		_, err = conn.Write(packet)
+		if err != nil {
+			return nil, err
+		}
+		buffer := buf.NewSize(mDNS.MaxMsgSize)
+		defer buffer.Release()
+		_, err = buffer.ReadOnceFrom(conn)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), buffer.Bytes()...), nil
+	}
+	request := make([]byte, 0, 2+len(packet))
+	request = binary.BigEndian.AppendUint16(request, uint16(len(packet)))
+	request = append(request, packet...)
+	_, err = conn.Write(request)
+	if err != nil {
+		return nil, err
+	}
+	var length uint16
+	err = binary.Read(conn, binary.BigEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]byte, length)
+	_, err = io.ReadFull(conn, response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
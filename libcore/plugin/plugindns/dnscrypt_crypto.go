@@ -0,0 +1,223 @@
+package plugindns
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/binary"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/poly1305"
+)
+
+// https://dnscrypt.info/protocol
+const (
+	dnscryptCertMagic         = "DNSC"
+	dnscryptResolverMagic     = "r6fnvWj8"
+	dnscryptESXSalsa20        = 0x0001
+	dnscryptESXChaCha20       = 0x0002
+	dnscryptCertLength        = 124
+	dnscryptClientMagicLength = 8
+	dnscryptHalfNonceLength   = 12
+	dnscryptNonceLength       = 24
+	dnscryptTagLength         = 16
+	dnscryptQueryOverhead     = dnscryptClientMagicLength + 32 + dnscryptHalfNonceLength + dnscryptTagLength
+	dnscryptResponseOverhead  = len(dnscryptResolverMagic) + dnscryptNonceLength + dnscryptTagLength
+	dnscryptMinUDPQueryLength = 256
+)
+
+var errDNSCryptDecrypt = E.New("dnscrypt: decryption failed")
+
+type dnscryptCertificate struct {
+	esVersion         uint16
+	resolverPublicKey [32]byte
+	clientMagic       [dnscryptClientMagicLength]byte
+	serial            uint32
+	notBefore         time.Time
+	notAfter          time.Time
+}
+
+func parseDNSCryptCertificate(content []byte, providerKey ed25519.PublicKey) (*dnscryptCertificate, error) {
+	if len(content) < dnscryptCertLength {
+		return nil, E.New("certificate too short")
+	}
+	if string(content[:4]) != dnscryptCertMagic {
+		return nil, E.New("invalid certificate magic")
+	}
+	cert := &dnscryptCertificate{
+		esVersion: binary.BigEndian.Uint16(content[4:6]),
+	}
+	switch cert.esVersion {
+	case dnscryptESXSalsa20, dnscryptESXChaCha20:
+	default:
+		return nil, E.New("unsupported crypto construction: ", cert.esVersion)
+	}
+	signature, signed := content[8:72], content[72:]
+	if !ed25519.Verify(providerKey, signed, signature) {
+		return nil, E.New("invalid certificate signature")
+	}
+	copy(cert.resolverPublicKey[:], signed[:32])
+	copy(cert.clientMagic[:], signed[32:40])
+	cert.serial = binary.BigEndian.Uint32(signed[40:44])
+	cert.notBefore = time.Unix(int64(binary.BigEndian.Uint32(signed[44:48])), 0)
+	cert.notAfter = time.Unix(int64(binary.BigEndian.Uint32(signed[48:52])), 0)
+	return cert, nil
+}
+
+// better reports whether cert should be used instead of other.
+func (c *dnscryptCertificate) better(other *dnscryptCertificate) bool {
+	if other == nil {
+		return true
+	}
+	if c.serial != other.serial {
+		return c.serial > other.serial
+	}
+	return c.esVersion > other.esVersion
+}
+
+func (c *dnscryptCertificate) validAt(now time.Time) bool {
+	return !now.Before(c.notBefore) && now.Before(c.notAfter)
+}
+
+func dnscryptSharedKey(esVersion uint16, secretKey, publicKey *[32]byte) ([32]byte, error) {
+	var sharedKey [32]byte
+	switch esVersion {
+	case dnscryptESXSalsa20:
+		box.Precompute(&sharedKey, publicKey, secretKey)
+	case dnscryptESXChaCha20:
+		rawKey, err := curve25519.X25519(secretKey[:], publicKey[:])
+		if err != nil {
+			return sharedKey, err
+		}
+		subKey, err := chacha20.HChaCha20(rawKey, make([]byte, 16))
+		if err != nil {
+			return sharedKey, err
+		}
+		copy(sharedKey[:], subKey)
+	default:
+		return sharedKey, E.New("unsupported crypto construction: ", esVersion)
+	}
+	return sharedKey, nil
+}
+
+// dnscryptSeal appends the tag and encrypted message to out.
+func dnscryptSeal(out []byte, esVersion uint16, key *[32]byte, nonce *[dnscryptNonceLength]byte, message []byte) []byte {
+	if esVersion == dnscryptESXSalsa20 {
+		return secretbox.Seal(out, message, nonce, key)
+	}
+	cipher, firstBlock := xchacha20FirstBlock(key, nonce)
+	ret := append(out, make([]byte, dnscryptTagLength+len(message))...)
+	tag, ciphertext := ret[len(out):len(out)+dnscryptTagLength], ret[len(out)+dnscryptTagLength:]
+	n := min(len(message), 32)
+	subtle.XORBytes(ciphertext[:n], message[:n], firstBlock[32:32+n])
+	cipher.XORKeyStream(ciphertext[n:], message[n:])
+	var polyKey [32]byte
+	copy(polyKey[:], firstBlock[:32])
+	var sum [dnscryptTagLength]byte
+	poly1305.Sum(&sum, ciphertext, &polyKey)
+	copy(tag, sum[:])
+	return ret
+}
+
+// dnscryptOpen appends the decrypted message to out.
+func dnscryptOpen(out []byte, esVersion uint16, key *[32]byte, nonce *[dnscryptNonceLength]byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < dnscryptTagLength {
+		return nil, errDNSCryptDecrypt
+	}
+	if esVersion == dnscryptESXSalsa20 {
+		message, ok := secretbox.Open(out, sealed, nonce, key)
+		if !ok {
+			return nil, errDNSCryptDecrypt
+		}
+		return message, nil
+	}
+	cipher, firstBlock := xchacha20FirstBlock(key, nonce)
+	var (
+		polyKey [32]byte
+		tag     [dnscryptTagLength]byte
+	)
+	copy(polyKey[:], firstBlock[:32])
+	copy(tag[:], sealed)
+	ciphertext := sealed[dnscryptTagLength:]
+	if !poly1305.Verify(&tag, ciphertext, &polyKey) {
+		return nil, errDNSCryptDecrypt
+	}
+	ret := append(out, make([]byte, len(ciphertext))...)
+	message := ret[len(out):]
+	n := min(len(ciphertext), 32)
+	subtle.XORBytes(message[:n], ciphertext[:n], firstBlock[32:32+n])
+	cipher.XORKeyStream(message[n:], ciphertext[n:])
+	return ret, nil
+}
+
+// xchacha20FirstBlock returns the cipher positioned at the second block, with the first block
+// used as Poly1305 key and for the first 32 bytes of message, like libsodium secretbox.
+func xchacha20FirstBlock(key *[32]byte, nonce *[dnscryptNonceLength]byte) (*chacha20.Cipher, [64]byte) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	if err != nil {
+		panic(err)
+	}
+	var firstBlock [64]byte
+	cipher.XORKeyStream(firstBlock[:], firstBlock[:])
+	return cipher, firstBlock
+}
+
+// dnscryptPad uses ISO/IEC 7816-4 padding to a multiple of 64 bytes, and at least minLength.
+func dnscryptPad(packet []byte, minLength int) []byte {
+	length := max(len(packet)+1, minLength)
+	length = (length + 63) &^ 63
+	padded := make([]byte, length)
+	copy(padded, packet)
+	padded[len(packet)] = 0x80
+	return padded
+}
+
+func dnscryptUnpad(packet []byte) ([]byte, error) {
+	index := bytes.LastIndexByte(packet, 0x80)
+	if index < 0 {
+		return nil, E.New("invalid padding")
+	}
+	for _, b := range packet[index+1:] {
+		if b != 0 {
+			return nil, E.New("invalid padding")
+		}
+	}
+	return packet[:index], nil
+}
+
+// unpackTXTString reverses the escaping of miekg/dns TXT strings.
+func unpackTXTString(s string) ([]byte, error) {
+	content := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			content = append(content, c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return nil, E.New("invalid escape in TXT string")
+		}
+		if i+2 < len(s) && isDigit(s[i]) && isDigit(s[i+1]) && isDigit(s[i+2]) {
+			value := int(s[i]-'0')*100 + int(s[i+1]-'0')*10 + int(s[i+2]-'0')
+			if value > 255 {
+				return nil, E.New("invalid escape in TXT string")
+			}
+			content = append(content, byte(value))
+			i += 2
+			continue
+		}
+		content = append(content, s[i])
+	}
+	return content, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
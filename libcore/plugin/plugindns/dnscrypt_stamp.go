@@ -0,0 +1,143 @@
+package plugindns
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// https://dnscrypt.info/stamps-specifications
+const (
+	stampScheme             = "sdns://"
+	stampProtoDNSCrypt      = 0x01
+	stampProtoDNSCryptRelay = 0x81
+	stampDefaultPort        = 443
+)
+
+type dnscryptServerStamp struct {
+	props        uint64
+	serverAddr   M.Socksaddr
+	providerKey  ed25519.PublicKey
+	providerName string
+}
+
+func parseDNSCryptStamp(stamp string) (*dnscryptServerStamp, error) {
+	content, err := decodeStamp(stamp)
+	if err != nil {
+		return nil, err
+	}
+	if content[0] != stampProtoDNSCrypt {
+		return nil, E.New("not a DNSCrypt stamp")
+	}
+	content = content[1:]
+	if len(content) < 8 {
+		return nil, E.New("stamp too short")
+	}
+	server := &dnscryptServerStamp{
+		props: binary.LittleEndian.Uint64(content),
+	}
+	content = content[8:]
+	rawAddr, content, err := readStampString(content)
+	if err != nil {
+		return nil, E.Cause(err, "read address")
+	}
+	server.serverAddr, err = parseStampAddr(string(rawAddr))
+	if err != nil {
+		return nil, err
+	}
+	providerKey, content, err := readStampString(content)
+	if err != nil {
+		return nil, E.Cause(err, "read provider public key")
+	}
+	if len(providerKey) != ed25519.PublicKeySize {
+		return nil, E.New("invalid provider public key length: ", len(providerKey))
+	}
+	server.providerKey = providerKey
+	providerName, content, err := readStampString(content)
+	if err != nil {
+		return nil, E.Cause(err, "read provider name")
+	}
+	if len(providerName) == 0 {
+		return nil, E.New("empty provider name")
+	}
+	if len(content) > 0 {
+		return nil, E.New("unexpected trailing data in stamp")
+	}
+	server.providerName = string(providerName)
+	return server, nil
+}
+
+// parseDNSCryptRelay accepts a relay stamp or a plain address.
+func parseDNSCryptRelay(relay string) (M.Socksaddr, error) {
+	if !strings.HasPrefix(relay, stampScheme) {
+		return parseStampAddr(relay)
+	}
+	content, err := decodeStamp(relay)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	if content[0] != stampProtoDNSCryptRelay {
+		return M.Socksaddr{}, E.New("not a DNSCrypt relay stamp")
+	}
+	rawAddr, content, err := readStampString(content[1:])
+	if err != nil {
+		return M.Socksaddr{}, E.Cause(err, "read address")
+	}
+	if len(content) > 0 {
+		return M.Socksaddr{}, E.New("unexpected trailing data in stamp")
+	}
+	return parseStampAddr(string(rawAddr))
+}
+
+func decodeStamp(stamp string) ([]byte, error) {
+	encoded, isStamp := strings.CutPrefix(stamp, stampScheme)
+	if !isStamp {
+		return nil, E.New("stamp must start with ", stampScheme)
+	}
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, E.Cause(err, "decode stamp")
+	}
+	if len(content) == 0 {
+		return nil, E.New("empty stamp")
+	}
+	return content, nil
+}
+
+func readStampString(content []byte) (value []byte, remaining []byte, err error) {
+	if len(content) == 0 {
+		return nil, nil, E.New("unexpected end of stamp")
+	}
+	length := int(content[0])
+	content = content[1:]
+	if len(content) < length {
+		return nil, nil, E.New("unexpected end of stamp")
+	}
+	return content[:length], content[length:], nil
+}
+
+// parseStampAddr parses IP with optional port. The port defaults to 443.
+func parseStampAddr(addr string) (M.Socksaddr, error) {
+	host, port := addr, stampDefaultPort
+	if !strings.HasPrefix(addr, "[") || !strings.HasSuffix(addr, "]") {
+		rawHost, rawPort, err := net.SplitHostPort(addr)
+		if err == nil {
+			host = rawHost
+			port, err = strconv.Atoi(rawPort)
+			if err != nil || port <= 0 || port > 65535 {
+				return M.Socksaddr{}, E.New("invalid port: ", rawPort)
+			}
+		}
+	}
+	serverAddr := M.ParseSocksaddrHostPort(strings.Trim(host, "[]"), uint16(port))
+	if !serverAddr.IsIP() {
+		return M.Socksaddr{}, E.New("address must be an IP: ", addr)
+	}
+	return serverAddr, nil
+}
@@ -0,0 +1,382 @@
+package plugindns
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	M "github.com/sagernet/sing/common/metadata"
+
+	mDNS "github.com/miekg/dns"
+	"golang.org/x/crypto/curve25519"
+)
+
+const testProviderName = "2.dnscrypt-cert.example.com."
+
+// testDNSCryptServer is a DNSCrypt resolver answering A queries with 127.0.0.1.
+type testDNSCryptServer struct {
+	t           *testing.T
+	providerKey ed25519.PrivateKey
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+	// certQueries is count of certificate queries.
+	certQueries atomic.Int32
+
+	access      sync.Mutex
+	esVersion   uint16
+	serial      uint32
+	secretKey   [32]byte
+	clientMagic [8]byte
+}
+
+func newTestDNSCryptServer(t *testing.T, esVersion uint16) *testDNSCryptServer {
+	_, providerKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpListener, err := net.Listen("tcp", udpConn.LocalAddr().String())
+	if err != nil {
+		udpConn.Close()
+		t.Fatal(err)
+	}
+	server := &testDNSCryptServer{
+		t:           t,
+		providerKey: providerKey,
+		udpConn:     udpConn,
+		tcpListener: tcpListener,
+		esVersion:   esVersion,
+	}
+	server.rotate()
+	go server.serveUDP()
+	go server.serveTCP()
+	return server
+}
+
+func (s *testDNSCryptServer) Close() {
+	s.udpConn.Close()
+	s.tcpListener.Close()
+}
+
+func (s *testDNSCryptServer) addr() M.Socksaddr {
+	return M.SocksaddrFromNet(s.udpConn.LocalAddr())
+}
+
+func (s *testDNSCryptServer) stamp() string {
+	addr := s.addr().String()
+	content := []byte{stampProtoDNSCrypt}
+	content = binary.LittleEndian.AppendUint64(content, 0)
+	content = append(content, byte(len(addr)))
+	content = append(content, addr...)
+	publicKey := s.providerKey.Public().(ed25519.PublicKey)
+	content = append(content, byte(len(publicKey)))
+	content = append(content, publicKey...)
+	content = append(content, byte(len(testProviderName)-1))
+	content = append(content, testProviderName[:len(testProviderName)-1]...)
+	return stampScheme + base64.RawURLEncoding.EncodeToString(content)
+}
+
+// rotate replaces the resolver key and certificate.
+func (s *testDNSCryptServer) rotate() {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.serial++
+	_, _ = rand.Read(s.secretKey[:])
+	_, _ = rand.Read(s.clientMagic[:])
+}
+
+func (s *testDNSCryptServer) certificate() []byte {
+	s.access.Lock()
+	defer s.access.Unlock()
+	publicKey, err := curve25519.X25519(s.secretKey[:], curve25519.Basepoint)
+	if err != nil {
+		s.t.Error(err)
+	}
+	now := time.Now()
+	signed := append([]byte(nil), publicKey...)
+	signed = append(signed, s.clientMagic[:]...)
+	signed = binary.BigEndian.AppendUint32(signed, s.serial)
+	signed = binary.BigEndian.AppendUint32(signed, uint32(now.Add(-time.Hour).Unix()))
+	signed = binary.BigEndian.AppendUint32(signed, uint32(now.Add(time.Hour).Unix()))
+	cert := []byte(dnscryptCertMagic)
+	cert = binary.BigEndian.AppendUint16(cert, s.esVersion)
+	cert = append(cert, 0, 0)
+	cert = append(cert, ed25519.Sign(s.providerKey, signed)...)
+	return append(cert, signed...)
+}
+
+func (s *testDNSCryptServer) serveUDP() {
+	buffer := make([]byte, mDNS.MaxMsgSize)
+	for {
+		n, addr, err := s.udpConn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		response := s.handle(s.unwrapRelayed(buffer[:n]), true)
+		if response != nil {
+			_, _ = s.udpConn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *testDNSCryptServer) serveTCP() {
+	for {
+		conn, err := s.tcpListener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length uint16
+			err := binary.Read(conn, binary.BigEndian, &length)
+			if err != nil {
+				return
+			}
+			packet := make([]byte, length)
+			_, err = io.ReadFull(conn, packet)
+			if err != nil {
+				return
+			}
+			response := s.handle(s.unwrapRelayed(packet), false)
+			if response == nil {
+				return
+			}
+			_, _ = conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(response))))
+			_, _ = conn.Write(response)
+		}()
+	}
+}
+
+// unwrapRelayed makes the server act as the relay of itself.
+func (s *testDNSCryptServer) unwrapRelayed(packet []byte) []byte {
+	if !bytes.HasPrefix(packet, anonymizedDNSHeader[:]) {
+		return packet
+	}
+	packet = packet[len(anonymizedDNSHeader):]
+	serverAddr := s.addr()
+	if [16]byte(packet[:net.IPv6len]) != serverAddr.Addr.As16() || binary.BigEndian.Uint16(packet[net.IPv6len:]) != serverAddr.Port {
+		s.t.Error("unexpected relay target")
+	}
+	return packet[net.IPv6len+2:]
+}
+
+func (s *testDNSCryptServer) handle(packet []byte, udp bool) []byte {
+	s.access.Lock()
+	esVersion, secretKey, clientMagic := s.esVersion, s.secretKey, s.clientMagic
+	s.access.Unlock()
+	if !bytes.HasPrefix(packet, clientMagic[:]) {
+		var query mDNS.Msg
+		if query.Unpack(packet) != nil {
+			return nil
+		}
+		s.certQueries.Add(1)
+		response := new(mDNS.Msg)
+		response.SetReply(&query)
+		var escaped strings.Builder
+		for _, b := range s.certificate() {
+			_, _ = fmt.Fprintf(&escaped, "\\%03d", b)
+		}
+		response.Answer = append(response.Answer, &mDNS.TXT{
+			Hdr: mDNS.RR_Header{Name: testProviderName, Rrtype: mDNS.TypeTXT, Class: mDNS.ClassINET, Ttl: 60},
+			Txt: []string{escaped.String()},
+		})
+		rawResponse, _ := response.Pack()
+		return rawResponse
+	}
+	if udp && len(packet) < dnscryptMinUDPQueryLength {
+		s.t.Errorf("UDP query not padded: %d", len(packet))
+	}
+	var clientKey [32]byte
+	copy(clientKey[:], packet[8:40])
+	var nonce [dnscryptNonceLength]byte
+	copy(nonce[:], packet[40:52])
+	sharedKey, err := dnscryptSharedKey(esVersion, &secretKey, &clientKey)
+	if err != nil {
+		s.t.Error(err)
+		return nil
+	}
+	plaintext, err := dnscryptOpen(nil, esVersion, &sharedKey, &nonce, packet[52:])
+	if err != nil {
+		s.t.Error(err)
+		return nil
+	}
+	plaintext, err = dnscryptUnpad(plaintext)
+	if err != nil {
+		s.t.Error(err)
+		return nil
+	}
+	var query mDNS.Msg
+	err = query.Unpack(plaintext)
+	if err != nil {
+		s.t.Error(err)
+		return nil
+	}
+	response := new(mDNS.Msg)
+	response.SetReply(&query)
+	if udp && query.Question[0].Name == "truncated.example.com." {
+		response.Truncated = true
+	} else {
+		response.Answer = append(response.Answer, &mDNS.A{
+			Hdr: mDNS.RR_Header{Name: query.Question[0].Name, Rrtype: mDNS.TypeA, Class: mDNS.ClassINET, Ttl: 60},
+			A:   net.IPv4(127, 0, 0, 1),
+		})
+	}
+	rawResponse, _ := response.Pack()
+	_, _ = rand.Read(nonce[dnscryptHalfNonceLength:])
+	sealed := append([]byte(dnscryptResolverMagic), nonce[:]...)
+	return dnscryptSeal(sealed, esVersion, &sharedKey, &nonce, dnscryptPad(rawResponse, 0))
+}
+
+func Test_DNSCryptTransportFetchOnce(t *testing.T) {
+	server := newTestDNSCryptServer(t, dnscryptESXChaCha20)
+	defer server.Close()
+	ctx := context.Background()
+	rawTransport, err := NewDNSCrypt(ctx, log.StdLogger(), "dnscrypt", pluginoption.DNSCryptDNSServerOptions{
+		Stamp: server.stamp(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := rawTransport.(*DNSCryptTransport)
+	defer transport.Close()
+	err = transport.Start(adapter.StartStateStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var group sync.WaitGroup
+	for range 8 {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			request := new(mDNS.Msg)
+			request.SetQuestion("example.com.", mDNS.TypeA)
+			_, err := transport.Exchange(ctx, request)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	group.Wait()
+	if queries := server.certQueries.Load(); queries != 1 {
+		t.Errorf("certificate should be fetched once, got %d", queries)
+	}
+}
+
+func Test_DNSCryptTransport(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		esVersion uint16
+		relay     bool
+	}{
+		{name: "xsalsa20", esVersion: dnscryptESXSalsa20},
+		{name: "xchacha20", esVersion: dnscryptESXChaCha20},
+		{name: "relay", esVersion: dnscryptESXChaCha20, relay: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestDNSCryptServer(t, tt.esVersion)
+			defer server.Close()
+			options := pluginoption.DNSCryptDNSServerOptions{
+				Stamp: server.stamp(),
+			}
+			if tt.relay {
+				options.Relays = []string{server.addr().String()}
+			}
+			ctx := context.Background()
+			rawTransport, err := NewDNSCrypt(ctx, log.StdLogger(), "dnscrypt", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := rawTransport.(*DNSCryptTransport)
+			defer transport.Close()
+			err = transport.Start(adapter.StartStateStart)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exchange := func(name string) {
+				request := new(mDNS.Msg)
+				request.SetQuestion(name, mDNS.TypeA)
+				response, err := transport.Exchange(ctx, request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response.Id != request.Id || response.Truncated || len(response.Answer) != 1 {
+					t.Fatalf("unexpected response: %s", response)
+				}
+			}
+			exchange("example.com.")
+			// Truncated UDP responses should be resent over TCP.
+			exchange("truncated.example.com.")
+
+			server.rotate()
+			transport.certRefreshInterval = time.Nanosecond
+			exchange("example.com.")
+			if serial := transport.session.serial; serial != 2 {
+				t.Errorf("certificate should be rotated, got serial %d", serial)
+			}
+		})
+	}
+}
+
+func Test_parseStampAddr(t *testing.T) {
+	for _, tt := range []struct {
+		addr     string
+		expected M.Socksaddr
+		fail     bool
+	}{
+		{addr: "1.1.1.1", expected: M.SocksaddrFrom(netip.MustParseAddr("1.1.1.1"), 443)},
+		{addr: "1.1.1.1:5353", expected: M.SocksaddrFrom(netip.MustParseAddr("1.1.1.1"), 5353)},
+		{addr: "[2606:4700::1111]", expected: M.SocksaddrFrom(netip.MustParseAddr("2606:4700::1111"), 443)},
+		{addr: "[2606:4700::1111]:8443", expected: M.SocksaddrFrom(netip.MustParseAddr("2606:4700::1111"), 8443)},
+		{addr: "example.com:443", fail: true},
+		{addr: "1.1.1.1:0", fail: true},
+	} {
+		addr, err := parseStampAddr(tt.addr)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.addr, err)
+			continue
+		}
+		if addr != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.addr, tt.expected, addr)
+		}
+	}
+}
+
+func Test_unpackTXTString(t *testing.T) {
+	content, err := unpackTXTString(`DN\"\\\000\255`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, []byte{'D', 'N', '"', '\\', 0, 255}) {
+		t.Errorf("unexpected content: %v", content)
+	}
+	_, err = unpackTXTString(`\`)
+	if err == nil {
+		t.Error("expected error for trailing backslash")
+	}
+}
@@ -0,0 +1,165 @@
+package plugindns
+
+import (
+	"context"
+	"net"
+
+	"libcore/dnsfilter"
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var (
+	_ adapter.DNSTransport = (*FilterTransport)(nil)
+	_ FilterHitsTransport  = (*FilterTransport)(nil)
+)
+
+const (
+	FilterBlockModeNXDomain = "nxdomain"
+	FilterBlockModeNullIP   = "null_ip"
+	FilterBlockModeRefused  = "refused"
+)
+
+const filterBlockTTL = 60
+
+func RegisterFilter(registry *dns.TransportRegistry) {
+	dns.RegisterTransport[pluginoption.FilterDNSServerOptions](registry, pluginoption.DNSTypeFilter, NewFilter)
+}
+
+// FilterListHits is the statistics of a list in a filter transport.
+type FilterListHits struct {
+	Tag   string
+	Rules int
+	Hits  int64
+}
+
+// FilterHitsTransport is a transport providing statistics of its lists.
+type FilterHitsTransport interface {
+	ListHits() []FilterListHits
+}
+
+// FilterTransport answers queries blocked by lists, and forwards others to the server.
+type FilterTransport struct {
+	dns.TransportAdapter
+	ctx       context.Context
+	logger    logger.ContextLogger
+	server    string
+	transport adapter.DNSTransport
+	blockMode string
+	filter    *dnsfilter.Filter
+}
+
+func NewFilter(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.FilterDNSServerOptions) (adapter.DNSTransport, error) {
+	if options.Server == "" {
+		return nil, E.New("missing server")
+	}
+	if options.Server == tag {
+		return nil, E.New("server can't be itself")
+	}
+	blockMode := options.BlockMode
+	switch blockMode {
+	case "":
+		blockMode = FilterBlockModeNXDomain
+	case FilterBlockModeNXDomain, FilterBlockModeNullIP, FilterBlockModeRefused:
+	default:
+		return nil, E.New("unknown block mode: ", blockMode)
+	}
+	filter := dnsfilter.New()
+	for i, list := range options.Lists {
+		if list.Path == "" {
+			return nil, E.New("missing path of list[", i, "]")
+		}
+		listTag := list.Tag
+		if listTag == "" {
+			listTag = list.Path
+		}
+		err := filter.LoadFile(listTag, list.Path)
+		if err != nil {
+			return nil, E.Cause(err, "load list ", listTag)
+		}
+	}
+	return &FilterTransport{
+		TransportAdapter: dns.NewTransportAdapter(pluginoption.DNSTypeFilter, tag, []string{options.Server}),
+		ctx:              ctx,
+		logger:           logger,
+		server:           options.Server,
+		blockMode:        blockMode,
+		filter:           filter,
+	}, nil
+}
+
+func (t *FilterTransport) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	transportManager := service.FromContext[adapter.DNSTransportManager](t.ctx)
+	if transportManager == nil {
+		return E.New("missing DNS transport manager")
+	}
+	transport, loaded := transportManager.Transport(t.server)
+	if !loaded {
+		return E.New("server not found: ", t.server)
+	}
+	t.transport = transport
+	return nil
+}
+
+func (t *FilterTransport) Close() error {
+	return nil
+}
+
+func (t *FilterTransport) Reset() {
+}
+
+func (t *FilterTransport) ListHits() []FilterListHits {
+	lists := t.filter.Lists()
+	hits := make([]FilterListHits, 0, len(lists))
+	for _, list := range lists {
+		hits = append(hits, FilterListHits{
+			Tag:   list.Tag,
+			Rules: list.Rules,
+			Hits:  list.Hits(),
+		})
+	}
+	return hits
+}
+
+func (t *FilterTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if len(message.Question) == 1 {
+		question := message.Question[0]
+		if list, blocked := t.filter.Match(question.Name); blocked {
+			t.logger.DebugContext(ctx, "blocked ", question.Name, " by ", list.Tag)
+			return t.blockResponse(message), nil
+		}
+	}
+	return t.transport.Exchange(ctx, message)
+}
+
+func (t *FilterTransport) blockResponse(message *mDNS.Msg) *mDNS.Msg {
+	response := new(mDNS.Msg)
+	response.SetReply(message)
+	switch t.blockMode {
+	case FilterBlockModeRefused:
+		response.Rcode = mDNS.RcodeRefused
+	case FilterBlockModeNullIP:
+		question := message.Question[0]
+		header := mDNS.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: mDNS.ClassINET, Ttl: filterBlockTTL}
+		switch question.Qtype {
+		case mDNS.TypeA:
+			response.Answer = append(response.Answer, &mDNS.A{Hdr: header, A: net.IPv4zero})
+		case mDNS.TypeAAAA:
+			response.Answer = append(response.Answer, &mDNS.AAAA{Hdr: header, AAAA: net.IPv6zero})
+		}
+	default:
+		response.Rcode = mDNS.RcodeNameError
+	}
+	return response
+}
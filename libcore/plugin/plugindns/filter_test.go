@@ -0,0 +1,64 @@
+package plugindns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/log"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func Test_Filter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.txt")
+	err := os.WriteFile(path, []byte("||ads.example.com^\n@@||safe.ads.example.com^\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		blockMode string
+		rcode     int
+		answers   int
+	}{
+		{blockMode: "", rcode: mDNS.RcodeNameError},
+		{blockMode: FilterBlockModeNullIP, rcode: mDNS.RcodeSuccess, answers: 1},
+		{blockMode: FilterBlockModeRefused, rcode: mDNS.RcodeRefused},
+	} {
+		rawTransport, err := NewFilter(context.Background(), log.StdLogger(), "filter", pluginoption.FilterDNSServerOptions{
+			Server:    "remote",
+			Lists:     []pluginoption.FilterListOptions{{Tag: "ads", Path: path}},
+			BlockMode: tt.blockMode,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport := rawTransport.(*FilterTransport)
+		transport.transport = &stubDNSTransport{address: "1.1.1.1"}
+
+		request := new(mDNS.Msg)
+		request.SetQuestion("x.ads.example.com.", mDNS.TypeA)
+		response, err := transport.Exchange(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Rcode != tt.rcode || len(response.Answer) != tt.answers {
+			t.Errorf("%s: unexpected response: %s", tt.blockMode, response)
+		}
+
+		request.SetQuestion("safe.ads.example.com.", mDNS.TypeA)
+		response, err = transport.Exchange(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Answer) != 1 || rrAddress(response.Answer[0]).String() != "1.1.1.1" {
+			t.Errorf("%s: allowed query should be forwarded: %s", tt.blockMode, response)
+		}
+		if hits := transport.ListHits(); hits[0].Hits != 1 || hits[0].Rules != 2 {
+			t.Errorf("%s: unexpected hits: %+v", tt.blockMode, hits)
+		}
+	}
+}
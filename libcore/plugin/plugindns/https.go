@@ -0,0 +1,267 @@
+package plugindns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/dns/transport"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	sHTTP "github.com/sagernet/sing/protocol/http"
+
+	mDNS "github.com/miekg/dns"
+	"golang.org/x/net/http2"
+)
+
+var (
+	_ adapter.DNSTransport = (*HTTPSTransport)(nil)
+	_ MetricsTransport     = (*HTTPSTransport)(nil)
+)
+
+func RegisterHTTPS(registry *dns.TransportRegistry) {
+	dns.RegisterTransport[pluginoption.RemoteHTTPSDNSServerOptions](registry, C.DNSTypeHTTPS, NewHTTPS)
+}
+
+var errFallback = E.New("fallback to HTTP/1.1")
+
+// HTTPSTransport multiplexes queries on HTTP/2 connections,
+// and falls back to HTTP/1.1 if the server doesn't support HTTP/2.
+type HTTPSTransport struct {
+	*transport.BaseTransport
+	logger         logger.ContextLogger
+	dialer         N.Dialer
+	tlsDialer      tls.Dialer
+	serverAddr     M.Socksaddr
+	destination    *url.URL
+	headers        http.Header
+	http2Transport *http2.Transport
+	httpTransport  *http.Transport
+	fallback       atomic.Bool
+	connections    *multiplexPool[*http2.ClientConn]
+	metrics        *transportMetrics
+}
+
+func NewHTTPS(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.RemoteHTTPSDNSServerOptions) (adapter.DNSTransport, error) {
+	transportDialer, err := dns.NewRemoteDialer(ctx, options.RemoteDNSServerOptions)
+	if err != nil {
+		return nil, err
+	}
+	tlsOptions := common.PtrValueOrDefault(options.TLS)
+	tlsOptions.Enabled = true
+	tlsConfig, err := tls.NewClient(ctx, logger, options.Server, tlsOptions)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsConfig.NextProtos()) == 0 {
+		tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+	}
+	headers := options.Headers.Build()
+	host := headers.Get("Host")
+	if host != "" {
+		headers.Del("Host")
+	} else {
+		if tlsConfig.ServerName() != "" {
+			host = tlsConfig.ServerName()
+		} else {
+			host = options.Server
+		}
+	}
+	destinationURL := url.URL{
+		Scheme: "https",
+		Host:   host,
+	}
+	if destinationURL.Host == "" {
+		destinationURL.Host = options.Server
+	}
+	if options.ServerPort != 0 && options.ServerPort != 443 {
+		destinationURL.Host = net.JoinHostPort(destinationURL.Host, strconv.Itoa(int(options.ServerPort)))
+	}
+	path := options.Path
+	if path == "" {
+		path = "/dns-query"
+	}
+	err = sHTTP.URLSetPath(&destinationURL, path)
+	if err != nil {
+		return nil, err
+	}
+	serverAddr := options.DNSServerAddressOptions.Build()
+	if serverAddr.Port == 0 {
+		serverAddr.Port = 443
+	}
+	if !serverAddr.IsValid() {
+		return nil, E.New("invalid server address: ", serverAddr)
+	}
+	idleTimeout := multiplexIdleTimeout(options.MultiplexDNSServerOptions)
+	t := &HTTPSTransport{
+		BaseTransport: transport.NewBaseTransport(
+			dns.NewTransportAdapterWithRemoteOptions(C.DNSTypeHTTPS, tag, options.RemoteDNSServerOptions),
+			logger,
+		),
+		logger:      logger,
+		dialer:      transportDialer,
+		tlsDialer:   tls.NewDialer(transportDialer, tlsConfig),
+		serverAddr:  serverAddr,
+		destination: &destinationURL,
+		headers:     headers,
+		http2Transport: &http2.Transport{
+			IdleConnTimeout: idleTimeout,
+			ReadIdleTimeout: C.TCPKeepAliveInitial,
+		},
+		metrics: newTransportMetrics(),
+	}
+	t.httpTransport = &http.Transport{
+		DialTLSContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return t.tlsDialer.DialTLSContext(ctx, t.serverAddr)
+		},
+		IdleConnTimeout:     idleTimeout,
+		MaxIdleConnsPerHost: max(options.MaxIdleConnections, defaultMultiplexIdleConnections),
+	}
+	t.connections = newMultiplexPool(options.MultiplexDNSServerOptions, t.dial, (*http2.ClientConn).CanTakeNewRequest, func(conn *http2.ClientConn) {
+		_ = conn.Close()
+	})
+	return t, nil
+}
+
+func (t *HTTPSTransport) dial(ctx context.Context) (*http2.ClientConn, error) {
+	tlsConn, err := t.tlsDialer.DialTLSContext(ctx, t.serverAddr)
+	if err != nil {
+		return nil, E.Cause(err, "dial TLS connection")
+	}
+	if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		tlsConn.Close()
+		t.fallback.Store(true)
+		return nil, errFallback
+	}
+	conn, err := t.http2Transport.NewClientConn(tlsConn)
+	if err != nil {
+		tlsConn.Close()
+		return nil, E.Cause(err, "create HTTP/2 connection")
+	}
+	return conn, nil
+}
+
+func (t *HTTPSTransport) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	err := t.SetStarted()
+	if err != nil {
+		return err
+	}
+	return dialer.InitializeDetour(t.dialer)
+}
+
+func (t *HTTPSTransport) Close() error {
+	t.Reset()
+	return t.BaseTransport.Close()
+}
+
+func (t *HTTPSTransport) Reset() {
+	t.connections.reset()
+	t.httpTransport.CloseIdleConnections()
+}
+
+func (t *HTTPSTransport) Metrics() Metrics {
+	metrics := t.metrics.snapshot()
+	metrics.ActiveConnections, metrics.PooledConnections = t.connections.stats()
+	return metrics
+}
+
+func (t *HTTPSTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if !t.BeginQuery() {
+		return nil, transport.ErrTransportClosed
+	}
+	defer t.EndQuery()
+	done := t.metrics.beginQuery()
+	response, err := t.exchange(ctx, message)
+	done(err)
+	return response, err
+}
+
+func (t *HTTPSTransport) exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	exMessage := *message
+	exMessage.Id = 0
+	exMessage.Compress = true
+	requestBuffer := buf.NewSize(1 + message.Len())
+	defer requestBuffer.Release()
+	rawMessage, err := exMessage.PackBuffer(requestBuffer.FreeBytes())
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.destination.String(), bytes.NewReader(rawMessage))
+	if err != nil {
+		return nil, err
+	}
+	request.Header = t.headers.Clone()
+	request.Header.Set("Content-Type", transport.MimeType)
+	request.Header.Set("Accept", transport.MimeType)
+
+	if !t.fallback.Load() {
+		conn, err := t.connections.acquire(ctx)
+		if err == nil {
+			response, err := conn.conn.RoundTrip(request)
+			if err != nil {
+				// The connection may be stuck if timed out.
+				t.connections.release(conn, errors.Is(err, context.DeadlineExceeded))
+				return nil, err
+			}
+			responseMessage, err := readHTTPSResponse(response)
+			t.connections.release(conn, false)
+			return responseMessage, err
+		} else if !errors.Is(err, errFallback) {
+			return nil, err
+		}
+	}
+	response, err := t.httpTransport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	return readHTTPSResponse(response)
+}
+
+func readHTTPSResponse(response *http.Response) (*mDNS.Msg, error) {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, E.New("unexpected status: ", response.Status)
+	}
+	var (
+		rawMessage []byte
+		err        error
+	)
+	if response.ContentLength > 0 {
+		responseBuffer := buf.NewSize(int(response.ContentLength))
+		defer responseBuffer.Release()
+		_, err = responseBuffer.ReadFullFrom(response.Body, int(response.ContentLength))
+		rawMessage = responseBuffer.Bytes()
+	} else {
+		rawMessage, err = io.ReadAll(response.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var responseMessage mDNS.Msg
+	err = responseMessage.Unpack(rawMessage)
+	if err != nil {
+		return nil, err
+	}
+	return &responseMessage, nil
+}
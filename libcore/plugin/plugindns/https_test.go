@@ -0,0 +1,110 @@
+package plugindns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func Test_HTTPSTransport(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		http2    bool
+		protocol string
+	}{
+		{name: "http2", http2: true, protocol: "HTTP/2.0"},
+		{name: "fallback", http2: false, protocol: "HTTP/1.1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				access    sync.Mutex
+				protocols = make(map[string]int)
+			)
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				access.Lock()
+				protocols[request.Proto]++
+				access.Unlock()
+				rawRequest, err := io.ReadAll(request.Body)
+				if err != nil {
+					writer.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				var message mDNS.Msg
+				err = message.Unpack(rawRequest)
+				if err != nil {
+					writer.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				response := new(mDNS.Msg)
+				response.SetReply(&message)
+				rawResponse, _ := response.Pack()
+				writer.Header().Set("Content-Type", "application/dns-message")
+				_, _ = writer.Write(rawResponse)
+			}))
+			server.EnableHTTP2 = tt.http2
+			server.StartTLS()
+			defer server.Close()
+
+			serverAddr := M.ParseSocksaddr(server.Listener.Addr().String())
+			var options pluginoption.RemoteHTTPSDNSServerOptions
+			options.Server = serverAddr.AddrString()
+			options.ServerPort = serverAddr.Port
+			options.TLS = &option.OutboundTLSOptions{Insecure: true}
+			options.MaxQueries = 2
+			ctx := context.Background()
+			rawTransport, err := NewHTTPS(ctx, log.StdLogger(), "https", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := rawTransport.(*HTTPSTransport)
+			defer transport.Close()
+			err = transport.Start(adapter.StartStateStart)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const queries = 8
+			var wg sync.WaitGroup
+			for range queries {
+				wg.Go(func() {
+					request := new(mDNS.Msg)
+					request.SetQuestion("example.com.", mDNS.TypeA)
+					response, err := transport.Exchange(ctx, request)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if !response.Response || len(response.Question) != 1 || response.Question[0] != request.Question[0] {
+						t.Errorf("unexpected response: %s", response)
+					}
+				})
+			}
+			wg.Wait()
+
+			access.Lock()
+			if protocols[tt.protocol] == 0 {
+				t.Errorf("expected %s, got %v", tt.protocol, protocols)
+			}
+			access.Unlock()
+			metrics := transport.Metrics()
+			if metrics.Queries != queries || metrics.Errors != 0 {
+				t.Errorf("unexpected query counters: %+v", metrics)
+			}
+			if tt.http2 && metrics.PooledConnections > int32(defaultMultiplexIdleConnections) {
+				t.Errorf("idle connections should be limited: %+v", metrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package plugindns
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"libcore/ringqueue"
+)
+
+// latencySamples is the count of recent queries used to calculate latency percentiles.
+const latencySamples = 256
+
+// Metrics is the statistics of a transport, used to tune reuse and pipeline.
+type Metrics struct {
+	ActiveConnections int32
+	PooledConnections int32
+	InflightQueries   int32
+	Pipeline          bool
+	PipelineDetected  bool
+	// OutOfOrderResponses is the count of pipelined responses arriving before earlier queries.
+	OutOfOrderResponses int64
+	PipelinedResponses  int64
+	Queries             int64
+	Errors              int64
+	LatencyP50          time.Duration
+	LatencyP90          time.Duration
+	LatencyP99          time.Duration
+}
+
+// MetricsTransport is a transport providing metrics.
+type MetricsTransport interface {
+	Metrics() Metrics
+}
+
+type transportMetrics struct {
+	activeConnections   atomic.Int32
+	inflightQueries     atomic.Int32
+	outOfOrderResponses atomic.Int64
+	pipelinedResponses  atomic.Int64
+	queries             atomic.Int64
+	errors              atomic.Int64
+
+	latencyAccess sync.Mutex
+	latency       *ringqueue.RingQueue[time.Duration]
+}
+
+func newTransportMetrics() *transportMetrics {
+	return &transportMetrics{
+		latency: ringqueue.New[time.Duration](latencySamples),
+	}
+}
+
+// beginQuery records a query and returns the function to call after it finished.
+func (m *transportMetrics) beginQuery() func(err error) {
+	m.queries.Add(1)
+	m.inflightQueries.Add(1)
+	start := time.Now()
+	return func(err error) {
+		m.inflightQueries.Add(-1)
+		if err != nil {
+			m.errors.Add(1)
+			return
+		}
+		m.latencyAccess.Lock()
+		m.latency.Add(time.Since(start))
+		m.latencyAccess.Unlock()
+	}
+}
+
+func (m *transportMetrics) snapshot() Metrics {
+	m.latencyAccess.Lock()
+	latency := m.latency.All()
+	m.latencyAccess.Unlock()
+	slices.Sort(latency)
+	return Metrics{
+		ActiveConnections:   m.activeConnections.Load(),
+		InflightQueries:     m.inflightQueries.Load(),
+		OutOfOrderResponses: m.outOfOrderResponses.Load(),
+		PipelinedResponses:  m.pipelinedResponses.Load(),
+		Queries:             m.queries.Load(),
+		Errors:              m.errors.Load(),
+		LatencyP50:          percentile(latency, 50),
+		LatencyP90:          percentile(latency, 90),
+		LatencyP99:          percentile(latency, 99),
+	}
+}
+
+// percentile uses nearest rank of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (len(sorted)*p + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
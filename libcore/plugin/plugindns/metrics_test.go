@@ -0,0 +1,92 @@
+package plugindns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"libcore/expiringpool"
+
+	"github.com/sagernet/sing-box/log"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func Test_TCPTransportMetrics(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &mDNS.Server{
+		Listener: listener,
+		Handler: mDNS.HandlerFunc(func(writer mDNS.ResponseWriter, request *mDNS.Msg) {
+			response := new(mDNS.Msg)
+			response.SetReply(request)
+			_ = writer.WriteMsg(response)
+		}),
+	}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	ctx := context.Background()
+	transport := &TCPTransport{
+		logger:         log.StdLogger(),
+		dialer:         N.SystemDialer,
+		serverAddr:     M.SocksaddrFromNet(listener.Addr()),
+		enablePipeline: true,
+		idleTimeout:    time.Minute,
+		metrics:        newTransportMetrics(),
+	}
+	transport.connections = expiringpool.New(ctx, time.Minute, func(conn *reuseableDNSConn) {
+		conn.Close()
+	})
+	defer transport.Close()
+
+	const queries = 5
+	for range queries {
+		request := new(mDNS.Msg)
+		request.SetQuestion("example.com.", mDNS.TypeA)
+		_, err = transport.Exchange(ctx, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	metrics := transport.Metrics()
+	if metrics.Queries != queries || metrics.Errors != 0 || metrics.InflightQueries != 0 {
+		t.Errorf("unexpected query counters: %+v", metrics)
+	}
+	if metrics.ActiveConnections != 1 || metrics.PooledConnections != 1 {
+		t.Errorf("connection should be reused: %+v", metrics)
+	}
+	if !metrics.Pipeline || metrics.PipelinedResponses != queries || metrics.OutOfOrderResponses != 0 {
+		t.Errorf("unexpected pipeline counters: %+v", metrics)
+	}
+	if metrics.LatencyP50 <= 0 || metrics.LatencyP50 > metrics.LatencyP99 {
+		t.Errorf("unexpected latency: %+v", metrics)
+	}
+}
+
+func Test_percentile(t *testing.T) {
+	samples := make([]time.Duration, 0, 100)
+	for i := range 100 {
+		samples = append(samples, time.Duration(i+1))
+	}
+	for _, tt := range []struct {
+		p        int
+		expected time.Duration
+	}{
+		{p: 50, expected: 50},
+		{p: 90, expected: 90},
+		{p: 99, expected: 99},
+	} {
+		if got := percentile(samples, tt.p); got != tt.expected {
+			t.Errorf("p%d: expected %d, got %d", tt.p, tt.expected, got)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("expected 0 for empty samples, got %d", got)
+	}
+}
@@ -0,0 +1,159 @@
+package plugindns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"libcore/plugin/pluginoption"
+)
+
+const (
+	defaultMultiplexIdleTimeout     = 90 * time.Second
+	defaultMultiplexIdleConnections = 2
+)
+
+// multiplexConn is a connection carrying concurrent queries.
+type multiplexConn[T any] struct {
+	conn          T
+	activeQueries int
+	broken        bool
+}
+
+// multiplexPool reuses connections carrying concurrent queries, like HTTP/2 and QUIC.
+// A new connection is dialed only if all connections are busy.
+type multiplexPool[T any] struct {
+	dial        func(ctx context.Context) (T, error)
+	isAvailable func(conn T) bool
+	closeConn   func(conn T)
+	// maxQueries is the limit of concurrent queries on a connection, 0 means unlimited.
+	maxQueries int
+	maxIdle    int
+
+	access sync.Mutex
+	conns  []*multiplexConn[T]
+}
+
+func newMultiplexPool[T any](options pluginoption.MultiplexDNSServerOptions, dial func(ctx context.Context) (T, error), isAvailable func(conn T) bool, closeConn func(conn T)) *multiplexPool[T] {
+	maxIdle := options.MaxIdleConnections
+	if maxIdle <= 0 {
+		maxIdle = defaultMultiplexIdleConnections
+	}
+	return &multiplexPool[T]{
+		dial:        dial,
+		isAvailable: isAvailable,
+		closeConn:   closeConn,
+		maxQueries:  max(options.MaxQueries, 0),
+		maxIdle:     maxIdle,
+	}
+}
+
+func multiplexIdleTimeout(options pluginoption.MultiplexDNSServerOptions) time.Duration {
+	if options.IdleTimeout > 0 {
+		return time.Duration(options.IdleTimeout)
+	}
+	return defaultMultiplexIdleTimeout
+}
+
+// acquire reserves a query on the least busy connection.
+func (p *multiplexPool[T]) acquire(ctx context.Context) (*multiplexConn[T], error) {
+	p.access.Lock()
+	var best *multiplexConn[T]
+	conns := p.conns[:0]
+	for _, conn := range p.conns {
+		if conn.broken || !p.isAvailable(conn.conn) {
+			if conn.activeQueries == 0 {
+				p.closeConn(conn.conn)
+				continue
+			}
+			conns = append(conns, conn)
+			continue
+		}
+		conns = append(conns, conn)
+		if p.maxQueries > 0 && conn.activeQueries >= p.maxQueries {
+			continue
+		}
+		if best == nil || conn.activeQueries < best.activeQueries {
+			best = conn
+		}
+	}
+	clear(p.conns[len(conns):])
+	p.conns = conns
+	if best != nil {
+		best.activeQueries++
+		p.access.Unlock()
+		return best, nil
+	}
+	p.access.Unlock()
+
+	rawConn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn := &multiplexConn[T]{
+		conn:          rawConn,
+		activeQueries: 1,
+	}
+	p.access.Lock()
+	p.conns = append(p.conns, conn)
+	p.access.Unlock()
+	return conn, nil
+}
+
+// release finishes a query. Broken connections are not used for new queries.
+func (p *multiplexPool[T]) release(conn *multiplexConn[T], broken bool) {
+	p.access.Lock()
+	defer p.access.Unlock()
+	conn.activeQueries--
+	if broken {
+		conn.broken = true
+	}
+	if conn.activeQueries > 0 {
+		return
+	}
+	var idle int
+	for _, it := range p.conns {
+		if it.activeQueries == 0 && !it.broken {
+			idle++
+		}
+	}
+	if conn.broken || idle > p.maxIdle {
+		p.remove(conn)
+		p.closeConn(conn.conn)
+	}
+}
+
+func (p *multiplexPool[T]) remove(conn *multiplexConn[T]) {
+	for i, it := range p.conns {
+		if it == conn {
+			last := len(p.conns) - 1
+			p.conns[i] = p.conns[last]
+			p.conns[last] = nil
+			p.conns = p.conns[:last]
+			return
+		}
+	}
+}
+
+// reset closes all connections. Running queries on them will fail.
+func (p *multiplexPool[T]) reset() {
+	p.access.Lock()
+	defer p.access.Unlock()
+	for _, conn := range p.conns {
+		p.closeConn(conn.conn)
+	}
+	p.conns = nil
+}
+
+// stats returns count of connections and idle connections.
+func (p *multiplexPool[T]) stats() (active int32, idle int32) {
+	p.access.Lock()
+	defer p.access.Unlock()
+	for _, conn := range p.conns {
+		active++
+		if conn.activeQueries == 0 {
+			idle++
+		}
+	}
+	return
+}
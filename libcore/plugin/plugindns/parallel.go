@@ -0,0 +1,416 @@
+package plugindns
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var (
+	_ adapter.DNSTransport   = (*ParallelTransport)(nil)
+	_ UpstreamStatsTransport = (*ParallelTransport)(nil)
+)
+
+const (
+	ParallelModeRace       = "race"
+	ParallelModeFastestIP  = "fastest_ip"
+	ParallelModeConsistent = "consistent"
+)
+
+const (
+	defaultParallelTimeout   = time.Second
+	defaultParallelProbePort = 443
+)
+
+func RegisterParallel(registry *dns.TransportRegistry) {
+	dns.RegisterTransport[pluginoption.ParallelDNSServerOptions](registry, pluginoption.DNSTypeParallel, NewParallel)
+}
+
+// UpstreamStats is the statistics of a server in a parallel transport.
+type UpstreamStats struct {
+	Tag     string
+	Queries int64
+	Errors  int64
+	// Wins is the count of answers returned by the group.
+	Wins int64
+	// Disagreements is the count of answers outvoted in consistent mode.
+	Disagreements  int64
+	AverageLatency time.Duration
+}
+
+// UpstreamStatsTransport is a transport providing statistics of its servers.
+type UpstreamStatsTransport interface {
+	UpstreamStats() []UpstreamStats
+}
+
+// ParallelTransport queries all servers at once, and selects the answer by mode.
+type ParallelTransport struct {
+	dns.TransportAdapter
+	ctx          context.Context
+	logger       logger.ContextLogger
+	dialer       N.Dialer
+	mode         string
+	probePort    uint16
+	timeout      time.Duration
+	minAgreement int
+	upstreams    []*parallelUpstream
+}
+
+type parallelUpstream struct {
+	tag           string
+	transport     adapter.DNSTransport
+	queries       atomic.Int64
+	errors        atomic.Int64
+	wins          atomic.Int64
+	disagreements atomic.Int64
+	successes     atomic.Int64
+	totalLatency  atomic.Int64
+}
+
+type parallelResult struct {
+	upstream *parallelUpstream
+	response *mDNS.Msg
+	err      error
+}
+
+func NewParallel(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.ParallelDNSServerOptions) (adapter.DNSTransport, error) {
+	if len(options.Servers) == 0 {
+		return nil, E.New("missing servers")
+	}
+	if slices.Contains(options.Servers, tag) {
+		return nil, E.New("server can't contain itself")
+	}
+	mode := options.Mode
+	switch mode {
+	case "":
+		mode = ParallelModeRace
+	case ParallelModeRace, ParallelModeFastestIP, ParallelModeConsistent:
+	default:
+		return nil, E.New("unknown mode: ", mode)
+	}
+	transport := &ParallelTransport{
+		TransportAdapter: dns.NewTransportAdapter(pluginoption.DNSTypeParallel, tag, options.Servers),
+		ctx:              ctx,
+		logger:           logger,
+		mode:             mode,
+		probePort:        options.ProbePort,
+		timeout:          time.Duration(options.Timeout),
+		minAgreement:     options.MinAgreement,
+	}
+	if transport.probePort == 0 {
+		transport.probePort = defaultParallelProbePort
+	}
+	if transport.timeout <= 0 {
+		transport.timeout = defaultParallelTimeout
+	}
+	if transport.minAgreement <= 0 {
+		transport.minAgreement = len(options.Servers)/2 + 1
+	}
+	if mode == ParallelModeFastestIP {
+		var err error
+		transport.dialer, err = dialer.NewWithOptions(dialer.Options{
+			Context: ctx,
+			Options: options.DialerOptions,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, server := range options.Servers {
+		transport.upstreams = append(transport.upstreams, &parallelUpstream{tag: server})
+	}
+	return transport, nil
+}
+
+func (t *ParallelTransport) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	transportManager := service.FromContext[adapter.DNSTransportManager](t.ctx)
+	if transportManager == nil {
+		return E.New("missing DNS transport manager")
+	}
+	for _, upstream := range t.upstreams {
+		transport, loaded := transportManager.Transport(upstream.tag)
+		if !loaded {
+			return E.New("server not found: ", upstream.tag)
+		}
+		upstream.transport = transport
+	}
+	if t.dialer != nil {
+		return dialer.InitializeDetour(t.dialer)
+	}
+	return nil
+}
+
+func (t *ParallelTransport) Close() error {
+	return nil
+}
+
+func (t *ParallelTransport) Reset() {
+}
+
+func (t *ParallelTransport) UpstreamStats() []UpstreamStats {
+	stats := make([]UpstreamStats, 0, len(t.upstreams))
+	for _, upstream := range t.upstreams {
+		it := UpstreamStats{
+			Tag:           upstream.tag,
+			Queries:       upstream.queries.Load(),
+			Errors:        upstream.errors.Load(),
+			Wins:          upstream.wins.Load(),
+			Disagreements: upstream.disagreements.Load(),
+		}
+		if successes := upstream.successes.Load(); successes > 0 {
+			it.AverageLatency = time.Duration(upstream.totalLatency.Load() / successes)
+		}
+		stats = append(stats, it)
+	}
+	return stats
+}
+
+func (t *ParallelTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// Stop slower servers after selected.
+	defer cancel()
+	var result *parallelResult
+	var err error
+	switch t.mode {
+	case ParallelModeFastestIP:
+		result, err = t.fastestIP(ctx, message)
+	case ParallelModeConsistent:
+		result, err = t.consistent(ctx, message)
+	default:
+		result, err = t.race(ctx, message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.upstream.wins.Add(1)
+	return result.response, nil
+}
+
+// race returns the first successful answer.
+func (t *ParallelTransport) race(ctx context.Context, message *mDNS.Msg) (*parallelResult, error) {
+	results := t.queryAll(ctx, message)
+	errors := make([]error, 0, len(t.upstreams))
+	for range t.upstreams {
+		result := <-results
+		if result.err == nil {
+			return &result, nil
+		}
+		errors = append(errors, result.err)
+	}
+	return nil, E.Errors(errors...)
+}
+
+// fastestIP returns the answer containing the address accepting TCP connection first,
+// with the address moved to the front.
+func (t *ParallelTransport) fastestIP(ctx context.Context, message *mDNS.Msg) (*parallelResult, error) {
+	results, err := t.collect(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make(map[netip.Addr]*parallelResult)
+	for i := range results {
+		for _, addr := range answerAddresses(results[i].response) {
+			if _, loaded := candidates[addr]; !loaded {
+				candidates[addr] = &results[i]
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return &results[0], nil
+	}
+	fastest, err := t.probe(ctx, candidates)
+	if err != nil {
+		t.logger.DebugContext(ctx, "probe addresses: ", err)
+		return &results[0], nil
+	}
+	result := candidates[fastest]
+	response := result.response.Copy()
+	slices.SortStableFunc(response.Answer, func(a, b mDNS.RR) int {
+		aIsFastest, bIsFastest := rrAddress(a) == fastest, rrAddress(b) == fastest
+		switch {
+		case aIsFastest && !bIsFastest:
+			return -1
+		case !aIsFastest && bIsFastest:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return &parallelResult{upstream: result.upstream, response: response}, nil
+}
+
+func (t *ParallelTransport) probe(ctx context.Context, candidates map[netip.Addr]*parallelResult) (netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	type probeResult struct {
+		addr netip.Addr
+		err  error
+	}
+	results := make(chan probeResult, len(candidates))
+	for addr := range candidates {
+		go func() {
+			conn, err := t.dialer.DialContext(ctx, N.NetworkTCP, M.SocksaddrFrom(addr, t.probePort))
+			if err == nil {
+				conn.Close()
+			}
+			results <- probeResult{addr, err}
+		}()
+	}
+	errors := make([]error, 0, len(candidates))
+	for range candidates {
+		result := <-results
+		if result.err == nil {
+			return result.addr, nil
+		}
+		errors = append(errors, result.err)
+	}
+	return netip.Addr{}, E.Errors(errors...)
+}
+
+// consistent returns the answer agreed by most servers, to detect poisoning.
+func (t *ParallelTransport) consistent(ctx context.Context, message *mDNS.Msg) (*parallelResult, error) {
+	results, err := t.collect(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		keys  = make([]string, len(results))
+		votes = make(map[string]int)
+		best  string
+	)
+	for i, result := range results {
+		keys[i] = answerKey(result.response)
+		votes[keys[i]]++
+		// The earliest answer wins on ties.
+		if votes[keys[i]] > votes[best] {
+			best = keys[i]
+		}
+	}
+	var selected *parallelResult
+	for i := range results {
+		if keys[i] != best {
+			results[i].upstream.disagreements.Add(1)
+		} else if selected == nil {
+			selected = &results[i]
+		}
+	}
+	if votes[best] < t.minAgreement {
+		return nil, E.New("inconsistent answers: ", votes[best], " of ", len(results), " servers agree, ", t.minAgreement, " required")
+	}
+	if len(votes) > 1 {
+		t.logger.WarnContext(ctx, "servers disagree on ", message.Question[0].Name)
+	}
+	return selected, nil
+}
+
+// collect waits for all servers, or until timeout after the first successful answer.
+func (t *ParallelTransport) collect(ctx context.Context, message *mDNS.Msg) ([]parallelResult, error) {
+	results := t.queryAll(ctx, message)
+	var (
+		successes = make([]parallelResult, 0, len(t.upstreams))
+		errors    []error
+		window    <-chan time.Time
+	)
+wait:
+	for range t.upstreams {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				errors = append(errors, result.err)
+				continue
+			}
+			successes = append(successes, result)
+			if window == nil {
+				timer := time.NewTimer(t.timeout)
+				defer timer.Stop()
+				window = timer.C
+			}
+		case <-window:
+			break wait
+		}
+	}
+	if len(successes) == 0 {
+		return nil, E.Errors(errors...)
+	}
+	return successes, nil
+}
+
+func (t *ParallelTransport) queryAll(ctx context.Context, message *mDNS.Msg) <-chan parallelResult {
+	results := make(chan parallelResult, len(t.upstreams))
+	for _, upstream := range t.upstreams {
+		go func() {
+			upstream.queries.Add(1)
+			start := time.Now()
+			response, err := upstream.transport.Exchange(ctx, message.Copy())
+			if err == nil && response.Rcode != mDNS.RcodeSuccess && response.Rcode != mDNS.RcodeNameError {
+				err = E.New(mDNS.RcodeToString[response.Rcode])
+			}
+			if err != nil {
+				err = E.Cause(err, upstream.tag)
+				// Don't blame servers stopped after selected.
+				if ctx.Err() == nil {
+					upstream.errors.Add(1)
+				}
+			} else {
+				upstream.successes.Add(1)
+				upstream.totalLatency.Add(int64(time.Since(start)))
+			}
+			results <- parallelResult{upstream, response, err}
+		}()
+	}
+	return results
+}
+
+func answerAddresses(response *mDNS.Msg) []netip.Addr {
+	var addresses []netip.Addr
+	for _, rr := range response.Answer {
+		if addr := rrAddress(rr); addr.IsValid() {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+func rrAddress(rr mDNS.RR) netip.Addr {
+	var addr netip.Addr
+	switch record := rr.(type) {
+	case *mDNS.A:
+		addr, _ = netip.AddrFromSlice(record.A)
+	case *mDNS.AAAA:
+		addr, _ = netip.AddrFromSlice(record.AAAA)
+	}
+	return addr.Unmap()
+}
+
+// answerKey identifies the answer regardless of order and TTL.
+func answerKey(response *mDNS.Msg) string {
+	records := make([]string, 0, len(response.Answer)+1)
+	for _, rr := range response.Answer {
+		rr = mDNS.Copy(rr)
+		rr.Header().Ttl = 0
+		records = append(records, rr.String())
+	}
+	slices.Sort(records)
+	records = append(records, mDNS.RcodeToString[response.Rcode])
+	return strings.Join(records, "\n")
+}
@@ -0,0 +1,154 @@
+package plugindns
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+type stubDNSTransport struct {
+	dns.TransportAdapter
+	delay   time.Duration
+	address string
+	err     error
+}
+
+func (s *stubDNSTransport) Start(adapter.StartStage) error { return nil }
+func (s *stubDNSTransport) Close() error                   { return nil }
+func (s *stubDNSTransport) Reset()                         {}
+
+func (s *stubDNSTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	response := new(mDNS.Msg)
+	response.SetReply(message)
+	response.Answer = append(response.Answer, &mDNS.A{
+		Hdr: mDNS.RR_Header{Name: message.Question[0].Name, Rrtype: mDNS.TypeA, Class: mDNS.ClassINET, Ttl: uint32(s.delay / time.Millisecond)},
+		A:   net.ParseIP(s.address),
+	})
+	return response, nil
+}
+
+func newTestParallel(t *testing.T, options pluginoption.ParallelDNSServerOptions, upstreams ...*stubDNSTransport) *ParallelTransport {
+	for i := range upstreams {
+		options.Servers = append(options.Servers, string(rune('a'+i)))
+	}
+	rawTransport, err := NewParallel(context.Background(), log.StdLogger(), "parallel", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := rawTransport.(*ParallelTransport)
+	for i, upstream := range upstreams {
+		transport.upstreams[i].transport = upstream
+	}
+	return transport
+}
+
+func exchangeA(transport adapter.DNSTransport) (netip.Addr, error) {
+	request := new(mDNS.Msg)
+	request.SetQuestion("example.com.", mDNS.TypeA)
+	response, err := transport.Exchange(context.Background(), request)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return rrAddress(response.Answer[0]), nil
+}
+
+func Test_ParallelRace(t *testing.T) {
+	transport := newTestParallel(t, pluginoption.ParallelDNSServerOptions{},
+		&stubDNSTransport{err: E.New("refused")},
+		&stubDNSTransport{delay: time.Second, address: "1.1.1.1"},
+		&stubDNSTransport{delay: 10 * time.Millisecond, address: "8.8.8.8"},
+	)
+	addr, err := exchangeA(transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != netip.MustParseAddr("8.8.8.8") {
+		t.Errorf("expected the fastest answer, got %s", addr)
+	}
+	stats := transport.UpstreamStats()
+	if stats[0].Errors != 1 || stats[1].Errors != 0 || stats[2].Wins != 1 || stats[2].AverageLatency <= 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func Test_ParallelConsistent(t *testing.T) {
+	upstreams := []*stubDNSTransport{
+		{delay: 10 * time.Millisecond, address: "6.6.6.6"},
+		{delay: 20 * time.Millisecond, address: "1.1.1.1"},
+		{delay: 30 * time.Millisecond, address: "1.1.1.1"},
+	}
+	transport := newTestParallel(t, pluginoption.ParallelDNSServerOptions{Mode: ParallelModeConsistent}, upstreams...)
+	addr, err := exchangeA(transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != netip.MustParseAddr("1.1.1.1") {
+		t.Errorf("expected the majority answer, got %s", addr)
+	}
+	stats := transport.UpstreamStats()
+	if stats[0].Disagreements != 1 || stats[1].Wins != 1 || stats[2].Disagreements != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	transport = newTestParallel(t, pluginoption.ParallelDNSServerOptions{Mode: ParallelModeConsistent, MinAgreement: 3}, upstreams...)
+	_, err = exchangeA(transport)
+	if err == nil {
+		t.Error("expected error for inconsistent answers")
+	}
+}
+
+func Test_ParallelFastestIP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	transport := newTestParallel(t, pluginoption.ParallelDNSServerOptions{
+		Mode:      ParallelModeFastestIP,
+		ProbePort: uint16(listener.Addr().(*net.TCPAddr).Port),
+	},
+		// Nothing listens on 127.0.0.2.
+		&stubDNSTransport{delay: 10 * time.Millisecond, address: "127.0.0.2"},
+		&stubDNSTransport{delay: 20 * time.Millisecond, address: "127.0.0.1"},
+	)
+	transport.dialer = N.SystemDialer
+	addr, err := exchangeA(transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != netip.MustParseAddr("127.0.0.1") {
+		t.Errorf("expected the reachable address, got %s", addr)
+	}
+	if stats := transport.UpstreamStats(); stats[1].Wins != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
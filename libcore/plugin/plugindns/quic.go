@@ -0,0 +1,215 @@
+package plugindns
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/dns/transport"
+	"github.com/sagernet/sing-box/log"
+	sQUIC "github.com/sagernet/sing-quic"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var (
+	_ adapter.DNSTransport = (*QUICTransport)(nil)
+	_ MetricsTransport     = (*QUICTransport)(nil)
+)
+
+func RegisterQUIC(registry *dns.TransportRegistry) {
+	dns.RegisterTransport[pluginoption.RemoteQUICDNSServerOptions](registry, C.DNSTypeQUIC, NewQUIC)
+}
+
+// QUICTransport sends queries on streams of pooled QUIC connections.
+type QUICTransport struct {
+	*transport.BaseTransport
+	logger      logger.ContextLogger
+	dialer      N.Dialer
+	serverAddr  M.Socksaddr
+	tlsConfig   tls.Config
+	quicConfig  *quic.Config
+	connections *multiplexPool[*quic.Conn]
+	metrics     *transportMetrics
+}
+
+func NewQUIC(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.RemoteQUICDNSServerOptions) (adapter.DNSTransport, error) {
+	transportDialer, err := dns.NewRemoteDialer(ctx, options.RemoteDNSServerOptions)
+	if err != nil {
+		return nil, err
+	}
+	tlsOptions := common.PtrValueOrDefault(options.TLS)
+	tlsOptions.Enabled = true
+	tlsConfig, err := tls.NewClient(ctx, logger, options.Server, tlsOptions)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsConfig.NextProtos()) == 0 {
+		tlsConfig.SetNextProtos([]string{"doq"})
+	}
+	serverAddr := options.DNSServerAddressOptions.Build()
+	if serverAddr.Port == 0 {
+		serverAddr.Port = 853
+	}
+	if !serverAddr.IsValid() {
+		return nil, E.New("invalid server address: ", serverAddr)
+	}
+	t := &QUICTransport{
+		BaseTransport: transport.NewBaseTransport(
+			dns.NewTransportAdapterWithRemoteOptions(C.DNSTypeQUIC, tag, options.RemoteDNSServerOptions),
+			logger,
+		),
+		logger:     logger,
+		dialer:     transportDialer,
+		serverAddr: serverAddr,
+		tlsConfig:  tlsConfig,
+		quicConfig: &quic.Config{
+			MaxIdleTimeout: multiplexIdleTimeout(options.MultiplexDNSServerOptions),
+		},
+		metrics: newTransportMetrics(),
+	}
+	t.connections = newMultiplexPool(options.MultiplexDNSServerOptions, t.dial, func(conn *quic.Conn) bool {
+		return !common.Done(conn.Context())
+	}, func(conn *quic.Conn) {
+		_ = conn.CloseWithError(0, "")
+	})
+	return t, nil
+}
+
+func (t *QUICTransport) dial(ctx context.Context) (*quic.Conn, error) {
+	conn, err := t.dialer.DialContext(ctx, N.NetworkUDP, t.serverAddr)
+	if err != nil {
+		return nil, E.Cause(err, "dial UDP connection")
+	}
+	earlyConnection, err := sQUIC.DialEarly(
+		ctx,
+		bufio.NewUnbindPacketConn(conn),
+		t.serverAddr.UDPAddr(),
+		t.tlsConfig,
+		t.quicConfig,
+	)
+	if err != nil {
+		conn.Close()
+		return nil, E.Cause(err, "establish QUIC connection")
+	}
+	return earlyConnection, nil
+}
+
+func (t *QUICTransport) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	err := t.SetStarted()
+	if err != nil {
+		return err
+	}
+	return dialer.InitializeDetour(t.dialer)
+}
+
+func (t *QUICTransport) Close() error {
+	t.connections.reset()
+	return t.BaseTransport.Close()
+}
+
+func (t *QUICTransport) Reset() {
+	t.connections.reset()
+}
+
+func (t *QUICTransport) Metrics() Metrics {
+	metrics := t.metrics.snapshot()
+	metrics.ActiveConnections, metrics.PooledConnections = t.connections.stats()
+	return metrics
+}
+
+func (t *QUICTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if !t.BeginQuery() {
+		return nil, transport.ErrTransportClosed
+	}
+	defer t.EndQuery()
+	done := t.metrics.beginQuery()
+	var (
+		response *mDNS.Msg
+		err      error
+	)
+	for range 2 {
+		var conn *multiplexConn[*quic.Conn]
+		conn, err = t.connections.acquire(ctx)
+		if err != nil {
+			break
+		}
+		response, err = t.exchange(ctx, message, conn.conn)
+		retry := err != nil && isQUICRetryError(err)
+		t.connections.release(conn, retry)
+		if !retry {
+			break
+		}
+	}
+	done(err)
+	return response, err
+}
+
+func (t *QUICTransport) exchange(ctx context.Context, message *mDNS.Msg, conn *quic.Conn) (*mDNS.Msg, error) {
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, E.Cause(err, "open stream")
+	}
+	defer stream.CancelRead(0)
+	err = transport.WriteMessage(stream, 0, message)
+	if err != nil {
+		stream.Close()
+		return nil, E.Cause(err, "write request")
+	}
+	stream.Close()
+	response, err := transport.ReadMessage(stream)
+	if err != nil {
+		return nil, E.Cause(err, "read response")
+	}
+	return response, nil
+}
+
+// https://github.com/AdguardTeam/dnsproxy/blob/fd1868577652c639cce3da00e12ca548f421baf1/upstream/upstream_quic.go#L394
+func isQUICRetryError(err error) (ok bool) {
+	if errors.Is(err, os.ErrClosed) {
+		return true
+	}
+
+	var qAppErr *quic.ApplicationError
+	if errors.As(err, &qAppErr) && qAppErr.ErrorCode == 0 {
+		return true
+	}
+
+	var qIdleErr *quic.IdleTimeoutError
+	if errors.As(err, &qIdleErr) {
+		return true
+	}
+
+	var resetErr *quic.StatelessResetError
+	if errors.As(err, &resetErr) {
+		return true
+	}
+
+	var qTransportError *quic.TransportError
+	if errors.As(err, &qTransportError) && qTransportError.ErrorCode == quic.NoError {
+		return true
+	}
+
+	if errors.Is(err, quic.Err0RTTRejected) {
+		return true
+	}
+
+	return false
+}
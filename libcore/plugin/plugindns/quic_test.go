@@ -0,0 +1,115 @@
+package plugindns
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/adapter"
+	boxTLS "github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/dns/transport"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func Test_QUICTransport(t *testing.T) {
+	certificate, err := boxTLS.GenerateKeyPair(nil, nil, time.Now, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer packetConn.Close()
+	listener, err := quic.Listen(packetConn, &tls.Config{
+		Certificates: []tls.Certificate{*certificate},
+		NextProtos:   []string{"doq"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go func() {
+						defer stream.Close()
+						request, err := transport.ReadMessage(stream)
+						if err != nil {
+							return
+						}
+						response := new(mDNS.Msg)
+						response.SetReply(request)
+						_ = transport.WriteMessage(stream, 0, response)
+					}()
+				}
+			}()
+		}
+	}()
+
+	serverAddr := M.SocksaddrFromNet(packetConn.LocalAddr())
+	var options pluginoption.RemoteQUICDNSServerOptions
+	options.Server = serverAddr.AddrString()
+	options.ServerPort = serverAddr.Port
+	options.TLS = &option.OutboundTLSOptions{Insecure: true}
+	ctx := context.Background()
+	rawTransport, err := NewQUIC(ctx, log.StdLogger(), "quic", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quicTransport := rawTransport.(*QUICTransport)
+	defer quicTransport.Close()
+	err = quicTransport.Start(adapter.StartStateStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const queries = 5
+	for range queries {
+		request := new(mDNS.Msg)
+		request.SetQuestion("example.com.", mDNS.TypeA)
+		response, err := quicTransport.Exchange(ctx, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !response.Response || len(response.Question) != 1 || response.Question[0] != request.Question[0] {
+			t.Errorf("unexpected response: %s", response)
+		}
+	}
+	metrics := quicTransport.Metrics()
+	if metrics.Queries != queries || metrics.Errors != 0 {
+		t.Errorf("unexpected query counters: %+v", metrics)
+	}
+	if metrics.ActiveConnections != 1 || metrics.PooledConnections != 1 {
+		t.Errorf("connection should be reused: %+v", metrics)
+	}
+
+	// Closed connections should be replaced.
+	quicTransport.connections.access.Lock()
+	_ = quicTransport.connections.conns[0].conn.CloseWithError(0, "")
+	quicTransport.connections.access.Unlock()
+	request := new(mDNS.Msg)
+	request.SetQuestion("example.org.", mDNS.TypeA)
+	_, err = quicTransport.Exchange(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
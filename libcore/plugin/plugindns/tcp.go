@@ -27,13 +27,17 @@ import (
 	mDNS "github.com/miekg/dns"
 )
 
-var _ adapter.DNSTransport = (*TCPTransport)(nil)
+var (
+	_ adapter.DNSTransport = (*TCPTransport)(nil)
+	_ MetricsTransport     = (*TCPTransport)(nil)
+)
 
 type dnsTransportManager interface {
 	removeActiveConn(conn *reuseableDNSConn)
 	markPipelineDetected() bool
 	isPipelineDetected() bool
 	getDetectionCounters() (consecutiveOutOfOrder, outOfOrderCount, totalResponses *int32)
+	transportMetrics() *transportMetrics
 }
 
 func RegisterTCP(registry *dns.TransportRegistry) {
@@ -57,6 +61,7 @@ type TCPTransport struct {
 	consecutiveOutOfOrder int32
 	outOfOrderCount       int32
 	totalResponses        int32
+	metrics               *transportMetrics
 }
 
 func NewTCP(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.RemoteTCPDNSServerOptions) (adapter.DNSTransport, error) {
@@ -108,6 +113,7 @@ func NewTCP(ctx context.Context, logger log.ContextLogger, tag string, options p
 		idleTimeout:      poolIdleTimeout,
 		disableKeepAlive: options.DisableTCPKeepAlive,
 		maxQueries:       maxQueries,
+		metrics:          newTransportMetrics(),
 	}
 	if enableConnReuse {
 		transport.connections = expiringpool.New(ctx, poolIdleTimeout, func(conn *reuseableDNSConn) {
@@ -138,6 +144,13 @@ func (t *TCPTransport) Reset() {
 }
 
 func (t *TCPTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	done := t.metrics.beginQuery()
+	response, err := t.exchange(ctx, message)
+	done(err)
+	return response, err
+}
+
+func (t *TCPTransport) exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
 	if t.connections == nil {
 		return t.createNewConnection(ctx, message)
 	}
@@ -275,6 +288,20 @@ func (t *TCPTransport) getDetectionCounters() (*int32, *int32, *int32) {
 	return &t.consecutiveOutOfOrder, &t.outOfOrderCount, &t.totalResponses
 }
 
+func (t *TCPTransport) transportMetrics() *transportMetrics {
+	return t.metrics
+}
+
+func (t *TCPTransport) Metrics() Metrics {
+	metrics := t.metrics.snapshot()
+	if t.connections != nil {
+		metrics.PooledConnections = int32(t.connections.Len())
+	}
+	metrics.Pipeline = t.enablePipeline
+	metrics.PipelineDetected = t.isPipelineDetected()
+	return metrics
+}
+
 func (t *TCPTransport) createNewConnection(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
 	rawConn, err := t.dialer.DialContext(ctx, N.NetworkTCP, t.serverAddr)
 	if err != nil {
@@ -355,6 +382,7 @@ type reuseableDNSConn struct {
 	transport      dnsTransportManager
 	idleTimeout    time.Duration
 	idleTimer      *time.Timer
+	metrics        *transportMetrics
 }
 
 func newReuseableDNSConn(conn net.Conn, logger logger.ContextLogger, enablePipeline bool, idleTimeout time.Duration, maxQueries int, pool *expiringpool.ExpiringPool[*reuseableDNSConn], transport dnsTransportManager) *reuseableDNSConn {
@@ -369,6 +397,10 @@ func newReuseableDNSConn(conn net.Conn, logger logger.ContextLogger, enablePipel
 		transport:      transport,
 		idleTimeout:    idleTimeout,
 	}
+	if transport != nil {
+		c.metrics = transport.transportMetrics()
+		c.metrics.activeConnections.Add(1)
+	}
 	if idleTimeout > 0 {
 		c.idleTimer = time.AfterFunc(idleTimeout, func() {
 			c.closeWithError(E.New("connection idle timeout"))
@@ -469,7 +501,10 @@ func (c *reuseableDNSConn) exchangeWithCleanup(ctx context.Context, message *mDN
 }
 
 func (c *reuseableDNSConn) recvLoop() {
-	var lastRecvId uint16
+	var (
+		lastRecvId uint16
+		received   bool
+	)
 	for {
 		message, err := ReadMessage(c.Conn)
 		if err != nil {
@@ -489,6 +524,14 @@ func (c *reuseableDNSConn) recvLoop() {
 			continue
 		}
 
+		if c.enablePipeline && c.metrics != nil {
+			c.metrics.pipelinedResponses.Add(1)
+			if received && message.Id-lastRecvId > 0x8000 {
+				c.metrics.outOfOrderResponses.Add(1)
+			}
+			received = true
+		}
+
 		if c.enablePipeline && c.transport != nil && !c.transport.isPipelineDetected() {
 			consecutivePtr, outOfOrderPtr, totalPtr := c.transport.getDetectionCounters()
 			totalResp := atomic.AddInt32(totalPtr, 1)
@@ -547,6 +590,9 @@ func (c *reuseableDNSConn) closeWithError(err error) {
 			c.idleTimer.Stop()
 		}
 		c.err = err
+		if c.metrics != nil {
+			c.metrics.activeConnections.Add(-1)
+		}
 		close(c.done)
 		_ = c.Conn.Close()
 	})
@@ -25,7 +25,10 @@ import (
 	mDNS "github.com/miekg/dns"
 )
 
-var _ adapter.DNSTransport = (*TLSTransport)(nil)
+var (
+	_ adapter.DNSTransport = (*TLSTransport)(nil)
+	_ MetricsTransport     = (*TLSTransport)(nil)
+)
 
 func RegisterTLS(registry *dns.TransportRegistry) {
 	dns.RegisterTransport[pluginoption.RemoteTLSDNSServerOptions](registry, C.DNSTypeTLS, NewTLS)
@@ -48,6 +51,7 @@ type TLSTransport struct {
 	consecutiveOutOfOrder int32
 	outOfOrderCount       int32
 	totalResponses        int32
+	metrics               *transportMetrics
 }
 
 func NewTLS(ctx context.Context, logger log.ContextLogger, tag string, options pluginoption.RemoteTLSDNSServerOptions) (adapter.DNSTransport, error) {
@@ -106,6 +110,7 @@ func NewTLSRaw(ctx context.Context, logger logger.ContextLogger, adapter dns.Tra
 		idleTimeout:      idleTimeout,
 		disableKeepAlive: disableKeepAlive,
 		maxQueries:       maxQueries,
+		metrics:          newTransportMetrics(),
 	}
 	transport.connections = expiringpool.New(ctx, idleTimeout, func(conn *reuseableDNSConn) {
 		conn.Close()
@@ -237,12 +242,32 @@ func (t *TLSTransport) getDetectionCounters() (*int32, *int32, *int32) {
 	return &t.consecutiveOutOfOrder, &t.outOfOrderCount, &t.totalResponses
 }
 
+func (t *TLSTransport) transportMetrics() *transportMetrics {
+	return t.metrics
+}
+
+func (t *TLSTransport) Metrics() Metrics {
+	metrics := t.metrics.snapshot()
+	if t.connections != nil {
+		metrics.PooledConnections = int32(t.connections.Len())
+	}
+	metrics.Pipeline = t.enablePipeline
+	metrics.PipelineDetected = t.isPipelineDetected()
+	return metrics
+}
+
 func (t *TLSTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
 	if !t.BeginQuery() {
 		return nil, transport.ErrTransportClosed
 	}
 	defer t.EndQuery()
+	done := t.metrics.beginQuery()
+	response, err := t.exchange(ctx, message)
+	done(err)
+	return response, err
+}
 
+func (t *TLSTransport) exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
 	if t.connections == nil {
 		return t.createNewConnection(ctx, message)
 	}
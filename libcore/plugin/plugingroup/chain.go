@@ -0,0 +1,289 @@
+package plugingroup
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"libcore/plugin/pluginoption"
+)
+
+func RegisterChain(registry *outbound.Registry) {
+	outbound.Register[pluginoption.ChainOutboundOptions](registry, pluginoption.TypeChain, NewChain)
+}
+
+var (
+	_ adapter.InterfaceUpdateListener = (*Chain)(nil)
+	_ adapter.SimpleLifecycle         = (*Chain)(nil)
+)
+
+// Chain dials each hop through the previous one.
+//
+// The first hop is used as it is. Other hops are created again with detour set to the previous hop,
+// so the configured outbounds are not affected. Groups in hops are resolved on dial.
+type Chain struct {
+	outbound.Adapter
+	ctx             context.Context
+	router          adapter.Router
+	logger          log.ContextLogger
+	outboundManager adapter.OutboundManager
+	options         *option.Options
+
+	access sync.Mutex
+	// hops are created outbounds of current path, by the real tags of path.
+	hops   map[string]adapter.Outbound
+	closed bool
+	// createAccess makes only one dial create hops at a time.
+	createAccess sync.Mutex
+}
+
+func NewChain(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options pluginoption.ChainOutboundOptions) (adapter.Outbound, error) {
+	if len(options.Outbounds) == 0 {
+		return nil, E.New("missing tags")
+	}
+	configOptions := pluginoption.OptionsFromContext(ctx)
+	if len(options.Outbounds) > 1 && configOptions == nil {
+		return nil, E.New("missing options of hops")
+	}
+	return &Chain{
+		Adapter:         outbound.NewAdapter(pluginoption.TypeChain, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
+		ctx:             ctx,
+		router:          router,
+		logger:          logger,
+		outboundManager: service.FromContext[adapter.OutboundManager](ctx),
+		options:         configOptions,
+		hops:            make(map[string]adapter.Outbound),
+	}, nil
+}
+
+func (c *Chain) Start() error {
+	for i, tag := range c.Dependencies() {
+		if _, loaded := c.outboundManager.Outbound(tag); !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+	}
+	return nil
+}
+
+func (c *Chain) Close() error {
+	c.access.Lock()
+	hops := c.hops
+	c.hops = nil
+	c.closed = true
+	c.access.Unlock()
+	var errors []error
+	for _, hop := range hops {
+		errors = append(errors, common.Close(hop))
+	}
+	return E.Errors(errors...)
+}
+
+// InterfaceUpdated resets created hops. The first hop is a configured outbound, which has been reset by network manager.
+func (c *Chain) InterfaceUpdated() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	for _, hop := range c.hops {
+		if listener, isListener := hop.(adapter.InterfaceUpdateListener); isListener {
+			listener.InterfaceUpdated()
+		}
+	}
+}
+
+// Hops returns the real tags of current path, with groups resolved.
+func (c *Chain) Hops() []string {
+	path, _ := c.realPath()
+	return path
+}
+
+// realPath resolves groups of hops into their selected outbounds.
+func (c *Chain) realPath() ([]string, error) {
+	path := make([]string, 0, len(c.Dependencies()))
+	for _, tag := range c.Dependencies() {
+		for {
+			detour, loaded := c.outboundManager.Outbound(tag)
+			if !loaded {
+				return nil, E.New("outbound not found: ", tag)
+			}
+			group, isGroup := detour.(adapter.OutboundGroup)
+			if !isGroup {
+				break
+			}
+			tag = group.Now()
+		}
+		path = append(path, tag)
+	}
+	return path, nil
+}
+
+// exit returns the last hop, creating missing hops before it.
+func (c *Chain) exit() (adapter.Outbound, error) {
+	path, err := c.realPath()
+	if err != nil {
+		return nil, err
+	}
+	first, _ := c.outboundManager.Outbound(path[0])
+	if len(path) == 1 {
+		return first, nil
+	}
+	if hop, loaded := c.loadHop(path); loaded {
+		return hop, nil
+	}
+	c.createAccess.Lock()
+	defer c.createAccess.Unlock()
+	previous := first
+	for i := 1; i < len(path); i++ {
+		hop, loaded := c.loadHop(path[:i+1])
+		if !loaded {
+			// Hops are created and started without holding access, as starting may take a while.
+			hop, err = c.createHop(path[i], previous)
+			if err != nil {
+				return nil, E.Cause(err, "create hop ", i, ": ", path[i])
+			}
+			err = c.storeHop(path[:i+1], hop)
+			if err != nil {
+				return nil, err
+			}
+		}
+		previous = hop
+	}
+	c.closeStale(path)
+	return previous, nil
+}
+
+func (c *Chain) loadHop(path []string) (adapter.Outbound, bool) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	hop, loaded := c.hops[strings.Join(path, "\x00")]
+	return hop, loaded
+}
+
+func (c *Chain) storeHop(path []string, hop adapter.Outbound) error {
+	c.access.Lock()
+	closed := c.closed
+	if !closed {
+		c.hops[strings.Join(path, "\x00")] = hop
+	}
+	c.access.Unlock()
+	if closed {
+		common.Close(hop)
+		return net.ErrClosed
+	}
+	return nil
+}
+
+// closeStale closes hops not in path, which are left by selection changes of groups in path.
+func (c *Chain) closeStale(path []string) {
+	current := make(map[string]bool, len(path))
+	for i := 2; i <= len(path); i++ {
+		current[strings.Join(path[:i], "\x00")] = true
+	}
+	var stale []adapter.Outbound
+	c.access.Lock()
+	for key, hop := range c.hops {
+		if !current[key] {
+			stale = append(stale, hop)
+			delete(c.hops, key)
+		}
+	}
+	c.access.Unlock()
+	for _, hop := range stale {
+		common.Close(hop)
+	}
+}
+
+func (c *Chain) createHop(tag string, previous adapter.Outbound) (adapter.Outbound, error) {
+	hopType, hopOptions, isEndpoint, loaded := c.findOptions(tag)
+	if !loaded {
+		return nil, E.New("missing options")
+	}
+	// Copy options to avoid affecting the configured one.
+	copied := reflect.New(reflect.TypeOf(hopOptions).Elem())
+	copied.Elem().Set(reflect.ValueOf(hopOptions).Elem())
+	wrapper, isWrapper := copied.Interface().(option.DialerOptionsWrapper)
+	if !isWrapper {
+		return nil, E.New("detour is not supported by ", hopType)
+	}
+	dialerOptions := wrapper.TakeDialerOptions()
+	dialerOptions.Detour = previous.Tag()
+	wrapper.ReplaceDialerOptions(dialerOptions)
+
+	ctx := service.ContextWith[adapter.OutboundManager](c.ctx, &hopManager{
+		OutboundManager: c.outboundManager,
+		previous:        previous,
+	})
+	var (
+		hop adapter.Outbound
+		err error
+	)
+	if isEndpoint {
+		hop, err = service.FromContext[adapter.EndpointRegistry](ctx).Create(ctx, c.router, c.logger, tag, hopType, copied.Interface())
+	} else {
+		hop, err = service.FromContext[adapter.OutboundRegistry](ctx).CreateOutbound(ctx, c.router, c.logger, tag, hopType, copied.Interface())
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, stage := range adapter.ListStartStages {
+		err = adapter.LegacyStart(hop, stage)
+		if err != nil {
+			common.Close(hop)
+			return nil, E.Cause(err, stage)
+		}
+	}
+	return hop, nil
+}
+
+func (c *Chain) findOptions(tag string) (hopType string, options any, isEndpoint bool, loaded bool) {
+	for _, outbound := range c.options.Outbounds {
+		if outbound.Tag == tag {
+			return outbound.Type, outbound.Options, false, outbound.Options != nil
+		}
+	}
+	for _, endpoint := range c.options.Endpoints {
+		if endpoint.Tag == tag {
+			return endpoint.Type, endpoint.Options, true, endpoint.Options != nil
+		}
+	}
+	return "", nil, false, false
+}
+
+func (c *Chain) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	exit, err := c.exit()
+	if err != nil {
+		return nil, err
+	}
+	return exit.DialContext(ctx, network, destination)
+}
+
+func (c *Chain) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	exit, err := c.exit()
+	if err != nil {
+		return nil, err
+	}
+	return exit.ListenPacket(ctx, destination)
+}
+
+// hopManager makes detour of a hop resolve to the previous hop.
+type hopManager struct {
+	adapter.OutboundManager
+	previous adapter.Outbound
+}
+
+func (m *hopManager) Outbound(tag string) (adapter.Outbound, bool) {
+	if tag == m.previous.Tag() {
+		return m.previous, true
+	}
+	return m.OutboundManager.Outbound(tag)
+}
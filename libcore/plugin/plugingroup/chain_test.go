@@ -0,0 +1,161 @@
+package plugingroup
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"libcore/plugin/pluginoption"
+)
+
+type testRelayOptions struct {
+	option.DialerOptions
+	Server string `json:"server"`
+}
+
+// testRelay records destinations and dials its server through its dialer.
+type testRelay struct {
+	outbound.Adapter
+	dialer N.Dialer
+	server M.Socksaddr
+	record *[]string
+	closed bool
+}
+
+func (r *testRelay) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (r *testRelay) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	*r.record = append(*r.record, r.Tag()+"->"+destination.String())
+	if r.dialer == nil {
+		conn, _ := net.Pipe()
+		return conn, nil
+	}
+	return r.dialer.DialContext(ctx, network, r.server)
+}
+
+func (r *testRelay) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	*r.record = append(*r.record, r.Tag()+"=>"+destination.String())
+	if r.dialer == nil {
+		return nil, nil
+	}
+	return r.dialer.ListenPacket(ctx, r.server)
+}
+
+type testGroup struct {
+	testRelay
+	now string
+}
+
+func (g *testGroup) Now() string {
+	return g.now
+}
+
+func (g *testGroup) All() []string {
+	return []string{g.now}
+}
+
+type testManager struct {
+	adapter.OutboundManager
+	outbounds map[string]adapter.Outbound
+}
+
+func (m *testManager) Outbound(tag string) (adapter.Outbound, bool) {
+	detour, loaded := m.outbounds[tag]
+	return detour, loaded
+}
+
+func Test_Chain(t *testing.T) {
+	var record []string
+	registry := outbound.NewRegistry()
+	outbound.Register[testRelayOptions](registry, "relay", func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options testRelayOptions) (adapter.Outbound, error) {
+		outboundDialer, err := dialer.New(ctx, options.DialerOptions, false)
+		if err != nil {
+			return nil, err
+		}
+		return &testRelay{
+			Adapter: outbound.NewAdapter("relay", tag, []string{N.NetworkTCP, N.NetworkUDP}, nil),
+			dialer:  outboundDialer,
+			server:  M.ParseSocksaddr(options.Server),
+			record:  &record,
+		}, nil
+	})
+	first := &testRelay{Adapter: outbound.NewAdapter("relay", "a", nil, nil), record: &record}
+	manager := &testManager{outbounds: map[string]adapter.Outbound{
+		"a": first,
+		"g": &testGroup{testRelay: testRelay{Adapter: outbound.NewAdapter("selector", "g", nil, nil)}, now: "b"},
+		// Configured outbounds are not used except the first hop.
+		"b": &testRelay{Adapter: outbound.NewAdapter("relay", "b", nil, nil)},
+		"c": &testRelay{Adapter: outbound.NewAdapter("relay", "c", nil, nil)},
+		"d": &testRelay{Adapter: outbound.NewAdapter("relay", "d", nil, nil)},
+	}}
+	ctx := service.ContextWith[adapter.OutboundManager](context.Background(), manager)
+	ctx = service.ContextWith[adapter.OutboundRegistry](ctx, registry)
+	ctx = pluginoption.ContextWithOptions(ctx, &option.Options{Outbounds: []option.Outbound{
+		{Type: "relay", Tag: "b", Options: &testRelayOptions{Server: "1.1.1.1:1"}},
+		{Type: "relay", Tag: "d", Options: &testRelayOptions{Server: "4.4.4.4:4"}},
+		// Detour of configured hops is replaced.
+		{Type: "relay", Tag: "c", Options: &testRelayOptions{DialerOptions: option.DialerOptions{Detour: "x"}, Server: "2.2.2.2:2"}},
+	}})
+	created, err := NewChain(ctx, nil, log.StdLogger(), "chain", pluginoption.ChainOutboundOptions{
+		Outbounds: []string{"a", "g", "c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := created.(*Chain)
+	if err = chain.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	if hops := chain.Hops(); !slices.Equal(hops, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected hops: %v", hops)
+	}
+
+	conn, err := chain.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("3.3.3.3:3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	_, err = chain.ListenPacket(context.Background(), M.ParseSocksaddr("3.3.3.3:3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"c->3.3.3.3:3", "b->2.2.2.2:2", "a->1.1.1.1:1",
+		"c=>3.3.3.3:3", "b=>2.2.2.2:2", "a=>1.1.1.1:1",
+	}
+	if !slices.Equal(record, expected) {
+		t.Errorf("expected %v, got %v", expected, record)
+	}
+	if len(chain.hops) != 2 {
+		t.Errorf("hops should be reused, got %d", len(chain.hops))
+	}
+
+	// Hops of previous selection are closed.
+	stale := chain.hops["a\x00b"].(*testRelay)
+	manager.outbounds["g"].(*testGroup).now = "d"
+	conn, err = chain.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("3.3.3.3:3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !stale.closed {
+		t.Error("stale hop should be closed")
+	}
+	if _, loaded := chain.hops["a\x00d\x00c"]; !loaded || len(chain.hops) != 2 {
+		t.Errorf("unexpected hops: %v", chain.hops)
+	}
+}
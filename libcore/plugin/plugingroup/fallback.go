@@ -0,0 +1,119 @@
+package plugingroup
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"libcore/plugin/pluginoption"
+)
+
+func RegisterFallback(registry *outbound.Registry) {
+	outbound.Register[pluginoption.FallbackOutboundOptions](registry, pluginoption.TypeFallback, NewFallback)
+}
+
+var _ adapter.URLTestGroup = (*Fallback)(nil)
+
+// Fallback uses the first healthy member in order.
+type Fallback struct {
+	outbound.Adapter
+	*healthGroup
+	interruptExternalConnections bool
+	selected                     common.TypedValue[string]
+}
+
+func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options pluginoption.FallbackOutboundOptions) (adapter.Outbound, error) {
+	health, err := newHealthGroup(ctx, logger, options.Outbounds, options.URL, time.Duration(options.Interval), time.Duration(options.IdleTimeout))
+	if err != nil {
+		return nil, err
+	}
+	return &Fallback{
+		Adapter:                      outbound.NewAdapter(pluginoption.TypeFallback, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
+		healthGroup:                  health,
+		interruptExternalConnections: options.InterruptExistConnections,
+	}, nil
+}
+
+func (f *Fallback) Now() string {
+	if f.outbounds == nil {
+		return ""
+	}
+	candidates := f.candidates(N.NetworkTCP)
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].Tag()
+}
+
+// pick returns candidates for network, and interrupts connections if the preferred one changed.
+func (f *Fallback) pick(network string) ([]adapter.Outbound, error) {
+	f.touch()
+	candidates := f.candidates(network)
+	if len(candidates) == 0 {
+		return nil, E.New("missing supported outbound")
+	}
+	if network == N.NetworkTCP {
+		tag := candidates[0].Tag()
+		if old := f.selected.Swap(tag); old != "" && old != tag {
+			f.logger.Info("switched from ", old, " to ", tag)
+			f.interruptGroup.Interrupt(f.interruptExternalConnections)
+		}
+	}
+	return candidates, nil
+}
+
+func (f *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	candidates, err := f.pick(N.NetworkName(network))
+	if err != nil {
+		return nil, err
+	}
+	var errors []error
+	for _, detour := range candidates {
+		conn, err := detour.DialContext(ctx, network, destination)
+		if err == nil {
+			return f.wrapConn(ctx, conn), nil
+		}
+		f.markFailed(ctx, detour, err)
+		errors = append(errors, E.Cause(err, detour.Tag()))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (f *Fallback) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	candidates, err := f.pick(N.NetworkUDP)
+	if err != nil {
+		return nil, err
+	}
+	var errors []error
+	for _, detour := range candidates {
+		conn, err := detour.ListenPacket(ctx, destination)
+		if err == nil {
+			return f.wrapPacketConn(ctx, conn), nil
+		}
+		f.markFailed(ctx, detour, err)
+		errors = append(errors, E.Cause(err, detour.Tag()))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (f *Fallback) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	f.newConnection(ctx, f, conn, metadata, onClose)
+}
+
+func (f *Fallback) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	f.newPacketConnection(ctx, f, conn, metadata, onClose)
+}
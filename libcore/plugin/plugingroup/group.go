@@ -0,0 +1,158 @@
+// Package plugingroup implements outbound groups that sing-box doesn't provide.
+package plugingroup
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/interrupt"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/protocol/group"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+// healthGroup checks members by URL test and keeps the results in URLTestHistoryStorage,
+// which is shared with urltest groups and clash API.
+type healthGroup struct {
+	ctx             context.Context
+	logger          log.ContextLogger
+	outboundManager adapter.OutboundManager
+	connection      adapter.ConnectionManager
+	tags            []string
+	link            string
+	interval        time.Duration
+	idleTimeout     time.Duration
+	outbounds       []adapter.Outbound
+	checker         *group.URLTestGroup
+	history         adapter.URLTestHistoryStorage
+	interruptGroup  *interrupt.Group
+}
+
+func newHealthGroup(ctx context.Context, logger log.ContextLogger, tags []string, link string, interval, idleTimeout time.Duration) (*healthGroup, error) {
+	if len(tags) == 0 {
+		return nil, E.New("missing tags")
+	}
+	return &healthGroup{
+		ctx:             ctx,
+		logger:          logger,
+		outboundManager: service.FromContext[adapter.OutboundManager](ctx),
+		connection:      service.FromContext[adapter.ConnectionManager](ctx),
+		tags:            tags,
+		link:            link,
+		interval:        interval,
+		idleTimeout:     idleTimeout,
+		history:         historyStorage(ctx),
+		interruptGroup:  interrupt.NewGroup(),
+	}, nil
+}
+
+// historyStorage finds the storage in the same way as urltest group.
+func historyStorage(ctx context.Context) adapter.URLTestHistoryStorage {
+	if history := service.PtrFromContext[urltest.HistoryStorage](ctx); history != nil {
+		return history
+	}
+	if clashServer := service.FromContext[adapter.ClashServer](ctx); clashServer != nil {
+		return clashServer.HistoryStorage()
+	}
+	return urltest.NewHistoryStorage()
+}
+
+func (g *healthGroup) Start() error {
+	outbounds := make([]adapter.Outbound, 0, len(g.tags))
+	for i, tag := range g.tags {
+		detour, loaded := g.outboundManager.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	g.outbounds = outbounds
+	// Reuse the checker of urltest group for interval, idle timeout and pause handling.
+	checker, err := group.NewURLTestGroup(g.ctx, g.outboundManager, g.logger, outbounds, g.link, g.interval, 0, g.idleTimeout, false)
+	if err != nil {
+		return err
+	}
+	g.checker = checker
+	return nil
+}
+
+func (g *healthGroup) PostStart() error {
+	g.checker.PostStart()
+	return nil
+}
+
+func (g *healthGroup) Close() error {
+	return common.Close(
+		common.PtrOrNil(g.checker),
+	)
+}
+
+func (g *healthGroup) All() []string {
+	return g.tags
+}
+
+func (g *healthGroup) URLTest(ctx context.Context) (map[string]uint16, error) {
+	return g.checker.URLTest(ctx)
+}
+
+func (g *healthGroup) CheckOutbounds() {
+	g.checker.CheckOutbounds(true)
+}
+
+// touch starts periodic checking like urltest group does on use.
+func (g *healthGroup) touch() {
+	g.checker.Touch()
+}
+
+func (g *healthGroup) healthy(detour adapter.Outbound) bool {
+	return g.history.LoadURLTestHistory(group.RealTag(detour)) != nil
+}
+
+// candidates returns healthy members supporting network in order.
+// If none is healthy, all members supporting network are returned, as the checks may be not finished yet.
+func (g *healthGroup) candidates(network string) []adapter.Outbound {
+	var supported, healthy []adapter.Outbound
+	for _, detour := range g.outbounds {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		supported = append(supported, detour)
+		if g.healthy(detour) {
+			healthy = append(healthy, detour)
+		}
+	}
+	if len(healthy) > 0 {
+		return healthy
+	}
+	return supported
+}
+
+// markFailed removes the health record of detour, so it won't be selected until next successful check.
+func (g *healthGroup) markFailed(ctx context.Context, detour adapter.Outbound, err error) {
+	g.logger.ErrorContext(ctx, err)
+	g.history.DeleteURLTestHistory(group.RealTag(detour))
+}
+
+func (g *healthGroup) wrapConn(ctx context.Context, conn net.Conn) net.Conn {
+	return g.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx))
+}
+
+func (g *healthGroup) wrapPacketConn(ctx context.Context, conn net.PacketConn) net.PacketConn {
+	return g.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx))
+}
+
+func (g *healthGroup) newConnection(ctx context.Context, this adapter.Outbound, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	ctx = interrupt.ContextWithIsExternalConnection(ctx)
+	g.connection.NewConnection(ctx, this, conn, metadata, onClose)
+}
+
+func (g *healthGroup) newPacketConnection(ctx context.Context, this adapter.Outbound, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	ctx = interrupt.ContextWithIsExternalConnection(ctx)
+	g.connection.NewPacketConnection(ctx, this, conn, metadata, onClose)
+}
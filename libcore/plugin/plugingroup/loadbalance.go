@@ -0,0 +1,214 @@
+package plugingroup
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"libcore/plugin/pluginoption"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+func RegisterLoadBalance(registry *outbound.Registry) {
+	outbound.Register[pluginoption.LoadBalanceOutboundOptions](registry, pluginoption.TypeLoadBalance, NewLoadBalance)
+}
+
+var _ adapter.URLTestGroup = (*LoadBalance)(nil)
+
+const defaultStickyTTL = 10 * time.Minute
+
+// LoadBalance spreads connections over healthy members.
+type LoadBalance struct {
+	outbound.Adapter
+	*healthGroup
+	interruptExternalConnections bool
+	strategy                     string
+	index                        atomic.Uint32
+	sessions                     *cache.LruCache[string, string]
+	last                         common.TypedValue[string]
+	members                      common.TypedValue[[]string]
+}
+
+func NewLoadBalance(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options pluginoption.LoadBalanceOutboundOptions) (adapter.Outbound, error) {
+	health, err := newHealthGroup(ctx, logger, options.Outbounds, options.URL, time.Duration(options.Interval), time.Duration(options.IdleTimeout))
+	if err != nil {
+		return nil, err
+	}
+	loadBalance := &LoadBalance{
+		Adapter:                      outbound.NewAdapter(pluginoption.TypeLoadBalance, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
+		healthGroup:                  health,
+		interruptExternalConnections: options.InterruptExistConnections,
+		strategy:                     options.Strategy,
+	}
+	switch options.Strategy {
+	case "":
+		loadBalance.strategy = pluginoption.LoadBalanceStrategyConsistentHashing
+	case pluginoption.LoadBalanceStrategyRoundRobin, pluginoption.LoadBalanceStrategyConsistentHashing:
+	case pluginoption.LoadBalanceStrategyStickySessions:
+		ttl := time.Duration(options.StickyTTL)
+		if ttl == 0 {
+			ttl = defaultStickyTTL
+		}
+		loadBalance.sessions = cache.New(
+			cache.WithAge[string, string](int64(ttl.Seconds())),
+			cache.WithUpdateAgeOnGet[string, string](),
+		)
+	default:
+		return nil, E.New("unknown load balance strategy: ", options.Strategy)
+	}
+	return loadBalance, nil
+}
+
+// Strategy returns the strategy in use.
+func (l *LoadBalance) Strategy() string {
+	return l.strategy
+}
+
+// Now returns the member used by the last connection.
+func (l *LoadBalance) Now() string {
+	return l.last.Load()
+}
+
+func (l *LoadBalance) pick(ctx context.Context, network string, destination M.Socksaddr) (adapter.Outbound, error) {
+	l.touch()
+	candidates := l.candidates(network)
+	if len(candidates) == 0 {
+		return nil, E.New("missing supported outbound")
+	}
+	if network == N.NetworkTCP {
+		l.updateMembers(candidates)
+	}
+	var selected adapter.Outbound
+	switch l.strategy {
+	case pluginoption.LoadBalanceStrategyRoundRobin:
+		// Convert by uint to keep index non-negative after overflow on 32-bit platforms.
+		selected = candidates[int(uint(l.index.Add(1)-1)%uint(len(candidates)))]
+	case pluginoption.LoadBalanceStrategyConsistentHashing:
+		selected = rendezvous(candidates, hashKey(ctx, destination))
+	case pluginoption.LoadBalanceStrategyStickySessions:
+		var source string
+		if metadata := adapter.ContextFrom(ctx); metadata != nil {
+			source = metadata.Source.Addr.String()
+		}
+		key := source + "|" + hashKey(ctx, destination)
+		if tag, loaded := l.sessions.Load(key); loaded {
+			selected = common.Find(candidates, func(it adapter.Outbound) bool {
+				return it.Tag() == tag
+			})
+		}
+		if selected == nil {
+			selected = rendezvous(candidates, key)
+			l.sessions.Store(key, selected.Tag())
+		}
+	}
+	l.last.Store(selected.Tag())
+	return selected, nil
+}
+
+// updateMembers interrupts connections if any member left the healthy candidates,
+// as connections through it are likely broken.
+func (l *LoadBalance) updateMembers(candidates []adapter.Outbound) {
+	members := common.Map(candidates, adapter.Outbound.Tag)
+	old := l.members.Swap(members)
+	for _, tag := range old {
+		if !common.Contains(members, tag) {
+			l.logger.Info("member ", tag, " left, interrupt connections")
+			l.interruptGroup.Interrupt(l.interruptExternalConnections)
+			return
+		}
+	}
+}
+
+func (l *LoadBalance) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	selected, err := l.pick(ctx, N.NetworkName(network), destination)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := selected.DialContext(ctx, network, destination)
+	if err != nil {
+		l.markFailed(ctx, selected, err)
+		return nil, err
+	}
+	return l.wrapConn(ctx, conn), nil
+}
+
+func (l *LoadBalance) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	selected, err := l.pick(ctx, N.NetworkUDP, destination)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := selected.ListenPacket(ctx, destination)
+	if err != nil {
+		l.markFailed(ctx, selected, err)
+		return nil, err
+	}
+	return l.wrapPacketConn(ctx, conn), nil
+}
+
+func (l *LoadBalance) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	l.newConnection(ctx, l, conn, metadata, onClose)
+}
+
+func (l *LoadBalance) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	l.newPacketConnection(ctx, l, conn, metadata, onClose)
+}
+
+// hashKey returns the registrable domain of destination, so subdomains of a site share the same member.
+func hashKey(ctx context.Context, destination M.Socksaddr) string {
+	domain := destination.Fqdn
+	if domain == "" {
+		if metadata := adapter.ContextFrom(ctx); metadata != nil {
+			domain = metadata.Domain
+		}
+	}
+	if domain == "" {
+		return destination.Addr.String()
+	}
+	if registrable, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		return registrable
+	}
+	return domain
+}
+
+// rendezvous selects by highest random weight hashing,
+// so only keys of an unavailable member are moved when membership changes.
+func rendezvous(outbounds []adapter.Outbound, key string) adapter.Outbound {
+	var (
+		selected  adapter.Outbound
+		bestScore uint64
+	)
+	for _, detour := range outbounds {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(key))
+		_, _ = hash.Write([]byte{0})
+		_, _ = hash.Write([]byte(detour.Tag()))
+		score := mix64(hash.Sum64())
+		if selected == nil || score > bestScore {
+			selected = detour
+			bestScore = score
+		}
+	}
+	return selected
+}
+
+// mix64 is the finalizer of SplitMix64, which makes FNV output well distributed.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,191 @@
+package plugingroup
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/interrupt"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/protocol/group"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"libcore/plugin/pluginoption"
+)
+
+type stubOutbound struct {
+	outbound.Adapter
+}
+
+func newStubOutbound(tag string) adapter.Outbound {
+	return &stubOutbound{outbound.NewAdapter(C.TypeDirect, tag, []string{N.NetworkTCP, N.NetworkUDP}, nil)}
+}
+
+func (s *stubOutbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return nil, os.ErrInvalid
+}
+
+func (s *stubOutbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
+
+func newTestHealthGroup(t *testing.T, tags ...string) *healthGroup {
+	outbounds := make([]adapter.Outbound, 0, len(tags))
+	for _, tag := range tags {
+		outbounds = append(outbounds, newStubOutbound(tag))
+	}
+	checker, err := group.NewURLTestGroup(context.Background(), nil, log.StdLogger(), outbounds, "", 0, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &healthGroup{
+		logger:         log.StdLogger(),
+		tags:           tags,
+		outbounds:      outbounds,
+		checker:        checker,
+		history:        urltest.NewHistoryStorage(),
+		interruptGroup: interrupt.NewGroup(),
+	}
+}
+
+func (g *healthGroup) setHealthy(tags ...string) {
+	for _, tag := range tags {
+		g.history.StoreURLTestHistory(tag, &adapter.URLTestHistory{Time: time.Now(), Delay: 100})
+	}
+}
+
+func Test_Fallback(t *testing.T) {
+	health := newTestHealthGroup(t, "a", "b", "c")
+	fallback := &Fallback{healthGroup: health}
+	if now := fallback.Now(); now != "a" {
+		t.Errorf("expected a before checked, got %s", now)
+	}
+	health.setHealthy("b", "c")
+	if now := fallback.Now(); now != "b" {
+		t.Errorf("expected b, got %s", now)
+	}
+	health.setHealthy("a")
+	if now := fallback.Now(); now != "a" {
+		t.Errorf("expected a, got %s", now)
+	}
+}
+
+func Test_LoadBalance(t *testing.T) {
+	destination := M.ParseSocksaddrHostPort("www.example.com", 443)
+	tests := []struct {
+		strategy string
+		check    func(t *testing.T, l *LoadBalance)
+	}{
+		{
+			strategy: pluginoption.LoadBalanceStrategyRoundRobin,
+			check: func(t *testing.T, l *LoadBalance) {
+				var selected []string
+				for range 4 {
+					detour, _ := l.pick(context.Background(), N.NetworkTCP, destination)
+					selected = append(selected, detour.Tag())
+				}
+				if selected[0] == selected[1] || selected[0] != selected[3] {
+					t.Errorf("unexpected round robin: %v", selected)
+				}
+			},
+		},
+		{
+			strategy: pluginoption.LoadBalanceStrategyConsistentHashing,
+			check: func(t *testing.T, l *LoadBalance) {
+				first, _ := l.pick(context.Background(), N.NetworkTCP, destination)
+				other, _ := l.pick(context.Background(), N.NetworkTCP, M.ParseSocksaddrHostPort("api.example.com", 443))
+				if first != other {
+					t.Errorf("subdomains should share member, got %s and %s", first.Tag(), other.Tag())
+				}
+				// Only keys of the unhealthy member may move.
+				l.history.DeleteURLTestHistory(first.Tag())
+				moved, _ := l.pick(context.Background(), N.NetworkTCP, destination)
+				if moved == first {
+					t.Error("unhealthy member selected")
+				}
+			},
+		},
+		{
+			strategy: pluginoption.LoadBalanceStrategyStickySessions,
+			check: func(t *testing.T, l *LoadBalance) {
+				first, _ := l.pick(context.Background(), N.NetworkTCP, destination)
+				for range 4 {
+					detour, _ := l.pick(context.Background(), N.NetworkTCP, destination)
+					if detour != first {
+						t.Fatalf("session moved from %s to %s", first.Tag(), detour.Tag())
+					}
+				}
+				if now := l.Now(); now != first.Tag() {
+					t.Errorf("expected %s, got %s", first.Tag(), now)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			health := newTestHealthGroup(t, "a", "b", "c")
+			health.setHealthy("a", "b", "c")
+			loadBalance, err := NewLoadBalance(context.Background(), nil, log.StdLogger(), "balance", pluginoption.LoadBalanceOutboundOptions{
+				Outbounds: health.tags,
+				Strategy:  tt.strategy,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := loadBalance.(*LoadBalance)
+			l.healthGroup = health
+			tt.check(t, l)
+		})
+	}
+}
+
+func Test_LoadBalanceInterrupt(t *testing.T) {
+	health := newTestHealthGroup(t, "a", "b", "c")
+	health.setHealthy("a", "b", "c")
+	l := &LoadBalance{healthGroup: health, strategy: pluginoption.LoadBalanceStrategyRoundRobin}
+	destination := M.ParseSocksaddrHostPort("www.example.com", 443)
+	_, err := l.pick(context.Background(), N.NetworkTCP, destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conn := l.wrapConn(context.Background(), local)
+
+	// Members recovering keep connections.
+	l.members.Store([]string{"a"})
+	_, _ = l.pick(context.Background(), N.NetworkTCP, destination)
+	go func() {
+		_, _ = remote.Read(make([]byte, 1))
+	}()
+	if _, err = conn.Write([]byte{0}); err != nil {
+		t.Fatal("interrupted by recovered member: ", err)
+	}
+
+	health.history.DeleteURLTestHistory("b")
+	_, _ = l.pick(context.Background(), N.NetworkTCP, destination)
+	if _, err = conn.Write([]byte{0}); err == nil {
+		t.Error("connection not interrupted")
+	}
+}
+
+func Test_rendezvous(t *testing.T) {
+	outbounds := []adapter.Outbound{newStubOutbound("a"), newStubOutbound("b"), newStubOutbound("c")}
+	counts := make(map[string]int)
+	for i := range 3000 {
+		counts[rendezvous(outbounds, strconv.Itoa(i)).Tag()]++
+	}
+	for tag, count := range counts {
+		if count < 800 {
+			t.Errorf("unbalanced distribution of %s: %v", tag, counts)
+		}
+	}
+}
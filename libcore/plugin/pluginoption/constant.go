@@ -8,6 +8,15 @@ import (
 const (
 	TypeJuicity     = "juicity"
 	TypeTrustTunnel = "trusttunnel"
+	TypeFallback    = "fallback"
+	TypeLoadBalance = "loadbalance"
+	TypeChain       = "chain"
+)
+
+const (
+	DNSTypeDNSCrypt = "dnscrypt"
+	DNSTypeParallel = "parallel"
+	DNSTypeFilter   = "filter"
 )
 
 func ProxyDisplayName(proxyType string) string {
@@ -16,6 +25,12 @@ func ProxyDisplayName(proxyType string) string {
 		return "Juicity"
 	case TypeTrustTunnel:
 		return "TrustTunnel"
+	case TypeFallback:
+		return "Fallback"
+	case TypeLoadBalance:
+		return "LoadBalance"
+	case TypeChain:
+		return "Chain"
 	default:
 		return C.ProxyDisplayName(proxyType)
 	}
@@ -0,0 +1,19 @@
+package pluginoption
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/service"
+)
+
+// ContextWithOptions provides the options of the whole config to plugins,
+// like chain creating its own hops and tun checking route rules.
+func ContextWithOptions(ctx context.Context, options *option.Options) context.Context {
+	return service.ContextWithPtr(ctx, options)
+}
+
+// OptionsFromContext returns the options provided by ContextWithOptions, or nil if not provided.
+func OptionsFromContext(ctx context.Context) *option.Options {
+	return service.PtrFromContext[option.Options](ctx)
+}
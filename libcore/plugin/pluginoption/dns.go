@@ -2,6 +2,7 @@ package pluginoption
 
 import (
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json/badoption"
 )
 
 type RemoteTCPDNSServerOptions struct {
@@ -17,3 +18,57 @@ type RemoteTLSDNSServerOptions struct {
 	Pipeline   bool `json:"pipeline,omitempty"`
 	MaxQueries int  `json:"max_queries,omitempty"`
 }
+
+// MultiplexDNSServerOptions tunes connections carrying concurrent queries, like HTTP/2 and QUIC.
+type MultiplexDNSServerOptions struct {
+	IdleTimeout        badoption.Duration `json:"idle_timeout,omitempty"`
+	MaxIdleConnections int                `json:"max_idle_connections,omitempty"`
+	MaxQueries         int                `json:"max_queries,omitempty"`
+}
+
+type RemoteHTTPSDNSServerOptions struct {
+	option.RemoteHTTPSDNSServerOptions
+	MultiplexDNSServerOptions
+}
+
+type RemoteQUICDNSServerOptions struct {
+	option.RemoteDNSServerOptions
+	option.OutboundTLSOptionsContainer
+	MultiplexDNSServerOptions
+}
+
+// DNSCryptDNSServerOptions reads the server address and provider key from the stamp.
+type DNSCryptDNSServerOptions struct {
+	option.RawLocalDNSServerOptions
+	Stamp string `json:"stamp"`
+	// Relays are anonymized DNSCrypt relays, in stamp or address form.
+	Relays              badoption.Listable[string] `json:"relays,omitempty"`
+	CertRefreshInterval badoption.Duration         `json:"cert_refresh_interval,omitempty"`
+}
+
+// ParallelDNSServerOptions queries all servers at once. The dialer is used to probe addresses in fastest_ip mode.
+type ParallelDNSServerOptions struct {
+	option.DialerOptions
+	Servers badoption.Listable[string] `json:"servers"`
+	// Mode is one of race, fastest_ip and consistent, default to race.
+	Mode      string `json:"mode,omitempty"`
+	ProbePort uint16 `json:"probe_port,omitempty"`
+	// Timeout bounds waiting for other servers after the first answer, and the probe.
+	Timeout badoption.Duration `json:"timeout,omitempty"`
+	// MinAgreement is the count of servers which must answer the same in consistent mode, default to majority.
+	MinAgreement int `json:"min_agreement,omitempty"`
+}
+
+// FilterDNSServerOptions blocks domains in lists, and forwards other queries to server.
+type FilterDNSServerOptions struct {
+	Server string              `json:"server"`
+	Lists  []FilterListOptions `json:"lists"`
+	// BlockMode is one of nxdomain, null_ip and refused, default to nxdomain.
+	BlockMode string `json:"block_mode,omitempty"`
+}
+
+// FilterListOptions is a hosts or AdGuard/ABP style list file.
+type FilterListOptions struct {
+	Tag  string `json:"tag"`
+	Path string `json:"path"`
+}
@@ -0,0 +1,37 @@
+package pluginoption
+
+import (
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+type FallbackOutboundOptions struct {
+	Outbounds                 []string           `json:"outbounds"`
+	URL                       string             `json:"url,omitempty"`
+	Interval                  badoption.Duration `json:"interval,omitempty"`
+	IdleTimeout               badoption.Duration `json:"idle_timeout,omitempty"`
+	InterruptExistConnections bool               `json:"interrupt_exist_connections,omitempty"`
+}
+
+// Strategies of load balance, named the same as Clash.
+const (
+	LoadBalanceStrategyRoundRobin        = "round-robin"
+	LoadBalanceStrategyConsistentHashing = "consistent-hashing"
+	LoadBalanceStrategyStickySessions    = "sticky-sessions"
+)
+
+type LoadBalanceOutboundOptions struct {
+	Outbounds                 []string           `json:"outbounds"`
+	URL                       string             `json:"url,omitempty"`
+	Interval                  badoption.Duration `json:"interval,omitempty"`
+	IdleTimeout               badoption.Duration `json:"idle_timeout,omitempty"`
+	InterruptExistConnections bool               `json:"interrupt_exist_connections,omitempty"`
+	// Strategy is one of the strategies, default to consistent-hashing as Clash.
+	Strategy string `json:"strategy,omitempty"`
+	// StickyTTL is how long a session sticks to an outbound for sticky-sessions.
+	StickyTTL badoption.Duration `json:"sticky_ttl,omitempty"`
+}
+
+type ChainOutboundOptions struct {
+	// Outbounds are hops from the nearest to the exit.
+	Outbounds []string `json:"outbounds"`
+}
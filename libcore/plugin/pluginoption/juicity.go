@@ -7,6 +7,8 @@ type JuicityOutboundOptions struct {
 	option.ServerOptions
 	UUID     string `json:"uuid,omitempty"`
 	Password string `json:"password,omitempty"`
+	// CongestionControl is one of cubic, new_reno, bbr and bbr2, default to bbr.
+	CongestionControl string `json:"congestion_control,omitempty"`
 	option.OutboundTLSOptionsContainer
 	PinCertSha256 string `json:"pin_cert_sha256,omitempty"`
 }
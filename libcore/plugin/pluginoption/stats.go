@@ -0,0 +1,8 @@
+package pluginoption
+
+// UDPStats is the statistics of UDP sessions of an outbound carrying them by streams.
+type UDPStats struct {
+	Active int64
+	Total  int64
+	Failed int64
+}
@@ -0,0 +1,588 @@
+package raybridge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+// https://xtls.github.io/config/transport.html
+type xrayStreamSettings struct {
+	Network             string          `json:"network"`
+	Security            string          `json:"security"`
+	TLSSettings         json.RawMessage `json:"tlsSettings"`
+	RealitySettings     json.RawMessage `json:"realitySettings"`
+	TCPSettings         json.RawMessage `json:"tcpSettings"`
+	RawSettings         json.RawMessage `json:"rawSettings"`
+	WSSettings          json.RawMessage `json:"wsSettings"`
+	GRPCSettings        json.RawMessage `json:"grpcSettings"`
+	HTTPUpgradeSettings json.RawMessage `json:"httpupgradeSettings"`
+	HTTPSettings        json.RawMessage `json:"httpSettings"`
+	XHTTPSettings       json.RawMessage `json:"xhttpSettings"`
+	SplitHTTPSettings   json.RawMessage `json:"splithttpSettings"`
+	KCPSettings         json.RawMessage `json:"kcpSettings"`
+	QUICSettings        json.RawMessage `json:"quicSettings"`
+	Sockopt             json.RawMessage `json:"sockopt"`
+}
+
+type xrayTLS struct {
+	ServerName                           string            `json:"serverName"`
+	AllowInsecure                        bool              `json:"allowInsecure"`
+	ALPN                                 []string          `json:"alpn"`
+	Fingerprint                          string            `json:"fingerprint"`
+	MinVersion                           string            `json:"minVersion"`
+	MaxVersion                           string            `json:"maxVersion"`
+	CipherSuites                         string            `json:"cipherSuites"`
+	CurvePreferences                     []string          `json:"curvePreferences"`
+	Certificates                         []xrayCertificate `json:"certificates"`
+	DisableSystemRoot                    bool              `json:"disableSystemRoot"`
+	EnableSessionResumption              bool              `json:"enableSessionResumption"`
+	PinnedPeerCertificateChainSha256     []string          `json:"pinnedPeerCertificateChainSha256"`
+	PinnedPeerCertificatePublicKeySha256 []string          `json:"pinnedPeerCertificatePublicKeySha256"`
+	ECHConfigList                        string            `json:"echConfigList"`
+}
+
+type xrayCertificate struct {
+	Usage           string   `json:"usage"`
+	Certificate     []string `json:"certificate"`
+	CertificateFile string   `json:"certificateFile"`
+	Key             []string `json:"key"`
+	KeyFile         string   `json:"keyFile"`
+}
+
+type xrayReality struct {
+	ServerName  string `json:"serverName"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"publicKey"`
+	Password    string `json:"password"`
+	ShortID     string `json:"shortId"`
+	SpiderX     string `json:"spiderX"`
+}
+
+type xrayWS struct {
+	Path    string            `json:"path"`
+	Host    string            `json:"host"`
+	Headers map[string]string `json:"headers"`
+}
+
+type xrayHTTPUpgrade xrayWS
+
+type xrayGRPC struct {
+	ServiceName         string `json:"serviceName"`
+	MultiMode           bool   `json:"multiMode"`
+	IdleTimeout         int    `json:"idle_timeout"`
+	HealthCheckTimeout  int    `json:"health_check_timeout"`
+	PermitWithoutStream bool   `json:"permit_without_stream"`
+}
+
+type xrayHTTP struct {
+	Host               []string              `json:"host"`
+	Path               string                `json:"path"`
+	Method             string                `json:"method"`
+	Headers            map[string]stringList `json:"headers"`
+	ReadIdleTimeout    int                   `json:"read_idle_timeout"`
+	HealthCheckTimeout int                   `json:"health_check_timeout"`
+}
+
+type xrayTCP struct {
+	Header struct {
+		Type    string `json:"type"`
+		Request struct {
+			Method  string                `json:"method"`
+			Path    []string              `json:"path"`
+			Headers map[string]stringList `json:"headers"`
+		} `json:"request"`
+	} `json:"header"`
+}
+
+type xraySockopt struct {
+	Mark                 uint32          `json:"mark"`
+	TCPFastOpen          json.RawMessage `json:"tcpFastOpen"`
+	TCPMptcp             bool            `json:"tcpMptcp"`
+	Interface            string          `json:"interface"`
+	DialerProxy          string          `json:"dialerProxy"`
+	DomainStrategy       string          `json:"domainStrategy"`
+	TCPKeepAliveIdle     int             `json:"tcpKeepAliveIdle"`
+	TCPKeepAliveInterval int             `json:"tcpKeepAliveInterval"`
+}
+
+// stringList is a string or an array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(content []byte) error {
+	var value string
+	if json.Unmarshal(content, &value) == nil {
+		*l = []string{value}
+		return nil
+	}
+	return json.Unmarshal(content, (*[]string)(l))
+}
+
+// https://xtls.github.io/config/transports/websocket.html
+const earlyDataHeaderName = "Sec-WebSocket-Protocol"
+
+type xrayStream struct {
+	transport *option.V2RayTransportOptions
+	tls       *option.OutboundTLSOptions
+}
+
+func (c *converter) stream(raw json.RawMessage) (*xrayStream, error) {
+	var settings xrayStreamSettings
+	err := c.decode("streamSettings.", raw, &settings)
+	if err != nil {
+		return nil, err
+	}
+	stream := new(xrayStream)
+	stream.transport, err = c.transport(&settings)
+	if err != nil {
+		return nil, err
+	}
+	switch settings.Security {
+	case "", "none":
+	case "tls":
+		stream.tls, err = c.tls(settings.TLSSettings)
+	case "reality":
+		stream.tls, err = c.reality(settings.RealitySettings)
+	default:
+		err = E.New("unsupported security: ", settings.Security)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (c *converter) transport(settings *xrayStreamSettings) (*option.V2RayTransportOptions, error) {
+	switch settings.Network {
+	case "", "tcp", "raw":
+		raw := settings.RawSettings
+		if len(raw) == 0 {
+			raw = settings.TCPSettings
+		}
+		var tcp xrayTCP
+		err := c.decode("streamSettings.rawSettings.", raw, &tcp)
+		if err != nil {
+			return nil, err
+		}
+		switch tcp.Header.Type {
+		case "", "none":
+			return nil, nil
+		case "http":
+		default:
+			return nil, E.New("unsupported tcp header: ", tcp.Header.Type)
+		}
+		request := tcp.Header.Request
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Method: request.Method,
+			},
+		}
+		if transport.HTTPOptions.Method == "" {
+			transport.HTTPOptions.Method = "GET"
+		}
+		if len(request.Path) > 0 {
+			transport.HTTPOptions.Path = request.Path[0]
+			if len(request.Path) > 1 {
+				c.warn("only the first of tcp header paths is used")
+			}
+		}
+		transport.HTTPOptions.Host, transport.HTTPOptions.Headers = splitHost(request.Headers)
+		return transport, nil
+	case "ws", "websocket":
+		var ws xrayWS
+		err := c.decode("streamSettings.wsSettings.", settings.WSSettings, &ws)
+		if err != nil {
+			return nil, err
+		}
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Headers: headers(ws.Headers, ws.Host),
+			},
+		}
+		transport.WebsocketOptions.Path, transport.WebsocketOptions.MaxEarlyData, err = parseEarlyData(ws.Path)
+		if err != nil {
+			return nil, err
+		}
+		if transport.WebsocketOptions.MaxEarlyData > 0 {
+			transport.WebsocketOptions.EarlyDataHeaderName = earlyDataHeaderName
+		}
+		return transport, nil
+	case "httpupgrade":
+		var httpUpgrade xrayHTTPUpgrade
+		err := c.decode("streamSettings.httpupgradeSettings.", settings.HTTPUpgradeSettings, &httpUpgrade)
+		if err != nil {
+			return nil, err
+		}
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTPUpgrade,
+			HTTPUpgradeOptions: option.V2RayHTTPUpgradeOptions{
+				Host:    httpUpgrade.Host,
+				Headers: headers(httpUpgrade.Headers, ""),
+			},
+		}
+		var maxEarlyData uint32
+		transport.HTTPUpgradeOptions.Path, maxEarlyData, err = parseEarlyData(httpUpgrade.Path)
+		if err != nil {
+			return nil, err
+		}
+		if maxEarlyData > 0 {
+			c.warn("early data of httpupgrade is not supported")
+		}
+		return transport, nil
+	case "grpc", "gun":
+		var grpc xrayGRPC
+		err := c.decode("streamSettings.grpcSettings.", settings.GRPCSettings, &grpc)
+		if err != nil {
+			return nil, err
+		}
+		if grpc.MultiMode {
+			c.warn("multiMode of grpc is not supported")
+		}
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName:         grpc.ServiceName,
+				IdleTimeout:         seconds(grpc.IdleTimeout),
+				PingTimeout:         seconds(grpc.HealthCheckTimeout),
+				PermitWithoutStream: grpc.PermitWithoutStream,
+			},
+		}, nil
+	case "http", "h2":
+		var http xrayHTTP
+		err := c.decode("streamSettings.httpSettings.", settings.HTTPSettings, &http)
+		if err != nil {
+			return nil, err
+		}
+		host, headers := splitHost(http.Headers)
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host:        append(http.Host, host...),
+				Path:        http.Path,
+				Method:      http.Method,
+				Headers:     headers,
+				IdleTimeout: seconds(http.ReadIdleTimeout),
+				PingTimeout: seconds(http.HealthCheckTimeout),
+			},
+		}, nil
+	case "quic":
+		return &option.V2RayTransportOptions{Type: C.V2RayTransportTypeQUIC}, nil
+	case "xhttp", "splithttp":
+		// XHTTP splits upload and download into different requests,
+		// which no transport of sing-box is compatible with.
+		return nil, E.New("transport ", settings.Network, " is not supported")
+	default:
+		return nil, E.New("unsupported transport: ", settings.Network)
+	}
+}
+
+// parseEarlyData extracts `?ed=` from path like Xray.
+func parseEarlyData(path string) (string, uint32, error) {
+	rawPath, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path, 0, nil
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !query.Has("ed") {
+		return path, 0, nil
+	}
+	maxEarlyData, err := strconv.ParseUint(query.Get("ed"), 10, 32)
+	if err != nil {
+		return "", 0, E.Cause(err, "parse early data")
+	}
+	query.Del("ed")
+	if len(query) > 0 {
+		rawPath += "?" + query.Encode()
+	}
+	return rawPath, uint32(maxEarlyData), nil
+}
+
+func headers(values map[string]string, host string) badoption.HTTPHeader {
+	if len(values) == 0 && host == "" {
+		return nil
+	}
+	header := make(badoption.HTTPHeader, len(values)+1)
+	for key, value := range values {
+		header[key] = badoption.Listable[string]{value}
+	}
+	if host != "" {
+		header["Host"] = badoption.Listable[string]{host}
+	}
+	return header
+}
+
+// splitHost moves Host header out, as sing-box HTTP transport has a dedicated field.
+func splitHost(values map[string]stringList) (badoption.Listable[string], badoption.HTTPHeader) {
+	var (
+		host   badoption.Listable[string]
+		header badoption.HTTPHeader
+	)
+	for key, value := range values {
+		if strings.EqualFold(key, "Host") {
+			host = append(host, value...)
+			continue
+		}
+		if header == nil {
+			header = make(badoption.HTTPHeader)
+		}
+		header[key] = badoption.Listable[string](value)
+	}
+	return host, header
+}
+
+func seconds(value int) badoption.Duration {
+	return badoption.Duration(time.Duration(value) * time.Second)
+}
+
+// Fingerprints supported by sing-box uTLS.
+var fingerprints = []string{"chrome", "firefox", "edge", "safari", "360", "qq", "ios", "android", "random", "randomized"}
+
+func (c *converter) utls(fingerprint string) *option.OutboundUTLSOptions {
+	if fingerprint == "" {
+		return nil
+	}
+	if !slices.Contains(fingerprints, fingerprint) {
+		c.warn("unsupported fingerprint: ", fingerprint)
+		return nil
+	}
+	return &option.OutboundUTLSOptions{
+		Enabled:     true,
+		Fingerprint: fingerprint,
+	}
+}
+
+func (c *converter) tls(raw json.RawMessage) (*option.OutboundTLSOptions, error) {
+	var settings xrayTLS
+	err := c.decode("streamSettings.tlsSettings.", raw, &settings)
+	if err != nil {
+		return nil, err
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: settings.ServerName,
+		Insecure:   settings.AllowInsecure,
+		ALPN:       settings.ALPN,
+		MinVersion: settings.MinVersion,
+		MaxVersion: settings.MaxVersion,
+		UTLS:       c.utls(settings.Fingerprint),
+	}
+	if settings.CipherSuites != "" {
+		options.CipherSuites = strings.Split(settings.CipherSuites, ":")
+	}
+	for _, curve := range settings.CurvePreferences {
+		var preference option.CurvePreference
+		err = json.Unmarshal([]byte(strconv.Quote(curve)), &preference)
+		if err != nil {
+			c.warn("unsupported curve preference: ", curve)
+			continue
+		}
+		options.CurvePreferences = append(options.CurvePreferences, preference)
+	}
+
+	var certificates []string
+	for _, certificate := range settings.Certificates {
+		switch certificate.Usage {
+		case "verify":
+			if certificate.CertificateFile != "" {
+				if options.CertificatePath != "" {
+					c.warn("only the first certificate file is used: ", options.CertificatePath)
+					continue
+				}
+				options.CertificatePath = certificate.CertificateFile
+			}
+			certificates = append(certificates, certificate.Certificate...)
+		case "", "encipherment":
+			options.ClientCertificate = append(options.ClientCertificate, certificate.Certificate...)
+			options.ClientKey = append(options.ClientKey, certificate.Key...)
+			options.ClientCertificatePath = certificate.CertificateFile
+			options.ClientKeyPath = certificate.KeyFile
+		default:
+			c.warn("unsupported certificate usage: ", certificate.Usage)
+		}
+	}
+	if len(certificates) > 0 {
+		options.Certificate = certificates
+	}
+	c.pinCertificate(&settings, options)
+	if settings.DisableSystemRoot && len(options.Certificate) == 0 && options.CertificatePath == "" && len(options.CertificatePublicKeySHA256) == 0 {
+		c.warn("disableSystemRoot without certificates is not supported")
+	}
+
+	if settings.ECHConfigList != "" {
+		options.ECH, err = c.ech(settings.ECHConfigList)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// pinCertificate converts pinned hashes of Xray.
+// sing-box can't pin certificate chain hash, but trusting the exact certificate has the same effect.
+func (c *converter) pinCertificate(settings *xrayTLS, options *option.OutboundTLSOptions) {
+	for _, value := range settings.PinnedPeerCertificatePublicKeySha256 {
+		hash, err := decodeHash(value)
+		if err != nil {
+			c.warn("bad pinned public key hash ", value, ": ", err)
+			continue
+		}
+		options.CertificatePublicKeySHA256 = append(options.CertificatePublicKeySHA256, hash)
+	}
+	if len(options.CertificatePublicKeySHA256) > 0 && (len(options.Certificate) > 0 || options.CertificatePath != "") {
+		// Conflicted in sing-box, and public key pinning is stricter.
+		c.warn("certificates are dropped in favor of pinned public key hash")
+		options.Certificate = nil
+		options.CertificatePath = ""
+	}
+	if len(settings.PinnedPeerCertificateChainSha256) == 0 {
+		return
+	}
+	if len(options.Certificate) == 0 {
+		c.warn("pinnedPeerCertificateChainSha256 is not supported without inline certificate, use certificate_public_key_sha256 instead")
+		return
+	}
+	hash := string(CalculatePEMCertHash([]byte(strings.Join(options.Certificate, "\n"))))
+	if !slices.Contains(settings.PinnedPeerCertificateChainSha256, hash) {
+		c.warn("pinnedPeerCertificateChainSha256 doesn't match the certificate, which is ", hash)
+	}
+}
+
+func decodeHash(value string) ([]byte, error) {
+	hash, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		hash, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != 32 {
+		return nil, E.New("bad length: ", len(hash))
+	}
+	return hash, nil
+}
+
+// ech converts echConfigList, which is either base64 encoded ECHConfigList
+// or `[domain+]DNS server` to query HTTPS record.
+func (c *converter) ech(value string) (*option.OutboundECHOptions, error) {
+	config, err := base64.StdEncoding.DecodeString(value)
+	if err == nil {
+		content := pem.EncodeToMemory(&pem.Block{Type: "ECH CONFIGS", Bytes: config})
+		return &option.OutboundECHOptions{
+			Enabled: true,
+			Config:  strings.Split(string(bytes.TrimSpace(content)), "\n"),
+		}, nil
+	}
+	options := &option.OutboundECHOptions{Enabled: true}
+	if queryServerName, _, found := strings.Cut(value, "+"); found {
+		options.QueryServerName = queryServerName
+	}
+	c.warn("DNS server of echConfigList is ignored, ECH config will be queried by the default DNS server")
+	return options, nil
+}
+
+func (c *converter) reality(raw json.RawMessage) (*option.OutboundTLSOptions, error) {
+	var settings xrayReality
+	err := c.decode("streamSettings.realitySettings.", raw, &settings)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := settings.PublicKey
+	if publicKey == "" {
+		publicKey = settings.Password
+	}
+	if publicKey == "" {
+		return nil, E.New("missing reality public key")
+	}
+	if settings.SpiderX != "" && settings.SpiderX != "/" {
+		c.warn("spiderX of reality is not supported")
+	}
+	// REALITY requires uTLS.
+	fingerprint := settings.Fingerprint
+	if fingerprint == "" {
+		fingerprint = "chrome"
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: settings.ServerName,
+		UTLS:       c.utls(fingerprint),
+		Reality: &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: publicKey,
+			ShortID:   settings.ShortID,
+		},
+	}
+	if options.UTLS == nil {
+		options.UTLS = &option.OutboundUTLSOptions{Enabled: true}
+	}
+	return options, nil
+}
+
+func (c *converter) sockopt(raw json.RawMessage, options *option.DialerOptions) error {
+	var sockopt xraySockopt
+	err := c.decode("streamSettings.sockopt.", raw, &sockopt)
+	if err != nil {
+		return err
+	}
+	options.RoutingMark = option.FwMark(sockopt.Mark)
+	options.TCPMultiPath = sockopt.TCPMptcp
+	options.BindInterface = sockopt.Interface
+	options.TCPKeepAlive = seconds(sockopt.TCPKeepAliveIdle)
+	options.TCPKeepAliveInterval = seconds(sockopt.TCPKeepAliveInterval)
+	if sockopt.DialerProxy != "" {
+		if options.Detour != "" && options.Detour != sockopt.DialerProxy {
+			c.warn("dialerProxy ", sockopt.DialerProxy, " is ignored in favor of proxySettings ", options.Detour)
+		} else {
+			options.Detour = sockopt.DialerProxy
+		}
+	}
+	c.domainStrategy("streamSettings.sockopt.", sockopt.DomainStrategy)
+	// tcpFastOpen is either a boolean or queue length.
+	if len(sockopt.TCPFastOpen) > 0 {
+		var enabled bool
+		if json.Unmarshal(sockopt.TCPFastOpen, &enabled) != nil {
+			var queue int
+			_ = json.Unmarshal(sockopt.TCPFastOpen, &queue)
+			enabled = queue > 0
+		}
+		options.TCPFastOpen = enabled
+	}
+	return nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		return netip.ParsePrefix(value)
+	}
+	address, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(address, address.BitLen()), nil
+}
+
+func splitHostPort(value string) (string, uint16, error) {
+	host, rawPort, err := net.SplitHostPort(value)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(rawPort, 10, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, uint16(port), nil
+}
@@ -0,0 +1,560 @@
+package raybridge
+
+import (
+	"bytes"
+	"maps"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"libcore/plugin/pluginoption"
+)
+
+// Result is the converted Xray configuration.
+type Result struct {
+	Outbounds []option.Outbound
+	Endpoints []option.Endpoint
+
+	// Warnings records features that can't be converted.
+	Warnings []string
+}
+
+// https://xtls.github.io/config/outbound.html
+type xrayOutbound struct {
+	Protocol       string          `json:"protocol"`
+	Tag            string          `json:"tag"`
+	SendThrough    string          `json:"sendThrough"`
+	Settings       json.RawMessage `json:"settings"`
+	StreamSettings json.RawMessage `json:"streamSettings"`
+	ProxySettings  json.RawMessage `json:"proxySettings"`
+	Mux            json.RawMessage `json:"mux"`
+	TargetStrategy string          `json:"targetStrategy"`
+}
+
+type xrayServer struct {
+	Address    string          `json:"address"`
+	Port       uint16          `json:"port"`
+	Users      json.RawMessage `json:"users"`
+	Password   string          `json:"password"`
+	Method     string          `json:"method"`
+	UoT        bool            `json:"uot"`
+	UoTVersion int             `json:"UoTVersion"`
+	Email      string          `json:"email"`
+	Level      int             `json:"level"`
+}
+
+type xrayUser struct {
+	ID         string `json:"id"`
+	AlterID    int    `json:"alterId"`
+	Security   string `json:"security"`
+	Encryption string `json:"encryption"`
+	Flow       string `json:"flow"`
+	User       string `json:"user"`
+	Pass       string `json:"pass"`
+	Email      string `json:"email"`
+	Level      int    `json:"level"`
+}
+
+type xraySettings struct {
+	Vnext   []json.RawMessage `json:"vnext"`
+	Servers []json.RawMessage `json:"servers"`
+}
+
+type xrayWireGuard struct {
+	SecretKey      string          `json:"secretKey"`
+	Address        []string        `json:"address"`
+	Peers          []xrayPeer      `json:"peers"`
+	MTU            uint32          `json:"mtu"`
+	Reserved       []uint8         `json:"reserved"`
+	Workers        int             `json:"workers"`
+	DomainStrategy string          `json:"domainStrategy"`
+	NoKernelTun    bool            `json:"noKernelTun"`
+	KernelMode     json.RawMessage `json:"kernelMode"`
+}
+
+type xrayPeer struct {
+	PublicKey    string   `json:"publicKey"`
+	PreSharedKey string   `json:"preSharedKey"`
+	Endpoint     string   `json:"endpoint"`
+	KeepAlive    uint16   `json:"keepAlive"`
+	AllowedIPs   []string `json:"allowedIPs"`
+}
+
+type xrayProxySettings struct {
+	Tag            string `json:"tag"`
+	TransportLayer bool   `json:"transportLayer"`
+}
+
+type xrayMux struct {
+	Enabled         bool   `json:"enabled"`
+	Concurrency     int    `json:"concurrency"`
+	XUDPConcurrency int    `json:"xudpConcurrency"`
+	XUDPProxyUDP443 string `json:"xudpProxyUDP443"`
+}
+
+type converter struct {
+	result *Result
+	// prefix of warnings, which is the tag of current outbound.
+	prefix string
+}
+
+// Convert converts outbounds of Xray or V2Ray JSON configuration.
+// content could be a full configuration, an array of outbounds or a single outbound.
+// Outbounds that can't be converted are skipped, and lossy conversion is reported in Result.Warnings.
+func Convert(content []byte) (*Result, error) {
+	content, err := readAllComment(content)
+	if err != nil {
+		return nil, err
+	}
+	var outbounds []json.RawMessage
+	switch content = bytes.TrimSpace(content); {
+	case len(content) == 0:
+		return nil, E.New("empty configuration")
+	case content[0] == '[':
+		err = json.Unmarshal(content, &outbounds)
+	default:
+		var config struct {
+			Outbounds []json.RawMessage `json:"outbounds"`
+			Protocol  string            `json:"protocol"`
+		}
+		err = json.Unmarshal(content, &config)
+		if config.Protocol != "" {
+			outbounds = []json.RawMessage{content}
+		} else {
+			outbounds = config.Outbounds
+		}
+	}
+	if err != nil {
+		return nil, E.Cause(err, "decode xray configuration")
+	}
+	c := &converter{result: new(Result)}
+	for i, raw := range outbounds {
+		var outbound xrayOutbound
+		c.prefix = "outbound " + strconv.Itoa(i)
+		err = c.decode("", raw, &outbound)
+		if err != nil {
+			c.warn(err)
+			continue
+		}
+		if outbound.Tag != "" {
+			c.prefix = "outbound " + outbound.Tag
+		}
+		err = c.convertOutbound(&outbound)
+		if err != nil {
+			c.warn(err)
+		}
+	}
+	if len(c.result.Outbounds) == 0 && len(c.result.Endpoints) == 0 {
+		return nil, E.New("no outbound converted: ", strings.Join(c.result.Warnings, "; "))
+	}
+	return c.result, nil
+}
+
+// IsConfig reports whether content looks like Xray or V2Ray configuration.
+func IsConfig(content []byte) bool {
+	var probe struct {
+		Outbounds []struct {
+			Protocol string `json:"protocol"`
+		} `json:"outbounds"`
+	}
+	if json.Unmarshal(content, &probe) != nil {
+		return false
+	}
+	for _, outbound := range probe.Outbounds {
+		if outbound.Protocol != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func readAllComment(content []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	_, err := buffer.ReadFrom(json.NewCommentFilter(bytes.NewReader(content)))
+	if err != nil {
+		return nil, E.Cause(err, "filter comments")
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c *converter) warn(message ...any) {
+	c.result.Warnings = append(c.result.Warnings, E.New(append([]any{c.prefix, ": "}, message...)...).Error())
+}
+
+// decode decodes raw to each of values and reports keys that none of them knows.
+// Keys are matched case-insensitively like encoding/json.
+func (c *converter) decode(path string, raw json.RawMessage, values ...any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, v := range values {
+		err := json.Unmarshal(raw, v)
+		if err != nil {
+			return E.Cause(err, "decode ", path)
+		}
+		maps.Copy(known, jsonFields(reflect.TypeOf(v)))
+	}
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(raw, &keys) != nil {
+		return nil
+	}
+	var unknown []string
+	for key, value := range keys {
+		if !known[strings.ToLower(key)] && !isEmptyJSON(value) {
+			unknown = append(unknown, path+key)
+		}
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		c.warn("unsupported field: ", key)
+	}
+	return nil
+}
+
+func jsonFields(t reflect.Type) map[string]bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[strings.ToLower(name)] = true
+		}
+	}
+	return fields
+}
+
+func isEmptyJSON(value json.RawMessage) bool {
+	switch string(bytes.TrimSpace(value)) {
+	case "null", "false", `""`, "0", "[]", "{}":
+		return true
+	}
+	return false
+}
+
+func (c *converter) convertOutbound(outbound *xrayOutbound) error {
+	tag := outbound.Tag
+	if tag == "" {
+		tag = outbound.Protocol
+	}
+	switch outbound.Protocol {
+	case "freedom":
+		options := &option.DirectOutboundOptions{}
+		var settings struct {
+			DomainStrategy string `json:"domainStrategy"`
+		}
+		err := c.decode("settings.", outbound.Settings, &settings)
+		if err != nil {
+			return err
+		}
+		c.domainStrategy("settings.", settings.DomainStrategy)
+		err = c.dialer(outbound, &options.DialerOptions)
+		if err != nil {
+			return err
+		}
+		c.result.Outbounds = append(c.result.Outbounds, option.Outbound{Type: C.TypeDirect, Tag: tag, Options: options})
+		return nil
+	case "blackhole":
+		c.result.Outbounds = append(c.result.Outbounds, option.Outbound{Type: C.TypeBlock, Tag: tag, Options: &option.StubOptions{}})
+		return nil
+	case "wireguard":
+		endpoint, err := c.wireGuard(tag, outbound)
+		if err != nil {
+			return err
+		}
+		c.result.Endpoints = append(c.result.Endpoints, endpoint)
+		return nil
+	}
+
+	server, user, err := c.server(outbound.Settings)
+	if err != nil {
+		return err
+	}
+	serverOptions := option.ServerOptions{
+		Server:     server.Address,
+		ServerPort: server.Port,
+	}
+	stream, err := c.stream(outbound.StreamSettings)
+	if err != nil {
+		return err
+	}
+	var mux xrayMux
+	err = c.decode("mux.", outbound.Mux, &mux)
+	if err != nil {
+		return err
+	}
+	// Mux.Cool of Xray is not compatible with sing-mux, but XUDP is.
+	if mux.Enabled && mux.Concurrency >= 0 {
+		c.warn("mux.cool is not supported, TCP connections won't be multiplexed")
+	}
+	var packetEncoding *string
+	if mux.Enabled && mux.XUDPConcurrency > 0 {
+		xudp := "xudp"
+		packetEncoding = &xudp
+	}
+
+	result := option.Outbound{Type: outbound.Protocol, Tag: tag}
+	switch outbound.Protocol {
+	case C.TypeVLESS:
+		options := &pluginoption.VLESSOutboundOptions{
+			VLESSOutboundOptions: option.VLESSOutboundOptions{
+				ServerOptions:  serverOptions,
+				UUID:           user.ID,
+				Flow:           user.Flow,
+				PacketEncoding: packetEncoding,
+			},
+		}
+		if user.Flow == "xtls-rprx-vision-udp443" {
+			c.warn("flow ", user.Flow, " is converted to xtls-rprx-vision")
+			options.Flow = "xtls-rprx-vision"
+		}
+		if user.Encryption != "none" {
+			options.Encryption = user.Encryption
+		}
+		options.Transport, options.TLS = stream.transport, stream.tls
+		result.Options = options
+		err = c.dialer(outbound, &options.DialerOptions)
+	case C.TypeVMess:
+		options := &option.VMessOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          user.ID,
+			Security:      user.Security,
+			AlterId:       user.AlterID,
+			Transport:     stream.transport,
+		}
+		options.TLS = stream.tls
+		if packetEncoding != nil {
+			options.PacketEncoding = *packetEncoding
+		}
+		result.Options = options
+		err = c.dialer(outbound, &options.DialerOptions)
+	case C.TypeTrojan:
+		options := &option.TrojanOutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      server.Password,
+			Transport:     stream.transport,
+		}
+		options.TLS = stream.tls
+		result.Options = options
+		err = c.dialer(outbound, &options.DialerOptions)
+	case C.TypeShadowsocks:
+		options := &option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        server.Method,
+			Password:      server.Password,
+		}
+		if server.UoT {
+			options.UDPOverTCP = &option.UDPOverTCPOptions{
+				Enabled: true,
+				Version: uint8(server.UoTVersion),
+			}
+		}
+		c.noStream(outbound.Protocol, stream)
+		result.Options = options
+		err = c.dialer(outbound, &options.DialerOptions)
+	case "socks":
+		result.Type = C.TypeSOCKS
+		options := &option.SOCKSOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      user.User,
+			Password:      user.Pass,
+		}
+		c.noStream(outbound.Protocol, stream)
+		result.Options = options
+		err = c.dialer(outbound, &options.DialerOptions)
+	case C.TypeHTTP:
+		options := &pluginoption.HTTPOutboundOptions{
+			HTTPOutboundOptions: option.HTTPOutboundOptions{
+				ServerOptions: serverOptions,
+				Username:      user.User,
+				Password:      user.Pass,
+			},
+		}
+		if stream.transport != nil {
+			c.warn("transport of http is not supported")
+		}
+		options.TLS = stream.tls
+		result.Options = options
+		err = c.dialer(outbound, &options.DialerOptions)
+	default:
+		return E.New("unsupported protocol: ", outbound.Protocol)
+	}
+	if err != nil {
+		return err
+	}
+	c.result.Outbounds = append(c.result.Outbounds, result)
+	return nil
+}
+
+// server picks the first server and user, as sing-box outbound connects to only one server.
+func (c *converter) server(raw json.RawMessage) (*xrayServer, *xrayUser, error) {
+	var (
+		settings xraySettings
+		server   = new(xrayServer)
+		user     = new(xrayUser)
+	)
+	_ = json.Unmarshal(raw, &settings)
+	servers := settings.Vnext
+	if len(servers) == 0 {
+		servers = settings.Servers
+	}
+	if len(servers) == 0 {
+		// Xray also accepts single server and user without vnext.
+		err := c.decode("settings.", raw, server, user)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		err := c.decode("settings.", raw, &settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(servers) > 1 {
+			c.warn("only the first of ", len(servers), " servers is converted")
+		}
+		err = c.decode("settings.servers.", servers[0], server)
+		if err != nil {
+			return nil, nil, err
+		}
+		var users []json.RawMessage
+		if len(server.Users) > 0 {
+			err = json.Unmarshal(server.Users, &users)
+			if err != nil {
+				return nil, nil, E.Cause(err, "decode users")
+			}
+		}
+		if len(users) > 1 {
+			c.warn("only the first of ", len(users), " users is converted")
+		}
+		if len(users) > 0 {
+			err = c.decode("settings.servers.users.", users[0], user)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if server.Address == "" || server.Port == 0 {
+		return nil, nil, E.New("missing server address or port")
+	}
+	return server, user, nil
+}
+
+func (c *converter) noStream(protocol string, stream *xrayStream) {
+	if stream.transport != nil {
+		c.warn("transport of ", protocol, " is not supported")
+	}
+	if stream.tls != nil {
+		c.warn("tls of ", protocol, " is not supported")
+	}
+}
+
+func (c *converter) dialer(outbound *xrayOutbound, options *option.DialerOptions) error {
+	var proxySettings xrayProxySettings
+	err := c.decode("proxySettings.", outbound.ProxySettings, &proxySettings)
+	if err != nil {
+		return err
+	}
+	if proxySettings.Tag != "" {
+		options.Detour = proxySettings.Tag
+	}
+	switch outbound.SendThrough {
+	case "", "0.0.0.0", "::":
+	default:
+		address, err := netip.ParseAddr(outbound.SendThrough)
+		if err != nil {
+			c.warn("unsupported sendThrough: ", outbound.SendThrough)
+			break
+		}
+		bindAddress := badoption.Addr(address)
+		if address.Is4() {
+			options.Inet4BindAddress = &bindAddress
+		} else {
+			options.Inet6BindAddress = &bindAddress
+		}
+	}
+	c.domainStrategy("", outbound.TargetStrategy)
+	if len(outbound.StreamSettings) == 0 {
+		return nil
+	}
+	var stream struct {
+		Sockopt json.RawMessage `json:"sockopt"`
+	}
+	err = json.Unmarshal(outbound.StreamSettings, &stream)
+	if err != nil {
+		return E.Cause(err, "decode streamSettings")
+	}
+	return c.sockopt(stream.Sockopt, options)
+}
+
+func (c *converter) domainStrategy(path, strategy string) {
+	switch strings.ToLower(strategy) {
+	case "", "asis":
+	default:
+		c.warn("unsupported domain strategy ", path, strategy, ", use domain_resolver instead")
+	}
+}
+
+func (c *converter) wireGuard(tag string, outbound *xrayOutbound) (option.Endpoint, error) {
+	var settings xrayWireGuard
+	err := c.decode("settings.", outbound.Settings, &settings)
+	if err != nil {
+		return option.Endpoint{}, err
+	}
+	c.domainStrategy("settings.", settings.DomainStrategy)
+	options := &option.WireGuardEndpointOptions{
+		PrivateKey: settings.SecretKey,
+		MTU:        settings.MTU,
+		Workers:    settings.Workers,
+	}
+	for _, address := range settings.Address {
+		prefix, err := parsePrefix(address)
+		if err != nil {
+			return option.Endpoint{}, err
+		}
+		options.Address = append(options.Address, prefix)
+	}
+	for _, peer := range settings.Peers {
+		host, port, err := splitHostPort(peer.Endpoint)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse peer endpoint")
+		}
+		wgPeer := option.WireGuardPeer{
+			Address:                     host,
+			Port:                        port,
+			PublicKey:                   peer.PublicKey,
+			PreSharedKey:                peer.PreSharedKey,
+			PersistentKeepaliveInterval: peer.KeepAlive,
+			Reserved:                    settings.Reserved,
+		}
+		if len(peer.AllowedIPs) == 0 {
+			peer.AllowedIPs = []string{"0.0.0.0/0", "::/0"}
+		}
+		for _, allowedIP := range peer.AllowedIPs {
+			prefix, err := parsePrefix(allowedIP)
+			if err != nil {
+				return option.Endpoint{}, err
+			}
+			wgPeer.AllowedIPs = append(wgPeer.AllowedIPs, prefix)
+		}
+		options.Peers = append(options.Peers, wgPeer)
+	}
+	if len(options.Peers) == 0 {
+		return option.Endpoint{}, E.New("missing wireguard peers")
+	}
+	err = c.dialer(outbound, &options.DialerOptions)
+	if err != nil {
+		return option.Endpoint{}, err
+	}
+	return option.Endpoint{Type: C.TypeWireGuard, Tag: tag, Options: options}, nil
+}
@@ -0,0 +1,178 @@
+package raybridge
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"libcore/plugin/pluginoption"
+)
+
+func Test_Convert(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		check    func(t *testing.T, result *Result)
+		warnings int
+	}{
+		{
+			name: "VLESS REALITY",
+			content: `{
+  // Comments are allowed by Xray.
+  "outbounds": [
+    {
+      "protocol": "vless",
+      "tag": "proxy",
+      "settings": {"vnext": [{"address": "example.com", "port": 443, "users": [{"id": "uuid", "encryption": "none", "flow": "xtls-rprx-vision"}]}]},
+      "streamSettings": {
+        "network": "raw",
+        "security": "reality",
+        "realitySettings": {"serverName": "www.example.com", "fingerprint": "chrome", "publicKey": "key", "shortId": "01"},
+        "sockopt": {"mark": 255, "tcpFastOpen": 256, "dialerProxy": "front"}
+      },
+      "mux": {"enabled": true, "concurrency": -1, "xudpConcurrency": 16}
+    },
+    {"protocol": "freedom", "tag": "direct"},
+    {"protocol": "dns", "tag": "dns-out"}
+  ]
+}`,
+			check: func(t *testing.T, result *Result) {
+				if len(result.Outbounds) != 2 {
+					t.Fatalf("expected 2 outbounds, got %d", len(result.Outbounds))
+				}
+				options := result.Outbounds[0].Options.(*pluginoption.VLESSOutboundOptions)
+				if options.Encryption != "" || options.Flow != "xtls-rprx-vision" {
+					t.Errorf("unexpected vless options: %+v", options)
+				}
+				if options.TLS == nil || options.TLS.Reality == nil || options.TLS.Reality.PublicKey != "key" || options.TLS.UTLS == nil {
+					t.Errorf("unexpected reality options: %+v", options.TLS)
+				}
+				if options.PacketEncoding == nil || *options.PacketEncoding != "xudp" {
+					t.Error("expected xudp packet encoding")
+				}
+				if options.RoutingMark != 255 || !options.TCPFastOpen || options.Detour != "front" {
+					t.Errorf("unexpected dialer options: %+v", options.DialerOptions)
+				}
+				if result.Outbounds[1].Type != C.TypeDirect {
+					t.Errorf("expected direct, got %s", result.Outbounds[1].Type)
+				}
+			},
+			// dns is not supported.
+			warnings: 1,
+		},
+		{
+			name: "VMess WebSocket",
+			content: `{
+  "protocol": "vmess",
+  "settings": {"vnext": [{"address": "example.com", "port": 443, "users": [{"id": "uuid", "security": "auto"}, {"id": "uuid2"}]}]},
+  "streamSettings": {
+    "network": "ws",
+    "security": "tls",
+    "tlsSettings": {"serverName": "example.com", "alpn": ["http/1.1"], "fingerprint": "unsafe", "masterKeyLog": "/tmp/key"},
+    "wsSettings": {"path": "/ws?ed=2048", "host": "cdn.example.com", "heartbeatPeriod": 30}
+  },
+  "mux": {"enabled": true, "concurrency": 8}
+}`,
+			check: func(t *testing.T, result *Result) {
+				options := result.Outbounds[0].Options.(*option.VMessOutboundOptions)
+				if result.Outbounds[0].Tag != C.TypeVMess {
+					t.Errorf("expected default tag, got %s", result.Outbounds[0].Tag)
+				}
+				ws := options.Transport.WebsocketOptions
+				if ws.Path != "/ws" || ws.MaxEarlyData != 2048 || ws.EarlyDataHeaderName != earlyDataHeaderName || ws.Headers["Host"][0] != "cdn.example.com" {
+					t.Errorf("unexpected websocket options: %+v", ws)
+				}
+				if options.TLS == nil || options.TLS.ServerName != "example.com" || options.TLS.UTLS != nil {
+					t.Errorf("unexpected tls options: %+v", options.TLS)
+				}
+			},
+			// users, fingerprint, masterKeyLog, heartbeatPeriod, mux.
+			warnings: 5,
+		},
+		{
+			name: "Trojan gRPC",
+			content: `[{
+  "protocol": "trojan",
+  "settings": {"servers": [{"address": "example.com", "port": 443, "password": "password"}]},
+  "streamSettings": {"network": "grpc", "security": "tls", "grpcSettings": {"serviceName": "grpc", "multiMode": true}}
+}, {
+  "protocol": "vless",
+  "settings": {"address": "example.com", "port": 443, "id": "uuid"},
+  "streamSettings": {"network": "xhttp", "xhttpSettings": {"path": "/"}}
+}]`,
+			check: func(t *testing.T, result *Result) {
+				if len(result.Outbounds) != 1 {
+					t.Fatalf("expected 1 outbound, got %d", len(result.Outbounds))
+				}
+				options := result.Outbounds[0].Options.(*option.TrojanOutboundOptions)
+				if options.Transport.Type != C.V2RayTransportTypeGRPC || options.Transport.GRPCOptions.ServiceName != "grpc" {
+					t.Errorf("unexpected transport: %+v", options.Transport)
+				}
+			},
+			// multiMode, xhttp.
+			warnings: 2,
+		},
+		{
+			name: "WireGuard",
+			content: `{"outbounds": [{
+  "protocol": "wireguard",
+  "settings": {
+    "secretKey": "key",
+    "address": ["172.16.0.2/32", "fd00::2"],
+    "peers": [{"publicKey": "peer", "endpoint": "example.com:2408"}],
+    "reserved": [1, 2, 3]
+  }
+}]}`,
+			check: func(t *testing.T, result *Result) {
+				options := result.Endpoints[0].Options.(*option.WireGuardEndpointOptions)
+				if len(options.Address) != 2 || options.Address[1].Bits() != 128 {
+					t.Errorf("unexpected address: %v", options.Address)
+				}
+				peer := options.Peers[0]
+				if peer.Address != "example.com" || peer.Port != 2408 || len(peer.AllowedIPs) != 2 || len(peer.Reserved) != 3 {
+					t.Errorf("unexpected peer: %+v", peer)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Convert([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Warnings) != tt.warnings {
+				t.Errorf("expected %d warnings, got %q", tt.warnings, result.Warnings)
+			}
+			tt.check(t, result)
+		})
+	}
+}
+
+func Test_pinCertificate(t *testing.T) {
+	certificate := []string{
+		"-----BEGIN CERTIFICATE-----",
+		"MIIBfTCCASOgAwIBAgIUJ1n1KjqZt5bCz6bLhn0FhVK0ccowCgYIKoZIzj0EAwIw",
+		"-----END CERTIFICATE-----",
+	}
+	hash := string(CalculatePEMCertHash([]byte("-----BEGIN CERTIFICATE-----\nMIIBfTCCASOgAwIBAgIUJ1n1KjqZt5bCz6bLhn0FhVK0ccowCgYIKoZIzj0EAwIw\n-----END CERTIFICATE-----")))
+	tests := []struct {
+		name     string
+		pinned   []string
+		warnings int
+	}{
+		{name: "match", pinned: []string{hash}},
+		{name: "mismatch", pinned: []string{"AAAA"}, warnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &converter{result: new(Result)}
+			options := &option.OutboundTLSOptions{Certificate: certificate}
+			c.pinCertificate(&xrayTLS{PinnedPeerCertificateChainSha256: tt.pinned}, options)
+			if len(c.result.Warnings) != tt.warnings {
+				t.Errorf("expected %d warnings, got %q", tt.warnings, c.result.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package trusttunnel
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Minimal IP and ICMP echo handling, so that ICMP works without gVisor.
+
+const (
+	ipv4MinimumSize = 20
+	ipv6HeaderSize  = 40
+	icmpHeaderSize  = 8
+
+	protocolICMPv4 = 1
+	protocolICMPv6 = 58
+
+	icmpv4EchoReply   = 0
+	icmpv4Echo        = 8
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+
+	replyHopLimit = 64
+)
+
+type echoRequest struct {
+	isIPv6   bool
+	ident    uint16
+	sequence uint16
+	hopLimit uint8
+	payload  []byte
+}
+
+// parseEchoRequest parses ICMP echo request in IP packet.
+func parseEchoRequest(data []byte) (request echoRequest, err error) {
+	if len(data) == 0 {
+		return echoRequest{}, E.New("empty packet")
+	}
+	var icmpData []byte
+	switch ipVersion := data[0] >> 4; ipVersion {
+	case 4:
+		headerLength := int(data[0]&0x0f) * 4
+		if len(data) < ipv4MinimumSize || headerLength < ipv4MinimumSize {
+			return echoRequest{}, E.New("invalid IPv4 header")
+		}
+		totalLength := int(binary.BigEndian.Uint16(data[2:]))
+		if totalLength < headerLength || totalLength > len(data) {
+			return echoRequest{}, E.New("invalid IPv4 header")
+		}
+		if data[9] != protocolICMPv4 {
+			return echoRequest{}, E.New("invalid ICMPv4 protocol")
+		}
+		request.hopLimit = data[8]
+		icmpData = data[headerLength:totalLength]
+		if len(icmpData) < icmpHeaderSize {
+			return echoRequest{}, E.New("invalid ICMPv4 header")
+		}
+		if icmpData[0] != icmpv4Echo {
+			return echoRequest{}, E.New("unsupported ICMPv4 type: ", icmpData[0])
+		}
+	case 6:
+		if len(data) < ipv6HeaderSize {
+			return echoRequest{}, E.New("invalid IPv6 header")
+		}
+		payloadLength := int(binary.BigEndian.Uint16(data[4:]))
+		if ipv6HeaderSize+payloadLength > len(data) {
+			return echoRequest{}, E.New("invalid IPv6 header")
+		}
+		if data[6] != protocolICMPv6 {
+			return echoRequest{}, E.New("invalid ICMPv6 protocol")
+		}
+		request.isIPv6 = true
+		request.hopLimit = data[7]
+		icmpData = data[ipv6HeaderSize : ipv6HeaderSize+payloadLength]
+		if len(icmpData) < icmpHeaderSize {
+			return echoRequest{}, E.New("invalid ICMPv6 header")
+		}
+		if icmpData[0] != icmpv6EchoRequest {
+			return echoRequest{}, E.New("unsupported ICMPv6 type: ", icmpData[0])
+		}
+	default:
+		return echoRequest{}, E.New("invalid IP version ", ipVersion)
+	}
+	request.ident = binary.BigEndian.Uint16(icmpData[4:])
+	request.sequence = binary.BigEndian.Uint16(icmpData[6:])
+	request.payload = icmpData[icmpHeaderSize:]
+	return request, nil
+}
+
+// buildEchoReply builds IP packet of ICMP echo reply from source to destination.
+func buildEchoReply(source, destination netip.Addr, icmpType, code uint8, ident, sequence uint16, payload []byte) *buf.Buffer {
+	icmpLength := icmpHeaderSize + len(payload)
+	var (
+		packet   *buf.Buffer
+		icmpData []byte
+	)
+	switch {
+	case source.Is4() && destination.Is4():
+		totalLength := ipv4MinimumSize + icmpLength
+		packet = buf.NewSize(totalLength)
+		data := packet.Extend(totalLength)
+		// Buffers are pooled and not zeroed.
+		clear(data)
+		data[0] = 4<<4 | ipv4MinimumSize/4
+		binary.BigEndian.PutUint16(data[2:], uint16(totalLength))
+		data[8] = replyHopLimit
+		data[9] = protocolICMPv4
+		sourceBytes, destinationBytes := source.As4(), destination.As4()
+		copy(data[12:], sourceBytes[:])
+		copy(data[16:], destinationBytes[:])
+		binary.BigEndian.PutUint16(data[10:], checksum(0, data[:ipv4MinimumSize]))
+		icmpData = data[ipv4MinimumSize:]
+	case source.Is6() && destination.Is6():
+		totalLength := ipv6HeaderSize + icmpLength
+		packet = buf.NewSize(totalLength)
+		data := packet.Extend(totalLength)
+		clear(data)
+		data[0] = 6 << 4
+		binary.BigEndian.PutUint16(data[4:], uint16(icmpLength))
+		data[6] = protocolICMPv6
+		data[7] = replyHopLimit
+		sourceBytes, destinationBytes := source.As16(), destination.As16()
+		copy(data[8:], sourceBytes[:])
+		copy(data[24:], destinationBytes[:])
+		icmpData = data[ipv6HeaderSize:]
+	default:
+		return nil
+	}
+	icmpData[0] = icmpType
+	icmpData[1] = code
+	binary.BigEndian.PutUint16(icmpData[4:], ident)
+	binary.BigEndian.PutUint16(icmpData[6:], sequence)
+	copy(icmpData[icmpHeaderSize:], payload)
+	var initial uint32
+	if source.Is6() {
+		initial = pseudoHeaderSum(source, destination, icmpLength)
+	}
+	binary.BigEndian.PutUint16(icmpData[2:], checksum(initial, icmpData))
+	return packet
+}
+
+// pseudoHeaderSum sums IPv6 pseudo header for upper layer checksum.
+func pseudoHeaderSum(source, destination netip.Addr, length int) uint32 {
+	var pseudoHeader [40]byte
+	sourceBytes, destinationBytes := source.As16(), destination.As16()
+	copy(pseudoHeader[:], sourceBytes[:])
+	copy(pseudoHeader[16:], destinationBytes[:])
+	binary.BigEndian.PutUint32(pseudoHeader[32:], uint32(length))
+	pseudoHeader[39] = protocolICMPv6
+	return sum(0, pseudoHeader[:])
+}
+
+// checksum returns the internet checksum of data.
+// https://www.rfc-editor.org/rfc/rfc1071
+func checksum(initial uint32, data []byte) uint16 {
+	total := sum(initial, data)
+	for total>>16 != 0 {
+		total = total&0xffff + total>>16
+	}
+	return ^uint16(total)
+}
+
+func sum(initial uint32, data []byte) uint32 {
+	total := initial
+	for len(data) >= 2 {
+		total += uint32(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		total += uint32(data[0]) << 8
+	}
+	return total
+}
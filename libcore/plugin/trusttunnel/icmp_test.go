@@ -0,0 +1,51 @@
+package trusttunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+)
+
+func Test_EchoPacket(t *testing.T) {
+	payload := []byte("abcdefg")
+	for _, tt := range []struct {
+		source, destination netip.Addr
+		requestType         uint8
+		replyType           uint8
+	}{
+		{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("1.1.1.1"), icmpv4Echo, icmpv4EchoReply},
+		{netip.MustParseAddr("fd00::1"), netip.MustParseAddr("2606:4700::1111"), icmpv6EchoRequest, icmpv6EchoReply},
+	} {
+		request := buildEchoReply(tt.source, tt.destination, tt.requestType, 0, 0x1234, 7, payload)
+		parsed, err := parseEchoRequest(request.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.isIPv6 != tt.source.Is6() || parsed.ident != 0x1234 || parsed.sequence != 7 || parsed.hopLimit != replyHopLimit || !bytes.Equal(parsed.payload, payload) {
+			t.Errorf("unexpected request: %+v", parsed)
+		}
+		request.Release()
+
+		reply := buildEchoReply(tt.destination, tt.source, tt.replyType, 0, 0x1234, 7, payload)
+		data := reply.Bytes()
+		var icmpData []byte
+		var initial uint32
+		if tt.source.Is4() {
+			if checksum(0, data[:ipv4MinimumSize]) != 0 {
+				t.Error("invalid IPv4 header checksum")
+			}
+			icmpData = data[ipv4MinimumSize:]
+		} else {
+			icmpData = data[ipv6HeaderSize:]
+			initial = pseudoHeaderSum(tt.destination, tt.source, len(icmpData))
+		}
+		if checksum(initial, icmpData) != 0 {
+			t.Errorf("invalid ICMP checksum %x", binary.BigEndian.Uint16(icmpData[2:]))
+		}
+		if _, err = parseEchoRequest(data); err == nil {
+			t.Error("reply should not be parsed as request")
+		}
+		reply.Release()
+	}
+}
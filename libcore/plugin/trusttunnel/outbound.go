@@ -3,6 +3,8 @@ package trusttunnel
 import (
 	"context"
 	"net"
+	"sync/atomic"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/outbound"
@@ -30,11 +32,23 @@ func RegisterOutbound(registry *outbound.Registry) {
 	outbound.Register[pluginoption.TrustTunnelOutboundOptions](registry, pluginoption.TypeTrustTunnel, NewOutbound)
 }
 
+const healthCheckInterval = 30 * time.Second
+
+var (
+	_ adapter.Outbound                = (*Outbound)(nil)
+	_ adapter.InterfaceUpdateListener = (*Outbound)(nil)
+)
+
 type Outbound struct {
 	outbound.Adapter
-	ctx    context.Context
-	logger log.ContextLogger
-	client *trusttunnel.Client
+	ctx         context.Context
+	cancel      context.CancelFunc
+	logger      log.ContextLogger
+	client      *trusttunnel.Client
+	healthCheck bool
+	checked     atomic.Bool
+	healthy     atomic.Bool
+	reconnects  atomic.Int64
 }
 
 func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options pluginoption.TrustTunnelOutboundOptions) (adapter.Outbound, error) {
@@ -64,23 +78,69 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		TLSConfig:             tlsConfig,
 		QUIC:                  options.QUIC,
 		QUICCongestionControl: options.QUICCongestionControl,
-		HealthCheck:           options.HealthCheck,
+		// Health check is driven by outbound to record the state.
 	})
 	if err != nil {
 		return nil, err
 	}
-	networks := []string{N.NetworkTCP, N.NetworkUDP}
-	if withGvisor {
-		networks = append(networks, N.NetworkICMP)
-	}
+	ctx, cancel := context.WithCancel(ctx)
 	return &Outbound{
-		Adapter: outbound.NewAdapterWithDialerOptions(pluginoption.TypeTrustTunnel, tag, networks, options.DialerOptions),
-		ctx:     ctx,
-		logger:  logger,
-		client:  client,
+		Adapter:     outbound.NewAdapterWithDialerOptions(pluginoption.TypeTrustTunnel, tag, []string{N.NetworkTCP, N.NetworkUDP, N.NetworkICMP}, options.DialerOptions),
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      logger,
+		client:      client,
+		healthCheck: options.HealthCheck,
 	}, nil
 }
 
+func (h *Outbound) Start(stage adapter.StartStage) error {
+	if stage == adapter.StartStateStart && h.healthCheck {
+		go h.loopHealthCheck()
+	}
+	return nil
+}
+
+// loopHealthCheck checks the session periodically, and reconnects if it is broken.
+func (h *Outbound) loopHealthCheck() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.ctx.Done():
+			return
+		}
+		ctx, cancel := context.WithTimeout(h.ctx, trusttunnel.DefaultHealthCheckTimeout)
+		err := h.client.HealthCheck(ctx)
+		cancel()
+		h.checked.Store(true)
+		h.healthy.Store(err == nil)
+		if err != nil {
+			if h.ctx.Err() != nil {
+				return
+			}
+			h.logger.WarnContext(h.ctx, "health check failed, reconnecting: ", err)
+			h.reset()
+		}
+	}
+}
+
+// Health returns the result of the last health check. checked is false before the first check.
+func (h *Outbound) Health() (healthy bool, checked bool) {
+	return h.healthy.Load(), h.checked.Load()
+}
+
+// Reconnects returns count of connections reset by failed health checks and network changes.
+func (h *Outbound) Reconnects() int64 {
+	return h.reconnects.Load()
+}
+
+func (h *Outbound) reset() {
+	h.reconnects.Add(1)
+	h.client.ResetConnections()
+}
+
 func (h *Outbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	switch network {
 	case N.NetworkTCP:
@@ -109,10 +169,11 @@ func (h *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (n
 }
 
 func (h *Outbound) InterfaceUpdated() {
-	h.client.ResetConnections()
+	h.reset()
 }
 
 func (h *Outbound) Close() error {
+	h.cancel()
 	return common.Close(
 		common.PtrOrNil(h.client),
 	)
@@ -0,0 +1,158 @@
+package trusttunnel
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/xchacha20-poly1305/sing-trusttunnel"
+)
+
+var _ adapter.DirectRouteOutbound = (*Outbound)(nil)
+
+func (h *Outbound) NewDirectRouteConnection(metadata adapter.InboundContext, routeContext tun.DirectRouteContext, timeout time.Duration) (tun.DirectRouteDestination, error) {
+	ctx := log.ContextWithNewID(h.ctx)
+	icmpConn, err := h.client.ListenICMP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pinger := &pingAdapter{
+		ctx:          ctx,
+		logger:       h.logger,
+		routeContext: routeContext,
+		source:       metadata.Source.Addr,
+		destination:  metadata.Destination.Addr,
+		timeout:      timeout,
+		requests:     make(map[pingRequest]pingRequestData),
+		IcmpConn:     icmpConn,
+	}
+	go pinger.loopRead()
+	h.logger.InfoContext(ctx, "linked ", metadata.Network, " connection from ", metadata.Source.AddrString(), " to ", metadata.Destination.AddrString())
+	return pinger, nil
+}
+
+var _ tun.DirectRouteDestination = (*pingAdapter)(nil)
+
+type pingAdapter struct {
+	isClosed      atomic.Bool
+	ctx           context.Context
+	logger        log.ContextLogger
+	routeContext  tun.DirectRouteContext
+	source        netip.Addr
+	destination   netip.Addr
+	timeout       time.Duration
+	requestAccess sync.Mutex
+	requests      map[pingRequest]pingRequestData
+	*trusttunnel.IcmpConn
+}
+
+func (p *pingAdapter) WritePacket(packet *buf.Buffer) error {
+	request, err := parseEchoRequest(packet.Bytes())
+	if err != nil {
+		return err
+	}
+	payload := bytes.Clone(request.payload)
+	p.registerRequest(request.isIPv6, request.ident, request.sequence, payload)
+	return p.IcmpConn.WritePing(request.ident, p.destination, request.sequence, request.hopLimit, uint16(len(payload)))
+}
+
+func (p *pingAdapter) Close() error {
+	p.isClosed.Store(true)
+	return p.IcmpConn.Close()
+}
+
+func (p *pingAdapter) IsClosed() bool {
+	return p.isClosed.Load()
+}
+
+type pingRequest struct {
+	id     uint16
+	seq    uint16
+	isIPv6 bool
+}
+
+type pingRequestData struct {
+	payload []byte
+	created time.Time
+}
+
+func (p *pingAdapter) registerRequest(isIPv6 bool, id uint16, seq uint16, payload []byte) {
+	const requestsLimit = 1024
+	now := time.Now()
+	key := pingRequest{id: id, seq: seq, isIPv6: isIPv6}
+	p.requestAccess.Lock()
+	defer p.requestAccess.Unlock()
+	var (
+		oldestKey  pingRequest
+		oldestTime = now
+	)
+	for request, data := range p.requests {
+		if now.Sub(data.created) > p.timeout {
+			delete(p.requests, request)
+			continue
+		}
+		if data.created.Before(oldestTime) {
+			oldestKey = request
+			oldestTime = data.created
+		}
+	}
+	if len(p.requests) >= requestsLimit {
+		delete(p.requests, oldestKey)
+	}
+	p.requests[key] = pingRequestData{payload: payload, created: now}
+}
+
+func (p *pingAdapter) popRequest(isIPv6 bool, id uint16, seq uint16) ([]byte, bool) {
+	key := pingRequest{id: id, seq: seq, isIPv6: isIPv6}
+	p.requestAccess.Lock()
+	defer p.requestAccess.Unlock()
+	data, loaded := p.requests[key]
+	if loaded {
+		delete(p.requests, key)
+		return data.payload, true
+	}
+	return nil, false
+}
+
+func (p *pingAdapter) loopRead() {
+	defer p.Close()
+	for {
+		id, source, icmpType, code, sequence, err := p.ReadPing()
+		if err != nil {
+			if p.IsClosed() || E.IsClosed(err) {
+				return
+			}
+			p.logger.ErrorContext(p.ctx, E.Cause(err, "receive ICMP echo reply"))
+			return
+		}
+		if !source.IsValid() || !p.source.IsValid() {
+			continue
+		}
+		isIPv6 := source.Is6()
+		if isIPv6 && icmpType != icmpv6EchoReply || !isIPv6 && icmpType != icmpv4EchoReply {
+			continue
+		}
+		payload, loaded := p.popRequest(isIPv6, id, sequence)
+		if !loaded {
+			continue
+		}
+		reply := buildEchoReply(source, p.source, icmpType, code, id, sequence, payload)
+		if reply == nil {
+			continue
+		}
+		err = p.routeContext.WritePacket(reply.Bytes())
+		reply.Release()
+		if err != nil {
+			p.logger.ErrorContext(p.ctx, E.Cause(err, "write ICMP echo reply"))
+		}
+	}
+}
@@ -1,5 +0,0 @@
-//go:build !with_gvisor
-
-package trusttunnel
-
-const withGvisor = false
@@ -0,0 +1,69 @@
+// Package tun implements wrapped tun inbound that filters UIDs in userspace.
+package tun
+
+import (
+	"context"
+
+	"libcore/plugin/pluginoption"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/inbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/protocol/direct"
+	"github.com/sagernet/sing-box/protocol/tun"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+func RegisterInbound(registry *inbound.Registry) {
+	inbound.Register[option.TunInboundOptions](registry, C.TypeTun, NewInbound)
+}
+
+// NewInbound creates tun inbound. UID options are not passed to the tun device,
+// which the platform can not apply, but matched for each connection instead.
+// Connections from filtered out UIDs are sent to direct,
+// except DNS which only works in the tunnel, see uidRouter.
+func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TunInboundOptions) (adapter.Inbound, error) {
+	filter, err := newUIDFilter(options)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return tun.NewInbound(ctx, router, logger, tag, options)
+	}
+	options.IncludeUID = nil
+	options.IncludeUIDRange = nil
+	options.ExcludeUID = nil
+	options.ExcludeUIDRange = nil
+	options.IncludeAndroidUser = nil
+	bypass, err := direct.NewOutbound(ctx, router, logger, tag+"-bypass", option.DirectOutboundOptions{})
+	if err != nil {
+		return nil, E.Cause(err, "create bypass outbound")
+	}
+	return tun.NewInbound(ctx, &uidRouter{
+		Router:            router,
+		logger:            logger,
+		connection:        service.FromContext[adapter.ConnectionManager](ctx),
+		platformInterface: service.FromContext[adapter.PlatformInterface](ctx),
+		bypass:            bypass,
+		filter:            filter,
+		tunAddress:        options.Address,
+		hijackDNS:         hasHijackDNS(pluginoption.OptionsFromContext(ctx)),
+	}, logger, tag, options)
+}
+
+// hasHijackDNS reports whether any route rule hijacks DNS.
+func hasHijackDNS(options *option.Options) bool {
+	if options == nil || options.Route == nil {
+		return false
+	}
+	return common.Any(options.Route.Rules, func(it option.Rule) bool {
+		if it.Type == C.RuleTypeLogical {
+			return it.LogicalOptions.Action == C.RuleActionTypeHijackDNS
+		}
+		return it.DefaultOptions.Action == C.RuleActionTypeHijackDNS
+	})
+}
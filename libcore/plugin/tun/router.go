@@ -0,0 +1,117 @@
+package tun
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"libcore/procfs"
+)
+
+// uidRouter sends connections from filtered out UIDs to bypass outbound,
+// and the others to the wrapped router.
+//
+// DNS to the tun address only exists in the tunnel, and DNS may be hijacked by route rules,
+// so they are always routed as the platform VPN builder never sees them either.
+type uidRouter struct {
+	adapter.Router
+	logger            log.ContextLogger
+	connection        adapter.ConnectionManager
+	platformInterface adapter.PlatformInterface
+	bypass            adapter.Outbound
+	filter            *uidFilter
+	tunAddress        []netip.Prefix
+	hijackDNS         bool
+}
+
+func (r *uidRouter) PreMatch(metadata adapter.InboundContext, routeContext tun.DirectRouteContext, timeout time.Duration, supportBypass bool) (tun.DirectRouteDestination, error) {
+	// Owner of ICMP is unknown, so ICMP is always routed.
+	if metadata.Network != N.NetworkICMP && !r.shouldRoute(context.Background(), metadata.Network, metadata) {
+		return nil, nil
+	}
+	return r.Router.PreMatch(metadata, routeContext, timeout, supportBypass)
+}
+
+func (r *uidRouter) RouteConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	if !r.shouldRoute(ctx, N.NetworkTCP, metadata) {
+		r.logger.InfoContext(ctx, "bypass connection to ", metadata.Destination)
+		r.connection.NewConnection(ctx, r.bypass, conn, metadata, onClose)
+		return
+	}
+	r.Router.RouteConnectionEx(ctx, conn, metadata, onClose)
+}
+
+func (r *uidRouter) RoutePacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	if !r.shouldRoute(ctx, N.NetworkUDP, metadata) {
+		r.logger.InfoContext(ctx, "bypass packet connection to ", metadata.Destination)
+		r.connection.NewPacketConnection(ctx, r.bypass, conn, metadata, onClose)
+		return
+	}
+	r.Router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
+}
+
+// shouldRoute reports whether connection should be routed.
+// Connections with unknown owner are routed.
+func (r *uidRouter) shouldRoute(ctx context.Context, network string, metadata adapter.InboundContext) bool {
+	if r.isDNS(metadata.Destination) {
+		return true
+	}
+	uid, err := r.findUID(network, metadata)
+	if err != nil {
+		r.logger.DebugContext(ctx, E.Cause(err, "find connection owner"))
+		return true
+	}
+	return r.filter.match(uid)
+}
+
+func (r *uidRouter) isDNS(destination M.Socksaddr) bool {
+	if destination.Port != 53 {
+		return false
+	}
+	if r.hijackDNS {
+		return true
+	}
+	return common.Any(r.tunAddress, func(it netip.Prefix) bool {
+		return it.Contains(destination.Addr)
+	})
+}
+
+func (r *uidRouter) findUID(network string, metadata adapter.InboundContext) (uint32, error) {
+	if r.platformInterface != nil && r.platformInterface.UsePlatformConnectionOwnerFinder() {
+		var ipProtocol int32
+		switch network {
+		case N.NetworkTCP:
+			ipProtocol = syscall.IPPROTO_TCP
+		case N.NetworkUDP:
+			ipProtocol = syscall.IPPROTO_UDP
+		default:
+			return 0, E.New("unknown network: ", network)
+		}
+		owner, err := r.platformInterface.FindConnectionOwner(&adapter.FindConnectionOwnerRequest{
+			IpProtocol:         ipProtocol,
+			SourceAddress:      metadata.Source.Addr.String(),
+			SourcePort:         int32(metadata.Source.Port),
+			DestinationAddress: metadata.Destination.Addr.String(),
+			DestinationPort:    int32(metadata.Destination.Port),
+		})
+		if err != nil {
+			return 0, err
+		}
+		return uint32(owner.UserId), nil
+	}
+	uid := procfs.ResolveSocketByProcSearch(network, metadata.Source.AddrPort(), metadata.Destination.AddrPort())
+	if uid == -1 {
+		return 0, E.New("procfs: not found")
+	}
+	return uint32(uid), nil
+}
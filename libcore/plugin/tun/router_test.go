@@ -0,0 +1,86 @@
+package tun
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type testRouter struct {
+	adapter.Router
+	routed bool
+}
+
+func (r *testRouter) RoutePacketConnectionEx(_ context.Context, _ N.PacketConn, _ adapter.InboundContext, _ N.CloseHandlerFunc) {
+	r.routed = true
+}
+
+type testConnectionManager struct {
+	adapter.ConnectionManager
+	bypassed bool
+}
+
+func (m *testConnectionManager) NewPacketConnection(_ context.Context, _ N.Dialer, _ N.PacketConn, _ adapter.InboundContext, _ N.CloseHandlerFunc) {
+	m.bypassed = true
+}
+
+type testPlatformInterface struct {
+	adapter.PlatformInterface
+	uid int32
+}
+
+func (p *testPlatformInterface) UsePlatformConnectionOwnerFinder() bool {
+	return true
+}
+
+func (p *testPlatformInterface) FindConnectionOwner(_ *adapter.FindConnectionOwnerRequest) (*adapter.ConnectionOwner, error) {
+	return &adapter.ConnectionOwner{UserId: p.uid}, nil
+}
+
+func Test_UIDRouterDNS(t *testing.T) {
+	filter, err := newUIDFilter(option.TunInboundOptions{ExcludeUID: []uint32{10001}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tunAddress := []netip.Prefix{netip.MustParsePrefix("172.19.0.1/30")}
+	for _, tt := range []struct {
+		name        string
+		destination string
+		uid         int32
+		hijackDNS   bool
+		wantRouted  bool
+	}{
+		{"excluded DNS to tun address", "172.19.0.2:53", 10001, false, true},
+		{"excluded DNS with hijack-dns", "8.8.8.8:53", 10001, true, true},
+		{"excluded DNS without hijack-dns", "8.8.8.8:53", 10001, false, false},
+		{"excluded other", "172.19.0.2:443", 10001, true, false},
+		{"included", "1.1.1.1:443", 10002, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			router := &testRouter{}
+			connection := &testConnectionManager{}
+			uidRouter := &uidRouter{
+				Router:            router,
+				logger:            log.NewNOPFactory().NewLogger("tun"),
+				connection:        connection,
+				platformInterface: &testPlatformInterface{uid: tt.uid},
+				filter:            filter,
+				tunAddress:        tunAddress,
+				hijackDNS:         tt.hijackDNS,
+			}
+			uidRouter.RoutePacketConnectionEx(context.Background(), nil, adapter.InboundContext{
+				Source:      M.ParseSocksaddr("172.19.0.1:40000"),
+				Destination: M.ParseSocksaddr(tt.destination),
+			}, nil)
+			if router.routed != tt.wantRouted || connection.bypassed == tt.wantRouted {
+				t.Errorf("routed = %v, bypassed = %v, want routed %v", router.routed, connection.bypassed, tt.wantRouted)
+			}
+		})
+	}
+}
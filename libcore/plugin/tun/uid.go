@@ -0,0 +1,103 @@
+package tun
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// androidPerUserRange is the UID count of each Android user.
+const androidPerUserRange = 100000
+
+type uidRange struct {
+	start uint32
+	end   uint32
+}
+
+func (r uidRange) contains(uid uint32) bool {
+	return uid >= r.start && uid <= r.end
+}
+
+type uidFilter struct {
+	include     []uidRange
+	exclude     []uidRange
+	androidUser []int
+}
+
+// newUIDFilter returns nil if no UID options set.
+func newUIDFilter(options option.TunInboundOptions) (*uidFilter, error) {
+	filter := &uidFilter{
+		include:     uidToRange(options.IncludeUID),
+		exclude:     uidToRange(options.ExcludeUID),
+		androidUser: options.IncludeAndroidUser,
+	}
+	var err error
+	filter.include, err = parseRange(filter.include, options.IncludeUIDRange)
+	if err != nil {
+		return nil, E.Cause(err, "parse include_uid_range")
+	}
+	filter.exclude, err = parseRange(filter.exclude, options.ExcludeUIDRange)
+	if err != nil {
+		return nil, E.Cause(err, "parse exclude_uid_range")
+	}
+	if len(filter.include) == 0 && len(filter.exclude) == 0 && len(filter.androidUser) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// match reports whether connection from uid should be routed.
+func (f *uidFilter) match(uid uint32) bool {
+	for _, it := range f.exclude {
+		if it.contains(uid) {
+			return false
+		}
+	}
+	if len(f.include) == 0 && len(f.androidUser) == 0 {
+		return true
+	}
+	for _, it := range f.include {
+		if it.contains(uid) {
+			return true
+		}
+	}
+	for _, user := range f.androidUser {
+		if int(uid/androidPerUserRange) == user {
+			return true
+		}
+	}
+	return false
+}
+
+func uidToRange(uidList []uint32) []uidRange {
+	ranges := make([]uidRange, 0, len(uidList))
+	for _, uid := range uidList {
+		ranges = append(ranges, uidRange{uid, uid})
+	}
+	return ranges
+}
+
+// parseRange parses ranges in format of "start:end".
+func parseRange(ranges []uidRange, rangeList []string) ([]uidRange, error) {
+	for _, rangeString := range rangeList {
+		startString, endString, found := strings.Cut(rangeString, ":")
+		if !found {
+			return nil, E.New("missing ':' in range: ", rangeString)
+		}
+		start, err := strconv.ParseUint(startString, 10, 32)
+		if err != nil {
+			return nil, E.Cause(err, "parse range start: ", rangeString)
+		}
+		end, err := strconv.ParseUint(endString, 10, 32)
+		if err != nil {
+			return nil, E.Cause(err, "parse range end: ", rangeString)
+		}
+		if start > end {
+			return nil, E.New("invalid range: ", rangeString)
+		}
+		ranges = append(ranges, uidRange{uint32(start), uint32(end)})
+	}
+	return ranges, nil
+}
@@ -0,0 +1,85 @@
+package tun
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+func Test_UIDFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options option.TunInboundOptions
+		uid     uint32
+		want    bool
+	}{
+		{
+			name:    "exclude",
+			options: option.TunInboundOptions{ExcludeUID: []uint32{10001}},
+			uid:     10001,
+			want:    false,
+		},
+		{
+			name:    "not excluded",
+			options: option.TunInboundOptions{ExcludeUID: []uint32{10001}},
+			uid:     10002,
+			want:    true,
+		},
+		{
+			name:    "include range",
+			options: option.TunInboundOptions{IncludeUIDRange: []string{"10000:10100"}},
+			uid:     10050,
+			want:    true,
+		},
+		{
+			name:    "not included",
+			options: option.TunInboundOptions{IncludeUID: []uint32{10001}},
+			uid:     10002,
+			want:    false,
+		},
+		{
+			name: "exclude over include",
+			options: option.TunInboundOptions{
+				IncludeUIDRange: []string{"10000:10100"},
+				ExcludeUIDRange: []string{"10050:10060"},
+			},
+			uid:  10055,
+			want: false,
+		},
+		{
+			name:    "android user",
+			options: option.TunInboundOptions{IncludeAndroidUser: []int{10}},
+			uid:     1010123,
+			want:    true,
+		},
+		{
+			name:    "other android user",
+			options: option.TunInboundOptions{IncludeAndroidUser: []int{0}},
+			uid:     1010123,
+			want:    false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newUIDFilter(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.match(tt.uid); got != tt.want {
+				t.Errorf("match(%d) = %v, want %v", tt.uid, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UIDFilterInvalid(t *testing.T) {
+	filter, err := newUIDFilter(option.TunInboundOptions{})
+	if err != nil || filter != nil {
+		t.Errorf("empty options: got %v, %v", filter, err)
+	}
+	for _, rangeString := range []string{"10000", "a:1", "10:1"} {
+		_, err = newUIDFilter(option.TunInboundOptions{ExcludeUIDRange: []string{rangeString}})
+		if err == nil {
+			t.Errorf("expected error for %q", rangeString)
+		}
+	}
+}
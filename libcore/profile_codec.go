@@ -0,0 +1,96 @@
+package libcore
+
+import (
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/profilecodec"
+)
+
+// EncodeProfile encodes the whole profile into fragments no longer than maxLength, which can be shown as QR codes.
+// The profile is encrypted if passphrase is not empty. base45 makes fragments fit QR code alphanumeric mode.
+func EncodeProfile(config, passphrase string, maxLength int32, base45 bool) (StringIterator, error) {
+	content, err := canonicalProfile(config)
+	if err != nil {
+		return nil, err
+	}
+	data, err := profilecodec.Encode(content, passphrase)
+	if err != nil {
+		return nil, E.Cause(err, "encode profile")
+	}
+	fragments, err := profilecodec.Split(data, int(maxLength), base45)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(fragments), nil
+}
+
+// canonicalProfile re-encodes config by sing-box options,
+// so that equal profiles produce the same bytes whatever the original formatting is.
+func canonicalProfile(config string) ([]byte, error) {
+	ctx := baseContext(nil)
+	options, err := parseConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.MarshalContext(ctx, &options)
+	if err != nil {
+		return nil, E.Cause(err, "encode options")
+	}
+	return content, nil
+}
+
+// IsProfileFragment reports whether content is a fragment of EncodeProfile.
+func IsProfileFragment(content string) bool {
+	return profilecodec.IsFragment(content)
+}
+
+// ProfileAssembler collects fragments of EncodeProfile in any order.
+type ProfileAssembler struct {
+	assembler profilecodec.Assembler
+}
+
+func NewProfileAssembler() *ProfileAssembler {
+	return &ProfileAssembler{}
+}
+
+// Add adds fragment, and reports whether all fragments are received.
+func (p *ProfileAssembler) Add(fragment string) (bool, error) {
+	return p.assembler.Add(fragment)
+}
+
+func (p *ProfileAssembler) Received() int32 {
+	return int32(p.assembler.Received())
+}
+
+// Total returns count of fragments, or 0 if nothing received.
+func (p *ProfileAssembler) Total() int32 {
+	return int32(p.assembler.Total())
+}
+
+// Decode verifies and decodes received fragments into profile.
+func (p *ProfileAssembler) Decode(passphrase string) (string, error) {
+	data, err := p.assembler.Result()
+	if err != nil {
+		return "", err
+	}
+	content, err := profilecodec.Decode(data, passphrase)
+	if err != nil {
+		return "", err
+	}
+	// Ensure the profile is still valid for current version.
+	content, err = canonicalProfile(string(content))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// Encrypted reports whether received profile requires passphrase.
+func (p *ProfileAssembler) Encrypted() (bool, error) {
+	data, err := p.assembler.Result()
+	if err != nil {
+		return false, err
+	}
+	return profilecodec.IsEncrypted(data), nil
+}
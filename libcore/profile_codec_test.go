@@ -0,0 +1,56 @@
+package libcore
+
+import (
+	"testing"
+)
+
+func Test_ProfileCodec(t *testing.T) {
+	config := `{
+  "dns": {
+    "servers": [{"type": "local", "tag": "local"}],
+    "rules": [{"domain_suffix": ["example.com"], "server": "local"}]
+  },
+  "outbounds": [
+    {"type": "selector", "tag": "proxy", "outbounds": ["direct-a", "direct-b"], "default": "direct-b"},
+    {"type": "urltest", "tag": "auto", "outbounds": ["direct-a", "direct-b"]},
+    {"type": "direct", "tag": "direct-a"},
+    {"type": "direct", "tag": "direct-b"}
+  ],
+  "route": {
+    "rules": [{"domain": ["example.org"], "outbound": "auto"}],
+    "final": "proxy"
+  }
+}`
+	want, err := canonicalProfile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, base45 := range []bool{false, true} {
+		fragments, err := EncodeProfile(config, "passphrase", 120, base45)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assembler := NewProfileAssembler()
+		for fragments.HasNext() {
+			fragment := fragments.Next()
+			if !IsProfileFragment(fragment) {
+				t.Fatalf("not a fragment: %s", fragment)
+			}
+			_, err = assembler.Add(fragment)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		encrypted, err := assembler.Encrypted()
+		if err != nil || !encrypted {
+			t.Fatalf("expected encrypted profile, got %v, %v", encrypted, err)
+		}
+		got, err := assembler.Decode("passphrase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Fatalf("profile changed after round-trip:\n%s\n%s", got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package profilecodec
+
+import (
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// https://www.rfc-editor.org/rfc/rfc9285
+// Base45 only uses characters of QR code alphanumeric mode, which is denser than byte mode.
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+func encodeBase45(data []byte) string {
+	var builder strings.Builder
+	builder.Grow(len(data)/2*3 + 2)
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])<<8 | int(data[i+1])
+		builder.WriteByte(base45Alphabet[n%45])
+		builder.WriteByte(base45Alphabet[n/45%45])
+		builder.WriteByte(base45Alphabet[n/45/45])
+	}
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		builder.WriteByte(base45Alphabet[n%45])
+		builder.WriteByte(base45Alphabet[n/45])
+	}
+	return builder.String()
+}
+
+func decodeBase45(content string) ([]byte, error) {
+	if len(content)%3 == 1 {
+		return nil, E.New("invalid base45 length")
+	}
+	values := make([]int, len(content))
+	for i := range content {
+		value := strings.IndexByte(base45Alphabet, content[i])
+		if value < 0 {
+			return nil, E.New("invalid base45 character: ", content[i:i+1])
+		}
+		values[i] = value
+	}
+	data := make([]byte, 0, len(content)/3*2+1)
+	for i := 0; i < len(values); i += 3 {
+		if i+2 < len(values) {
+			n := values[i] + values[i+1]*45 + values[i+2]*45*45
+			if n > 0xFFFF {
+				return nil, E.New("invalid base45 triplet")
+			}
+			data = append(data, byte(n>>8), byte(n))
+		} else {
+			n := values[i] + values[i+1]*45
+			if n > 0xFF {
+				return nil, E.New("invalid base45 pair")
+			}
+			data = append(data, byte(n))
+		}
+	}
+	return data, nil
+}
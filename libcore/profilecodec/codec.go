@@ -0,0 +1,126 @@
+// Package profilecodec encodes whole profiles into compact binary, and splits it into URI fragments.
+package profilecodec
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Binary format:
+//
+//	magic(3) | version(1) | flags(1) | [salt(16) | nonce(24)] | payload
+//
+// payload is zstd of the profile, sealed by XChaCha20-Poly1305 if flagEncrypted,
+// with key derived from passphrase by scrypt and header as additional data.
+const (
+	magic   = "HPF"
+	version = 1
+
+	flagEncrypted = 1 << 0
+
+	headerLength = len(magic) + 2
+	saltLength   = 16
+
+	// maxProfileSize limits decompressed profile, against decompression bomb.
+	maxProfileSize = 16 << 20
+
+	// https://pkg.go.dev/golang.org/x/crypto/scrypt#Key
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var ErrPassphraseRequired = E.New("passphrase required")
+
+// Encode compresses profile, and encrypts it if passphrase is not empty.
+func Encode(profile []byte, passphrase string) ([]byte, error) {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		return nil, err
+	}
+	payload := encoder.EncodeAll(profile, nil)
+	_ = encoder.Close()
+
+	header := []byte(magic + "\x00\x00")
+	header[len(magic)] = version
+	if passphrase == "" {
+		return append(header, payload...), nil
+	}
+	header[len(magic)+1] |= flagEncrypted
+	salt := make([]byte, saltLength)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	data := bytes.Join([][]byte{header, salt, nonce}, nil)
+	return aead.Seal(data, nonce, payload, header), nil
+}
+
+// Decode reverses Encode. passphrase is ignored if data is not encrypted.
+func Decode(data []byte, passphrase string) ([]byte, error) {
+	if len(data) < headerLength || string(data[:len(magic)]) != magic {
+		return nil, E.New("not an encoded profile")
+	}
+	if data[len(magic)] != version {
+		return nil, E.New("unsupported version: ", data[len(magic)])
+	}
+	header, payload := data[:headerLength], data[headerLength:]
+	if header[len(magic)+1]&flagEncrypted != 0 {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		if len(payload) < saltLength+chacha20poly1305.NonceSizeX {
+			return nil, io.ErrUnexpectedEOF
+		}
+		salt := payload[:saltLength]
+		nonce := payload[saltLength : saltLength+chacha20poly1305.NonceSizeX]
+		aead, err := newAEAD(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		payload, err = aead.Open(nil, nonce, payload[saltLength+chacha20poly1305.NonceSizeX:], header)
+		if err != nil {
+			return nil, E.New("wrong passphrase or corrupted profile")
+		}
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxProfileSize))
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	profile, err := decoder.DecodeAll(payload, nil)
+	if err != nil {
+		return nil, E.Cause(err, "decompress profile")
+	}
+	return profile, nil
+}
+
+// IsEncrypted reports whether data of Encode is encrypted.
+func IsEncrypted(data []byte) bool {
+	return len(data) >= headerLength && string(data[:len(magic)]) == magic && data[len(magic)+1]&flagEncrypted != 0
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
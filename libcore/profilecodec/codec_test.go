@@ -0,0 +1,147 @@
+package profilecodec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Base45(t *testing.T) {
+	// https://www.rfc-editor.org/rfc/rfc9285#section-4.3
+	tt := []struct {
+		data    string
+		encoded string
+	}{
+		{"AB", "BB8"},
+		{"Hello!!", "%69 VD92EX0"},
+		{"base-45", "UJCLQE7W581"},
+		{"", ""},
+	}
+	for _, tc := range tt {
+		encoded := encodeBase45([]byte(tc.data))
+		if encoded != tc.encoded {
+			t.Errorf("encode %q: got %q, want %q", tc.data, encoded, tc.encoded)
+		}
+		decoded, err := decodeBase45(encoded)
+		if err != nil || string(decoded) != tc.data {
+			t.Errorf("decode %q: got %q, %v", encoded, decoded, err)
+		}
+	}
+	_, err := decodeBase45("GGW")
+	if err == nil {
+		t.Error("decode overflow should fail")
+	}
+}
+
+func Test_RoundTrip(t *testing.T) {
+	profile := []byte(strings.Repeat(`{"outbounds":[{"type":"direct","tag":"direct"}]}`, 64))
+	tt := []struct {
+		name       string
+		passphrase string
+		base45     bool
+	}{
+		{"Plain base64", "", false},
+		{"Encrypted base45", "correct horse", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Encode(profile, tc.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if IsEncrypted(data) != (tc.passphrase != "") {
+				t.Fatal("unexpected encryption flag")
+			}
+			fragments, err := Split(data, 60, tc.base45)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fragments) < 2 {
+				t.Fatalf("expected multiple fragments, got %d", len(fragments))
+			}
+			var assembler Assembler
+			for i := len(fragments) - 1; i >= 0; i-- {
+				if len(fragments[i]) > 60 {
+					t.Fatalf("fragment too long: %s", fragments[i])
+				}
+				if tc.base45 && fragments[i] != strings.ToUpper(fragments[i]) {
+					t.Fatalf("base45 fragment is not upper case: %s", fragments[i])
+				}
+				done, err := assembler.Add(fragments[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if done != (i == 0) {
+					t.Fatalf("unexpected done at %d", i)
+				}
+			}
+			joined, err := assembler.Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.passphrase != "" {
+				_, err = Decode(joined, "")
+				if err != ErrPassphraseRequired {
+					t.Fatalf("expected passphrase required, got %v", err)
+				}
+				_, err = Decode(joined, "wrong")
+				if err == nil {
+					t.Fatal("wrong passphrase should fail")
+				}
+			}
+			decoded, err := Decode(joined, tc.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, profile) {
+				t.Fatal("profile changed after round-trip")
+			}
+		})
+	}
+}
+
+func Test_AssemblerMismatch(t *testing.T) {
+	first, err := Split([]byte(strings.Repeat("a", 64)), 60, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Split([]byte(strings.Repeat("b", 64)), 60, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var assembler Assembler
+	_, err = assembler.Add(first[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = assembler.Add(second[1])
+	if err == nil {
+		t.Fatal("fragment of another profile should be rejected")
+	}
+	// Same checksum, corrupted payload.
+	_, err = Join(append(first[:1:1], first[1][:len(first[1])-4]+"AAAA"))
+	if err == nil {
+		t.Fatal("corrupted fragment should fail checksum")
+	}
+}
+
+func Test_UntrustedInput(t *testing.T) {
+	var assembler Assembler
+	_, err := assembler.Add("husiprofile://1/999999999999/x/y")
+	if err == nil {
+		t.Fatal("huge total should be rejected")
+	}
+	_, err = assembler.Add("husiprofile://1/1000/x/y")
+	if err == nil {
+		t.Fatal("total above MaxFragments should be rejected")
+	}
+
+	bomb, err := Encode(make([]byte, maxProfileSize+1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Decode(bomb, "")
+	if err == nil {
+		t.Fatal("oversized profile should be rejected")
+	}
+}
@@ -0,0 +1,167 @@
+package profilecodec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Fragment is "husiprofile://<index>/<total>/<checksum>/<payload>" with base64url payload,
+// or the same in upper case with base45 payload, which fits QR code alphanumeric mode.
+// checksum is the prefix of SHA-256 of the whole data, identifies the bundle and verifies reassembly.
+const (
+	scheme         = "husiprofile://"
+	checksumLength = 8
+
+	// MaxFragments limits fragments of a profile, which also limits memory for untrusted input.
+	MaxFragments = 256
+)
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:checksumLength])
+}
+
+// Split splits data into fragments, each no longer than maxLength.
+func Split(data []byte, maxLength int, base45 bool) ([]string, error) {
+	if len(data) == 0 {
+		return nil, E.New("empty data")
+	}
+	sum := checksum(data)
+	// Header length depends on digits of total, so grow total until it is stable.
+	var (
+		total     = 1
+		chunkSize int
+	)
+	for {
+		digits := len(strconv.Itoa(total))
+		capacity := maxLength - len(scheme) - 2*digits - len(sum) - 3
+		if base45 {
+			chunkSize = capacity / 3 * 2
+		} else {
+			chunkSize = capacity / 4 * 3
+		}
+		if chunkSize <= 0 {
+			return nil, E.New("fragment length too small: ", maxLength)
+		}
+		newTotal := (len(data) + chunkSize - 1) / chunkSize
+		if newTotal > MaxFragments {
+			return nil, E.New("profile too large for ", MaxFragments, " fragments of length ", maxLength)
+		}
+		if len(strconv.Itoa(newTotal)) <= digits {
+			total = newTotal
+			break
+		}
+		total = newTotal
+	}
+	fragments := make([]string, 0, total)
+	for i := range total {
+		chunk := data[i*chunkSize : min((i+1)*chunkSize, len(data))]
+		header := scheme + strconv.Itoa(i+1) + "/" + strconv.Itoa(total) + "/" + sum + "/"
+		if base45 {
+			fragments = append(fragments, strings.ToUpper(header)+encodeBase45(chunk))
+		} else {
+			fragments = append(fragments, header+base64.RawURLEncoding.EncodeToString(chunk))
+		}
+	}
+	return fragments, nil
+}
+
+// IsFragment reports whether content looks like a fragment.
+func IsFragment(content string) bool {
+	return len(content) > len(scheme) && strings.EqualFold(content[:len(scheme)], scheme)
+}
+
+// Assembler collects fragments in any order. Duplicated fragments are ignored.
+type Assembler struct {
+	checksum string
+	chunks   [][]byte
+	received int
+}
+
+// Add adds fragment, and reports whether all fragments are received.
+func (a *Assembler) Add(fragment string) (bool, error) {
+	fragment = strings.TrimSpace(fragment)
+	if !IsFragment(fragment) {
+		return false, E.New("not a profile fragment")
+	}
+	parts := strings.SplitN(fragment[len(scheme):], "/", 4)
+	if len(parts) != 4 {
+		return false, E.New("invalid fragment")
+	}
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false, E.Cause(err, "parse fragment index")
+	}
+	total, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false, E.Cause(err, "parse fragment total")
+	}
+	if total < 1 || total > MaxFragments || index < 1 || index > total {
+		return false, E.New("invalid fragment index: ", index, "/", total)
+	}
+	sum := strings.ToLower(parts[2])
+	if a.chunks == nil {
+		a.checksum = sum
+		a.chunks = make([][]byte, total)
+	} else if sum != a.checksum || total != len(a.chunks) {
+		return false, E.New("fragment belongs to another profile")
+	}
+	if a.chunks[index-1] != nil {
+		return a.Done(), nil
+	}
+	var chunk []byte
+	if strings.HasPrefix(fragment, strings.ToUpper(scheme)) {
+		chunk, err = decodeBase45(parts[3])
+	} else {
+		chunk, err = base64.RawURLEncoding.DecodeString(parts[3])
+	}
+	if err != nil {
+		return false, E.Cause(err, "decode fragment ", index)
+	}
+	a.chunks[index-1] = chunk
+	a.received++
+	return a.Done(), nil
+}
+
+func (a *Assembler) Received() int {
+	return a.received
+}
+
+// Total returns count of fragments, or 0 if nothing received.
+func (a *Assembler) Total() int {
+	return len(a.chunks)
+}
+
+func (a *Assembler) Done() bool {
+	return a.chunks != nil && a.received == len(a.chunks)
+}
+
+// Result joins fragments and verifies checksum.
+func (a *Assembler) Result() ([]byte, error) {
+	if !a.Done() {
+		return nil, E.New("missing fragments: received ", a.received, " of ", len(a.chunks))
+	}
+	data := bytes.Join(a.chunks, nil)
+	if checksum(data) != a.checksum {
+		return nil, E.New("checksum mismatch")
+	}
+	return data, nil
+}
+
+// Join is a shortcut of Assembler.
+func Join(fragments []string) ([]byte, error) {
+	var assembler Assembler
+	for _, fragment := range fragments {
+		_, err := assembler.Add(fragment)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return assembler.Result()
+}
@@ -3,6 +3,8 @@ package libcore
 import (
 	"io"
 
+	"libcore/combinedapi/trafficcontrol"
+	"libcore/plugin/plugingroup"
 	"libcore/plugin/pluginoption"
 	"libcore/vario"
 
@@ -55,11 +57,12 @@ func (s *Service) handleSelectOutbound(conn io.ReadWriter, instance *boxInstance
 // InitializeProxySet initializes the proxy set by iterating through all outbounds
 // and identifying outbound groups.
 func (b *boxInstance) InitializeProxySet() {
-	var urlTests []*group.URLTest
+	var urlTests []adapter.URLTestGroup
 	for _, outbound := range b.Outbound().Outbounds() {
 		if outboundGroup, isGroup := outbound.(adapter.OutboundGroup); isGroup {
 			b.platformInterface.OnGroupSelectedChange(outboundGroup.Tag(), "", outboundGroup.Now())
-			if urlTest, isURLTest := outboundGroup.(*group.URLTest); isURLTest {
+			// urltest, fallback and load balance groups change selection by health checks.
+			if urlTest, isURLTest := outboundGroup.(adapter.URLTestGroup); isURLTest {
 				urlTests = append(urlTests, urlTest)
 			}
 		}
@@ -70,14 +73,14 @@ func (b *boxInstance) InitializeProxySet() {
 }
 
 // watchGroupChange monitors changes in the selected outbound for URLTest groups.
-func (b *boxInstance) watchGroupChange(urlTests []*group.URLTest) {
+func (b *boxInstance) watchGroupChange(urlTests []adapter.URLTestGroup) {
 	tagCache := make(map[string]string, len(urlTests)) // group:current_tag
 	for _, urlTest := range urlTests {
 		tagCache[urlTest.Tag()] = urlTest.Now()
 	}
 
 	hook := observable.NewSubscriber[struct{}](1) // Prevent not receive notification when checking
-	historyStorage := b.api.HistoryStorage()
+	historyStorage := b.api.URLTestHistory()
 	historyStorage.SetHook(hook)
 	subscription, done := hook.Subscription()
 	go func() {
@@ -120,7 +123,9 @@ type ProxySet struct {
 	Type       string
 	Selected   string
 	Selectable bool
-	Items      []*GroupItem
+	// Strategy is the strategy of load balance group.
+	Strategy string
+	Items    []*GroupItem
 }
 
 func (p *ProxySet) GetItems() GroupItemIterator {
@@ -147,14 +152,15 @@ func (c *Client) QueryProxySets() (ProxySetIterator, error) {
 
 func (s *Service) handleQueryProxySets(conn io.ReadWriter, instance *boxInstance) error {
 	outboundManager := instance.Outbound()
-	historyStorage := instance.api.HistoryStorage()
+	historyStorage := instance.api.URLTestHistory()
+	trafficManager := instance.api.TrafficManager()
 	var proxySets []*ProxySet
 	for _, outbound := range outboundManager.Outbounds() {
 		outboundGroup, isGroup := outbound.(adapter.OutboundGroup)
 		if !isGroup {
 			continue
 		}
-		proxySets = append(proxySets, buildProxySet(outboundManager, outboundGroup, historyStorage))
+		proxySets = append(proxySets, buildProxySet(outboundManager, outboundGroup, historyStorage, trafficManager))
 	}
 	err := vario.WriteSlices(conn, proxySets)
 	if err != nil {
@@ -163,24 +169,63 @@ func (s *Service) handleQueryProxySets(conn io.ReadWriter, instance *boxInstance
 	return nil
 }
 
-func buildProxySet(outboundManager adapter.OutboundManager, outboundGroup adapter.OutboundGroup, historyStorage adapter.URLTestHistoryStorage) *ProxySet {
+func buildProxySet(outboundManager adapter.OutboundManager, outboundGroup adapter.OutboundGroup, historyStorage adapter.URLTestHistoryStorage, trafficManager *trafficcontrol.Manager) *ProxySet {
 	_, isSelector := outboundGroup.(*group.Selector)
+	var strategy string
+	if loadBalance, isLoadBalance := outboundGroup.(*plugingroup.LoadBalance); isLoadBalance {
+		strategy = loadBalance.Strategy()
+	}
 	return &ProxySet{
 		Tag:        outboundGroup.Tag(),
 		Type:       pluginoption.ProxyDisplayName(outboundGroup.Type()),
 		Selected:   outboundGroup.Now(),
 		Selectable: isSelector,
+		Strategy:   strategy,
 		Items: common.Map(outboundGroup.All(), func(it string) *GroupItem {
 			outbound, _ := outboundManager.Outbound(it)
-			return buildGroupItem(outbound, historyStorage)
+			return buildGroupItem(outbound, historyStorage, trafficManager)
 		}),
 	}
 }
 
+// Health states of GroupItem.
+const (
+	HealthUnknown int32 = iota
+	HealthHealthy
+	HealthDegraded
+)
+
 type GroupItem struct {
 	Tag   string
 	Type  string
 	Delay int16
+	// Health is one of HealthUnknown, HealthHealthy and HealthDegraded, reported by outbound's health check.
+	Health int32
+	// HealthScore is the success rate in percent of real connections through outbound, or -1 if none is recorded.
+	HealthScore int32
+	// HealthFailures is the count of consecutive failures of real connections through outbound.
+	HealthFailures int32
+	// Reconnects is count of connections reset by outbound.
+	Reconnects int64
+	// UDPActive, UDPTotal and UDPFailed are counts of UDP sessions of outbound carrying them by streams, like Juicity.
+	UDPActive int64
+	UDPTotal  int64
+	UDPFailed int64
+}
+
+// healthReporter is an outbound checking its connection, like TrustTunnel.
+type healthReporter interface {
+	Health() (healthy bool, checked bool)
+}
+
+// reconnectCounter is an outbound counting its reconnections.
+type reconnectCounter interface {
+	Reconnects() int64
+}
+
+// udpSessionCounter is an outbound counting its UDP sessions.
+type udpSessionCounter interface {
+	UDPStats() pluginoption.UDPStats
 }
 
 type GroupItemIterator interface {
@@ -189,18 +234,45 @@ type GroupItemIterator interface {
 	Length() int32
 }
 
-func buildGroupItem(outbound adapter.Outbound, historyStorage adapter.URLTestHistoryStorage) *GroupItem {
+func buildGroupItem(outbound adapter.Outbound, historyStorage adapter.URLTestHistoryStorage, trafficManager *trafficcontrol.Manager) *GroupItem {
 	var delay int16
 	if historyStorage != nil {
-		if history := historyStorage.LoadURLTestHistory(outbound.Type()); history != nil {
+		// History is stored by tag of the real outbound, which is also the tag for non-group outbounds.
+		if history := historyStorage.LoadURLTestHistory(group.RealTag(outbound)); history != nil {
 			delay = int16(history.Delay)
 		}
 	}
-	return &GroupItem{
-		Tag:   outbound.Tag(),
-		Type:  pluginoption.ProxyDisplayName(outbound.Type()),
-		Delay: delay,
+	item := &GroupItem{
+		Tag:         outbound.Tag(),
+		Type:        pluginoption.ProxyDisplayName(outbound.Type()),
+		Delay:       delay,
+		HealthScore: -1,
+	}
+	if trafficManager != nil {
+		if health, loaded := trafficManager.OutboundHealth(group.RealTag(outbound)); loaded {
+			item.HealthScore = int32(health.Score * 100)
+			item.HealthFailures = int32(health.Failures)
+		}
+	}
+	if reporter, isReporter := outbound.(healthReporter); isReporter {
+		switch healthy, checked := reporter.Health(); {
+		case !checked:
+		case healthy:
+			item.Health = HealthHealthy
+		default:
+			item.Health = HealthDegraded
+		}
+	}
+	if counter, isCounter := outbound.(reconnectCounter); isCounter {
+		item.Reconnects = counter.Reconnects()
+	}
+	if counter, isCounter := outbound.(udpSessionCounter); isCounter {
+		stats := counter.UDPStats()
+		item.UDPActive = stats.Active
+		item.UDPTotal = stats.Total
+		item.UDPFailed = stats.Failed
 	}
+	return item
 }
 
 func (p *ProxySet) WriteToBinary(writer io.Writer) error {
@@ -220,6 +292,10 @@ func (p *ProxySet) WriteToBinary(writer io.Writer) error {
 	if err != nil {
 		return E.Cause(err, "write selectable")
 	}
+	err = vario.WriteString(writer, p.Strategy)
+	if err != nil {
+		return E.Cause(err, "write strategy")
+	}
 	err = vario.WriteSlices(writer, p.Items)
 	if err != nil {
 		return E.Cause(err, "write items")
@@ -244,6 +320,10 @@ func readProxySet(reader io.Reader) (*ProxySet, error) {
 	if err != nil {
 		return nil, E.Cause(err, "read selectable")
 	}
+	strategy, err := vario.ReadString(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read strategy")
+	}
 	items, err := vario.ReadSlices(reader, readGroupItem)
 	if err != nil {
 		return nil, E.Cause(err, "read items")
@@ -253,6 +333,7 @@ func readProxySet(reader io.Reader) (*ProxySet, error) {
 		Type:       proxyType,
 		Selected:   selected,
 		Selectable: selectable,
+		Strategy:   strategy,
 		Items:      items,
 	}, nil
 }
@@ -270,6 +351,34 @@ func (g *GroupItem) WriteToBinary(writer io.Writer) error {
 	if err != nil {
 		return E.Cause(err, "write delay")
 	}
+	err = vario.WriteInt32(writer, g.Health)
+	if err != nil {
+		return E.Cause(err, "write health")
+	}
+	err = vario.WriteInt32(writer, g.HealthScore)
+	if err != nil {
+		return E.Cause(err, "write health score")
+	}
+	err = vario.WriteInt32(writer, g.HealthFailures)
+	if err != nil {
+		return E.Cause(err, "write health failures")
+	}
+	err = vario.WriteInt64(writer, g.Reconnects)
+	if err != nil {
+		return E.Cause(err, "write reconnects")
+	}
+	err = vario.WriteInt64(writer, g.UDPActive)
+	if err != nil {
+		return E.Cause(err, "write udp active")
+	}
+	err = vario.WriteInt64(writer, g.UDPTotal)
+	if err != nil {
+		return E.Cause(err, "write udp total")
+	}
+	err = vario.WriteInt64(writer, g.UDPFailed)
+	if err != nil {
+		return E.Cause(err, "write udp failed")
+	}
 	return nil
 }
 
@@ -286,9 +395,42 @@ func readGroupItem(reader io.Reader) (*GroupItem, error) {
 	if err != nil {
 		return nil, E.Cause(err, "read delay")
 	}
-	return &GroupItem{
-		Tag:   tag,
-		Type:  itemType,
-		Delay: delay,
-	}, nil
+	health, err := vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read health")
+	}
+	healthScore, err := vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read health score")
+	}
+	healthFailures, err := vario.ReadInt32(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read health failures")
+	}
+	reconnects, err := vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read reconnects")
+	}
+	item := &GroupItem{
+		Tag:            tag,
+		Type:           itemType,
+		Delay:          delay,
+		Health:         health,
+		HealthScore:    healthScore,
+		HealthFailures: healthFailures,
+		Reconnects:     reconnects,
+	}
+	item.UDPActive, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read udp active")
+	}
+	item.UDPTotal, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read udp total")
+	}
+	item.UDPFailed, err = vario.ReadInt64(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read udp failed")
+	}
+	return item, nil
 }
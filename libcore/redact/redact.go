@@ -0,0 +1,180 @@
+// Package redact finds secrets in options, and hides them in logs and exports.
+package redact
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const Placeholder = "[redacted]"
+
+// minLength avoids mangling logs by short secrets, which are likely common words.
+const minLength = 6
+
+// secretFields are json names of secret fields in sing-box and plugin options.
+// Names too common to be secret everywhere are qualified by json name of the parent field.
+var secretFields = []string{
+	"password",
+	"uuid",
+	"private_key",
+	"private_key_passphrase",
+	"pre_shared_key",
+	"short_id",
+	"auth",
+	"auth_str",
+	"auth_key",
+	"tls.key",
+	"ech.key",
+	"client_key",
+	"mac_key",
+	"token",
+	"api_token",
+	"zone_token",
+	"security_token",
+	"secret",
+	"access_key_secret",
+	"mesh_psk",
+}
+
+// secretKeys are keys of secret values in maps like HTTP headers, compared case-insensitively.
+var secretKeys = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+}
+
+// Secrets returns values of secret fields in options, which should be a pointer.
+func Secrets(options any) []string {
+	var secrets []string
+	walk(reflect.ValueOf(options), "", false, func(value reflect.Value) {
+		if secret := value.String(); len(secret) >= minLength && !slices.Contains(secrets, secret) {
+			secrets = append(secrets, secret)
+		}
+	})
+	return secrets
+}
+
+// Options replaces values of secret fields in options with Placeholder.
+func Options(options any) {
+	walk(reflect.ValueOf(options), "", false, func(value reflect.Value) {
+		if value.CanSet() && value.String() != "" {
+			value.SetString(Placeholder)
+		}
+	})
+}
+
+// walk calls fn for every string inside secret fields. parent is json name of the field containing value.
+func walk(value reflect.Value, parent string, secret bool, fn func(reflect.Value)) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			walk(value.Elem(), parent, secret, fn)
+		}
+	case reflect.Interface:
+		if value.IsNil() {
+			return
+		}
+		elem := value.Elem()
+		if elem.Kind() == reflect.Pointer || !value.CanSet() {
+			walk(elem, parent, secret, fn)
+			return
+		}
+		// Values in interface are not addressable.
+		copied := reflect.New(elem.Type()).Elem()
+		copied.Set(elem)
+		walk(copied, parent, secret, fn)
+		value.Set(copied)
+	case reflect.Struct:
+		valueType := value.Type()
+		for i := range value.NumField() {
+			field := valueType.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			isSecret := slices.Contains(secretFields, name) || slices.Contains(secretFields, parent+"."+name)
+			if field.Anonymous && name == "" {
+				// Fields of embedded struct belong to the parent.
+				name = parent
+			}
+			walk(value.Field(i), name, secret || isSecret, fn)
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return
+		}
+		iter := value.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			isSecret := slices.Contains(secretKeys, strings.ToLower(key)) || slices.Contains(secretFields, key)
+			// Values in map are not addressable.
+			copied := reflect.New(iter.Value().Type()).Elem()
+			copied.Set(iter.Value())
+			walk(copied, key, secret || isSecret, fn)
+			if value.CanInterface() {
+				value.SetMapIndex(iter.Key(), copied)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := range value.Len() {
+			walk(value.Index(i), parent, secret, fn)
+		}
+	case reflect.String:
+		if secret {
+			fn(value)
+		}
+	}
+}
+
+// Redactor replaces known secrets in messages.
+type Redactor struct {
+	access   sync.RWMutex
+	secrets  []string
+	replacer *strings.Replacer
+}
+
+// Add adds secrets, which are kept until Reset.
+func (r *Redactor) Add(secrets ...string) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	added := false
+	for _, secret := range secrets {
+		if len(secret) >= minLength && !slices.Contains(r.secrets, secret) {
+			r.secrets = append(r.secrets, secret)
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	// Replace longer secrets first, in case of one contains another.
+	slices.SortFunc(r.secrets, func(a, b string) int {
+		return len(b) - len(a)
+	})
+	pairs := make([]string, 0, len(r.secrets)*2)
+	for _, secret := range r.secrets {
+		pairs = append(pairs, secret, Placeholder)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+func (r *Redactor) Reset() {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.secrets = nil
+	r.replacer = nil
+}
+
+func (r *Redactor) Redact(message string) string {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	if r.replacer == nil {
+		return message
+	}
+	return r.replacer.Replace(message)
+}
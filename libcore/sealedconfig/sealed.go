@@ -0,0 +1,61 @@
+// Package sealedconfig encrypts configs at rest by key from platform keystore.
+package sealedconfig
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Prefix marks sealed config, which is "sealed:v1:" + base64(nonce | ciphertext).
+// Prefix is also the additional data of AEAD.
+const Prefix = "sealed:v1:"
+
+const KeySize = chacha20poly1305.KeySize
+
+func IsSealed(content string) bool {
+	return strings.HasPrefix(content, Prefix)
+}
+
+// Seal encrypts config by XChaCha20-Poly1305 with key.
+func Seal(key []byte, config []byte) (string, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", E.Cause(err, "invalid key")
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(config)+aead.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, config, []byte(Prefix))
+	return Prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts content of Seal.
+func Open(key []byte, content string) ([]byte, error) {
+	encoded, isSealed := strings.CutPrefix(content, Prefix)
+	if !isSealed {
+		return nil, E.New("config is not sealed")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, E.Cause(err, "decode sealed config")
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, E.Cause(err, "invalid key")
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, E.New("sealed config too short")
+	}
+	config, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(Prefix))
+	if err != nil {
+		return nil, E.New("wrong key or corrupted sealed config")
+	}
+	return config, nil
+}
@@ -0,0 +1,33 @@
+package sealedconfig
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Seal(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	config := []byte(`{"outbounds":[{"type":"vless","uuid":"secret"}]}`)
+	sealed, err := Seal(key, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || bytes.Contains([]byte(sealed), []byte("secret")) {
+		t.Fatalf("unexpected sealed config: %s", sealed)
+	}
+	opened, err := Open(key, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, config) {
+		t.Fatalf("got %s, want %s", opened, config)
+	}
+	_, err = Open(bytes.Repeat([]byte{2}, KeySize), sealed)
+	if err == nil {
+		t.Fatal("wrong key should fail")
+	}
+	_, err = Seal([]byte("short"), config)
+	if err == nil {
+		t.Fatal("short key should fail")
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"syscall"
 	"time"
 
+	"libcore/automation"
+	"libcore/dnscache"
+	"libcore/metered"
 	"libcore/vario"
 
 	"github.com/sagernet/sing-box/log"
@@ -21,11 +24,19 @@ type Service struct {
 	platformInterface PlatformInterface
 	instance          *boxInstance
 	listener          *net.UnixListener
+	dnsCache          *dnscache.Cache
+	automation        *automation.Engine
+	metered           *metered.Controller
+
+	temporaryAccess    sync.Mutex
+	temporaryID        uint64
+	temporaryInstances map[uint64]*heldInstance
 }
 
 func NewService(platformInterface PlatformInterface) *Service {
 	return &Service{
 		platformInterface: platformInterface,
+		metered:           metered.NewController(),
 	}
 }
 
@@ -164,6 +175,12 @@ func (s *Service) Close() (err error) {
 	)
 	s.listener = nil
 	s.instance = nil
+	s.temporaryAccess.Lock()
+	for _, held := range s.temporaryInstances {
+		// Instance is closed by its handler after the connection closed.
+		_ = held.conn.Close()
+	}
+	s.temporaryAccess.Unlock()
 	return
 }
 
@@ -353,6 +370,72 @@ func (s *Service) handleRequest(conn net.Conn) error {
 			return E.Cause(err, "handle url test")
 		}
 		return nil
+	case commandQueryDNSTransportMetrics:
+		s.access.RLock()
+		instance, err := s.requireInstance()
+		s.access.RUnlock()
+		if err != nil {
+			return err
+		}
+		err = s.handleQueryDNSTransportMetrics(conn, instance)
+		if err != nil {
+			return E.Cause(err, "handle query dns transport metrics")
+		}
+		return nil
+	case commandQueryDNSUpstreamStats:
+		s.access.RLock()
+		instance, err := s.requireInstance()
+		s.access.RUnlock()
+		if err != nil {
+			return err
+		}
+		err = s.handleQueryDNSUpstreamStats(conn, instance)
+		if err != nil {
+			return E.Cause(err, "handle query dns upstream stats")
+		}
+		return nil
+	case commandClearDNSCache:
+		err := s.clearDNSCache()
+		if err != nil {
+			log.Warn("clear dns cache: ", err)
+		}
+		return nil
+	case commandQueryDNSFilterHits:
+		s.access.RLock()
+		instance, err := s.requireInstance()
+		s.access.RUnlock()
+		if err != nil {
+			return err
+		}
+		err = s.handleQueryDNSFilterHits(conn, instance)
+		if err != nil {
+			return E.Cause(err, "handle query dns filter hits")
+		}
+		return nil
+	case commandDialOutbound:
+		err := s.handleDialOutbound(conn)
+		if err != nil {
+			return E.Cause(err, "handle dial outbound")
+		}
+		return nil
+	case commandSetMeteredPolicy:
+		err := s.handleSetMeteredPolicy(conn)
+		if err != nil {
+			return E.Cause(err, "handle set metered policy")
+		}
+		return nil
+	case commandQueryMeteredStatus:
+		err := s.handleQueryMeteredStatus(conn)
+		if err != nil {
+			return E.Cause(err, "handle query metered status")
+		}
+		return nil
+	case commandNewTemporaryInstance:
+		err := s.handleNewTemporaryInstance(conn)
+		if err != nil {
+			return E.Cause(err, "handle new temporary instance")
+		}
+		return nil
 	default:
 		return E.New("unknown command: ", command)
 	}
@@ -365,10 +448,15 @@ func (s *Service) newInstance(config string) error {
 	if s.instance != nil {
 		return E.Cause(os.ErrExist, "instance exists")
 	}
-	instance, err := newBoxInstance(config, s.platformInterface, false)
+	instance, err := newBoxInstance(config, s.platformInterface, s.dnsCache, s.metered, false)
 	if err != nil {
 		return err
 	}
+	if s.automation != nil {
+		// Apply matched rule to the new instance.
+		s.automation.Reset()
+		instance.automation.Store(s.automation)
+	}
 	s.instance = instance
 	return nil
 }
@@ -0,0 +1,63 @@
+package libcore
+
+import (
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/sharelink"
+)
+
+// ParseShareLink converts share link to JSON of sing-box outbound,
+// or endpoint for WireGuard.
+func ParseShareLink(link string) (string, error) {
+	ctx := baseContext(nil)
+	var (
+		content []byte
+		err     error
+	)
+	if sharelink.IsEndpoint(link) {
+		var endpoint option.Endpoint
+		endpoint, err = sharelink.ParseEndpoint(link)
+		if err != nil {
+			return "", err
+		}
+		content, err = json.MarshalContext(ctx, &endpoint)
+	} else {
+		var outbound option.Outbound
+		outbound, err = sharelink.Parse(link)
+		if err != nil {
+			return "", err
+		}
+		content, err = json.MarshalContext(ctx, &outbound)
+	}
+	if err != nil {
+		return "", E.Cause(err, "encode options")
+	}
+	return string(content), nil
+}
+
+// ExportShareLink converts JSON of sing-box outbound or endpoint to share link.
+func ExportShareLink(content string) (string, error) {
+	ctx := baseContext(nil)
+	var probe struct {
+		Type string `json:"type"`
+	}
+	err := json.Unmarshal([]byte(content), &probe)
+	if err != nil {
+		return "", E.Cause(err, "read type")
+	}
+	if probe.Type == C.TypeWireGuard {
+		endpoint, err := json.UnmarshalExtendedContext[option.Endpoint](ctx, []byte(content))
+		if err != nil {
+			return "", E.Cause(err, "decode endpoint")
+		}
+		return sharelink.ExportEndpoint(endpoint)
+	}
+	outbound, err := json.UnmarshalExtendedContext[option.Outbound](ctx, []byte(content))
+	if err != nil {
+		return "", E.Cause(err, "decode outbound")
+	}
+	return sharelink.Export(outbound)
+}
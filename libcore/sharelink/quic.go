@@ -0,0 +1,218 @@
+package sharelink
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"libcore/plugin/pluginoption"
+)
+
+// https://v2.hysteria.network/docs/developers/URI-Scheme/
+func parseHysteria2(link string) (option.Outbound, error) {
+	u, port, err := ParseURLWithPorts(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse hysteria2 link")
+	}
+	query := u.Query()
+	options := &option.Hysteria2OutboundOptions{
+		ServerOptions: option.ServerOptions{
+			Server: u.Hostname(),
+		},
+		OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+			TLS: &option.OutboundTLSOptions{
+				Enabled:    true,
+				ServerName: query.Get("sni"),
+				Insecure:   isTrue(query.Get("insecure")),
+				ALPN:       splitList(query.Get("alpn")),
+			},
+		},
+	}
+	if u.User != nil {
+		options.Password = u.User.Username()
+		if password, isSet := u.User.Password(); isSet {
+			options.Password += ":" + password
+		}
+	}
+	if mport := query.Get("mport"); mport != "" {
+		port = mport
+	}
+	switch {
+	case port == "":
+		options.ServerPort = 443
+	case strings.ContainsAny(port, ",-"):
+		options.ServerPorts, err = ParsePortRanges(port)
+	default:
+		options.ServerPort, err = ParsePort(port)
+	}
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if obfs := query.Get("obfs"); obfs != "" {
+		options.Obfs = &option.Hysteria2Obfs{
+			Type:     obfs,
+			Password: query.Get("obfs-password"),
+		}
+	}
+	if upMbps := query.Get("upmbps"); upMbps != "" {
+		options.UpMbps, err = strconv.Atoi(upMbps)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "parse upmbps")
+		}
+	}
+	if downMbps := query.Get("downmbps"); downMbps != "" {
+		options.DownMbps, err = strconv.Atoi(downMbps)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "parse downmbps")
+		}
+	}
+	return option.Outbound{
+		Type:    C.TypeHysteria2,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportHysteria2(tag string, options *option.Hysteria2OutboundOptions) (string, error) {
+	query := url.Values{}
+	if tls := options.TLS; tls != nil {
+		setString(query, "sni", tls.ServerName)
+		setBool(query, "insecure", tls.Insecure)
+		setString(query, "alpn", strings.Join(tls.ALPN, ","))
+	}
+	if obfs := options.Obfs; obfs != nil {
+		setString(query, "obfs", obfs.Type)
+		setString(query, "obfs-password", obfs.Password)
+	}
+	if options.UpMbps > 0 {
+		query.Set("upmbps", strconv.Itoa(options.UpMbps))
+	}
+	if options.DownMbps > 0 {
+		query.Set("downmbps", strconv.Itoa(options.DownMbps))
+	}
+	u := url.URL{
+		Scheme:   SchemeHysteria2,
+		Host:     serverHost(options.ServerOptions),
+		Path:     "/",
+		RawQuery: query.Encode(),
+		Fragment: tag,
+	}
+	if options.Password != "" {
+		// The whole userinfo is the auth string.
+		u.User = url.User(options.Password)
+	}
+	if len(options.ServerPorts) > 0 {
+		// url.URL rejects port list, so append it to the host directly.
+		u.Host = u.Hostname()
+		if strings.Contains(u.Host, ":") {
+			u.Host = "[" + u.Host + "]"
+		}
+		u.Host += ":" + formatPortRanges(options.ServerPorts)
+	}
+	return u.String(), nil
+}
+
+// https://github.com/daeuniverse/dae/discussions/182
+func parseTUIC(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse tuic link")
+	}
+	server, err := parseServer(u, 443)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	uuid, password := userPassword(u)
+	options := &option.TUICOutboundOptions{
+		ServerOptions:     server,
+		UUID:              uuid,
+		Password:          password,
+		CongestionControl: query.Get("congestion_control"),
+		UDPRelayMode:      query.Get("udp_relay_mode"),
+		ZeroRTTHandshake:  isTrue(query.Get("reduce_rtt")),
+		OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+			TLS: &option.OutboundTLSOptions{
+				Enabled:    true,
+				ServerName: query.Get("sni"),
+				Insecure:   isTrue(query.Get("allow_insecure")),
+				DisableSNI: isTrue(query.Get("disable_sni")),
+				ALPN:       splitList(query.Get("alpn")),
+			},
+		},
+	}
+	return option.Outbound{
+		Type:    C.TypeTUIC,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportTUIC(tag string, options *option.TUICOutboundOptions) (string, error) {
+	query := url.Values{}
+	setString(query, "congestion_control", options.CongestionControl)
+	setString(query, "udp_relay_mode", options.UDPRelayMode)
+	setBool(query, "reduce_rtt", options.ZeroRTTHandshake)
+	if tls := options.TLS; tls != nil {
+		setString(query, "sni", tls.ServerName)
+		setBool(query, "allow_insecure", tls.Insecure)
+		setBool(query, "disable_sni", tls.DisableSNI)
+		setString(query, "alpn", strings.Join(tls.ALPN, ","))
+	}
+	return newURL(SchemeTUIC, tag, url.UserPassword(options.UUID, options.Password), options.ServerOptions, query), nil
+}
+
+// https://github.com/juicity/juicity#quick-start-guide
+func parseJuicity(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse juicity link")
+	}
+	server, err := parseServer(u, 443)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	uuid, password := userPassword(u)
+	options := &pluginoption.JuicityOutboundOptions{
+		ServerOptions: server,
+		UUID:          uuid,
+		Password:      password,
+		OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+			TLS: &option.OutboundTLSOptions{
+				Enabled:    true,
+				ServerName: query.Get("sni"),
+				Insecure:   isTrue(query.Get("allow_insecure")),
+			},
+		},
+		PinCertSha256: query.Get("pinned_certchain_sha256"),
+	}
+	// Keep the default empty.
+	if congestionControl := query.Get("congestion_control"); congestionControl != "bbr" {
+		options.CongestionControl = congestionControl
+	}
+	return option.Outbound{
+		Type:    pluginoption.TypeJuicity,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportJuicity(tag string, options *pluginoption.JuicityOutboundOptions) (string, error) {
+	query := url.Values{}
+	congestionControl := options.CongestionControl
+	if congestionControl == "" {
+		congestionControl = "bbr"
+	}
+	query.Set("congestion_control", congestionControl)
+	if tls := options.TLS; tls != nil {
+		setString(query, "sni", tls.ServerName)
+		setBool(query, "allow_insecure", tls.Insecure)
+	}
+	setString(query, "pinned_certchain_sha256", options.PinCertSha256)
+	return newURL(SchemeJuicity, tag, url.UserPassword(options.UUID, options.Password), options.ServerOptions, query), nil
+}
@@ -0,0 +1,97 @@
+package sharelink
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// https://shadowsocks.org/doc/sip002.html
+
+func parseShadowsocks(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil || u.Port() == "" {
+		// Legacy format: ss://base64(method:password@host:port)#name
+		body, name, _ := strings.Cut(link[len(SchemeShadowsocks+"://"):], "#")
+		decoded, err := DecodeBase64(body)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "decode legacy shadowsocks link")
+		}
+		u, err = url.Parse(SchemeShadowsocks + "://" + string(decoded) + "#" + name)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "parse legacy shadowsocks link")
+		}
+	}
+	server, err := parseServer(u, 0)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if u.User == nil {
+		return option.Outbound{}, E.New("missing userinfo")
+	}
+	options := &option.ShadowsocksOutboundOptions{
+		ServerOptions: server,
+	}
+	if password, isSet := u.User.Password(); isSet {
+		options.Method = u.User.Username()
+		options.Password = password
+	} else {
+		decoded, err := DecodeBase64(u.User.Username())
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "decode userinfo")
+		}
+		method, password, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return option.Outbound{}, E.New("bad userinfo")
+		}
+		options.Method = method
+		options.Password = password
+	}
+	query := u.Query()
+	if plugin := query.Get("plugin"); plugin != "" {
+		options.Plugin, options.PluginOptions = parseSIP003Plugin(plugin)
+	}
+	if isTrue(query.Get("uot")) {
+		options.UDPOverTCP = &option.UDPOverTCPOptions{Enabled: true}
+	}
+	return option.Outbound{
+		Type:    C.TypeShadowsocks,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportShadowsocks(tag string, options *option.ShadowsocksOutboundOptions) (string, error) {
+	var userinfo *url.Userinfo
+	if strings.HasPrefix(options.Method, "2022-") {
+		// SIP022 requires plain userinfo.
+		userinfo = url.UserPassword(options.Method, options.Password)
+	} else {
+		userinfo = url.User(base64.RawURLEncoding.EncodeToString([]byte(options.Method + ":" + options.Password)))
+	}
+	query := url.Values{}
+	if options.Plugin != "" {
+		plugin := options.Plugin
+		if options.PluginOptions != "" {
+			plugin += ";" + options.PluginOptions
+		}
+		query.Set("plugin", plugin)
+	}
+	if options.UDPOverTCP != nil {
+		setBool(query, "uot", options.UDPOverTCP.Enabled)
+	}
+	return newURL(SchemeShadowsocks, tag, userinfo, options.ServerOptions, query), nil
+}
+
+// parseSIP003Plugin splits `obfs-local;obfs=http` to plugin and options.
+func parseSIP003Plugin(plugin string) (name, options string) {
+	name, options, _ = strings.Cut(plugin, ";")
+	if name == "simple-obfs" {
+		name = "obfs-local"
+	}
+	return
+}
@@ -0,0 +1,303 @@
+// Package sharelink converts between share links and sing-box options.
+package sharelink
+
+import (
+	"encoding/base64"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"libcore/plugin/pluginoption"
+)
+
+const (
+	SchemeShadowsocks = "ss"
+	SchemeVMess       = "vmess"
+	SchemeVLESS       = "vless"
+	SchemeTrojan      = "trojan"
+	SchemeHysteria2   = "hysteria2"
+	SchemeTUIC        = "tuic"
+	SchemeJuicity     = "juicity"
+	SchemeAnyTLS      = "anytls"
+	SchemeWireGuard   = "wireguard"
+	SchemeSSH         = "ssh"
+	SchemeTrustTunnel = "trusttunnel"
+	SchemeSOCKS       = "socks5"
+	SchemeHTTP        = "http"
+	SchemeHTTPS       = "https"
+)
+
+// Scheme returns the lower case scheme of link.
+func Scheme(link string) string {
+	scheme, _, found := strings.Cut(link, "://")
+	if !found {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// IsEndpoint reports whether link should be parsed by ParseEndpoint.
+func IsEndpoint(link string) bool {
+	switch Scheme(link) {
+	case SchemeWireGuard, "wg":
+		return true
+	default:
+		return false
+	}
+}
+
+// Parse converts share link to outbound.
+func Parse(link string) (option.Outbound, error) {
+	switch scheme := Scheme(link); scheme {
+	case "":
+		return option.Outbound{}, E.New("not a share link")
+	case SchemeShadowsocks:
+		return parseShadowsocks(link)
+	case SchemeVMess:
+		return parseVMess(link)
+	case SchemeVLESS:
+		return parseVLESS(link)
+	case SchemeTrojan:
+		return parseTrojan(link)
+	case SchemeHysteria2, "hy2":
+		return parseHysteria2(link)
+	case SchemeTUIC:
+		return parseTUIC(link)
+	case SchemeJuicity:
+		return parseJuicity(link)
+	case SchemeAnyTLS:
+		return parseAnyTLS(link)
+	case SchemeSSH:
+		return parseSSH(link)
+	case SchemeTrustTunnel:
+		return parseTrustTunnel(link)
+	case "socks", "socks4", "socks4a", SchemeSOCKS, "socks5h":
+		return parseSOCKS(link)
+	case SchemeHTTP, SchemeHTTPS:
+		return parseHTTP(link)
+	case SchemeWireGuard, "wg":
+		return option.Outbound{}, E.New("wireguard is an endpoint")
+	default:
+		return option.Outbound{}, E.New("unsupported scheme: ", scheme)
+	}
+}
+
+// ParseEndpoint converts share link to endpoint.
+func ParseEndpoint(link string) (option.Endpoint, error) {
+	switch scheme := Scheme(link); scheme {
+	case SchemeWireGuard, "wg":
+		return parseWireGuard(link)
+	default:
+		return option.Endpoint{}, E.New("unsupported endpoint scheme: ", scheme)
+	}
+}
+
+// Export converts outbound to share link.
+func Export(outbound option.Outbound) (string, error) {
+	var (
+		link string
+		err  error
+	)
+	switch options := outbound.Options.(type) {
+	case *option.ShadowsocksOutboundOptions:
+		link, err = exportShadowsocks(outbound.Tag, options)
+	case *option.VMessOutboundOptions:
+		link, err = exportVMess(outbound.Tag, options)
+	case *pluginoption.VLESSOutboundOptions:
+		link, err = exportVLESS(outbound.Tag, options)
+	case *option.TrojanOutboundOptions:
+		link, err = exportTrojan(outbound.Tag, options)
+	case *option.Hysteria2OutboundOptions:
+		link, err = exportHysteria2(outbound.Tag, options)
+	case *option.TUICOutboundOptions:
+		link, err = exportTUIC(outbound.Tag, options)
+	case *pluginoption.JuicityOutboundOptions:
+		link, err = exportJuicity(outbound.Tag, options)
+	case *option.AnyTLSOutboundOptions:
+		link, err = exportAnyTLS(outbound.Tag, options)
+	case *option.SSHOutboundOptions:
+		link, err = exportSSH(outbound.Tag, options)
+	case *pluginoption.TrustTunnelOutboundOptions:
+		link, err = exportTrustTunnel(outbound.Tag, options)
+	case *option.SOCKSOutboundOptions:
+		link, err = exportSOCKS(outbound.Tag, options)
+	case *pluginoption.HTTPOutboundOptions:
+		link, err = exportHTTP(outbound.Tag, options)
+	default:
+		return "", E.New("unsupported outbound type: ", outbound.Type)
+	}
+	if err != nil {
+		return "", E.Cause(err, "export ", outbound.Type)
+	}
+	return link, nil
+}
+
+// ExportEndpoint converts endpoint to share link.
+func ExportEndpoint(endpoint option.Endpoint) (string, error) {
+	switch options := endpoint.Options.(type) {
+	case *option.WireGuardEndpointOptions:
+		link, err := exportWireGuard(endpoint.Tag, options)
+		if err != nil {
+			return "", E.Cause(err, "export ", C.TypeWireGuard)
+		}
+		return link, nil
+	default:
+		return "", E.New("unsupported endpoint type: ", endpoint.Type)
+	}
+}
+
+// DecodeBase64 tries all base64 variants used by share links.
+// Whitespaces are ignored.
+func DecodeBase64(raw string) ([]byte, error) {
+	raw = strings.Join(strings.Fields(raw), "")
+	var errors []error
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		decoded, err := encoding.DecodeString(raw)
+		if err == nil {
+			return decoded, nil
+		}
+		errors = append(errors, err)
+	}
+	return nil, E.Errors(errors...)
+}
+
+// ParsePort parses a single port.
+func ParsePort(port string) (uint16, error) {
+	if port == "" {
+		return 0, E.New("missing port")
+	}
+	value, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, E.Cause(err, "parse port")
+	}
+	return uint16(value), nil
+}
+
+// ParsePortRanges converts `443,8000-9000` to the `server_ports` format of sing-box.
+func ParsePortRanges(ports string) (badoption.Listable[string], error) {
+	var ranges badoption.Listable[string]
+	for _, portRange := range strings.Split(ports, ",") {
+		start, end, isRange := strings.Cut(strings.TrimSpace(portRange), "-")
+		if !isRange {
+			end = start
+		}
+		_, err := ParsePort(start)
+		if err != nil {
+			return nil, err
+		}
+		_, err = ParsePort(end)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, start+":"+end)
+	}
+	return ranges, nil
+}
+
+func formatPortRanges(ranges []string) string {
+	ports := make([]string, 0, len(ranges))
+	for _, portRange := range ranges {
+		start, end, _ := strings.Cut(portRange, ":")
+		if start == end {
+			ports = append(ports, start)
+		} else {
+			ports = append(ports, start+"-"+end)
+		}
+	}
+	return strings.Join(ports, ",")
+}
+
+func parseServer(u *url.URL, defaultPort uint16) (option.ServerOptions, error) {
+	server := option.ServerOptions{
+		Server:     u.Hostname(),
+		ServerPort: defaultPort,
+	}
+	if port := u.Port(); port != "" || defaultPort == 0 {
+		var err error
+		server.ServerPort, err = ParsePort(port)
+		if err != nil {
+			return option.ServerOptions{}, err
+		}
+	}
+	return server, nil
+}
+
+func serverHost(server option.ServerOptions) string {
+	return net.JoinHostPort(server.Server, strconv.Itoa(int(server.ServerPort)))
+}
+
+// ParseURLWithPorts parses link whose port may be a list like `443,8000-9000`,
+// which is rejected by url.Parse. The port of returned URL is empty in that case.
+func ParseURLWithPorts(link string) (*url.URL, string, error) {
+	u, err := url.Parse(link)
+	if err == nil {
+		return u, u.Port(), nil
+	}
+	multiplePort := common.SubstringBetween(err.Error(), "invalid port \"", "\" after host")
+	if multiplePort == "" {
+		return nil, "", err
+	}
+	u, err = url.Parse(strings.Replace(link, multiplePort, "", 1))
+	if err != nil {
+		return nil, "", err
+	}
+	return u, strings.TrimPrefix(multiplePort, ":"), nil
+}
+
+func userPassword(u *url.URL) (username, password string) {
+	if u.User == nil {
+		return
+	}
+	username = u.User.Username()
+	password, _ = u.User.Password()
+	return
+}
+
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func setBool(query url.Values, key string, value bool) {
+	if value {
+		query.Set(key, "1")
+	}
+}
+
+func setString(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+func splitList(value string) badoption.Listable[string] {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func newURL(scheme, tag string, userinfo *url.Userinfo, server option.ServerOptions, query url.Values) string {
+	u := url.URL{
+		Scheme:   scheme,
+		User:     userinfo,
+		Host:     serverHost(server),
+		RawQuery: query.Encode(),
+		Fragment: tag,
+	}
+	return u.String()
+}
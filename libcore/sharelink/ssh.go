@@ -0,0 +1,62 @@
+package sharelink
+
+import (
+	"net/url"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func parseSSH(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse ssh link")
+	}
+	server, err := parseServer(u, 22)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	user, password := userPassword(u)
+	options := &option.SSHOutboundOptions{
+		ServerOptions:        server,
+		User:                 user,
+		Password:             password,
+		PrivateKey:           query["private_key"],
+		PrivateKeyPassphrase: query.Get("private_key_passphrase"),
+		HostKey:              query["host_key"],
+		HostKeyAlgorithms:    query["host_key_algorithms"],
+		ClientVersion:        query.Get("client_version"),
+	}
+	return option.Outbound{
+		Type:    C.TypeSSH,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportSSH(tag string, options *option.SSHOutboundOptions) (string, error) {
+	if options.PrivateKeyPath != "" {
+		return "", E.New("private key path is not portable")
+	}
+	query := url.Values{}
+	if len(options.PrivateKey) > 0 {
+		query["private_key"] = options.PrivateKey
+	}
+	setString(query, "private_key_passphrase", options.PrivateKeyPassphrase)
+	if len(options.HostKey) > 0 {
+		query["host_key"] = options.HostKey
+	}
+	if len(options.HostKeyAlgorithms) > 0 {
+		query["host_key_algorithms"] = options.HostKeyAlgorithms
+	}
+	setString(query, "client_version", options.ClientVersion)
+	var userinfo *url.Userinfo
+	if options.Password != "" {
+		userinfo = url.UserPassword(options.User, options.Password)
+	} else {
+		userinfo = url.User(options.User)
+	}
+	return newURL(SchemeSSH, tag, userinfo, options.ServerOptions, query), nil
+}
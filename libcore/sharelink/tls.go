@@ -0,0 +1,99 @@
+package sharelink
+
+import (
+	"net/url"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"libcore/plugin/pluginoption"
+)
+
+// https://github.com/anytls/anytls-go/blob/main/docs/uri_scheme.md
+func parseAnyTLS(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse anytls link")
+	}
+	server, err := parseServer(u, 443)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	password, _ := userPassword(u)
+	options := &option.AnyTLSOutboundOptions{
+		ServerOptions: server,
+		Password:      password,
+		OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+			TLS: &option.OutboundTLSOptions{
+				Enabled:    true,
+				ServerName: query.Get("sni"),
+				Insecure:   isTrue(query.Get("insecure")),
+			},
+		},
+	}
+	return option.Outbound{
+		Type:    C.TypeAnyTLS,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportAnyTLS(tag string, options *option.AnyTLSOutboundOptions) (string, error) {
+	query := url.Values{}
+	if tls := options.TLS; tls != nil {
+		setString(query, "sni", tls.ServerName)
+		setBool(query, "insecure", tls.Insecure)
+	}
+	return newURL(SchemeAnyTLS, tag, url.User(options.Password), options.ServerOptions, query), nil
+}
+
+// TrustTunnel has no official link format, so use the same keys as v2ray links.
+func parseTrustTunnel(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse trusttunnel link")
+	}
+	server, err := parseServer(u, 443)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	username, password := userPassword(u)
+	options := &pluginoption.TrustTunnelOutboundOptions{
+		ServerOptions: server,
+		Username:      username,
+		Password:      password,
+		HealthCheck:   isTrue(query.Get("health_check")),
+		QUIC:          isTrue(query.Get("quic")),
+	}
+	if options.QUIC {
+		options.QUICCongestionControl = query.Get("congestion_control")
+	}
+	query.Set("security", "tls")
+	options.TLS = parseV2RayTLS(query)
+	return option.Outbound{
+		Type:    pluginoption.TypeTrustTunnel,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportTrustTunnel(tag string, options *pluginoption.TrustTunnelOutboundOptions) (string, error) {
+	query := url.Values{}
+	setBool(query, "health_check", options.HealthCheck)
+	if options.QUIC {
+		query.Set("quic", "1")
+		setString(query, "congestion_control", options.QUICCongestionControl)
+	}
+	err := exportV2RayTLS(query, options.TLS)
+	if err != nil {
+		return "", err
+	}
+	if query.Get("security") != "tls" {
+		return "", E.New("trusttunnel requires TLS")
+	}
+	query.Del("security")
+	return newURL(SchemeTrustTunnel, tag, url.UserPassword(options.Username, options.Password), options.ServerOptions, query), nil
+}
@@ -0,0 +1,430 @@
+package sharelink
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"libcore/plugin/pluginoption"
+)
+
+// Query keys follow https://github.com/XTLS/Xray-core/discussions/716
+
+type flexibleString string
+
+func (s *flexibleString) UnmarshalJSON(content []byte) error {
+	var value string
+	if json.Unmarshal(content, &value) == nil {
+		*s = flexibleString(value)
+		return nil
+	}
+	var number float64
+	if err := json.Unmarshal(content, &number); err != nil {
+		return err
+	}
+	*s = flexibleString(strconv.FormatFloat(number, 'f', -1, 64))
+	return nil
+}
+
+// https://github.com/2dust/v2rayN/wiki/Description-of-VMess-share-link
+type vmessLink struct {
+	Version     flexibleString `json:"v"`
+	Name        flexibleString `json:"ps"`
+	Address     flexibleString `json:"add"`
+	Port        flexibleString `json:"port"`
+	ID          flexibleString `json:"id"`
+	AlterID     flexibleString `json:"aid"`
+	Security    flexibleString `json:"scy"`
+	Network     flexibleString `json:"net"`
+	Type        flexibleString `json:"type"`
+	Host        flexibleString `json:"host"`
+	Path        flexibleString `json:"path"`
+	TLS         flexibleString `json:"tls"`
+	SNI         flexibleString `json:"sni,omitempty"`
+	ALPN        flexibleString `json:"alpn,omitempty"`
+	Fingerprint flexibleString `json:"fp,omitempty"`
+	Insecure    flexibleString `json:"allowInsecure,omitempty"`
+}
+
+func parseVMess(link string) (option.Outbound, error) {
+	decoded, err := DecodeBase64(link[len(SchemeVMess+"://"):])
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "decode vmess link")
+	}
+	var vmess vmessLink
+	err = json.Unmarshal(decoded, &vmess)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "unmarshal vmess link")
+	}
+	port, err := ParsePort(string(vmess.Port))
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	options := &option.VMessOutboundOptions{
+		ServerOptions: option.ServerOptions{
+			Server:     string(vmess.Address),
+			ServerPort: port,
+		},
+		UUID:     string(vmess.ID),
+		Security: string(vmess.Security),
+	}
+	if options.Security == "" {
+		options.Security = "auto"
+	}
+	if vmess.AlterID != "" {
+		options.AlterId, err = strconv.Atoi(string(vmess.AlterID))
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "parse alter id")
+		}
+	}
+	query := url.Values{}
+	setString(query, "type", string(vmess.Network))
+	setString(query, "headerType", string(vmess.Type))
+	setString(query, "host", string(vmess.Host))
+	if vmess.Network == "grpc" {
+		setString(query, "serviceName", string(vmess.Path))
+	} else {
+		setString(query, "path", string(vmess.Path))
+	}
+	setString(query, "security", string(vmess.TLS))
+	setString(query, "sni", string(vmess.SNI))
+	setString(query, "alpn", string(vmess.ALPN))
+	setString(query, "fp", string(vmess.Fingerprint))
+	setString(query, "allowInsecure", string(vmess.Insecure))
+	options.Transport, err = parseV2RayTransport(query)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	options.TLS = parseV2RayTLS(query)
+	return option.Outbound{
+		Type:    C.TypeVMess,
+		Tag:     string(vmess.Name),
+		Options: options,
+	}, nil
+}
+
+func exportVMess(tag string, options *option.VMessOutboundOptions) (string, error) {
+	query := url.Values{}
+	err := exportV2RayTransport(query, options.Transport)
+	if err != nil {
+		return "", err
+	}
+	err = exportV2RayTLS(query, options.TLS)
+	if err != nil {
+		return "", err
+	}
+	if query.Get("security") == "reality" {
+		return "", E.New("reality is not supported by vmess")
+	}
+	vmess := vmessLink{
+		Version:     "2",
+		Name:        flexibleString(tag),
+		Address:     flexibleString(options.Server),
+		Port:        flexibleString(strconv.Itoa(int(options.ServerPort))),
+		ID:          flexibleString(options.UUID),
+		AlterID:     flexibleString(strconv.Itoa(options.AlterId)),
+		Security:    flexibleString(options.Security),
+		Network:     flexibleString(query.Get("type")),
+		Type:        flexibleString(query.Get("headerType")),
+		Host:        flexibleString(query.Get("host")),
+		Path:        flexibleString(query.Get("path")),
+		TLS:         flexibleString(query.Get("security")),
+		SNI:         flexibleString(query.Get("sni")),
+		ALPN:        flexibleString(query.Get("alpn")),
+		Fingerprint: flexibleString(query.Get("fp")),
+		Insecure:    flexibleString(query.Get("allowInsecure")),
+	}
+	if vmess.Network == "grpc" {
+		vmess.Path = flexibleString(query.Get("serviceName"))
+	}
+	if vmess.TLS == "none" {
+		vmess.TLS = ""
+	}
+	content, err := json.Marshal(vmess)
+	if err != nil {
+		return "", err
+	}
+	return SchemeVMess + "://" + base64.StdEncoding.EncodeToString(content), nil
+}
+
+func parseVLESS(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse vless link")
+	}
+	server, err := parseServer(u, 0)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	username, _ := userPassword(u)
+	options := &pluginoption.VLESSOutboundOptions{
+		VLESSOutboundOptions: option.VLESSOutboundOptions{
+			ServerOptions: server,
+			UUID:          username,
+			Flow:          query.Get("flow"),
+		},
+	}
+	if encryption := query.Get("encryption"); encryption != "none" {
+		options.Encryption = encryption
+	}
+	if packetEncoding := query.Get("packetEncoding"); packetEncoding != "" {
+		options.PacketEncoding = &packetEncoding
+	}
+	options.Transport, err = parseV2RayTransport(query)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	options.TLS = parseV2RayTLS(query)
+	return option.Outbound{
+		Type:    C.TypeVLESS,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportVLESS(tag string, options *pluginoption.VLESSOutboundOptions) (string, error) {
+	query := url.Values{}
+	if options.Encryption != "" {
+		query.Set("encryption", options.Encryption)
+	} else {
+		query.Set("encryption", "none")
+	}
+	setString(query, "flow", options.Flow)
+	if options.PacketEncoding != nil {
+		setString(query, "packetEncoding", *options.PacketEncoding)
+	}
+	err := exportV2RayTransport(query, options.Transport)
+	if err != nil {
+		return "", err
+	}
+	err = exportV2RayTLS(query, options.TLS)
+	if err != nil {
+		return "", err
+	}
+	return newURL(SchemeVLESS, tag, url.User(options.UUID), options.ServerOptions, query), nil
+}
+
+func parseTrojan(link string) (option.Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse trojan link")
+	}
+	server, err := parseServer(u, 443)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := u.Query()
+	if query.Get("security") == "" {
+		// Trojan uses TLS by default.
+		query.Set("security", "tls")
+	}
+	password, _ := userPassword(u)
+	options := &option.TrojanOutboundOptions{
+		ServerOptions: server,
+		Password:      password,
+	}
+	options.Transport, err = parseV2RayTransport(query)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	options.TLS = parseV2RayTLS(query)
+	return option.Outbound{
+		Type:    C.TypeTrojan,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportTrojan(tag string, options *option.TrojanOutboundOptions) (string, error) {
+	query := url.Values{}
+	err := exportV2RayTransport(query, options.Transport)
+	if err != nil {
+		return "", err
+	}
+	err = exportV2RayTLS(query, options.TLS)
+	if err != nil {
+		return "", err
+	}
+	return newURL(SchemeTrojan, tag, url.User(options.Password), options.ServerOptions, query), nil
+}
+
+const earlyDataHeaderName = "Sec-WebSocket-Protocol"
+
+// parseV2RayTransport converts share link parameters to transport.
+// Returns nil if using raw TCP.
+func parseV2RayTransport(query url.Values) (*option.V2RayTransportOptions, error) {
+	host := query.Get("host")
+	path := query.Get("path")
+	switch transportType := query.Get("type"); transportType {
+	case "", "tcp", "raw":
+		if query.Get("headerType") != "http" {
+			return nil, nil
+		}
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host:   splitList(host),
+				Path:   path,
+				Method: "GET",
+			},
+		}, nil
+	case "http", "h2":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: splitList(host),
+				Path: path,
+			},
+		}, nil
+	case "ws", "websocket":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+		}
+		// Xray puts early data in path like `/ws?ed=2048`.
+		if rawPath, rawQuery, found := strings.Cut(path, "?"); found {
+			pathQuery, err := url.ParseQuery(rawQuery)
+			if err == nil && pathQuery.Has("ed") {
+				maxEarlyData, err := strconv.ParseUint(pathQuery.Get("ed"), 10, 32)
+				if err != nil {
+					return nil, E.Cause(err, "parse early data")
+				}
+				transport.WebsocketOptions.MaxEarlyData = uint32(maxEarlyData)
+				transport.WebsocketOptions.EarlyDataHeaderName = earlyDataHeaderName
+				pathQuery.Del("ed")
+				path = rawPath
+				if len(pathQuery) > 0 {
+					path += "?" + pathQuery.Encode()
+				}
+			}
+		}
+		transport.WebsocketOptions.Path = path
+		if host != "" {
+			transport.WebsocketOptions.Headers = badoption.HTTPHeader{"Host": {host}}
+		}
+		return transport, nil
+	case "httpupgrade":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTPUpgrade,
+			HTTPUpgradeOptions: option.V2RayHTTPUpgradeOptions{
+				Host: host,
+				Path: path,
+			},
+		}, nil
+	case "grpc":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName: query.Get("serviceName"),
+			},
+		}, nil
+	case "quic":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeQUIC,
+		}, nil
+	default:
+		return nil, E.New("unsupported transport: ", transportType)
+	}
+}
+
+func exportV2RayTransport(query url.Values, transport *option.V2RayTransportOptions) error {
+	if transport == nil {
+		query.Set("type", "tcp")
+		return nil
+	}
+	switch transport.Type {
+	case C.V2RayTransportTypeHTTP:
+		if transport.HTTPOptions.Method == "GET" {
+			query.Set("type", "tcp")
+			query.Set("headerType", "http")
+		} else {
+			query.Set("type", "http")
+		}
+		setString(query, "host", strings.Join(transport.HTTPOptions.Host, ","))
+		setString(query, "path", transport.HTTPOptions.Path)
+	case C.V2RayTransportTypeWebsocket:
+		query.Set("type", "ws")
+		path := transport.WebsocketOptions.Path
+		if transport.WebsocketOptions.MaxEarlyData > 0 {
+			if transport.WebsocketOptions.EarlyDataHeaderName != earlyDataHeaderName {
+				return E.New("early data header is not supported: ", transport.WebsocketOptions.EarlyDataHeaderName)
+			}
+			separator := "?"
+			if strings.Contains(path, "?") {
+				separator = "&"
+			}
+			path += separator + "ed=" + strconv.FormatUint(uint64(transport.WebsocketOptions.MaxEarlyData), 10)
+		}
+		setString(query, "path", path)
+		setString(query, "host", transport.WebsocketOptions.Headers.Build().Get("Host"))
+	case C.V2RayTransportTypeHTTPUpgrade:
+		query.Set("type", "httpupgrade")
+		setString(query, "host", transport.HTTPUpgradeOptions.Host)
+		setString(query, "path", transport.HTTPUpgradeOptions.Path)
+	case C.V2RayTransportTypeGRPC:
+		query.Set("type", "grpc")
+		setString(query, "serviceName", transport.GRPCOptions.ServiceName)
+	case C.V2RayTransportTypeQUIC:
+		query.Set("type", "quic")
+	default:
+		return E.New("unsupported transport: ", transport.Type)
+	}
+	return nil
+}
+
+// parseV2RayTLS converts share link parameters to TLS options.
+// Returns nil if TLS disabled.
+func parseV2RayTLS(query url.Values) *option.OutboundTLSOptions {
+	security := query.Get("security")
+	if security == "" || security == "none" {
+		return nil
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: query.Get("sni"),
+		Insecure:   isTrue(query.Get("allowInsecure")) || isTrue(query.Get("insecure")),
+		ALPN:       splitList(query.Get("alpn")),
+	}
+	if fingerprint := query.Get("fp"); fingerprint != "" {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	if security == "reality" {
+		options.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: query.Get("pbk"),
+			ShortID:   query.Get("sid"),
+		}
+	}
+	return options
+}
+
+func exportV2RayTLS(query url.Values, options *option.OutboundTLSOptions) error {
+	if options == nil || !options.Enabled {
+		query.Set("security", "none")
+		return nil
+	}
+	if options.Reality != nil && options.Reality.Enabled {
+		query.Set("security", "reality")
+		setString(query, "pbk", options.Reality.PublicKey)
+		setString(query, "sid", options.Reality.ShortID)
+	} else {
+		query.Set("security", "tls")
+	}
+	setString(query, "sni", options.ServerName)
+	setBool(query, "allowInsecure", options.Insecure)
+	setString(query, "alpn", strings.Join(options.ALPN, ","))
+	if options.UTLS != nil && options.UTLS.Enabled {
+		setString(query, "fp", options.UTLS.Fingerprint)
+	}
+	return nil
+}
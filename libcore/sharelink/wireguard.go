@@ -0,0 +1,143 @@
+package sharelink
+
+import (
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+// Same as v2rayN: wireguard://private_key@host:port?publickey=&address=&reserved=&mtu=
+func parseWireGuard(link string) (option.Endpoint, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return option.Endpoint{}, E.Cause(err, "parse wireguard link")
+	}
+	server, err := parseServer(u, 51820)
+	if err != nil {
+		return option.Endpoint{}, err
+	}
+	query := u.Query()
+	privateKey, _ := userPassword(u)
+	options := &option.WireGuardEndpointOptions{
+		PrivateKey: privateKey,
+	}
+	options.Address, err = parsePrefixes(query.Get("address"))
+	if err != nil {
+		return option.Endpoint{}, E.Cause(err, "parse address")
+	}
+	if mtu := query.Get("mtu"); mtu != "" {
+		value, err := strconv.ParseUint(mtu, 10, 32)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse mtu")
+		}
+		options.MTU = uint32(value)
+	}
+	peer := option.WireGuardPeer{
+		Address:      server.Server,
+		Port:         server.ServerPort,
+		PublicKey:    query.Get("publickey"),
+		PreSharedKey: query.Get("presharedkey"),
+	}
+	if allowedIPs := query.Get("allowedips"); allowedIPs != "" {
+		peer.AllowedIPs, err = parsePrefixes(allowedIPs)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse allowed ips")
+		}
+	} else {
+		peer.AllowedIPs = badoption.Listable[netip.Prefix]{
+			netip.MustParsePrefix("0.0.0.0/0"),
+			netip.MustParsePrefix("::/0"),
+		}
+	}
+	if keepalive := query.Get("keepalive"); keepalive != "" {
+		value, err := strconv.ParseUint(keepalive, 10, 16)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse keepalive")
+		}
+		peer.PersistentKeepaliveInterval = uint16(value)
+	}
+	if reserved := query.Get("reserved"); reserved != "" {
+		for _, field := range strings.Split(reserved, ",") {
+			value, err := strconv.ParseUint(strings.TrimSpace(field), 10, 8)
+			if err != nil {
+				return option.Endpoint{}, E.Cause(err, "parse reserved")
+			}
+			peer.Reserved = append(peer.Reserved, uint8(value))
+		}
+	}
+	options.Peers = []option.WireGuardPeer{peer}
+	return option.Endpoint{
+		Type:    C.TypeWireGuard,
+		Tag:     u.Fragment,
+		Options: options,
+	}, nil
+}
+
+func exportWireGuard(tag string, options *option.WireGuardEndpointOptions) (string, error) {
+	if len(options.Peers) != 1 {
+		return "", E.New("only one peer is supported")
+	}
+	peer := options.Peers[0]
+	query := url.Values{}
+	setString(query, "publickey", peer.PublicKey)
+	setString(query, "presharedkey", peer.PreSharedKey)
+	setString(query, "address", formatPrefixes(options.Address))
+	setString(query, "allowedips", formatPrefixes(peer.AllowedIPs))
+	if options.MTU > 0 {
+		query.Set("mtu", strconv.FormatUint(uint64(options.MTU), 10))
+	}
+	if peer.PersistentKeepaliveInterval > 0 {
+		query.Set("keepalive", strconv.FormatUint(uint64(peer.PersistentKeepaliveInterval), 10))
+	}
+	if len(peer.Reserved) > 0 {
+		reserved := make([]string, 0, len(peer.Reserved))
+		for _, value := range peer.Reserved {
+			reserved = append(reserved, strconv.Itoa(int(value)))
+		}
+		query.Set("reserved", strings.Join(reserved, ","))
+	}
+	server := option.ServerOptions{
+		Server:     peer.Address,
+		ServerPort: peer.Port,
+	}
+	return newURL(SchemeWireGuard, tag, url.User(options.PrivateKey), server, query), nil
+}
+
+// parsePrefixes parses comma separated prefixes. Bare address is treated as a single host.
+func parsePrefixes(value string) (badoption.Listable[netip.Prefix], error) {
+	if value == "" {
+		return nil, nil
+	}
+	var prefixes badoption.Listable[netip.Prefix]
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if !strings.Contains(field, "/") {
+			address, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(address, address.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func formatPrefixes(prefixes []netip.Prefix) string {
+	values := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		values = append(values, prefix.String())
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,128 @@
+package libcore
+
+import (
+	"context"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/subscription"
+)
+
+const (
+	SubscriptionFormatUnknown  = int32(subscription.FormatUnknown)
+	SubscriptionFormatLinkList = int32(subscription.FormatLinkList)
+	SubscriptionFormatClash    = int32(subscription.FormatClash)
+	SubscriptionFormatSingBox  = int32(subscription.FormatSingBox)
+	SubscriptionFormatSIP008   = int32(subscription.FormatSIP008)
+	SubscriptionFormatOOCv1    = int32(subscription.FormatOOCv1)
+	SubscriptionFormatXray     = int32(subscription.FormatXray)
+)
+
+// SubscriptionResult is the parsed subscription.
+type SubscriptionResult struct {
+	Format int32
+
+	// Outbounds is JSON array of sing-box outbounds.
+	Outbounds string
+	// Endpoints is JSON array of sing-box endpoints.
+	Endpoints string
+
+	// HasUserinfo reports whether the quota fields are available.
+	HasUserinfo bool
+	Upload      int64
+	Download    int64
+	Total       int64
+	// Expire is unix seconds. Zero means never.
+	Expire int64
+
+	warnings []string
+}
+
+func (s *SubscriptionResult) GetWarnings() StringIterator {
+	return newIterator(s.warnings)
+}
+
+// FetchSubscription downloads link by client and parses it.
+// link could also be an OOCv1 token, then its certificate sha256 is also accepted for this request,
+// besides certificates trusted by root CA. client itself is not changed.
+func FetchSubscription(client HTTPClient, link, userAgent string) (*SubscriptionResult, error) {
+	request := client.NewRequest()
+	if token, err := subscription.ParseOOCv1Token(link); err == nil {
+		link, err = token.APIURL()
+		if err != nil {
+			return nil, err
+		}
+		if token.CertSha256 != "" {
+			err = request.SetPinnedSHA256(token.CertSha256)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err := request.SetURL(link)
+	if err != nil {
+		return nil, E.Cause(err, "set URL")
+	}
+	if userAgent != "" {
+		request.SetUserAgent(userAgent)
+	}
+	response, err := request.Execute()
+	if err != nil {
+		return nil, E.Cause(err, "fetch subscription")
+	}
+	defer response.Close()
+	content, err := response.GetContentString()
+	if err != nil {
+		return nil, E.Cause(err, "read subscription")
+	}
+	return parseSubscription(content, response.GetHeader(subscription.HeaderUserinfo))
+}
+
+// ParseSubscription parses subscription content without fetching.
+func ParseSubscription(content string) (*SubscriptionResult, error) {
+	return parseSubscription(content, "")
+}
+
+func parseSubscription(content, userinfoHeader string) (*SubscriptionResult, error) {
+	ctx := baseContext(nil)
+	parsed, err := subscription.Parse(ctx, []byte(content))
+	if err != nil {
+		return nil, err
+	}
+	if userinfoHeader != "" {
+		// Header is more reliable than the one in content.
+		userinfo, err := subscription.ParseUserinfo(userinfoHeader)
+		if err != nil {
+			parsed.Warnings = append(parsed.Warnings, E.Cause(err, "parse ", subscription.HeaderUserinfo).Error())
+		} else {
+			parsed.Userinfo = userinfo
+		}
+	}
+	return buildSubscriptionResult(ctx, parsed)
+}
+
+func buildSubscriptionResult(ctx context.Context, parsed *subscription.Subscription) (*SubscriptionResult, error) {
+	outbounds, err := json.MarshalContext(ctx, parsed.Outbounds)
+	if err != nil {
+		return nil, E.Cause(err, "encode outbounds")
+	}
+	endpoints, err := json.MarshalContext(ctx, parsed.Endpoints)
+	if err != nil {
+		return nil, E.Cause(err, "encode endpoints")
+	}
+	result := &SubscriptionResult{
+		Format:    int32(parsed.Format),
+		Outbounds: string(outbounds),
+		Endpoints: string(endpoints),
+		warnings:  parsed.Warnings,
+	}
+	if userinfo := parsed.Userinfo; userinfo != nil {
+		result.HasUserinfo = true
+		result.Upload = userinfo.Upload
+		result.Download = userinfo.Download
+		result.Total = userinfo.Total
+		result.Expire = userinfo.Expire
+	}
+	return result, nil
+}
@@ -0,0 +1,18 @@
+package subscription
+
+import (
+	"libcore/clash"
+)
+
+func parseClash(content []byte) (*Subscription, error) {
+	result, err := clash.ConvertProxies(content)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription{
+		Format:    FormatClash,
+		Outbounds: result.Options.Outbounds,
+		Endpoints: result.Options.Endpoints,
+		Warnings:  result.Warnings,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package subscription
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"libcore/sharelink"
+)
+
+func parseLinkList(content []byte) (*Subscription, error) {
+	if decoded, err := sharelink.DecodeBase64(string(content)); err == nil {
+		content = decoded
+	}
+	subscription := &Subscription{Format: FormatLinkList}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if sharelink.IsEndpoint(line) {
+			endpoint, err := sharelink.ParseEndpoint(line)
+			if err != nil {
+				subscription.Warnings = append(subscription.Warnings, E.Cause(err, truncate(line)).Error())
+				continue
+			}
+			subscription.Endpoints = append(subscription.Endpoints, endpoint)
+			continue
+		}
+		outbound, err := sharelink.Parse(line)
+		if err != nil {
+			subscription.Warnings = append(subscription.Warnings, E.Cause(err, truncate(line)).Error())
+			continue
+		}
+		subscription.Outbounds = append(subscription.Outbounds, outbound)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, E.Cause(err, "read link list")
+	}
+	return subscription, nil
+}
+
+func truncate(s string) string {
+	const limit = 64
+	if len(s) > limit {
+		return s[:limit] + "..."
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package subscription
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+// https://github.com/Shadowsocks-NET/OpenOnlineConfig/blob/master/docs/0001-open-online-config-v1.md
+
+// OOCv1Token is the API access information shared to users.
+type OOCv1Token struct {
+	Version    int    `json:"version"`
+	BaseURL    string `json:"baseUrl"`
+	Secret     string `json:"secret"`
+	UserID     string `json:"userId"`
+	CertSha256 string `json:"certSha256"`
+}
+
+// ParseOOCv1Token parses the token JSON. It returns error if content is not a token.
+func ParseOOCv1Token(content string) (*OOCv1Token, error) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "{") {
+		return nil, E.New("not an OOCv1 token")
+	}
+	var token OOCv1Token
+	err := json.Unmarshal([]byte(content), &token)
+	if err != nil {
+		return nil, E.Cause(err, "decode OOCv1 token")
+	}
+	if token.Version != 1 {
+		return nil, E.New("unsupported OOCv1 version: ", token.Version)
+	}
+	if token.BaseURL == "" || token.Secret == "" || token.UserID == "" {
+		return nil, E.New("incomplete OOCv1 token")
+	}
+	return &token, nil
+}
+
+// APIURL returns the URL of `{baseUrl}/{secret}/ooc/v1/{userId}`.
+func (t *OOCv1Token) APIURL() (string, error) {
+	baseURL, err := url.Parse(t.BaseURL)
+	if err != nil {
+		return "", E.Cause(err, "parse base URL")
+	}
+	if baseURL.Scheme != "https" {
+		return "", E.New("OOCv1 requires HTTPS")
+	}
+	return baseURL.JoinPath(t.Secret, "ooc", "v1", t.UserID).String(), nil
+}
+
+type oocv1 struct {
+	Username       string             `json:"username"`
+	BytesUsed      *int64             `json:"bytesUsed"`
+	BytesRemaining *int64             `json:"bytesRemaining"`
+	ExpiryDate     string             `json:"expiryDate"`
+	Protocols      []string           `json:"protocols"`
+	Shadowsocks    []oocv1Shadowsocks `json:"shadowsocks"`
+}
+
+type oocv1Shadowsocks struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Address         string `json:"address"`
+	Port            uint16 `json:"port"`
+	Method          string `json:"method"`
+	Password        string `json:"password"`
+	PluginName      string `json:"pluginName"`
+	PluginOptions   string `json:"pluginOptions"`
+	PluginArguments string `json:"pluginArguments"`
+}
+
+func parseOOCv1(content []byte) (*Subscription, error) {
+	var document oocv1
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, E.Cause(err, "decode OOCv1")
+	}
+	subscription := &Subscription{Format: FormatOOCv1}
+	for _, protocol := range document.Protocols {
+		if protocol != "shadowsocks" {
+			subscription.Warnings = append(subscription.Warnings, "unsupported protocol: "+protocol)
+		}
+	}
+	for i, server := range document.Shadowsocks {
+		if server.Address == "" || server.Port == 0 {
+			subscription.Warnings = append(subscription.Warnings, E.New("server ", i, ": missing address").Error())
+			continue
+		}
+		tag := server.Name
+		if tag == "" {
+			tag = server.Address + ":" + strconv.Itoa(int(server.Port))
+		}
+		options := &option.ShadowsocksOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     server.Address,
+				ServerPort: server.Port,
+			},
+			Method:        server.Method,
+			Password:      server.Password,
+			Plugin:        server.PluginName,
+			PluginOptions: server.PluginOptions,
+		}
+		if options.Plugin == "simple-obfs" {
+			options.Plugin = "obfs-local"
+		}
+		subscription.Outbounds = append(subscription.Outbounds, option.Outbound{
+			Type:    C.TypeShadowsocks,
+			Tag:     tag,
+			Options: options,
+		})
+	}
+	if document.BytesUsed != nil || document.BytesRemaining != nil || document.ExpiryDate != "" {
+		userinfo := &Userinfo{}
+		if document.BytesUsed != nil {
+			userinfo.Download = *document.BytesUsed
+		}
+		if document.BytesRemaining != nil {
+			userinfo.Total = userinfo.Download + *document.BytesRemaining
+		}
+		if document.ExpiryDate != "" {
+			expiry, err := time.Parse(time.RFC3339, document.ExpiryDate)
+			if err != nil {
+				subscription.Warnings = append(subscription.Warnings, E.Cause(err, "parse expiry date").Error())
+			} else {
+				userinfo.Expire = expiry.Unix()
+			}
+		}
+		subscription.Userinfo = userinfo
+	}
+	return subscription, nil
+}
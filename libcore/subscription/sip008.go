@@ -0,0 +1,83 @@
+package subscription
+
+import (
+	"strconv"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+// https://shadowsocks.org/doc/sip008.html
+type sip008 struct {
+	Version        int            `json:"version"`
+	Servers        []sip008Server `json:"servers"`
+	BytesUsed      *int64         `json:"bytes_used"`
+	BytesRemaining *int64         `json:"bytes_remaining"`
+}
+
+type sip008Server struct {
+	ID         string `json:"id"`
+	Remarks    string `json:"remarks"`
+	Server     string `json:"server"`
+	ServerPort uint16 `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+}
+
+func parseSIP008(content []byte) (*Subscription, error) {
+	var document sip008
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, E.Cause(err, "decode SIP008")
+	}
+	if document.Version != 1 {
+		return nil, E.New("unsupported SIP008 version: ", document.Version)
+	}
+	subscription := &Subscription{Format: FormatSIP008}
+	for i, server := range document.Servers {
+		tag := server.Remarks
+		if tag == "" {
+			tag = server.Server + ":" + strconv.Itoa(int(server.ServerPort))
+		}
+		if server.Server == "" || server.ServerPort == 0 {
+			subscription.Warnings = append(subscription.Warnings, E.New("server ", i, ": missing address").Error())
+			continue
+		}
+		options := &option.ShadowsocksOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     server.Server,
+				ServerPort: server.ServerPort,
+			},
+			Method:        server.Method,
+			Password:      server.Password,
+			Plugin:        server.Plugin,
+			PluginOptions: server.PluginOpts,
+		}
+		if options.Plugin == "simple-obfs" {
+			options.Plugin = "obfs-local"
+		}
+		subscription.Outbounds = append(subscription.Outbounds, option.Outbound{
+			Type:    C.TypeShadowsocks,
+			Tag:     tag,
+			Options: options,
+		})
+	}
+	if document.BytesUsed != nil || document.BytesRemaining != nil {
+		var used, remaining int64
+		if document.BytesUsed != nil {
+			used = *document.BytesUsed
+		}
+		if document.BytesRemaining != nil {
+			remaining = *document.BytesRemaining
+		}
+		subscription.Userinfo = &Userinfo{
+			Download: used,
+			Total:    used + remaining,
+		}
+	}
+	return subscription, nil
+}
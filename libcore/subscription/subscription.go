@@ -0,0 +1,163 @@
+// Package subscription parses subscription contents into sing-box outbounds and endpoints.
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"libcore/clash"
+	"libcore/plugin/raybridge"
+)
+
+type Format uint8
+
+const (
+	FormatUnknown Format = iota
+	FormatLinkList
+	FormatClash
+	FormatSingBox
+	FormatSIP008
+	FormatOOCv1
+	FormatXray
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatLinkList:
+		return "Link list"
+	case FormatClash:
+		return "Clash"
+	case FormatSingBox:
+		return "sing-box"
+	case FormatSIP008:
+		return "SIP008"
+	case FormatOOCv1:
+		return "OOCv1"
+	case FormatXray:
+		return "Xray"
+	default:
+		return "Unknown"
+	}
+}
+
+// Subscription is the normalized result of a subscription.
+type Subscription struct {
+	Format    Format
+	Outbounds []option.Outbound
+	Endpoints []option.Endpoint
+	Userinfo  *Userinfo
+
+	// Warnings records items that were skipped.
+	Warnings []string
+}
+
+// Parse detects the format of content and converts it to outbounds.
+// ctx should contain the option registries when content is sing-box configuration.
+func Parse(ctx context.Context, content []byte) (*Subscription, error) {
+	content = bytes.TrimSpace(trimBOM(content))
+	if len(content) == 0 {
+		return nil, E.New("empty subscription")
+	}
+	var (
+		subscription *Subscription
+		err          error
+	)
+	switch detectFormat(content) {
+	case FormatSingBox:
+		subscription, err = parseSingBox(ctx, content)
+	case FormatSIP008:
+		subscription, err = parseSIP008(content)
+	case FormatOOCv1:
+		subscription, err = parseOOCv1(content)
+	case FormatClash:
+		subscription, err = parseClash(content)
+	case FormatXray:
+		subscription, err = parseXray(content)
+	case FormatLinkList:
+		subscription, err = parseLinkList(content)
+	default:
+		return nil, E.New("unknown subscription format")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(subscription.Outbounds) == 0 && len(subscription.Endpoints) == 0 {
+		return nil, E.New("no outbound found in ", subscription.Format, " subscription")
+	}
+	uniqueTags(subscription.Outbounds, subscription.Endpoints)
+	return subscription, nil
+}
+
+func detectFormat(content []byte) Format {
+	if content[0] == '{' {
+		var probe struct {
+			Outbounds   json.RawMessage `json:"outbounds"`
+			Servers     json.RawMessage `json:"servers"`
+			Shadowsocks json.RawMessage `json:"shadowsocks"`
+			Protocols   json.RawMessage `json:"protocols"`
+		}
+		if json.Unmarshal(content, &probe) != nil {
+			return FormatUnknown
+		}
+		switch {
+		case probe.Outbounds != nil && raybridge.IsConfig(content):
+			return FormatXray
+		case probe.Outbounds != nil:
+			return FormatSingBox
+		case probe.Servers != nil:
+			return FormatSIP008
+		case probe.Shadowsocks != nil, probe.Protocols != nil:
+			return FormatOOCv1
+		default:
+			return FormatUnknown
+		}
+	}
+	if clash.IsProfile(content) {
+		return FormatClash
+	}
+	return FormatLinkList
+}
+
+func parseSingBox(ctx context.Context, content []byte) (*Subscription, error) {
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, content)
+	if err != nil {
+		return nil, E.Cause(err, "decode sing-box configuration")
+	}
+	return &Subscription{
+		Format:    FormatSingBox,
+		Outbounds: options.Outbounds,
+		Endpoints: options.Endpoints,
+	}, nil
+}
+
+func trimBOM(content []byte) []byte {
+	return bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+}
+
+// uniqueTags makes sure every outbound and endpoint has a non-empty and unique tag,
+// which is required by sing-box.
+func uniqueTags(outbounds []option.Outbound, endpoints []option.Endpoint) {
+	used := make(map[string]bool, len(outbounds)+len(endpoints))
+	unique := func(tag, fallback string) string {
+		if tag == "" {
+			tag = fallback
+		}
+		unique := tag
+		for n := 2; used[unique]; n++ {
+			unique = tag + " (" + strconv.Itoa(n) + ")"
+		}
+		used[unique] = true
+		return unique
+	}
+	for i := range outbounds {
+		outbounds[i].Tag = unique(outbounds[i].Tag, outbounds[i].Type)
+	}
+	for i := range endpoints {
+		endpoints[i].Tag = unique(endpoints[i].Tag, endpoints[i].Type)
+	}
+}
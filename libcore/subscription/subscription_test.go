@@ -0,0 +1,145 @@
+package subscription
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+func Test_Parse(t *testing.T) {
+	links := "ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ@example.com:8388#ss\n" +
+		"vless://b831381d-6324-4d53-ad4f-8cda48b30811@example.com:443?type=ws&path=%2Fws&security=tls&sni=sni.example.com&encryption=none#vless\n" +
+		"hysteria2://auth@example.com:443,8000-9000/?sni=example.com#hy2\n" +
+		"wireguard://eCtXsJZ27%2B4PbhDkHnB923tkUn2Gj59wZw5wFA75MnU%3D@example.com:2408?address=172.16.0.2&publickey=bmXOC%2BF1FxEMF9dyiK2H5%2F1SUtzH0JuVo51h2wPfgyo%3D#wg\n" +
+		"unknown://foo\n"
+	tests := []struct {
+		name      string
+		content   string
+		format    Format
+		outbounds []string
+		endpoints int
+		warnings  int
+	}{
+		{
+			name:      "Plain links",
+			content:   links,
+			format:    FormatLinkList,
+			outbounds: []string{C.TypeShadowsocks, C.TypeVLESS, C.TypeHysteria2},
+			endpoints: 1,
+			warnings:  1,
+		},
+		{
+			name:      "Base64 links",
+			content:   base64.StdEncoding.EncodeToString([]byte(links)),
+			format:    FormatLinkList,
+			outbounds: []string{C.TypeShadowsocks, C.TypeVLESS, C.TypeHysteria2},
+			endpoints: 1,
+			warnings:  1,
+		},
+		{
+			name: "Clash",
+			content: `
+mixed-port: 7890
+proxies:
+  - name: trojan
+    type: trojan
+    server: example.com
+    port: 443
+    password: password
+    network: ws
+    ws-opts:
+      path: /ws
+  - name: snell
+    type: snell
+    server: example.com
+    port: 443
+`,
+			format:    FormatClash,
+			outbounds: []string{C.TypeTrojan},
+			warnings:  1,
+		},
+		{
+			name:      "Xray",
+			content:   `{"outbounds":[{"protocol":"trojan","tag":"a","settings":{"servers":[{"address":"example.com","port":443,"password":"p"}]},"streamSettings":{"security":"tls"}},{"protocol":"freedom","tag":"direct"}]}`,
+			format:    FormatXray,
+			outbounds: []string{C.TypeTrojan},
+		},
+		{
+			name:      "SIP008",
+			content:   `{"version":1,"servers":[{"id":"1","remarks":"a","server":"example.com","server_port":8388,"password":"p","method":"aes-128-gcm"}],"bytes_used":1,"bytes_remaining":2}`,
+			format:    FormatSIP008,
+			outbounds: []string{C.TypeShadowsocks},
+		},
+		{
+			name:      "OOCv1",
+			content:   `{"username":"u","bytesUsed":1,"bytesRemaining":2,"expiryDate":"2030-01-01T00:00:00Z","protocols":["shadowsocks","trojan"],"shadowsocks":[{"id":"1","name":"a","address":"example.com","port":8388,"method":"aes-128-gcm","password":"p"}]}`,
+			format:    FormatOOCv1,
+			outbounds: []string{C.TypeShadowsocks},
+			warnings:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription, err := Parse(context.Background(), []byte(tt.content))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if subscription.Format != tt.format {
+				t.Errorf("format = %s, want %s", subscription.Format, tt.format)
+			}
+			if len(subscription.Outbounds) != len(tt.outbounds) {
+				t.Fatalf("got %d outbounds, want %d", len(subscription.Outbounds), len(tt.outbounds))
+			}
+			for i, outbound := range subscription.Outbounds {
+				if outbound.Type != tt.outbounds[i] {
+					t.Errorf("outbound %d type = %s, want %s", i, outbound.Type, tt.outbounds[i])
+				}
+			}
+			if len(subscription.Endpoints) != tt.endpoints {
+				t.Errorf("got %d endpoints, want %d", len(subscription.Endpoints), tt.endpoints)
+			}
+			if len(subscription.Warnings) != tt.warnings {
+				t.Errorf("warnings = %v, want %d", subscription.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func Test_ParseUserinfo(t *testing.T) {
+	userinfo, err := ParseUserinfo("upload=455727941; download=6174315083; total=1073741824000; expire=1671815872")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Userinfo{
+		Upload:   455727941,
+		Download: 6174315083,
+		Total:    1073741824000,
+		Expire:   1671815872,
+	}
+	if *userinfo != want {
+		t.Errorf("got %+v, want %+v", *userinfo, want)
+	}
+	_, err = ParseUserinfo("foo=bar")
+	if err == nil {
+		t.Error("want error for empty userinfo")
+	}
+}
+
+func Test_UniqueTags(t *testing.T) {
+	outbounds := []option.Outbound{
+		{Type: C.TypeSOCKS, Tag: "a"},
+		{Type: C.TypeSOCKS, Tag: "a"},
+		{Type: C.TypeSOCKS, Tag: "a (2)"},
+		{Type: C.TypeSOCKS},
+	}
+	uniqueTags(outbounds, nil)
+	want := []string{"a", "a (2)", "a (2) (2)", C.TypeSOCKS}
+	for i, outbound := range outbounds {
+		if outbound.Tag != want[i] {
+			t.Errorf("tag %d = %s, want %s", i, outbound.Tag, want[i])
+		}
+	}
+}
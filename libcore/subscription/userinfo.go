@@ -0,0 +1,71 @@
+package subscription
+
+import (
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// HeaderUserinfo is the de facto header for quota metadata.
+const HeaderUserinfo = "Subscription-Userinfo"
+
+// Userinfo is the quota metadata of a subscription.
+// Expire is unix seconds, zero means never.
+type Userinfo struct {
+	Upload   int64
+	Download int64
+	Total    int64
+	Expire   int64
+}
+
+// ParseUserinfo parses header like `upload=1; download=2; total=3; expire=4`.
+// Unknown keys are ignored.
+func ParseUserinfo(header string) (*Userinfo, error) {
+	var (
+		userinfo Userinfo
+		loaded   bool
+	)
+	for _, field := range strings.Split(header, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		var target *int64
+		switch key {
+		case "upload":
+			target = &userinfo.Upload
+		case "download":
+			target = &userinfo.Download
+		case "total":
+			target = &userinfo.Total
+		case "expire":
+			target = &userinfo.Expire
+		default:
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		// Some providers send float numbers.
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, E.Cause(err, "parse ", key)
+		}
+		*target = int64(number)
+		loaded = true
+	}
+	if !loaded {
+		return nil, E.New("empty userinfo")
+	}
+	return &userinfo, nil
+}
+
+func (u *Userinfo) String() string {
+	return "upload=" + strconv.FormatInt(u.Upload, 10) +
+		"; download=" + strconv.FormatInt(u.Download, 10) +
+		"; total=" + strconv.FormatInt(u.Total, 10) +
+		"; expire=" + strconv.FormatInt(u.Expire, 10)
+}
@@ -0,0 +1,28 @@
+package subscription
+
+import (
+	C "github.com/sagernet/sing-box/constant"
+
+	"libcore/plugin/raybridge"
+)
+
+func parseXray(content []byte) (*Subscription, error) {
+	result, err := raybridge.Convert(content)
+	if err != nil {
+		return nil, err
+	}
+	subscription := &Subscription{
+		Format:    FormatXray,
+		Endpoints: result.Endpoints,
+		Warnings:  result.Warnings,
+	}
+	// Full Xray configurations usually carry freedom and blackhole outbounds, which are not nodes.
+	for _, outbound := range result.Outbounds {
+		switch outbound.Type {
+		case C.TypeDirect, C.TypeBlock:
+		default:
+			subscription.Outbounds = append(subscription.Outbounds, outbound)
+		}
+	}
+	return subscription, nil
+}
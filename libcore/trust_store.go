@@ -0,0 +1,114 @@
+package libcore
+
+import (
+	"path/filepath"
+	"sync"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"libcore/truststore"
+)
+
+const trustStoreDir = "truststore"
+
+// TrustStore manages named CA bundles and blocked CAs.
+//
+// Profile trusts a bundle by "truststore:<name>" in certificate_path of certificate options,
+// and outbound or DNS server trusts only the bundle by it in certificate_path of TLS options.
+// Changes take effect for new instances.
+type TrustStore struct {
+	store *truststore.Store
+}
+
+func NewTrustStore() *TrustStore {
+	return &TrustStore{store: defaultTrustStore()}
+}
+
+var (
+	trustStoreAccess sync.Mutex
+	trustStorePath   string
+	trustStore       *truststore.Store
+)
+
+// defaultTrustStore returns the store in externalAssetsPath.
+// It is shared by all callers, so that changes are serialized by its lock.
+func defaultTrustStore() *truststore.Store {
+	trustStoreAccess.Lock()
+	defer trustStoreAccess.Unlock()
+	path := filepath.Join(externalAssetsPath, trustStoreDir)
+	if trustStore == nil || trustStorePath != path {
+		trustStorePath = path
+		trustStore = truststore.New(path)
+	}
+	return trustStore
+}
+
+// AddBundle saves certificates in PEM or DER as bundle name, and returns count of them.
+func (t *TrustStore) AddBundle(name, content string) (int32, error) {
+	count, err := t.store.AddBundle(name, []byte(content))
+	return int32(count), err
+}
+
+func (t *TrustStore) RemoveBundle(name string) error {
+	return t.store.RemoveBundle(name)
+}
+
+func (t *TrustStore) Bundles() (StringIterator, error) {
+	names, err := t.store.Bundles()
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(names), nil
+}
+
+// BundlePEM returns certificates of bundle, excluding blocked ones.
+func (t *TrustStore) BundlePEM(name string) (string, error) {
+	return t.store.BundlePEM(name)
+}
+
+// Block distrusts CA by base64 of its SPKI sha256, which is CertInfo.PublicKeySHA256.
+// It fails if current root store can not exclude the CA, see UpdateRootCACerts.
+func (t *TrustStore) Block(spkiHash string) error {
+	if !blocklistSupported() {
+		return E.New("blocklist is not supported by current root store")
+	}
+	err := t.store.Block(spkiHash)
+	if err != nil {
+		return err
+	}
+	applyBlocklist()
+	return nil
+}
+
+func (t *TrustStore) Unblock(spkiHash string) error {
+	err := t.store.Unblock(spkiHash)
+	if err != nil {
+		return err
+	}
+	applyBlocklist()
+	return nil
+}
+
+func (t *TrustStore) Blocklist() (StringIterator, error) {
+	blocklist, err := t.store.Blocklist()
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(blocklist), nil
+}
+
+// Export returns bundles and blocklist in JSON.
+func (t *TrustStore) Export() (string, error) {
+	content, err := t.store.Export()
+	return string(content), err
+}
+
+// Import merges content from Export.
+func (t *TrustStore) Import(content string) error {
+	err := t.store.Import([]byte(content))
+	if err != nil {
+		return err
+	}
+	applyBlocklist()
+	return nil
+}
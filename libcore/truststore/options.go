@@ -0,0 +1,71 @@
+package truststore
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Prefix refers bundle by name in certificate_path, like "truststore:name".
+const Prefix = "truststore:"
+
+// ResolveOptions replaces bundle references in certificate_path with certificates of the bundles.
+//
+// Top-level certificate options of profile adds the bundles to the root store of the instance,
+// and TLS options of outbound or DNS server trusts only the bundle.
+func (s *Store) ResolveOptions(options *option.Options) error {
+	if certificateOptions := options.Certificate; certificateOptions != nil {
+		var paths []string
+		for _, path := range certificateOptions.CertificatePath {
+			name, isBundle := strings.CutPrefix(path, Prefix)
+			if !isBundle {
+				paths = append(paths, path)
+				continue
+			}
+			bundle, err := s.BundlePEM(name)
+			if err != nil {
+				return E.Cause(err, "certificate")
+			}
+			certificateOptions.Certificate = append(certificateOptions.Certificate, bundle)
+		}
+		certificateOptions.CertificatePath = paths
+	}
+	for _, outbound := range options.Outbounds {
+		err := s.resolveTLSOptions(outbound.Options)
+		if err != nil {
+			return E.Cause(err, "outbound[", outbound.Tag, "]")
+		}
+	}
+	if options.DNS != nil {
+		for _, server := range options.DNS.Servers {
+			err := s.resolveTLSOptions(server.Options)
+			if err != nil {
+				return E.Cause(err, "dns server[", server.Tag, "]")
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) resolveTLSOptions(options any) error {
+	wrapper, isWrapper := options.(option.OutboundTLSOptionsWrapper)
+	if !isWrapper {
+		return nil
+	}
+	tlsOptions := wrapper.TakeOutboundTLSOptions()
+	if tlsOptions == nil {
+		return nil
+	}
+	name, isBundle := strings.CutPrefix(tlsOptions.CertificatePath, Prefix)
+	if !isBundle {
+		return nil
+	}
+	bundle, err := s.BundlePEM(name)
+	if err != nil {
+		return err
+	}
+	tlsOptions.Certificate = []string{bundle}
+	tlsOptions.CertificatePath = ""
+	return nil
+}
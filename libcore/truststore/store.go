@@ -0,0 +1,340 @@
+// Package truststore manages named CA bundles and blocked CAs.
+package truststore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	bundleSuffix  = ".pem"
+	blocklistFile = "blocklist.txt"
+
+	typeCert = "CERTIFICATE"
+)
+
+// Store saves each bundle as "<name>.pem" and blocklist as lines of SPKI hash in its directory.
+type Store struct {
+	access sync.RWMutex
+	dir    string
+}
+
+// New returns store in dir, which is created when saving.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// SPKIHash returns base64 of sha256 of certificate's SubjectPublicKeyInfo,
+// which is the same as sing-box certificate_public_key_sha256.
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return E.New("invalid bundle name: ", name)
+	}
+	return nil
+}
+
+func (s *Store) bundlePath(name string) string {
+	return filepath.Join(s.dir, name+bundleSuffix)
+}
+
+// AddBundle saves certificates in PEM or DER as bundle name, and returns count of them.
+func (s *Store) AddBundle(name string, content []byte) (int, error) {
+	err := checkName(name)
+	if err != nil {
+		return 0, err
+	}
+	certs, err := ParseCertificates(content)
+	if err != nil {
+		return 0, err
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	err = writeFileAtomic(s.bundlePath(name), encodeCertificates(certs))
+	if err != nil {
+		return 0, err
+	}
+	return len(certs), nil
+}
+
+func (s *Store) RemoveBundle(name string) error {
+	err := checkName(name)
+	if err != nil {
+		return err
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	err = os.Remove(s.bundlePath(name))
+	if os.IsNotExist(err) {
+		return E.New("bundle not found: ", name)
+	}
+	return err
+}
+
+// Bundles returns sorted names of bundles.
+func (s *Store) Bundles() ([]string, error) {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, isBundle := strings.CutSuffix(entry.Name(), bundleSuffix); isBundle && entry.Type().IsRegular() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Bundle returns certificates of bundle name, excluding blocked ones.
+func (s *Store) Bundle(name string) ([]*x509.Certificate, error) {
+	err := checkName(name)
+	if err != nil {
+		return nil, err
+	}
+	s.access.RLock()
+	defer s.access.RUnlock()
+	content, err := os.ReadFile(s.bundlePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, E.New("bundle not found: ", name)
+		}
+		return nil, err
+	}
+	certs, err := ParseCertificates(content)
+	if err != nil {
+		return nil, E.Cause(err, "parse bundle ", name)
+	}
+	blocklist, err := s.loadBlocklist()
+	if err != nil {
+		return nil, err
+	}
+	certs = filterBlocked(certs, blocklist)
+	if len(certs) == 0 {
+		return nil, E.New("all certificates of bundle ", name, " are blocked")
+	}
+	return certs, nil
+}
+
+// BundlePEM is like Bundle, but returns PEM.
+func (s *Store) BundlePEM(name string) (string, error) {
+	certs, err := s.Bundle(name)
+	if err != nil {
+		return "", err
+	}
+	return string(encodeCertificates(certs)), nil
+}
+
+// Block distrusts CA with SPKI hash, even if it is in the root store.
+func (s *Store) Block(spkiHash string) error {
+	sum, err := base64.StdEncoding.DecodeString(spkiHash)
+	if err != nil || len(sum) != sha256.Size {
+		return E.New("invalid SPKI hash: ", spkiHash)
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	blocklist, err := s.loadBlocklist()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(blocklist, spkiHash) {
+		return nil
+	}
+	return s.saveBlocklist(append(blocklist, spkiHash))
+}
+
+func (s *Store) Unblock(spkiHash string) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	blocklist, err := s.loadBlocklist()
+	if err != nil {
+		return err
+	}
+	return s.saveBlocklist(slices.DeleteFunc(blocklist, func(it string) bool {
+		return it == spkiHash
+	}))
+}
+
+func (s *Store) Blocklist() ([]string, error) {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.loadBlocklist()
+}
+
+// FilterPEM removes blocked certificates in PEM list.
+func (s *Store) FilterPEM(certs []string) ([]string, error) {
+	blocklist, err := s.Blocklist()
+	if err != nil {
+		return nil, err
+	}
+	if len(blocklist) == 0 {
+		return certs, nil
+	}
+	filtered := make([]string, 0, len(certs))
+	for _, content := range certs {
+		parsed, err := ParseCertificates([]byte(content))
+		if err != nil {
+			continue
+		}
+		parsed = filterBlocked(parsed, blocklist)
+		if len(parsed) > 0 {
+			filtered = append(filtered, string(encodeCertificates(parsed)))
+		}
+	}
+	return filtered, nil
+}
+
+func (s *Store) loadBlocklist() ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, blocklistFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var blocklist []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			blocklist = append(blocklist, line)
+		}
+	}
+	return blocklist, nil
+}
+
+func (s *Store) saveBlocklist(blocklist []string) error {
+	var content []byte
+	if len(blocklist) > 0 {
+		content = []byte(strings.Join(blocklist, "\n") + "\n")
+	}
+	return writeFileAtomic(filepath.Join(s.dir, blocklistFile), content)
+}
+
+type exportedStore struct {
+	Bundles   map[string]string `json:"bundles,omitempty"`
+	Blocklist []string          `json:"blocklist,omitempty"`
+}
+
+// Export returns all bundles and blocklist in JSON.
+func (s *Store) Export() ([]byte, error) {
+	names, err := s.Bundles()
+	if err != nil {
+		return nil, err
+	}
+	s.access.RLock()
+	defer s.access.RUnlock()
+	exported := exportedStore{Bundles: make(map[string]string, len(names))}
+	for _, name := range names {
+		content, err := os.ReadFile(s.bundlePath(name))
+		if err != nil {
+			return nil, err
+		}
+		exported.Bundles[name] = string(content)
+	}
+	exported.Blocklist, err = s.loadBlocklist()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(exported)
+}
+
+// Import merges content of Export into the store.
+func (s *Store) Import(content []byte) error {
+	var imported exportedStore
+	err := json.Unmarshal(content, &imported)
+	if err != nil {
+		return E.Cause(err, "decode trust store")
+	}
+	for name, bundle := range imported.Bundles {
+		_, err = s.AddBundle(name, []byte(bundle))
+		if err != nil {
+			return E.Cause(err, "import bundle ", name)
+		}
+	}
+	for _, spkiHash := range imported.Blocklist {
+		err = s.Block(spkiHash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseCertificates parses certificates in PEM or DER.
+func ParseCertificates(content []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := content
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != typeCert {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		var err error
+		certs, err = x509.ParseCertificates(content)
+		if err != nil || len(certs) == 0 {
+			return nil, E.New("no certificate found")
+		}
+	}
+	return certs, nil
+}
+
+func filterBlocked(certs []*x509.Certificate, blocklist []string) []*x509.Certificate {
+	if len(blocklist) == 0 {
+		return certs
+	}
+	return slices.DeleteFunc(certs, func(cert *x509.Certificate) bool {
+		return slices.Contains(blocklist, SPKIHash(cert))
+	})
+}
+
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var buffer bytes.Buffer
+	for _, cert := range certs {
+		_ = pem.Encode(&buffer, &pem.Block{Type: typeCert, Bytes: cert.Raw})
+	}
+	return buffer.Bytes()
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	err = os.WriteFile(tempPath, content, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
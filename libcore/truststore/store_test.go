@@ -0,0 +1,126 @@
+package truststore
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/option"
+)
+
+func generateCertificate(t *testing.T, serverName string) (string, string) {
+	_, certPEM, err := tls.GenerateCertificate(nil, nil, time.Now, serverName, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(certPEM), SPKIHash(cert)
+}
+
+func Test_Store(t *testing.T) {
+	store := New(t.TempDir())
+	privateCA, privateHash := generateCertificate(t, "private.example")
+	otherCA, _ := generateCertificate(t, "other.example")
+
+	count, err := store.AddBundle("private", []byte(privateCA+otherCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("added %d certificates", count)
+	}
+	_, err = store.AddBundle("../escape", []byte(privateCA))
+	if err == nil {
+		t.Error("expected error for invalid name")
+	}
+
+	err = store.Block(privateHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := store.Bundle("private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 || SPKIHash(certs[0]) == privateHash {
+		t.Error("blocked certificate not removed")
+	}
+	filtered, err := store.FilterPEM([]string{privateCA, otherCA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 {
+		t.Errorf("filtered %d certificates", len(filtered))
+	}
+
+	exported, err := store.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := New(t.TempDir())
+	err = imported.Import(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := imported.Bundles(); len(names) != 1 || names[0] != "private" {
+		t.Errorf("imported bundles: %v", names)
+	}
+	if blocklist, _ := imported.Blocklist(); len(blocklist) != 1 || blocklist[0] != privateHash {
+		t.Errorf("imported blocklist: %v", blocklist)
+	}
+
+	err = store.Unblock(privateHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certs, _ = store.Bundle("private"); len(certs) != 2 {
+		t.Error("certificate not unblocked")
+	}
+}
+
+func Test_ResolveOptions(t *testing.T) {
+	store := New(t.TempDir())
+	privateCA, _ := generateCertificate(t, "private.example")
+	_, err := store.AddBundle("private", []byte(privateCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsOptions := &option.OutboundTLSOptions{Enabled: true, CertificatePath: Prefix + "private"}
+	options := option.Options{
+		Certificate: &option.CertificateOptions{
+			CertificatePath: []string{"/etc/ca.pem", Prefix + "private"},
+		},
+		Outbounds: []option.Outbound{{
+			Type: "http",
+			Tag:  "proxy",
+			Options: &option.HTTPOutboundOptions{
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{TLS: tlsOptions},
+			},
+		}},
+	}
+	err = store.ResolveOptions(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := options.Certificate.CertificatePath; len(paths) != 1 || paths[0] != "/etc/ca.pem" {
+		t.Errorf("certificate paths: %v", paths)
+	}
+	if len(options.Certificate.Certificate) != 1 {
+		t.Error("bundle not added to certificate options")
+	}
+	if tlsOptions.CertificatePath != "" || len(tlsOptions.Certificate) != 1 {
+		t.Error("bundle not resolved in TLS options")
+	}
+
+	tlsOptions.CertificatePath = Prefix + "missing"
+	err = store.ResolveOptions(&options)
+	if err == nil {
+		t.Error("expected error for missing bundle")
+	}
+}
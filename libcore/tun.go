@@ -112,6 +112,9 @@ func (m *interfaceMonitor) UnregisterCallback(element *list.Element[tun.DefaultI
 func (m *interfaceMonitor) UpdateDefaultInterface(interfaceName string, interfaceIndex32 int32) {
 	/*m.isExpensive = isExpensive
 	m.isConstrained = isConstrained*/
+	if m.onDefaultInterfaceUpdate != nil {
+		defer m.onDefaultInterfaceUpdate()
+	}
 	err := m.networkManager.UpdateInterfaces()
 	if err != nil {
 		m.logger.Error(E.Cause(err, "update interfaces"))
@@ -3,10 +3,10 @@ package libcore
 import (
 	"net"
 	"net/url"
-	"strings"
 	_ "unsafe"
 
-	"github.com/sagernet/sing/common"
+	"libcore/sharelink"
+
 	E "github.com/sagernet/sing/common/exceptions"
 )
 
@@ -74,20 +74,13 @@ func setPath(u *url.URL, fragment string) error
 
 func ParseURL(rawURL string) (URL, error) {
 	u := &netURL{}
-	uu, err := url.Parse(rawURL)
+	// For Hysteria port hop non-standard format
+	uu, ports, err := sharelink.ParseURLWithPorts(rawURL)
 	if err != nil {
-		// For Hysteria port hop non-standard format
-		errStr := err.Error()
-		if !strings.Contains(errStr, "invalid port") {
-			return nil, E.Cause(err, "pause rawURL")
-		}
-		multiplePort := common.SubstringBetween(errStr, "invalid port \"", "\" after host")
-		noPort := strings.Replace(rawURL, multiplePort, "", 1)
-		uu, err = url.Parse(noPort)
-		if err != nil {
-			return nil, E.Cause(err, "parse rawURL with invalid port")
-		}
-		uu.Host = net.JoinHostPort(uu.Host, strings.TrimPrefix(multiplePort, ":"))
+		return nil, E.Cause(err, "parse rawURL")
+	}
+	if ports != "" && uu.Port() == "" {
+		uu.Host = net.JoinHostPort(uu.Host, ports)
 	}
 	u.URL = *uu
 	u.Values = u.Query()